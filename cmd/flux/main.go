@@ -30,6 +30,8 @@ var flags struct {
 	Format            string
 	Features          string
 	EnableSuggestions bool
+	LineMode          bool
+	FluxErrors        bool
 }
 
 func runE(cmd *cobra.Command, args []string) error {
@@ -71,9 +73,15 @@ func runE(cmd *cobra.Command, args []string) error {
 	if flags.EnableSuggestions {
 		// opts = append(opts, repl.EnableSuggestions())
 	}
+	if flags.FluxErrors {
+		opts = append(opts, repl.WithFluxErrors())
+	}
+	if dir := os.Getenv(repl.InitDirEnvVar); dir != "" {
+		opts = append(opts, repl.WithInitDir(dir))
+	}
 
 	if len(args) == 0 {
-		return replE(ctx, opts...)
+		return replE(ctx, flags.LineMode, opts...)
 	}
 	return executeE(ctx, script, flags.Format)
 }
@@ -127,6 +135,8 @@ func main() {
 	}
 	fluxCmd.Flags().BoolVarP(&flags.ExecScript, "exec", "e", false, "Interpret file argument as a raw flux script")
 	fluxCmd.Flags().BoolVarP(&flags.EnableSuggestions, "enable-suggestions", "", false, "enable suggestions in the repl")
+	fluxCmd.Flags().BoolVarP(&flags.LineMode, "line-mode", "", false, "read one line of Flux at a time from stdin instead of serving JSON-RPC")
+	fluxCmd.Flags().BoolVarP(&flags.FluxErrors, "flux-errors", "", false, "report the full analyzer error of an input in JSON-RPC responses")
 	fluxCmd.Flags().StringVar(&flags.Trace, "trace", "", "Trace query execution")
 	fluxCmd.Flags().StringVarP(&flags.Format, "format", "", "cli", "Output format one of: cli,csv. Defaults to cli")
 	fluxCmd.Flag("trace").NoOptDefVal = "jaeger"
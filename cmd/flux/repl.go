@@ -2,12 +2,19 @@ package main
 
 import (
 	"context"
+	"os"
 
 	"github.com/influxdata/flux/repl"
 )
 
-func replE(ctx context.Context, opts ...repl.Option) error {
-	r := repl.New(ctx, opts...)
+func replE(ctx context.Context, lineMode bool, opts ...repl.Option) error {
+	r, err := repl.NewWithError(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	if lineMode {
+		return r.RunLineMode(os.Stdin, os.Stdout, os.Stderr)
+	}
 	r.Run()
 	return nil
 }
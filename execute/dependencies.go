@@ -2,7 +2,9 @@ package execute
 
 import (
 	"context"
+	"hash/fnv"
 	"math"
+	"math/rand"
 	"time"
 
 	"github.com/influxdata/flux/codes"
@@ -24,6 +26,11 @@ type ExecutionOptions struct {
 	Profilers          []Profiler
 	DefaultMemoryLimit int64
 	ConcurrencyLimit   int
+	// SourceTimeout limits how long each source of a query may run,
+	// so that a slow source fails on its own rather than using up the
+	// time of the whole query. The error tells which source timed out.
+	// A zero SourceTimeout does not limit the sources.
+	SourceTimeout time.Duration
 }
 
 // ExecutionDependencies represents the dependencies that a function call
@@ -35,6 +42,9 @@ type ExecutionDependencies struct {
 
 	// Allowed to be nil
 	Logger *zap.Logger
+	// Seed makes the random choices of the transformations repeatable
+	// when it is set. See NewRand.
+	Seed *int64
 
 	// Metadata is passed up from any invocations of execution up to the parent
 	// execution, and out through the statistics.
@@ -48,6 +58,20 @@ func (d ExecutionDependencies) Inject(ctx context.Context) context.Context {
 	return interpreter.Packages{}.Inject(ctx)
 }
 
+// NewRand returns a source of random numbers for the transformation
+// with the given ID. When the dependencies have a seed, the numbers only
+// depend on it and on the ID, so that a transformation makes the same
+// choices each time its query runs. Otherwise, the source is seeded
+// from the global one.
+func (d ExecutionDependencies) NewRand(id DatasetID) *rand.Rand {
+	if d.Seed == nil {
+		return rand.New(rand.NewSource(rand.Int63()))
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(id[:])
+	return rand.New(rand.NewSource(*d.Seed ^ int64(h.Sum64())))
+}
+
 // ResolveTimeable returns the time represented by a value.
 // The value's type must be Timeable, one of time or duration.
 func (d ExecutionDependencies) ResolveTimeable(t values.Value) (values.Time, error) {
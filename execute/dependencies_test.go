@@ -0,0 +1,38 @@
+package execute_test
+
+import (
+	"testing"
+
+	"github.com/influxdata/flux/execute"
+)
+
+func TestExecutionDependencies_NewRand(t *testing.T) {
+	seed := int64(42)
+	deps := execute.DefaultExecutionDependencies()
+	deps.Seed = &seed
+	a, b := execute.DatasetID{1}, execute.DatasetID{2}
+
+	draw := func(id execute.DatasetID) []int {
+		r := deps.NewRand(id)
+		vs := make([]int, 8)
+		for i := range vs {
+			vs[i] = r.Intn(1000)
+		}
+		return vs
+	}
+	first, again := draw(a), draw(a)
+	for i := range first {
+		if first[i] != again[i] {
+			t.Fatalf("expected the same numbers from the same seed and id, got %v and %v", first, again)
+		}
+	}
+
+	other := draw(b)
+	same := true
+	for i := range first {
+		same = same && first[i] == other[i]
+	}
+	if same {
+		t.Fatalf("expected different numbers for another transformation, got %v for both", first)
+	}
+}
@@ -233,6 +233,9 @@ func (v *createExecutionNodeVisitor) Visit(node plan.Node) error {
 			}
 
 			source.SetLabel(string(node.ID()))
+			if timeout := getSourceTimeout(v.es.ctx); timeout > 0 {
+				source = newTimedSource(source, timeout)
+			}
 			v.es.sources = append(v.es.sources, source)
 			v.nodes[node][i] = source
 		}
@@ -441,6 +444,9 @@ func (es *executionState) do() {
 		go func(src Source) {
 			ctx := es.ctx
 			opName := reflect.TypeOf(src).String()
+			if ts, ok := src.(*timedSource); ok {
+				opName = reflect.TypeOf(ts.Source).String()
+			}
 
 			// If operator profiling is enabled for this execution, begin profiling
 			profile := flux.TransportProfile{
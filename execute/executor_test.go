@@ -3,6 +3,7 @@ package execute_test
 import (
 	"context"
 	"math"
+	"strings"
 	"testing"
 	"time"
 
@@ -28,6 +29,7 @@ func init() {
 	execute.RegisterSource(executetest.AllocatingFromTestKind, executetest.CreateAllocatingFromSource)
 	execute.RegisterTransformation(executetest.ToTestKind, executetest.CreateToTransformation)
 	plan.RegisterProcedureSpecWithSideEffect(executetest.ToTestKind, executetest.NewToProcedure, executetest.ToTestKind)
+	execute.RegisterSource(slowFromTestKind, createSlowFromSource)
 }
 
 func TestExecutor_Execute(t *testing.T) {
@@ -802,3 +804,115 @@ func TestExecutor_Execute(t *testing.T) {
 		})
 	}
 }
+
+const slowFromTestKind = "slow-from-test"
+
+// slowFromProcedureSpec is a source that produces nothing
+// until it is canceled, and then fails with the reason.
+type slowFromProcedureSpec struct {
+	execute.ExecutionNode
+	ts []execute.Transformation
+}
+
+func (src *slowFromProcedureSpec) Kind() plan.ProcedureKind {
+	return slowFromTestKind
+}
+
+func (src *slowFromProcedureSpec) Copy() plan.ProcedureSpec {
+	return src
+}
+
+func (src *slowFromProcedureSpec) Cost(inStats []plan.Statistics) (plan.Cost, plan.Statistics) {
+	return plan.Cost{}, plan.Statistics{}
+}
+
+func (src *slowFromProcedureSpec) AddTransformation(t execute.Transformation) {
+	src.ts = append(src.ts, t)
+}
+
+func (src *slowFromProcedureSpec) Run(ctx context.Context) {
+	<-ctx.Done()
+	for _, t := range src.ts {
+		t.Finish(executetest.RandomDatasetID(), ctx.Err())
+	}
+}
+
+func createSlowFromSource(spec plan.ProcedureSpec, id execute.DatasetID, a execute.Administration) (execute.Source, error) {
+	return spec.(*slowFromProcedureSpec), nil
+}
+
+func TestExecutor_SourceTimeout(t *testing.T) {
+	newTable := func() *executetest.Table {
+		return &executetest.Table{
+			ColMeta: []flux.ColMeta{
+				{Label: "_time", Type: flux.TTime},
+				{Label: "_value", Type: flux.TFloat},
+			},
+			Data: [][]interface{}{
+				{execute.Time(0), 1.0},
+			},
+		}
+	}
+	spec := &plantest.PlanSpec{
+		Nodes: []plan.Node{
+			plan.CreatePhysicalNode("from-test", executetest.NewFromProcedureSpec([]*executetest.Table{newTable()})),
+			plan.CreatePhysicalNode("slow-from", &slowFromProcedureSpec{}),
+			plan.CreatePhysicalNode("yield", executetest.NewYieldProcedureSpec("fast")),
+			plan.CreatePhysicalNode("yield", executetest.NewYieldProcedureSpec("slow")),
+		},
+		Edges: [][2]int{
+			{0, 2},
+			{1, 3},
+		},
+		Resources: flux.ResourceManagement{
+			ConcurrencyQuota: 2,
+			MemoryBytesQuota: math.MaxInt64,
+		},
+		Now: time.Now(),
+	}
+
+	deps := execute.DefaultExecutionDependencies()
+	deps.ExecutionOptions.SourceTimeout = 50 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx, d := dependency.Inject(ctx, executetest.NewTestExecuteDependencies(), deps)
+	defer d.Finish()
+
+	exe := execute.NewExecutor(zaptest.NewLogger(t))
+	results, _, err := exe.Execute(ctx, plantest.CreatePlanSpec(spec), executetest.UnlimitedAllocator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []*executetest.Table
+	if err := results["fast"].Tables().Do(func(tbl flux.Table) error {
+		cb, err := executetest.ConvertTable(tbl)
+		if err != nil {
+			return err
+		}
+		got = append(got, cb)
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error from the fast source: %v", err)
+	}
+	executetest.NormalizeTables(got)
+	want := []*executetest.Table{newTable()}
+	executetest.NormalizeTables(want)
+	if !cmp.Equal(want, got) {
+		t.Errorf("unexpected results -want/+got:\n%s", cmp.Diff(want, got))
+	}
+
+	err = results["slow"].Tables().Do(func(flux.Table) error { return nil })
+	if err == nil {
+		t.Fatal("expected the slow source to time out")
+	}
+	if got, want := errors.Code(err), codes.DeadlineExceeded; got != want {
+		t.Errorf("unexpected error code %v, want %v: %v", got, want, err)
+	}
+	if want := "source slow-from timed out after 50ms"; !strings.Contains(err.Error(), want) {
+		t.Errorf("expected the error to contain %q, got %q", want, err)
+	}
+	if ctx.Err() != nil {
+		t.Errorf("expected the query to outlive the source, got %v", ctx.Err())
+	}
+}
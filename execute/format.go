@@ -46,6 +46,15 @@ type FormatOptions struct {
 	RepeatHeaderCount int
 
 	NullRepresentation string
+
+	// FloatPrecision is the number of digits after the decimal point
+	// used when formatting float values. If this is nil, the smallest
+	// number of digits necessary to represent the value exactly is used.
+	FloatPrecision *int
+
+	// PreserveColumnOrder writes the columns in the order of the table.
+	// By default, the columns of the group key are written first.
+	PreserveColumnOrder bool
 }
 
 func DefaultFormatOptions() *FormatOptions {
@@ -98,7 +107,9 @@ func (f *Formatter) WriteTo(out io.Writer) (int64, error) {
 	// Sort cols
 	cols := f.tbl.Cols()
 	f.cols = newOrderedCols(cols, f.tbl.Key())
-	sort.Sort(f.cols)
+	if !f.opts.PreserveColumnOrder {
+		sort.Sort(f.cols)
+	}
 
 	// Compute header widths
 	f.widths = make([]int, len(cols))
@@ -246,8 +257,11 @@ func (f *Formatter) valueBuf(i, j int, typ flux.ColType, cr flux.ColReader) []by
 		}
 	case flux.TFloat:
 		if cr.Floats(j).IsValid(i) {
-			// TODO allow specifying format and precision
-			buf = strconv.AppendFloat(f.fmtBuf[0:0], cr.Floats(j).Value(i), 'f', -1, 64)
+			prec := -1
+			if f.opts.FloatPrecision != nil {
+				prec = *f.opts.FloatPrecision
+			}
+			buf = strconv.AppendFloat(f.fmtBuf[0:0], cr.Floats(j).Value(i), 'f', prec, 64)
 		}
 	case flux.TString:
 		if cr.Strings(j).IsValid(i) {
@@ -0,0 +1,91 @@
+package execute_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+)
+
+func TestFormatter_FloatPrecision(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		prec *int
+		want string
+	}{
+		{
+			name: "default",
+			want: "0.3333333333333333",
+		},
+		{
+			name: "two digits",
+			prec: func(v int) *int { return &v }(2),
+			want: "0.33",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tbl := &executetest.Table{
+				ColMeta: []flux.ColMeta{
+					{Label: "_value", Type: flux.TFloat},
+				},
+				Data: [][]interface{}{
+					{1.0 / 3.0},
+				},
+			}
+
+			var buf bytes.Buffer
+			opts := &execute.FormatOptions{FloatPrecision: tc.prec}
+			if _, err := execute.NewFormatter(tbl, opts).WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if got := strings.TrimSpace(lines[len(lines)-1]); got != tc.want {
+				t.Fatalf("unexpected formatted value -want/+got:\n\t- %s\n\t+ %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatter_PreserveColumnOrder(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		preserve bool
+		want     string
+	}{
+		{
+			name: "default",
+			want: "host:string  _value:int",
+		},
+		{
+			name:     "preserved",
+			preserve: true,
+			want:     "_value:int  host:string",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tbl := &executetest.Table{
+				KeyCols: []string{"host"},
+				ColMeta: []flux.ColMeta{
+					{Label: "_value", Type: flux.TInt},
+					{Label: "host", Type: flux.TString},
+				},
+				Data: [][]interface{}{
+					{int64(1), "a"},
+				},
+			}
+
+			var buf bytes.Buffer
+			opts := &execute.FormatOptions{PreserveColumnOrder: tc.preserve}
+			if _, err := execute.NewFormatter(tbl, opts).WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			header := strings.Join(strings.Fields(strings.Split(buf.String(), "\n")[1]), "  ")
+			if header != tc.want {
+				t.Fatalf("unexpected header -want/+got:\n\t- %s\n\t+ %s", tc.want, header)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package execute
+
+import (
+	"context"
+	"time"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/metadata"
+)
+
+// getSourceTimeout returns the SourceTimeout of the
+// execution options, if there are any.
+func getSourceTimeout(ctx context.Context) time.Duration {
+	if !HaveExecutionDependencies(ctx) {
+		return 0
+	}
+	if opts := GetExecutionDependencies(ctx).ExecutionOptions; opts != nil {
+		return opts.SourceTimeout
+	}
+	return 0
+}
+
+// timedSource is a source that must finish within its timeout.
+// The sources of the other branches of the query keep running
+// when it times out.
+type timedSource struct {
+	Source
+	timeout time.Duration
+	// parent and ctx are the contexts of the query and of the source.
+	// They are set before the source runs.
+	parent, ctx context.Context
+}
+
+func newTimedSource(src Source, timeout time.Duration) *timedSource {
+	return &timedSource{Source: src, timeout: timeout}
+}
+
+func (s *timedSource) AddTransformation(t Transformation) {
+	tt := &timedTransformation{Transformation: t, src: s}
+	if tr, ok := t.(Transport); ok {
+		s.Source.AddTransformation(&timedTransport{timedTransformation: tt, tr: tr})
+		return
+	}
+	s.Source.AddTransformation(tt)
+}
+
+func (s *timedSource) Run(ctx context.Context) {
+	s.parent = ctx
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	s.ctx = ctx
+	s.Source.Run(ctx)
+}
+
+// Metadata returns the metadata of the source, if it has any.
+func (s *timedSource) Metadata() metadata.Metadata {
+	if mdn, ok := s.Source.(MetadataNode); ok {
+		return mdn.Metadata()
+	}
+	return nil
+}
+
+// timedTransformation is a successor of a timedSource. It annotates
+// the error that the source finishes with once the source timed out,
+// so that the error tells which operation was too slow.
+type timedTransformation struct {
+	Transformation
+	src *timedSource
+}
+
+func (t *timedTransformation) Finish(id DatasetID, err error) {
+	t.Transformation.Finish(id, t.src.annotate(err))
+}
+
+// timedTransport is a timedTransformation whose successor is a
+// Transport. The source sends it messages rather than calling its
+// methods, so it annotates the error of the finish message instead.
+type timedTransport struct {
+	*timedTransformation
+	tr Transport
+}
+
+func (t *timedTransport) ProcessMessage(m Message) error {
+	if fm, ok := m.(FinishMsg); ok && fm.Error() != nil && t.src.timedOut() {
+		m = &finishMsg{srcMessage: srcMessage(fm.SrcDatasetID()), err: t.src.annotate(fm.Error())}
+	}
+	return t.tr.ProcessMessage(m)
+}
+
+// annotate wraps err with the name of the source once the source
+// timed out. Any other err is returned as is.
+func (s *timedSource) annotate(err error) error {
+	if err != nil && s.timedOut() {
+		return errors.Wrapf(err, codes.DeadlineExceeded, "source %s timed out after %v", s.Label(), s.timeout)
+	}
+	return err
+}
+
+// timedOut reports whether the source ran past its own timeout,
+// rather than being canceled along with the query.
+func (s *timedSource) timedOut() bool {
+	return s.ctx != nil && s.ctx.Err() == context.DeadlineExceeded && s.parent.Err() == nil
+}
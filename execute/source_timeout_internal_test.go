@@ -0,0 +1,71 @@
+package execute
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// blockingSource is a source that runs until its context is done
+// and then finishes its successors with the error of the context.
+type blockingSource struct {
+	ts []Transformation
+}
+
+func (s *blockingSource) AddTransformation(t Transformation) { s.ts = append(s.ts, t) }
+func (s *blockingSource) SetLabel(string)                    {}
+func (s *blockingSource) Label() string                      { return "slow" }
+func (s *blockingSource) Run(ctx context.Context) {
+	<-ctx.Done()
+	for _, t := range s.ts {
+		if tr, ok := t.(Transport); ok {
+			_ = tr.ProcessMessage(&finishMsg{err: ctx.Err()})
+			continue
+		}
+		t.Finish(DatasetID{}, ctx.Err())
+	}
+}
+
+// recordingTransport is a Transformation that is also a Transport.
+// It records the error of the finish message it is sent.
+type recordingTransport struct {
+	err error
+}
+
+func (t *recordingTransport) RetractTable(DatasetID, flux.GroupKey) error { return nil }
+func (t *recordingTransport) Process(DatasetID, flux.Table) error         { return nil }
+func (t *recordingTransport) UpdateWatermark(DatasetID, Time) error       { return nil }
+func (t *recordingTransport) UpdateProcessingTime(DatasetID, Time) error  { return nil }
+func (t *recordingTransport) Finish(DatasetID, error)                     {}
+func (t *recordingTransport) ProcessMessage(m Message) error {
+	if fm, ok := m.(FinishMsg); ok {
+		t.err = fm.Error()
+	}
+	return nil
+}
+
+func TestTimedSource_Transport(t *testing.T) {
+	src := newTimedSource(&blockingSource{}, 10*time.Millisecond)
+	succ := &recordingTransport{}
+	src.AddTransformation(succ)
+
+	if _, ok := src.Source.(*blockingSource).ts[0].(Transport); !ok {
+		t.Fatal("expected the successor to stay a Transport")
+	}
+	src.Run(context.Background())
+
+	if succ.err == nil {
+		t.Fatal("expected the successor to finish with an error")
+	}
+	if got, want := errors.Code(succ.err), codes.DeadlineExceeded; got != want {
+		t.Errorf("unexpected error code %v, want %v", got, want)
+	}
+	if want := "source slow timed out after 10ms"; !strings.Contains(succ.err.Error(), want) {
+		t.Errorf("expected the error to contain %q, got %q", want, succ.err)
+	}
+}
@@ -185,6 +185,18 @@ func UnusedSymbolWarnings() BoolFlag {
 	return unusedSymbolWarnings
 }
 
+var holtWintersConcurrency = feature.MakeIntFlag(
+	"Holt Winters Concurrency",
+	"holtWintersConcurrency",
+	"Westonside",
+	0,
+)
+
+// HoltWintersConcurrency - Sets the number of groups that holtWinters forecasts concurrently
+func HoltWintersConcurrency() IntFlag {
+	return holtWintersConcurrency
+}
+
 // Inject will inject the Flagger into the context.
 func Inject(ctx context.Context, flagger Flagger) context.Context {
 	return feature.Inject(ctx, flagger)
@@ -205,6 +217,7 @@ var all = []Flag{
 	labelPolymorphism,
 	optimizeSetTransformation,
 	unusedSymbolWarnings,
+	holtWintersConcurrency,
 }
 
 var byKey = map[string]Flag{
@@ -222,6 +235,7 @@ var byKey = map[string]Flag{
 	"labelPolymorphism":                labelPolymorphism,
 	"optimizeSetTransformation":        optimizeSetTransformation,
 	"unusedSymbolWarnings":             unusedSymbolWarnings,
+	"holtWintersConcurrency":           holtWintersConcurrency,
 }
 
 // Flags returns all feature flags.
@@ -706,6 +706,13 @@ func (itrp *Interpreter) doCall(ctx context.Context, call *semantic.CallExpressi
 
 	f := callee.Function()
 
+	// Stop before calling the function if evaluation has been canceled.
+	// Every function call passes through here, so this bounds how long
+	// a canceled evaluation can continue to run.
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, codes.Canceled, "evaluation canceled")
+	}
+
 	// Check if the function is an interpFunction and rebind it.
 	// This is needed so that any side effects produced when
 	// calling this function are bound to the correct interpreter.
@@ -720,12 +727,17 @@ func (itrp *Interpreter) doCall(ctx context.Context, call *semantic.CallExpressi
 	// arguments as this source location information is only
 	// for the currently called function.
 	fname := functionName(call)
+	if max, ok := maxCallDepth(ctx); ok && callDepth(ctx) >= max {
+		return nil, errors.Wrapf(errCallDepth, codes.ResourceExhausted, "function %q @%s nests calls deeper than the limit of %d", fname, call.Location(), max)
+	}
 	ctx = withStackEntry(ctx, fname, call.Location())
 	value, err := f.Call(ctx, argObj)
 	if err != nil {
 		// If a function has an underscore as a prefix, consider it
 		// as an internal call and don't add it to the error message.
-		if !strings.HasPrefix(fname, "_") {
+		// The calls that led to the call depth limit are not added
+		// either, as there may be as many of them as the limit.
+		if !strings.HasPrefix(fname, "_") && !errors.Is(err, errCallDepth) {
 			err = errors.Wrapf(err, codes.Inherit, "error calling function %q @%s", fname, call.Location())
 		}
 		return nil, err
@@ -1679,6 +1691,7 @@ type contextKey int
 
 const (
 	callStackKey contextKey = iota
+	maxCallDepthKey
 )
 
 // StackEntry describes a single entry in the call stack.
@@ -1713,6 +1726,34 @@ func Stack(ctx context.Context) []StackEntry {
 	return stack
 }
 
+// callDepth returns the number of nested function calls
+// that are being evaluated in ctx.
+func callDepth(ctx context.Context) int {
+	e, ok := ctx.Value(callStackKey).(*stackElement)
+	if !ok {
+		return 0
+	}
+	return e.depth + 1
+}
+
+// errCallDepth is the cause of the error of a call that nests deeper
+// than the limit set WithMaxCallDepth.
+var errCallDepth = errors.New(codes.ResourceExhausted, "maximum call depth exceeded")
+
+// WithMaxCallDepth returns a context in which the evaluation of
+// function calls nested deeper than n fails with a resource exhausted
+// error. This stops a recursive function that never returns before it
+// overflows the stack, which would crash the process. A limit of zero
+// or less means no limit.
+func WithMaxCallDepth(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, maxCallDepthKey, n)
+}
+
+func maxCallDepth(ctx context.Context) (int, bool) {
+	n, ok := ctx.Value(maxCallDepthKey).(int)
+	return n, ok && n > 0
+}
+
 // withStackEntry will attach StackEntry information
 // to the context to be retrieved by Stack.
 func withStackEntry(ctx context.Context, name string, loc ast.SourceLocation) context.Context {
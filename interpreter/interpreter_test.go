@@ -858,6 +858,45 @@ func getSideEffectsValues(ses []interpreter.SideEffect) []values.Value {
 	return vs
 }
 
+func TestEval_Canceled(t *testing.T) {
+	src := `
+		f = (x) => x + 1
+		f(x: 1)`
+	ctx, deps := dependency.Inject(context.Background(), dependenciestest.Default())
+	defer deps.Finish()
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, _, err := runtime.Eval(ctx, src)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if got, want := flux.ErrorCode(err), codes.Canceled; got != want {
+		t.Fatalf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+}
+
+func TestEval_MaxCallDepth(t *testing.T) {
+	src := `
+		f = (x) => x + 1
+		g = (x) => f(x: x)
+		g(x: 1)`
+	ctx, deps := dependency.Inject(context.Background(), dependenciestest.Default())
+	defer deps.Finish()
+
+	if _, _, err := runtime.Eval(interpreter.WithMaxCallDepth(ctx, 2), src); err != nil {
+		t.Fatalf("unexpected error within the limit: %s", err)
+	}
+	_, _, err := runtime.Eval(interpreter.WithMaxCallDepth(ctx, 1), src)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if got, want := flux.ErrorCode(err), codes.ResourceExhausted; got != want {
+		t.Fatalf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+}
+
 func TestStack(t *testing.T) {
 	src := `from(bucket: "telegraf") |> range(start: -5m) |> aggregateWindow(every: 1m, fn: mean)`
 	ctx, deps := dependency.Inject(context.Background(), dependenciestest.Default())
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 	"time"
 
 	"github.com/influxdata/flux"
@@ -333,13 +334,21 @@ func (p *Program) Start(ctx context.Context, alloc memory.Allocator) (flux.Query
 	return q, nil
 }
 
+// processResults sends the results of the query downstream in the
+// order of their names, so that a query produces its results in the
+// same order every time it runs.
 func (p *Program) processResults(ctx context.Context, q *query, resultMap map[string]flux.Result) {
 	defer q.wg.Done()
 	defer close(q.results)
 
-	for _, res := range resultMap {
+	names := make([]string, 0, len(resultMap))
+	for name := range resultMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
 		select {
-		case q.results <- res:
+		case q.results <- resultMap[name]:
 		case <-ctx.Done():
 			q.err = ctx.Err()
 			return
@@ -20,13 +20,22 @@ type query struct {
 	cancel  func()
 	err     error
 	wg      sync.WaitGroup
+
+	doneOnce sync.Once
 }
 
 func (q *query) Results() <-chan flux.Result {
 	return q.results
 }
 
+// Done cancels the query and waits for it to finish.
+// It is safe to call Done more than once and from multiple goroutines;
+// the teardown only runs on the first call and later calls wait for it.
 func (q *query) Done() {
+	q.doneOnce.Do(q.done)
+}
+
+func (q *query) done() {
 	q.cancel()
 	q.wg.Wait()
 	q.stats.MaxAllocated = q.alloc.MaxAllocated()
@@ -0,0 +1,95 @@
+package lang
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/memory"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func newTestQuery(tracer *mocktracer.MockTracer) *query {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &query{
+		ctx:     ctx,
+		results: make(chan flux.Result),
+		alloc:   &memory.ResourceAllocator{},
+		span:    tracer.StartSpan("query"),
+		cancel:  cancel,
+	}
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		defer close(q.results)
+		<-ctx.Done()
+	}()
+	return q
+}
+
+func TestQuery_DoneTwice(t *testing.T) {
+	tracer := mocktracer.New()
+	q := newTestQuery(tracer)
+
+	q.Done()
+	q.Done()
+	if got := len(tracer.FinishedSpans()); got != 1 {
+		t.Fatalf("expected the span to be finished once, got %d", got)
+	}
+	if err := q.Err(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestQuery_DoneConcurrent(t *testing.T) {
+	tracer := mocktracer.New()
+	q := newTestQuery(tracer)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.Cancel()
+			q.Done()
+		}()
+	}
+	wg.Wait()
+	if got := len(tracer.FinishedSpans()); got != 1 {
+		t.Fatalf("expected the span to be finished once, got %d", got)
+	}
+}
+
+// namedResult is a result with no tables.
+type namedResult string
+
+func (r namedResult) Name() string               { return string(r) }
+func (r namedResult) Tables() flux.TableIterator { return nil }
+
+func TestProgram_ProcessResults_Order(t *testing.T) {
+	resultMap := make(map[string]flux.Result)
+	for _, name := range []string{"c", "_result", "a", "b"} {
+		resultMap[name] = namedResult(name)
+	}
+	want := []string{"_result", "a", "b", "c"}
+
+	// Map iteration is random, so repeat to catch an unstable order.
+	for i := 0; i < 20; i++ {
+		q := &query{results: make(chan flux.Result)}
+		q.wg.Add(1)
+		go (&Program{}).processResults(context.Background(), q, resultMap)
+		var got []string
+		for res := range q.results {
+			got = append(got, res.Name())
+		}
+		if len(got) != len(want) {
+			t.Fatalf("expected %d results, got %v", len(want), got)
+		}
+		for j := range want {
+			if got[j] != want[j] {
+				t.Fatalf("unexpected result order: want %v, got %v", want, got)
+			}
+		}
+	}
+}
@@ -74,6 +74,10 @@ type ResourceAllocator struct {
 	// Allocator is the underlying memory allocator used to
 	// allocate and free memory.
 	// If this is unset, the DefaultAllocator is used.
+	//
+	// If the underlying allocator is itself an Allocator, such as
+	// the ResourceAllocator of a whole session, the memory accounted
+	// for with Account is also accounted for by it.
 	Allocator memory.Allocator
 }
 
@@ -116,8 +120,9 @@ func (a *ResourceAllocator) Reallocate(size int, b []byte) []byte {
 		return DefaultAllocator.Reallocate(size, b)
 	}
 
+	// The underlying allocator accounts for the reallocation itself.
 	sizediff := size - cap(b)
-	if err := a.Account(sizediff); err != nil {
+	if err := a.count(sizediff); err != nil {
 		panic(err)
 	}
 
@@ -133,7 +138,17 @@ func (a *ResourceAllocator) Account(size int) error {
 	if size == 0 {
 		return nil
 	}
-	return a.count(size)
+	if err := a.count(size); err != nil {
+		return err
+	}
+	if parent, ok := a.Allocator.(Allocator); ok {
+		if err := parent.Account(size); err != nil {
+			// Releasing memory never fails.
+			_ = a.count(-size)
+			return err
+		}
+	}
+	return nil
 }
 
 // Allocated returns the amount of currently allocated memory.
@@ -342,3 +342,50 @@ func TestAllocator_RequestMemory_Concurrently(t *testing.T) {
 		t.Fatalf("unexpected memory left in the manager -want/+got\n\t- %d\n\t+ %d", want, got)
 	}
 }
+
+func TestAllocator_Parent(t *testing.T) {
+	mem := arrowmemory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer mem.AssertSize(t, 0)
+
+	parent := &memory.ResourceAllocator{
+		Limit:     func(v int64) *int64 { return &v }(256),
+		Allocator: mem,
+	}
+	allocator := memory.NewResourceAllocator(parent)
+
+	// Memory that is allocated, reallocated or accounted for by
+	// the allocator is counted once by the parent.
+	b := allocator.Allocate(64)
+	b = allocator.Reallocate(128, b)
+	if err := allocator.Account(64); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want, got := int64(192), allocator.Allocated(); want != got {
+		t.Fatalf("unexpected allocated count -want/+got\n\t- %d\n\t+ %d", want, got)
+	}
+	if want, got := int64(192), parent.Allocated(); want != got {
+		t.Fatalf("unexpected parent allocated count -want/+got\n\t- %d\n\t+ %d", want, got)
+	}
+
+	// The limit of the parent applies to the allocator,
+	// and nothing is accounted for when it is exceeded.
+	if err := allocator.Account(128); err == nil {
+		t.Fatal("expected error")
+	} else if want, got := codes.ResourceExhausted, errors.Code(err); want != got {
+		t.Fatalf("unexpected error code -want/+got\n\t- %d\n\t+ %d", want, got)
+	}
+	if want, got := int64(192), allocator.Allocated(); want != got {
+		t.Fatalf("unexpected allocated count -want/+got\n\t- %d\n\t+ %d", want, got)
+	}
+
+	allocator.Free(b)
+	if err := allocator.Account(-64); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want, got := int64(0), parent.Allocated(); want != got {
+		t.Fatalf("unexpected parent allocated count -want/+got\n\t- %d\n\t+ %d", want, got)
+	}
+	if want, got := int64(192), parent.TotalAllocated(); want != got {
+		t.Fatalf("unexpected parent total allocated count -want/+got\n\t- %d\n\t+ %d", want, got)
+	}
+}
@@ -0,0 +1,100 @@
+package plan
+
+import (
+	"sort"
+	"strings"
+)
+
+// Graph is a structured representation of a plan that is suitable for
+// encoding as JSON, e.g., so that a user interface can render it.
+// It carries the same information as the output of Formatted with WithDetails.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode describes a single node of a plan.
+type GraphNode struct {
+	ID   NodeID        `json:"id"`
+	Kind ProcedureKind `json:"kind"`
+	// Physical reports whether this is a physical plan node.
+	Physical bool `json:"physical"`
+	// Details holds the lines of detail provided by the procedure spec
+	// and the output attributes of the node that implement Detailer.
+	Details []string `json:"details,omitempty"`
+	// RequiredAttrs and OutputAttrs are only set for physical plan nodes.
+	RequiredAttrs []GraphAttr `json:"required_attrs,omitempty"`
+	OutputAttrs   []GraphAttr `json:"output_attrs,omitempty"`
+}
+
+// GraphAttr describes a physical attribute of a plan node.
+type GraphAttr struct {
+	Key   string       `json:"key"`
+	Value PhysicalAttr `json:"value"`
+}
+
+// GraphEdge is a directed edge from a node to one of its successors.
+type GraphEdge struct {
+	From NodeID `json:"from"`
+	To   NodeID `json:"to"`
+}
+
+// NewGraph returns the structured representation of p.
+// Nodes are listed in the same bottom-up order used by Formatted,
+// so every node appears after its predecessors.
+func NewGraph(p *Spec) *Graph {
+	g := &Graph{
+		Nodes: []GraphNode{},
+		Edges: []GraphEdge{},
+	}
+	_ = p.BottomUpWalk(func(pn Node) error {
+		n := GraphNode{
+			ID:   pn.ID(),
+			Kind: pn.Kind(),
+		}
+		if d, ok := pn.ProcedureSpec().(Detailer); ok {
+			n.Details = append(n.Details, detailLines(d)...)
+		}
+		if ppn, ok := pn.(*PhysicalPlanNode); ok {
+			n.Physical = true
+			n.RequiredAttrs = graphAttrs(ppn.RequiredAttrs)
+			n.OutputAttrs = graphAttrs(ppn.OutputAttrs)
+			for _, attr := range n.OutputAttrs {
+				if d, ok := attr.Value.(Detailer); ok {
+					n.Details = append(n.Details, detailLines(d)...)
+				}
+			}
+		}
+		g.Nodes = append(g.Nodes, n)
+		for _, pred := range pn.Predecessors() {
+			g.Edges = append(g.Edges, GraphEdge{From: pred.ID(), To: pn.ID()})
+		}
+		return nil
+	})
+	return g
+}
+
+// graphAttrs returns the attributes sorted by key so the output is stable.
+func graphAttrs(attrs PhysicalAttributes) []GraphAttr {
+	if len(attrs) == 0 {
+		return nil
+	}
+	ga := make([]GraphAttr, 0, len(attrs))
+	for k, v := range attrs {
+		ga = append(ga, GraphAttr{Key: k, Value: v})
+	}
+	sort.Slice(ga, func(i, j int) bool {
+		return ga[i].Key < ga[j].Key
+	})
+	return ga
+}
+
+func detailLines(d Detailer) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(d.PlanDetails()), "\n") {
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,153 @@
+package plan_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/andreyvit/diff"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/plan/plantest"
+	"github.com/influxdata/flux/plan/plantest/spec"
+	"github.com/influxdata/flux/stdlib/influxdata/influxdb"
+	"github.com/influxdata/flux/stdlib/universe"
+)
+
+func TestNewGraph(t *testing.T) {
+	fromSpec := &influxdb.FromProcedureSpec{
+		Bucket: influxdb.NameOrID{Name: "my-bucket"},
+	}
+
+	// (r) => r._value > 5.0
+	filterSpec := &universe.FilterProcedureSpec{
+		Fn: interpreter.ResolvedFunction{
+			Fn: executetest.FunctionExpression(t, `(r) => r._value > 5.0`),
+		},
+	}
+
+	type testcase struct {
+		name string
+		plan *plantest.PlanSpec
+		want string
+	}
+
+	tcs := []testcase{
+		{
+			name: "from |> filter",
+			plan: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreateLogicalNode("from", fromSpec),
+					plan.CreateLogicalNode("filter", filterSpec),
+				},
+				Edges: [][2]int{
+					{0, 1},
+				},
+			},
+			want: `{
+  "nodes": [
+    {
+      "id": "from",
+      "kind": "from",
+      "physical": false
+    },
+    {
+      "id": "filter",
+      "kind": "filter",
+      "physical": false,
+      "details": [
+        "r._value > 5.000000"
+      ]
+    }
+  ],
+  "edges": [
+    {
+      "from": "from",
+      "to": "filter"
+    }
+  ]
+}`,
+		},
+		{
+			name: "parallel merge attribute",
+			plan: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plantest.CreatePhysicalNode("source", spec.MockProcedureSpec{},
+						plantest.WithOutputAttr(plan.ParallelRunKey, plan.ParallelRunAttribute{Factor: 8})),
+					plantest.CreatePhysicalNode("filter", filterSpec,
+						plantest.WithRequiredAttr(plan.ParallelRunKey, plan.ParallelRunAttribute{Factor: 8}),
+						plantest.WithOutputAttr(plan.ParallelMergeKey, plan.ParallelMergeAttribute{Factor: 8})),
+				},
+				Edges: [][2]int{
+					{0, 1},
+				},
+			},
+			want: `{
+  "nodes": [
+    {
+      "id": "source",
+      "kind": "mock",
+      "physical": true,
+      "output_attrs": [
+        {
+          "key": "parallel-run",
+          "value": {
+            "Factor": 8
+          }
+        }
+      ]
+    },
+    {
+      "id": "filter",
+      "kind": "filter",
+      "physical": true,
+      "details": [
+        "r._value > 5.000000",
+        "ParallelMergeFactor: 8"
+      ],
+      "required_attrs": [
+        {
+          "key": "parallel-run",
+          "value": {
+            "Factor": 8
+          }
+        }
+      ],
+      "output_attrs": [
+        {
+          "key": "parallel-merge",
+          "value": {
+            "Factor": 8
+          }
+        }
+      ]
+    }
+  ],
+  "edges": [
+    {
+      "from": "source",
+      "to": "filter"
+    }
+  ]
+}`,
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ps := plantest.CreatePlanSpec(tc.plan)
+			var buf strings.Builder
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(plan.NewGraph(ps)); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(buf.String()); tc.want != got {
+				t.Fatalf("unexpected output: -want/+got:\n%v", diff.LineDiff(tc.want, got))
+			}
+		})
+	}
+}
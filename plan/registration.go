@@ -2,6 +2,7 @@ package plan
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/influxdata/flux"
@@ -89,6 +90,34 @@ func RegisterParallelizeRules(rules ...Rule) {
 	registerRule(ruleNameToParallelizeRules, rules...)
 }
 
+// LogicalRuleNames returns the names of the registered logical rules, sorted.
+func LogicalRuleNames() []string {
+	return ruleNames(ruleNameToLogicalRule)
+}
+
+// PhysicalRuleNames returns the names of the registered physical rules,
+// including the parallelization rules, sorted. RemovePhysicalRules
+// removes a rule of either kind.
+func PhysicalRuleNames() []string {
+	names := ruleNames(ruleNameToPhysicalRule)
+	for name := range ruleNameToParallelizeRules {
+		if _, ok := ruleNameToPhysicalRule[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func ruleNames(ruleMap map[string]Rule) []string {
+	names := make([]string, 0, len(ruleMap))
+	for name := range ruleMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func registerRule(ruleMap map[string]Rule, rules ...Rule) {
 	for _, rule := range rules {
 		name := rule.Name()
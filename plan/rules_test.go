@@ -80,6 +80,22 @@ func TestRuleRegistration(t *testing.T) {
 
 type contextKey string
 
+func TestRuleNames(t *testing.T) {
+	plan.ClearRegisteredRules()
+	defer plan.ClearRegisteredRules()
+
+	plan.RegisterLogicalRules(&plantest.SimpleRule{}, &plantest.FunctionRule{})
+	plan.RegisterPhysicalRules(plantest.SmashPlanRule{})
+	plan.RegisterParallelizeRules(plantest.CreateCycleRule{})
+
+	if want, got := []string{"function", "simple"}, plan.LogicalRuleNames(); !cmp.Equal(want, got) {
+		t.Errorf("unexpected logical rules -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if want, got := []string{"CreateCycleRule", "SmashPlanRule"}, plan.PhysicalRuleNames(); !cmp.Equal(want, got) {
+		t.Errorf("unexpected physical rules -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
 func TestRewriteWithContext(t *testing.T) {
 	plan.ClearRegisteredRules()
 
@@ -0,0 +1,222 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/libflux/go/libflux"
+	"github.com/influxdata/flux/parser"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+// The analyzer is stateful: every source it analyzes successfully adds
+// its definitions and imports to those visible when analyzing the next
+// one. This is what lets a line refer to a name bound by an earlier
+// line, but it also means that the analyzer must only see the sources
+// whose definitions end up in the scope that later sources are
+// evaluated in.
+//
+// The session analyzer therefore only analyzes lines evaluated in the
+// session scope, and the history of those lines is kept so that other
+// analyzers can be brought to the same state. Sources evaluated in a
+// nested scope, such as the bindings of EvalWithParams, are analyzed
+// by an analyzer of their own that starts from the session history.
+//
+// A line is evaluated in a scope of its own nested within the session
+// scope, whose bindings are only copied to the session scope once the
+// whole line has evaluated. A line that fails part way through then
+// binds nothing, just as it adds nothing to the history.
+//
+// Bringing an analyzer to the state of the session means analyzing
+// the whole history again, so it is avoided where it is not needed.
+// Sources that define nothing, such as a line made of expression
+// statements, are left out of the history and are analyzed by the
+// session analyzer even when they are evaluated in a nested scope.
+
+// nestedScope is a scope nested within the session scope along with
+// the analyzer for the sources evaluated in it.
+type nestedScope struct {
+	values.Scope
+	// analyzer is created when the first source is evaluated in the scope.
+	analyzer *libflux.Analyzer
+}
+
+// nestScope returns a new scope nested within the session scope whose
+// definitions are not seen by the analysis of the session.
+func (r *ScopeHolder) nestScope() values.Scope {
+	return &nestedScope{Scope: r.scope.Nest(nil)}
+}
+
+// analyzerFor returns the analyzer whose definitions match those
+// of scope, to analyze t with. The caller must hold evalMu.
+func (r *ScopeHolder) analyzerFor(scope values.Scope, t string) (*libflux.Analyzer, error) {
+	ns, ok := scope.(*nestedScope)
+	if !ok || (ns.analyzer == nil && definesNothing(t)) {
+		if r.analyzerBroken {
+			if err := r.resetAnalyzer(); err != nil {
+				return nil, err
+			}
+		}
+		return r.analyzer, nil
+	}
+	if ns.analyzer == nil {
+		a, err := r.forkAnalyzer()
+		if err != nil {
+			return nil, err
+		}
+		ns.analyzer = a
+	}
+	return ns.analyzer, nil
+}
+
+// forkAnalyzer returns a new analyzer that has analyzed the history
+// of the session, so that it sees the same definitions as the session
+// analyzer without affecting it. The caller must hold evalMu.
+func (r *ScopeHolder) forkAnalyzer() (*libflux.Analyzer, error) {
+	a, err := libflux.NewAnalyzerWithOptions(libflux.NewOptions(r.ctx))
+	if err != nil {
+		return nil, err
+	}
+	for i, t := range r.history {
+		if _, fluxError := a.AnalyzeString(t); fluxError != nil {
+			return nil, errors.Wrapf(fluxError.GoError(), codes.Internal, "failed to replay line %d of the session", i+1)
+		}
+	}
+	return a, nil
+}
+
+// evalLine evaluates pkg, the analysis of the line t by the session
+// analyzer, and binds what it defines in the session scope if the
+// whole line evaluated. The caller must hold evalMu.
+func (r *ScopeHolder) evalLine(ctx context.Context, t string, pkg *semantic.Package) ([]interpreter.SideEffect, error) {
+	line := r.scope.Nest(nil)
+	x, err := r.evalPackage(ctx, pkg, line)
+	if err == nil {
+		line.LocalRange(func(k string, v values.Value) {
+			r.scope.Set(k, v)
+		})
+	}
+	r.recordLine(t, err)
+	return x, err
+}
+
+// recordLine keeps the session analyzer in step with the session scope
+// after t was analyzed by it and evaluated with the error err.
+// A line that failed to evaluate contributes no definitions, so the
+// analyzer is rebuilt from the history without it, unless the line
+// defines nothing. Should that fail, the current analyzer is kept.
+// The caller must hold evalMu.
+func (r *ScopeHolder) recordLine(t string, err error) {
+	if definesNothing(t) {
+		return
+	}
+	if err == nil {
+		r.history = append(r.history, t)
+		return
+	}
+	if a, err := r.forkAnalyzer(); err == nil {
+		r.analyzer = a
+	}
+}
+
+// definesNothing reports whether analyzing t leaves the definitions
+// of the analyzer as they were, because t has no imports and only
+// expression statements. Source that does not parse is assumed to
+// define something.
+func definesNothing(t string) bool {
+	pkg := parser.ParseSource(t)
+	if ast.Check(pkg) > 0 {
+		return false
+	}
+	for _, f := range pkg.Files {
+		if f.Package != nil || len(f.Imports) > 0 {
+			return false
+		}
+		for _, st := range f.Body {
+			if _, ok := st.(*ast.ExpressionStatement); !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ResetAnalyzer recreates the session analyzer as described by
+// ScopeHolder.ResetAnalyzer.
+func (s *Service) ResetAnalyzer(req struct{}, resp *struct{}) error {
+	return s.r.ResetAnalyzer()
+}
+
+// ResetAnalyzer replaces the session analyzer with a new one that has
+// analyzed the history of the session, so that a session whose
+// analyzer is in a bad state recovers without losing the bindings of
+// its scope. An evaluation in progress is waited for, so one whose
+// analysis never returns should be interrupted with Cancel first.
+//
+// The analyzer is recreated the same way before the next analysis
+// after it panics.
+func (r *ScopeHolder) ResetAnalyzer() error {
+	r.evalMu.Lock()
+	defer r.evalMu.Unlock()
+	return r.resetAnalyzer()
+}
+
+// resetAnalyzer replaces the session analyzer.
+// The caller must hold evalMu.
+func (r *ScopeHolder) resetAnalyzer() error {
+	a, err := r.forkAnalyzer()
+	if err != nil {
+		return errors.Wrap(err, codes.Inherit, "failed to recreate the analyzer")
+	}
+	r.analyzer = a
+	r.analyzerBroken = false
+	r.health.recordAnalyzerReset()
+	return nil
+}
+
+// discardAnalyzer drops a, the analyzer of scope, if err tells that
+// it panicked, so that analyzerFor recreates it. The caller must hold
+// evalMu.
+func (r *ScopeHolder) discardAnalyzer(scope values.Scope, a *libflux.Analyzer, err error) {
+	var p *analyzerPanic
+	if !errors.As(err, &p) {
+		return
+	}
+	if a == r.analyzer {
+		r.analyzerBroken = true
+		return
+	}
+	if ns, ok := scope.(*nestedScope); ok {
+		ns.analyzer = nil
+	}
+}
+
+// analyzerPanic is the error of an analysis that panicked.
+type analyzerPanic struct {
+	value interface{}
+}
+
+func (e *analyzerPanic) Error() string {
+	return fmt.Sprintf("analyzer panicked: %v", e.value)
+}
+
+// analyzeString analyzes t with a, returning an *analyzerPanic
+// error if the analyzer panics.
+func analyzeString(a *libflux.Analyzer, t string) (pkg *libflux.SemanticPkg, fluxError *libflux.FluxError, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			pkg, fluxError = nil, nil
+			err = errors.Wrap(&analyzerPanic{value: v}, codes.Internal)
+		}
+	}()
+	pkg, fluxError = a.AnalyzeString(t)
+	if fluxError != nil {
+		return nil, fluxError, fluxError.GoError()
+	}
+	return pkg, nil, nil
+}
@@ -0,0 +1,33 @@
+package repl
+
+import (
+	"context"
+
+	"github.com/influxdata/flux/interpreter"
+)
+
+// DefaultMaxCallDepth is the call depth limit of a session
+// created without WithMaxCallDepth.
+const DefaultMaxCallDepth = 1000
+
+// WithMaxCallDepth limits how deeply the function calls of a line may
+// nest while it is evaluated. A line that goes deeper, such as one
+// that calls a function redefined to call itself with no base case,
+// fails with a resource exhausted error instead of overflowing the
+// stack and crashing the process along with every other session.
+//
+// The limit is DefaultMaxCallDepth by default. A limit of zero or
+// less means no limit.
+func WithMaxCallDepth(n int) Option {
+	return option(func(r *ScopeHolder) {
+		r.maxCallDepth = n
+	})
+}
+
+// withCallDepthLimit returns ctx with the call depth limit of the session.
+func (r *ScopeHolder) withCallDepthLimit(ctx context.Context) context.Context {
+	if r.maxCallDepth <= 0 {
+		return ctx
+	}
+	return interpreter.WithMaxCallDepth(ctx, r.maxCallDepth)
+}
@@ -0,0 +1,98 @@
+package repl
+
+import (
+	"context"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/memory"
+)
+
+// cancelReason is why a query stopped before it finished.
+type cancelReason int
+
+const (
+	// cancelUnknown means the query failed on its own,
+	// or was canceled for a reason the session does not know.
+	cancelUnknown cancelReason = iota
+	// cancelUser means the query was canceled by a call to
+	// CancelQuery or CancelAll, or by an interrupt.
+	cancelUser
+	// cancelTimeout means the deadline of the query passed,
+	// or the watchdog canceled it.
+	cancelTimeout
+	// cancelMemoryLimit means the query exceeded the memory limit.
+	cancelMemoryLimit
+	// cancelShutdown means the session was shut down.
+	cancelShutdown
+	// cancelReset means the session was reset.
+	cancelReset
+)
+
+func (reason cancelReason) String() string {
+	switch reason {
+	case cancelUser:
+		return "canceled by the user"
+	case cancelTimeout:
+		return "timed out"
+	case cancelMemoryLimit:
+		return "exceeded the memory limit"
+	case cancelShutdown:
+		return "interrupted by the session shutting down"
+	case cancelReset:
+		return "interrupted by a reset of the session"
+	default:
+		return "canceled"
+	}
+}
+
+// code returns the error code that clients can use to react to the
+// reason. A timeout or shutdown may succeed if it is run again,
+// but a query the user canceled, or whose session was reset,
+// should not be retried.
+func (reason cancelReason) code() codes.Code {
+	switch reason {
+	case cancelUser, cancelReset:
+		return codes.Canceled
+	case cancelTimeout:
+		return codes.DeadlineExceeded
+	case cancelMemoryLimit:
+		return codes.ResourceExhausted
+	case cancelShutdown:
+		return codes.Unavailable
+	default:
+		return codes.Inherit
+	}
+}
+
+// queryError annotates err, which stopped the query with the given ID,
+// with the reason the query was canceled. Errors of queries that were
+// not canceled are returned unchanged.
+func (r *ScopeHolder) queryError(ctx context.Context, id string, err error) error {
+	reason := r.cancelReason(ctx, id, err)
+	if reason == cancelUnknown {
+		return err
+	}
+	return errors.Wrapf(err, reason.code(), "query %s %s", id, reason)
+}
+
+// cancelReason determines why the query with the given ID stopped with err.
+func (r *ScopeHolder) cancelReason(ctx context.Context, id string, err error) cancelReason {
+	var limitErr memory.LimitExceededError
+	if errors.As(err, &limitErr) {
+		return cancelMemoryLimit
+	}
+	if reason := r.queries.reason(id); reason != cancelUnknown {
+		return reason
+	}
+	if r.ctx != nil && r.ctx.Err() != nil {
+		return cancelShutdown
+	}
+	if resetCanceled(ctx) {
+		return cancelReset
+	}
+	if ctx.Err() == context.DeadlineExceeded || watchdogCanceled(ctx) {
+		return cancelTimeout
+	}
+	return cancelUnknown
+}
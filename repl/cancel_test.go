@@ -0,0 +1,123 @@
+package repl
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/memory"
+)
+
+func TestScopeHolder_QueryError(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		// stop stops a running query and returns its context
+		// and the error it failed with.
+		stop func(t *testing.T, r *ScopeHolder, ctx context.Context, id string) (context.Context, error)
+		code codes.Code
+		msg  string
+	}{
+		{
+			name: "user",
+			stop: func(t *testing.T, r *ScopeHolder, ctx context.Context, id string) (context.Context, error) {
+				if err := r.CancelQuery(id); err != nil {
+					t.Fatal(err)
+				}
+				return ctx, ctx.Err()
+			},
+			code: codes.Canceled,
+			msg:  "canceled by the user",
+		},
+		{
+			name: "cancel all",
+			stop: func(t *testing.T, r *ScopeHolder, ctx context.Context, id string) (context.Context, error) {
+				r.CancelAll()
+				return ctx, ctx.Err()
+			},
+			code: codes.Canceled,
+			msg:  "canceled by the user",
+		},
+		{
+			name: "timeout",
+			stop: func(t *testing.T, r *ScopeHolder, ctx context.Context, id string) (context.Context, error) {
+				ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+				defer cancel()
+				<-ctx.Done()
+				return ctx, ctx.Err()
+			},
+			code: codes.DeadlineExceeded,
+			msg:  "timed out",
+		},
+		{
+			name: "watchdog",
+			stop: func(t *testing.T, r *ScopeHolder, ctx context.Context, id string) (context.Context, error) {
+				r.watchdog = &Watchdog{Threshold: time.Millisecond, Cancel: true, Output: &syncBuilder{}}
+				ctx, stop := r.watch(ctx)
+				defer stop()
+				<-ctx.Done()
+				return ctx, ctx.Err()
+			},
+			code: codes.DeadlineExceeded,
+			msg:  "timed out",
+		},
+		{
+			name: "memory limit",
+			stop: func(t *testing.T, r *ScopeHolder, ctx context.Context, id string) (context.Context, error) {
+				return ctx, errors.Wrap(memory.LimitExceededError{Limit: 1024, Allocated: 1000, Wanted: 64}, codes.ResourceExhausted)
+			},
+			code: codes.ResourceExhausted,
+			msg:  "exceeded the memory limit",
+		},
+		{
+			name: "shutdown",
+			stop: func(t *testing.T, r *ScopeHolder, ctx context.Context, id string) (context.Context, error) {
+				ctx, cancel := context.WithCancel(r.ctx)
+				r.ctx = ctx
+				cancel()
+				return ctx, ctx.Err()
+			},
+			code: codes.Unavailable,
+			msg:  "interrupted by the session shutting down",
+		},
+		{
+			name: "reset",
+			stop: func(t *testing.T, r *ScopeHolder, ctx context.Context, id string) (context.Context, error) {
+				r.drainLines()()
+				return ctx, ctx.Err()
+			},
+			code: codes.Canceled,
+			msg:  "interrupted by a reset of the session",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestHolder()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			id := r.queries.add(cancel)
+
+			ctx, err := tt.stop(t, r, ctx, id)
+			err = r.queryError(ctx, id, err)
+			if got, want := errors.Code(err), tt.code; got != want {
+				t.Fatalf("unexpected error code: got %v, want %v: %v", got, want, err)
+			}
+			if want := "query " + id + " " + tt.msg; !strings.Contains(err.Error(), want) {
+				t.Fatalf("expected error to contain %q, got %q", want, err)
+			}
+		})
+	}
+}
+
+func TestScopeHolder_QueryError_NotCanceled(t *testing.T) {
+	r := newTestHolder()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	id := r.queries.add(cancel)
+
+	want := errors.New(codes.Invalid, "bad query")
+	if got := r.queryError(ctx, id, want); got != want {
+		t.Fatalf("expected the error to be unchanged, got %v", got)
+	}
+}
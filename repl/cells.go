@@ -0,0 +1,46 @@
+package repl
+
+import (
+	"context"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/libflux/go/libflux"
+)
+
+// EvalCells evaluates the cells of a notebook that come before cell, in
+// order, and then cell, and returns the output of cell as EvalString
+// does. This is what a notebook runs to "run all above": the outcome of
+// cell depends only on the cells given and their order, not on the lines
+// that the session evaluated before, in whatever order they came.
+//
+// The cells are evaluated in a fresh scope, nested within the prelude,
+// which sees none of the bindings of the session and is discarded
+// afterwards. The queries of the cells before cell are not run, so their
+// output is not produced; only their bindings carry over to cell.
+func (r *ScopeHolder) EvalCells(ctx context.Context, cells []string, cell string) (Result, error) {
+	ctx, end := r.beginLine(ctx)
+	defer end()
+	scope, err := r.freshScope()
+	if err != nil {
+		return Result{}, err
+	}
+	for i, t := range cells {
+		if _, _, err := r.evalInScope(ctx, t, scope); err != nil {
+			return Result{}, errors.Wrapf(err, codes.Inherit, "cell %d failed", i)
+		}
+	}
+	return r.evalString(ctx, cell, scope)
+}
+
+// freshScope returns a new scope nested within the prelude
+// along with an analyzer that has analyzed none of the session.
+func (r *ScopeHolder) freshScope() (*nestedScope, error) {
+	analyzer, err := libflux.NewAnalyzerWithOptions(libflux.NewOptions(r.ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, codes.Inherit, "failed to create the analyzer")
+	}
+	r.evalMu.Lock()
+	defer r.evalMu.Unlock()
+	return &nestedScope{Scope: r.newOverrides(r.prelude).Nest(nil), analyzer: analyzer}, nil
+}
@@ -0,0 +1,266 @@
+package repl
+
+import (
+	"math"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/metadata"
+	"github.com/influxdata/flux/values"
+)
+
+// columnStatsKey is the statistics metadata key under which
+// the column statistics of each result are recorded.
+const columnStatsKey = "flux/column-stats"
+
+// WithColumnStats controls whether a summary of the values in each
+// column is computed for the results of every query, to be returned
+// along with their output. The summary is computed while the tables
+// are written out, without reading them a second time.
+// It is disabled by default.
+func WithColumnStats(enabled bool) Option {
+	return option(func(r *ScopeHolder) {
+		r.columnStats = enabled
+	})
+}
+
+// ResultStats summarizes the columns of the tables of a result.
+type ResultStats struct {
+	Name    string        `json:"name"`
+	Columns []ColumnStats `json:"columns"`
+}
+
+// ColumnStats summarizes the values of a column across
+// the tables of a result.
+type ColumnStats struct {
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	// Count is the number of values that are not null.
+	Count int64 `json:"count"`
+	// Nulls is the number of null values.
+	Nulls int64 `json:"nulls"`
+	// Min and Max are the smallest and largest values of int, uint,
+	// float and time columns, as an int64, uint64, float64 or
+	// time.Time. They are nil for other columns or when every value
+	// is null. NaNs are left out of the comparison.
+	Min interface{} `json:"min,omitempty"`
+	Max interface{} `json:"max,omitempty"`
+}
+
+// statsColumnStats returns the column statistics recorded in stats,
+// one for each result in the order they were produced.
+func statsColumnStats(stats flux.Statistics) []ResultStats {
+	var results []ResultStats
+	for _, v := range stats.Metadata.GetAll(columnStatsKey) {
+		if rs, ok := v.(ResultStats); ok {
+			results = append(results, rs)
+		}
+	}
+	return results
+}
+
+// columnProfiler computes the column statistics of the results
+// of a query as their tables are read.
+// A nil profiler computes nothing.
+type columnProfiler struct {
+	results []*resultProfile
+}
+
+// newColumnProfiler returns a profiler for a query, or nil
+// if the session does not compute column statistics.
+func (r *ScopeHolder) newColumnProfiler() *columnProfiler {
+	if !r.columnStats {
+		return nil
+	}
+	return &columnProfiler{}
+}
+
+// wrap returns a result with the tables of result
+// whose columns are profiled as they are read.
+func (p *columnProfiler) wrap(result flux.Result) flux.Result {
+	if p == nil {
+		return result
+	}
+	rp := &resultProfile{name: result.Name(), index: make(map[flux.ColMeta]int)}
+	p.results = append(p.results, rp)
+	return &profiledResult{Result: result, profile: rp}
+}
+
+// addTo records the column statistics of the results in stats.
+func (p *columnProfiler) addTo(stats flux.Statistics) flux.Statistics {
+	if p == nil || len(p.results) == 0 {
+		return stats
+	}
+	if stats.Metadata == nil {
+		stats.Metadata = make(metadata.Metadata)
+	}
+	for _, rp := range p.results {
+		stats.Metadata.Add(columnStatsKey, rp.stats())
+	}
+	return stats
+}
+
+// profiledResult is a result whose tables are profiled as they are read.
+type profiledResult struct {
+	flux.Result
+	profile *resultProfile
+}
+
+func (r *profiledResult) Tables() flux.TableIterator {
+	return profiledTables{TableIterator: r.Result.Tables(), profile: r.profile}
+}
+
+type profiledTables struct {
+	flux.TableIterator
+	profile *resultProfile
+}
+
+func (t profiledTables) Do(f func(flux.Table) error) error {
+	return t.TableIterator.Do(func(tbl flux.Table) error {
+		return f(&profiledTable{Table: tbl, profile: t.profile})
+	})
+}
+
+// profiledTable is a table whose columns are profiled as they are read.
+type profiledTable struct {
+	flux.Table
+	profile *resultProfile
+}
+
+func (t *profiledTable) Do(f func(flux.ColReader) error) error {
+	return t.Table.Do(func(cr flux.ColReader) error {
+		t.profile.observe(cr)
+		return f(cr)
+	})
+}
+
+// resultProfile accumulates the column statistics of a result.
+type resultProfile struct {
+	name    string
+	columns []*columnProfile
+	// index maps each column to its position in columns.
+	index map[flux.ColMeta]int
+}
+
+// columnProfile accumulates the statistics of a column.
+// The bounds are kept in the type of the column.
+type columnProfile struct {
+	ColumnStats
+	hasBounds  bool
+	minI, maxI int64
+	minU, maxU uint64
+	minF, maxF float64
+}
+
+func (p *resultProfile) column(col flux.ColMeta) *columnProfile {
+	if i, ok := p.index[col]; ok {
+		return p.columns[i]
+	}
+	cp := &columnProfile{ColumnStats: ColumnStats{Label: col.Label, Type: col.Type.String()}}
+	p.index[col] = len(p.columns)
+	p.columns = append(p.columns, cp)
+	return cp
+}
+
+// observe adds the values of each column of cr to the profile.
+func (p *resultProfile) observe(cr flux.ColReader) {
+	n := cr.Len()
+	for j, col := range cr.Cols() {
+		cp := p.column(col)
+		switch col.Type {
+		case flux.TInt, flux.TTime:
+			vs := cr.Ints(j)
+			if col.Type == flux.TTime {
+				vs = cr.Times(j)
+			}
+			for i := 0; i < n; i++ {
+				if vs.IsValid(i) {
+					cp.observeInt(vs.Value(i))
+				}
+			}
+			cp.Nulls += int64(vs.NullN())
+		case flux.TUInt:
+			vs := cr.UInts(j)
+			for i := 0; i < n; i++ {
+				if vs.IsValid(i) {
+					cp.observeUInt(vs.Value(i))
+				}
+			}
+			cp.Nulls += int64(vs.NullN())
+		case flux.TFloat:
+			vs := cr.Floats(j)
+			for i := 0; i < n; i++ {
+				if vs.IsValid(i) {
+					cp.observeFloat(vs.Value(i))
+				}
+			}
+			cp.Nulls += int64(vs.NullN())
+		case flux.TString:
+			vs := cr.Strings(j)
+			cp.Count += int64(n - vs.NullN())
+			cp.Nulls += int64(vs.NullN())
+		case flux.TBool:
+			vs := cr.Bools(j)
+			cp.Count += int64(n - vs.NullN())
+			cp.Nulls += int64(vs.NullN())
+		}
+	}
+}
+
+func (cp *columnProfile) observeInt(v int64) {
+	cp.Count++
+	if !cp.hasBounds || v < cp.minI {
+		cp.minI = v
+	}
+	if !cp.hasBounds || v > cp.maxI {
+		cp.maxI = v
+	}
+	cp.hasBounds = true
+}
+
+func (cp *columnProfile) observeUInt(v uint64) {
+	cp.Count++
+	if !cp.hasBounds || v < cp.minU {
+		cp.minU = v
+	}
+	if !cp.hasBounds || v > cp.maxU {
+		cp.maxU = v
+	}
+	cp.hasBounds = true
+}
+
+func (cp *columnProfile) observeFloat(v float64) {
+	cp.Count++
+	if math.IsNaN(v) {
+		return
+	}
+	if !cp.hasBounds || v < cp.minF {
+		cp.minF = v
+	}
+	if !cp.hasBounds || v > cp.maxF {
+		cp.maxF = v
+	}
+	cp.hasBounds = true
+}
+
+// stats returns the statistics of the columns in the order
+// they were first read.
+func (p *resultProfile) stats() ResultStats {
+	rs := ResultStats{Name: p.name, Columns: make([]ColumnStats, len(p.columns))}
+	for i, cp := range p.columns {
+		cs := cp.ColumnStats
+		if cp.hasBounds {
+			switch cs.Type {
+			case flux.TInt.String():
+				cs.Min, cs.Max = cp.minI, cp.maxI
+			case flux.TTime.String():
+				cs.Min, cs.Max = values.Time(cp.minI).Time(), values.Time(cp.maxI).Time()
+			case flux.TUInt.String():
+				cs.Min, cs.Max = cp.minU, cp.maxU
+			case flux.TFloat.String():
+				cs.Min, cs.Max = cp.minF, cp.maxF
+			}
+		}
+		rs.Columns[i] = cs
+	}
+	return rs
+}
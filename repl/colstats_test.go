@@ -0,0 +1,88 @@
+package repl
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/csv"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/values"
+)
+
+func TestColumnProfiler(t *testing.T) {
+	ts := func(sec int64) values.Time {
+		return values.ConvertTime(time.Unix(sec, 0).UTC())
+	}
+	cols := []flux.ColMeta{
+		{Label: "_time", Type: flux.TTime},
+		{Label: "host", Type: flux.TString},
+		{Label: "_value", Type: flux.TFloat},
+		{Label: "n", Type: flux.TInt},
+	}
+	result := &executetest.Result{
+		Nm: "_result",
+		Tbls: []*executetest.Table{
+			{KeyCols: []string{"host"}, ColMeta: cols, Data: [][]interface{}{
+				{ts(10), "a", 2.5, int64(3)},
+				{ts(20), "a", nil, int64(-4)},
+			}},
+			{KeyCols: []string{"host"}, ColMeta: cols, Data: [][]interface{}{
+				{ts(5), "b", -1.0, nil},
+				{nil, "b", 7.25, nil},
+				{ts(30), "b", 0.0, int64(9)},
+			}},
+		},
+	}
+
+	r := newTestHolder(WithColumnStats(true))
+	prof := r.newColumnProfiler()
+	var a, b bytes.Buffer
+	states := newSinkStates([]ResultSink{
+		{Writer: &a, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())},
+		{Writer: &b, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())},
+	})
+	if err := encodeResult(states, prof.wrap(result)); err != nil {
+		t.Fatal(err)
+	}
+	if a.Len() == 0 || a.String() != b.String() {
+		t.Fatal("expected the sinks to receive the tables while they were profiled")
+	}
+
+	want := []ResultStats{{
+		Name: "_result",
+		Columns: []ColumnStats{
+			{Label: "_time", Type: "time", Count: 4, Nulls: 1, Min: time.Unix(5, 0).UTC(), Max: time.Unix(30, 0).UTC()},
+			{Label: "host", Type: "string", Count: 5},
+			{Label: "_value", Type: "float", Count: 4, Nulls: 1, Min: -1.0, Max: 7.25},
+			{Label: "n", Type: "int", Count: 3, Nulls: 2, Min: int64(-4), Max: int64(9)},
+		},
+	}}
+	if got := statsColumnStats(prof.addTo(flux.Statistics{})); !cmp.Equal(want, got) {
+		t.Fatalf("unexpected column stats -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestColumnProfiler_Disabled(t *testing.T) {
+	prof := newTestHolder().newColumnProfiler()
+	result := sinkTestResult()
+	if got := prof.wrap(result); got != result {
+		t.Fatal("expected the result to be unchanged without column stats")
+	}
+	if stats := prof.addTo(flux.Statistics{}); stats.Metadata != nil {
+		t.Fatalf("expected no metadata without column stats, got %v", stats.Metadata)
+	}
+}
+
+func TestLineResult_Response_ColumnStats(t *testing.T) {
+	stats := []ResultStats{{Name: "_result", Columns: []ColumnStats{{Label: "_value", Type: "int", Count: 1}}}}
+	var resp Response
+	if err := (lineResult{columnStats: stats}).response(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(stats, resp.ColumnStats) {
+		t.Fatalf("unexpected column stats -want/+got:\n%s", cmp.Diff(stats, resp.ColumnStats))
+	}
+}
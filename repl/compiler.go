@@ -14,10 +14,18 @@ const CompilerType = "REPL"
 // Compiler specific to the Flux REPL
 type Compiler struct {
 	Spec *flux.Spec `json:"spec"`
+
+	// disabledRules are the planner rules that the spec is planned without.
+	disabledRules []string
 }
 
 func (c Compiler) Compile(ctx context.Context, runtime flux.Runtime) (flux.Program, error) {
-	planner := plan.PlannerBuilder{}.Build()
+	var pb plan.PlannerBuilder
+	if len(c.disabledRules) > 0 {
+		pb.AddLogicalOptions(plan.RemoveLogicalRules(c.disabledRules...))
+		pb.AddPhysicalOptions(plan.RemovePhysicalRules(c.disabledRules...))
+	}
+	planner := pb.Build()
 	ps, err := planner.Plan(ctx, c.Spec)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,126 @@
+package repl
+
+import (
+	"context"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/memory"
+)
+
+// WithMaxConcurrentQueries limits the number of queries that may
+// execute at the same time. A limit of zero or less means no limit.
+//
+// When combined with WithMemoryLimit, the memory used by queries
+// in a session is bounded by n times the per-query memory limit.
+func WithMaxConcurrentQueries(n int) Option {
+	return option(func(r *ScopeHolder) {
+		if n <= 0 {
+			r.querySem = nil
+			return
+		}
+		r.querySem = make(chan struct{}, n)
+	})
+}
+
+// WithQueueQueries controls what happens to a query that arrives
+// when the concurrent query limit has been reached.
+// If queue is true, the query waits for a slot to become available.
+// Otherwise, the query is rejected with a resource exhausted error.
+func WithQueueQueries(queue bool) Option {
+	return option(func(r *ScopeHolder) {
+		r.queueQueries = queue
+	})
+}
+
+// WithMemoryLimit sets the maximum number of bytes a single query
+// may allocate. A limit of zero or less means no limit.
+func WithMemoryLimit(bytes int64) Option {
+	return option(func(r *ScopeHolder) {
+		r.memoryLimit = bytes
+	})
+}
+
+// WithSessionAllocator makes every query of the session allocate its
+// memory from alloc, so that the Limit of alloc, if it has one, is a
+// memory budget shared by all the queries of the session. Each query
+// still has an allocator of its own, whose use is reported in the
+// statistics of the query and is bounded by WithMemoryLimit.
+//
+// By default, the session has an allocator without a limit.
+func WithSessionAllocator(alloc *memory.ResourceAllocator) Option {
+	return option(func(r *ScopeHolder) {
+		r.sessionAlloc = alloc
+	})
+}
+
+// MemoryUsage reports the memory allocated by the queries of a session.
+type MemoryUsage struct {
+	// Allocated is the number of bytes held by running queries.
+	Allocated int64
+	// MaxAllocated is the largest number of bytes
+	// held by the queries of the session at once.
+	MaxAllocated int64
+	// TotalAllocated is the number of bytes allocated by every query
+	// of the session, including memory that has since been released.
+	TotalAllocated int64
+}
+
+// MemoryUsage reports the memory allocated by every query of the session
+// from its session allocator.
+func (r *ScopeHolder) MemoryUsage() MemoryUsage {
+	if r.sessionAlloc == nil {
+		return MemoryUsage{}
+	}
+	return MemoryUsage{
+		Allocated:      r.sessionAlloc.Allocated(),
+		MaxAllocated:   r.sessionAlloc.MaxAllocated(),
+		TotalAllocated: r.sessionAlloc.TotalAllocated(),
+	}
+}
+
+// acquireQuery reserves a slot for a query to execute.
+// Every successful call must be paired with a call to releaseQuery.
+func (r *ScopeHolder) acquireQuery(ctx context.Context) error {
+	if r.querySem == nil {
+		return nil
+	}
+
+	if !r.queueQueries {
+		select {
+		case r.querySem <- struct{}{}:
+			return nil
+		default:
+			return errors.Newf(codes.ResourceExhausted, "too many concurrent queries: limit is %d", cap(r.querySem))
+		}
+	}
+
+	select {
+	case r.querySem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), codes.Canceled, "query canceled while waiting for a query slot")
+	}
+}
+
+// releaseQuery frees a slot reserved by acquireQuery.
+func (r *ScopeHolder) releaseQuery() {
+	if r.querySem == nil {
+		return
+	}
+	<-r.querySem
+}
+
+// newAllocator returns the allocator used for a single query.
+// It allocates from the session allocator, when there is one.
+func (r *ScopeHolder) newAllocator() *memory.ResourceAllocator {
+	alloc := &memory.ResourceAllocator{}
+	if r.sessionAlloc != nil {
+		alloc.Allocator = r.sessionAlloc
+	}
+	if r.memoryLimit > 0 {
+		limit := r.memoryLimit
+		alloc.Limit = &limit
+	}
+	return alloc
+}
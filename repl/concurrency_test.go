@@ -0,0 +1,148 @@
+package repl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/memory"
+)
+
+func newTestHolder(opts ...Option) *ScopeHolder {
+	r := &ScopeHolder{ctx: context.Background()}
+	for _, opt := range opts {
+		opt.applyOption(r)
+	}
+	return r
+}
+
+func TestMaxConcurrentQueries_Reject(t *testing.T) {
+	r := newTestHolder(WithMaxConcurrentQueries(2))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := r.acquireQuery(ctx); err != nil {
+			t.Fatalf("unexpected error acquiring slot %d: %s", i, err)
+		}
+	}
+
+	err := r.acquireQuery(ctx)
+	if err == nil {
+		t.Fatal("expected error when limit is saturated")
+	}
+	if got, want := errors.Code(err), codes.ResourceExhausted; got != want {
+		t.Fatalf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+
+	r.releaseQuery()
+	if err := r.acquireQuery(ctx); err != nil {
+		t.Fatalf("unexpected error after release: %s", err)
+	}
+}
+
+func TestMaxConcurrentQueries_Queue(t *testing.T) {
+	r := newTestHolder(WithMaxConcurrentQueries(1), WithQueueQueries(true))
+	ctx := context.Background()
+
+	if err := r.acquireQuery(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		acquired <- r.acquireQuery(ctx)
+	}()
+
+	select {
+	case err := <-acquired:
+		t.Fatalf("queued query acquired a slot before one was released: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	r.releaseQuery()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued query did not acquire a slot after release")
+	}
+}
+
+func TestMaxConcurrentQueries_QueueCanceled(t *testing.T) {
+	r := newTestHolder(WithMaxConcurrentQueries(1), WithQueueQueries(true))
+	if err := r.acquireQuery(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := r.acquireQuery(ctx)
+	if got, want := errors.Code(err), codes.Canceled; got != want {
+		t.Fatalf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	r := newTestHolder(WithMemoryLimit(1024))
+	alloc := r.newAllocator()
+	if alloc.Limit == nil || *alloc.Limit != 1024 {
+		t.Fatalf("unexpected allocator limit: %v", alloc.Limit)
+	}
+
+	r = newTestHolder()
+	if alloc := r.newAllocator(); alloc.Limit != nil {
+		t.Fatalf("expected no allocator limit, got %d", *alloc.Limit)
+	}
+}
+
+func TestSessionAllocator(t *testing.T) {
+	session := &memory.ResourceAllocator{}
+	r := newTestHolder(WithSessionAllocator(session))
+
+	for i := 0; i < 3; i++ {
+		alloc := r.newAllocator()
+		b := alloc.Allocate(64)
+		if err := alloc.Account(32); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := alloc.TotalAllocated(), int64(96); got != want {
+			t.Fatalf("unexpected allocation of query %d: got %d, want %d", i, got, want)
+		}
+		alloc.Free(b)
+		if err := alloc.Account(-32); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := MemoryUsage{Allocated: 0, MaxAllocated: 96, TotalAllocated: 3 * 96}
+	if got := r.MemoryUsage(); got != want {
+		t.Fatalf("unexpected session memory usage: got %+v, want %+v", got, want)
+	}
+}
+
+func TestSessionAllocator_Limit(t *testing.T) {
+	limit := int64(100)
+	r := newTestHolder(WithSessionAllocator(&memory.ResourceAllocator{Limit: &limit}))
+
+	first, second := r.newAllocator(), r.newAllocator()
+	if err := first.Account(64); err != nil {
+		t.Fatal(err)
+	}
+	err := second.Account(64)
+	if got, want := errors.Code(err), codes.ResourceExhausted; got != want {
+		t.Fatalf("expected the session budget to be exceeded, got %v", err)
+	}
+	if got := second.Allocated(); got != 0 {
+		t.Fatalf("expected the rejected allocation not to be counted, got %d", got)
+	}
+	if err := first.Account(-64); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Account(64); err != nil {
+		t.Fatalf("expected the released memory to be available to other queries, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/csv"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/semantic"
+)
+
+// CSVChunk is the annotated CSV of one table of a result.
+type CSVChunk struct {
+	// Result is the name of the result the table belongs to.
+	Result string
+	// Table is the index of the table within its result.
+	Table int
+	// Data holds the CSV of the table along with any annotations
+	// and header that precede it. Concatenating the chunks of a
+	// result gives the CSV of the result as a whole.
+	Data []byte
+}
+
+// EvalCSV evaluates the Flux source t in the session scope and calls
+// fn with the CSV of each table of every query it runs, as soon as the
+// table is encoded. The results are encoded in the dialect set by
+// WithCSVDialect, or as annotated CSV otherwise, with floats in full
+// precision regardless of WithFloatPrecision. Expression statements
+// that do not produce tables are evaluated but not returned.
+//
+// The tables of a result are encoded by a single encoder, so that
+// the annotations and header are only repeated when the schema
+// changes from one table to the next. Queries are not retried,
+// since their chunks have already been handed to fn.
+func (r *ScopeHolder) EvalCSV(ctx context.Context, t string, fn func(CSVChunk) error) error {
+	ctx, end := r.beginLine(ctx)
+	defer end()
+	ctx, stop := r.watch(ctx)
+	defer stop()
+
+	ses, _, err := r.evalWithFluxError(ctx, t)
+	if err != nil {
+		return err
+	}
+
+	specs, err := r.tableSpecs(ctx, ses)
+	if err != nil {
+		return err
+	}
+	if err := checkYields(specs, r.disambiguateYields); err != nil {
+		return err
+	}
+
+	config := csv.DefaultEncoderConfig()
+	if r.csvConfig != nil {
+		config = *r.csvConfig
+	}
+	for _, se := range ses {
+		if _, ok := se.Node.(*semantic.ExpressionStatement); !ok {
+			continue
+		}
+		if _, ok := se.Value.(*flux.TableObject); !ok {
+			continue
+		}
+		s := specs[0]
+		specs = specs[1:]
+		if _, err := r.runQuery(ctx, s, func(result flux.Result) error {
+			return encodeCSVChunks(csv.NewResultEncoder(config), result, fn)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeCSVChunks encodes result with enc and calls fn with the CSV
+// of each table once the encoder has written it.
+func encodeCSVChunks(enc flux.ResultEncoder, result flux.Result, fn func(CSVChunk) error) error {
+	w := &chunkWriter{}
+	chunked := &chunkedResult{Result: result, w: w, fn: fn}
+	if _, err := enc.Encode(w, chunked); err != nil {
+		if chunked.err != nil {
+			return chunked.err
+		}
+		return errors.Wrapf(err, codes.Inherit, "failed to encode result %q", result.Name())
+	}
+	return nil
+}
+
+// chunkWriter collects what the encoder writes for a table.
+type chunkWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+// take returns what was written since it was last called.
+func (w *chunkWriter) take() []byte {
+	data := make([]byte, w.buf.Len())
+	copy(data, w.buf.Bytes())
+	w.buf.Reset()
+	return data
+}
+
+// chunkedResult is a result that hands what the encoder wrote for
+// each of its tables to fn once the encoder is done with the table.
+type chunkedResult struct {
+	flux.Result
+	w  *chunkWriter
+	fn func(CSVChunk) error
+	// err is the error returned by fn, if any.
+	err error
+}
+
+func (r *chunkedResult) Tables() flux.TableIterator {
+	return r
+}
+
+func (r *chunkedResult) Do(f func(flux.Table) error) error {
+	n := 0
+	return r.Result.Tables().Do(func(tbl flux.Table) error {
+		if err := f(tbl); err != nil {
+			return err
+		}
+		r.err = r.fn(CSVChunk{Result: r.Name(), Table: n, Data: r.w.take()})
+		n++
+		return r.err
+	})
+}
@@ -0,0 +1,83 @@
+package repl
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/csv"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func csvChunksResult() flux.Result {
+	cols := []flux.ColMeta{
+		{Label: "host", Type: flux.TString},
+		{Label: "_value", Type: flux.TFloat},
+	}
+	return &executetest.Result{
+		Nm: "_result",
+		Tbls: []*executetest.Table{
+			{KeyCols: []string{"host"}, ColMeta: cols, Data: [][]interface{}{
+				{"a", 1.5},
+				{"a", 2.0},
+			}},
+			{KeyCols: []string{"host"}, ColMeta: cols, Data: [][]interface{}{
+				{"b", -1.0},
+			}},
+			// The schema changes, so the annotations are written again.
+			{KeyCols: []string{"host"}, ColMeta: []flux.ColMeta{
+				{Label: "host", Type: flux.TString},
+				{Label: "_value", Type: flux.TInt},
+			}, Data: [][]interface{}{
+				{"c", int64(3)},
+			}},
+		},
+	}
+}
+
+func TestEncodeCSVChunks(t *testing.T) {
+	var want bytes.Buffer
+	if _, err := csv.NewResultEncoder(csv.DefaultEncoderConfig()).Encode(&want, csvChunksResult()); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		got    bytes.Buffer
+		chunks []CSVChunk
+	)
+	if err := encodeCSVChunks(csv.NewResultEncoder(csv.DefaultEncoderConfig()), csvChunksResult(), func(c CSVChunk) error {
+		chunks = append(chunks, c)
+		got.Write(c.Data)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("expected a chunk for each table, got %d", len(chunks))
+	}
+	for i, c := range chunks {
+		if c.Result != "_result" || c.Table != i || len(c.Data) == 0 {
+			t.Errorf("unexpected chunk %d: %+v", i, c)
+		}
+	}
+	if got.String() != want.String() {
+		t.Fatalf("expected the chunks to add up to the encoded result, got:\n%s\nwant:\n%s", got.String(), want.String())
+	}
+}
+
+func TestEncodeCSVChunks_Error(t *testing.T) {
+	stop := errors.New(codes.Canceled, "stop")
+	n := 0
+	err := encodeCSVChunks(csv.NewResultEncoder(csv.DefaultEncoderConfig()), csvChunksResult(), func(c CSVChunk) error {
+		n++
+		return stop
+	})
+	if err != stop {
+		t.Fatalf("expected the error of the callback, got %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected encoding to stop after the first chunk, got %d chunks", n)
+	}
+}
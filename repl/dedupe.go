@@ -0,0 +1,156 @@
+package repl
+
+import (
+	"reflect"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/stdlib/universe"
+)
+
+// WithDedupeResults controls whether a query of an input whose result
+// repeats that of an earlier query of the same input is run. When
+// enabled, such a query is skipped and its result is reported as an
+// alias of the earlier one, so that clients binding several panels to
+// the same stream receive its tables once.
+//
+// Results repeat each other when they yield the same stream, or when
+// their queries are made of the same operations. Queries with more
+// than one result are always run. It is disabled by default.
+func WithDedupeResults(enabled bool) Option {
+	return option(func(r *ScopeHolder) {
+		r.dedupeResults = enabled
+	})
+}
+
+// resultAlias is the name of a result that is not produced because
+// it repeats the result named by of.
+type resultAlias struct {
+	name, of string
+}
+
+// resultAliases returns the aliases of the results of specs as
+// dedupeQueries does, or none if the session does not dedupe results.
+func (r *ScopeHolder) resultAliases(ses []interpreter.SideEffect, specs []*flux.Spec) []*resultAlias {
+	if !r.dedupeResults {
+		return make([]*resultAlias, len(specs))
+	}
+	return dedupeQueries(ses, specs)
+}
+
+// dedupeQueries returns, for each of specs, the alias of its result if
+// it repeats the result of an earlier spec, or nil if it must be run.
+// The specs are those of the table objects of ses, in order.
+//
+// The yielded streams are compared by reference first, and only by
+// the content of the specs when they differ.
+func dedupeQueries(ses []interpreter.SideEffect, specs []*flux.Spec) []*resultAlias {
+	var tables []*flux.TableObject
+	for _, se := range ses {
+		if _, ok := se.Node.(*semantic.ExpressionStatement); !ok {
+			continue
+		}
+		if t, ok := se.Value.(*flux.TableObject); ok {
+			tables = append(tables, t)
+		}
+	}
+
+	var earlier []*queryResult
+	aliases := make([]*resultAlias, len(specs))
+	for i, s := range specs {
+		name, ok := singleResult(s)
+		if !ok {
+			continue
+		}
+		cur := &queryResult{name: name, stream: yieldedStream(tables[i]), spec: s}
+		for _, e := range earlier {
+			if e.stream == cur.stream || e.sameContent(cur) {
+				aliases[i] = &resultAlias{name: name, of: e.name}
+				break
+			}
+		}
+		if aliases[i] == nil {
+			earlier = append(earlier, cur)
+		}
+	}
+	return aliases
+}
+
+// singleResult returns the name of the result of s
+// if it has exactly one.
+func singleResult(s *flux.Spec) (string, bool) {
+	names, err := yieldNames(s)
+	if err != nil || len(names) != 1 {
+		return "", false
+	}
+	return names[0], true
+}
+
+// yieldedStream returns the stream that t yields.
+func yieldedStream(t *flux.TableObject) *flux.TableObject {
+	for t.Kind == universe.YieldKind && len(t.Parents) == 1 {
+		t = t.Parents[0]
+	}
+	return t
+}
+
+// queryResult is the only result of a query of an input.
+type queryResult struct {
+	name   string
+	stream *flux.TableObject
+	spec   *flux.Spec
+}
+
+// sameContent reports whether the queries of q and o are made of the
+// same operations and edges, leaving out their yields. The source
+// locations of the operations are left out too, so that the same
+// operations written twice compare alike.
+//
+// The operation specs are compared deeply rather than by their JSON,
+// since values such as the rows of array.from or the functions of
+// filter are not encoded in full.
+func (q *queryResult) sameContent(o *queryResult) bool {
+	qops, qedges := withoutYields(q.spec)
+	oops, oedges := withoutYields(o.spec)
+	if len(qops) != len(oops) || !reflect.DeepEqual(qedges, oedges) {
+		return false
+	}
+	for i, op := range qops {
+		if op.ID != oops[i].ID || !reflect.DeepEqual(op.Spec, oops[i].Spec) {
+			return false
+		}
+	}
+	return true
+}
+
+// withoutYields returns the operations and edges of s
+// that are not yields.
+func withoutYields(s *flux.Spec) ([]*flux.Operation, []flux.Edge) {
+	var ops []*flux.Operation
+	yields := make(map[flux.OperationID]bool)
+	for _, o := range s.Operations {
+		if o.Spec.Kind() == universe.YieldKind {
+			yields[o.ID] = true
+			continue
+		}
+		ops = append(ops, o)
+	}
+	var edges []flux.Edge
+	for _, e := range s.Edges {
+		if !yields[e.Parent] && !yields[e.Child] {
+			edges = append(edges, e)
+		}
+	}
+	return ops, edges
+}
+
+// aliasNames records each alias in names, which maps the name of a
+// result that was not produced to the name of the result it repeats.
+func aliasNames(names map[string]string, alias *resultAlias) map[string]string {
+	if names == nil {
+		names = make(map[string]string)
+	}
+	names[alias.name] = alias.of
+	return names
+}
@@ -0,0 +1,98 @@
+package repl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/stdlib/universe"
+)
+
+// limitYield returns a table object that yields the stream of parent
+// under name, along with the spec of its query, which limits a test
+// source to n rows.
+func limitYield(parent *flux.TableObject, n int64, name string) (*flux.TableObject, *flux.Spec) {
+	t := &flux.TableObject{
+		Kind:    universe.YieldKind,
+		Spec:    &universe.YieldOpSpec{Name: name},
+		Parents: []*flux.TableObject{parent},
+	}
+	s := &flux.Spec{
+		Operations: []*flux.Operation{
+			{ID: "test0", Spec: testOpSpec{}},
+			{ID: "limit1", Spec: &universe.LimitOpSpec{N: n}},
+			{ID: "yield2", Spec: &universe.YieldOpSpec{Name: name}},
+		},
+		Edges: []flux.Edge{
+			{Parent: "test0", Child: "limit1"},
+			{Parent: "limit1", Child: "yield2"},
+		},
+	}
+	return t, s
+}
+
+func newLimit(n int64) *flux.TableObject {
+	return &flux.TableObject{Kind: universe.LimitKind, Spec: &universe.LimitOpSpec{N: n}}
+}
+
+func TestDedupeQueries(t *testing.T) {
+	shared := newLimit(1)
+	a, sa := limitYield(shared, 1, "a")
+	b, sb := limitYield(shared, 1, "b")
+	c, sc := limitYield(newLimit(1), 1, "c")
+	d, sd := limitYield(newLimit(2), 2, "d")
+	both, sboth := limitYield(newLimit(1), 1, "e")
+	sboth.Operations = append(sboth.Operations, &flux.Operation{ID: "yield3", Spec: &universe.YieldOpSpec{Name: "f"}})
+	sboth.Edges = append(sboth.Edges, flux.Edge{Parent: "limit1", Child: "yield3"})
+
+	ses := []interpreter.SideEffect{
+		{Node: &semantic.ExpressionStatement{}, Value: a},
+		// Side effects other than the table objects of
+		// expression statements have no spec.
+		{Node: &semantic.NativeVariableAssignment{}, Value: shared},
+		{Node: &semantic.ExpressionStatement{}, Value: b},
+		{Node: &semantic.ExpressionStatement{}, Value: c},
+		{Node: &semantic.ExpressionStatement{}, Value: d},
+		{Node: &semantic.ExpressionStatement{}, Value: both},
+	}
+
+	aliases := dedupeQueries(ses, []*flux.Spec{sa, sb, sc, sd, sboth})
+	var got map[string]string
+	for _, alias := range aliases {
+		if alias != nil {
+			got = aliasNames(got, alias)
+		}
+	}
+	// b yields the stream of a and c runs the same operations,
+	// whereas d limits to more rows and the last spec has two results.
+	want := map[string]string{"b": "a", "c": "a"}
+	if !cmp.Equal(want, got) {
+		t.Fatalf("unexpected aliases -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if aliases[0] != nil || aliases[3] != nil || aliases[4] != nil {
+		t.Fatalf("expected the specs that do not repeat a result to be run, got %v", aliases)
+	}
+}
+
+func TestScopeHolder_ResultAliases_Disabled(t *testing.T) {
+	shared := newLimit(1)
+	a, sa := limitYield(shared, 1, "a")
+	b, sb := limitYield(shared, 1, "b")
+	ses := []interpreter.SideEffect{
+		{Node: &semantic.ExpressionStatement{}, Value: a},
+		{Node: &semantic.ExpressionStatement{}, Value: b},
+	}
+
+	r := newTestHolder()
+	for _, alias := range r.resultAliases(ses, []*flux.Spec{sa, sb}) {
+		if alias != nil {
+			t.Fatalf("expected no aliases by default, got %v", alias)
+		}
+	}
+	r = newTestHolder(WithDedupeResults(true))
+	if aliases := r.resultAliases(ses, []*flux.Spec{sa, sb}); aliases[1] == nil {
+		t.Fatal("expected the second result to be an alias of the first")
+	}
+}
@@ -0,0 +1,75 @@
+package repl
+
+import (
+	"context"
+
+	"github.com/influxdata/flux/values"
+)
+
+// WithDefaultBucket sets the bucket that from() reads when a query
+// specifies neither bucket nor bucketID.
+func WithDefaultBucket(bucket string) Option {
+	return option(func(r *ScopeHolder) {
+		r.defaultBucket = bucket
+	})
+}
+
+// WithDefaultOrg sets the organization that from() uses when a query
+// specifies neither org nor orgID.
+func WithDefaultOrg(org string) Option {
+	return option(func(r *ScopeHolder) {
+		r.defaultOrg = org
+	})
+}
+
+// defaultArg is a string argument that is supplied to a function
+// when neither the named parameter nor its ID form was passed.
+type defaultArg struct {
+	name, id string
+	value    string
+}
+
+// bindDefaultSource replaces from() in scope with a version
+// that fills in the default bucket and org.
+// Only the prelude from() is affected; influxdb.from() is left unchanged.
+func (r *ScopeHolder) bindDefaultSource(scope values.Scope) {
+	var defaults []defaultArg
+	if r.defaultBucket != "" {
+		defaults = append(defaults, defaultArg{name: "bucket", id: "bucketID", value: r.defaultBucket})
+	}
+	if r.defaultOrg != "" {
+		defaults = append(defaults, defaultArg{name: "org", id: "orgID", value: r.defaultOrg})
+	}
+	if len(defaults) == 0 {
+		return
+	}
+
+	v, ok := scope.Lookup("from")
+	if !ok {
+		return
+	}
+	fn, ok := v.(values.Function)
+	if !ok {
+		return
+	}
+	scope.Set("from", withDefaultArgs("from", fn, defaults))
+}
+
+// withDefaultArgs wraps fn so that each default is added to the arguments
+// of a call that does not already specify it. Explicit arguments always win.
+func withDefaultArgs(name string, fn values.Function, defaults []defaultArg) values.Function {
+	return values.NewFunction(name, fn.Type(), func(ctx context.Context, args values.Object) (values.Value, error) {
+		vals := make(map[string]values.Value, args.Len()+len(defaults))
+		args.Range(func(k string, v values.Value) {
+			vals[k] = v
+		})
+		for _, d := range defaults {
+			_, hasName := vals[d.name]
+			_, hasID := vals[d.id]
+			if !hasName && !hasID {
+				vals[d.name] = values.NewString(d.value)
+			}
+		}
+		return fn.Call(ctx, values.NewObjectWithValues(vals))
+	}, fn.HasSideEffect())
+}
@@ -0,0 +1,59 @@
+package repl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+func TestWithDefaultArgs(t *testing.T) {
+	defaults := []defaultArg{
+		{name: "bucket", id: "bucketID", value: "default-bucket"},
+		{name: "org", id: "orgID", value: "default-org"},
+	}
+	for _, tt := range []struct {
+		name string
+		args map[string]values.Value
+		want map[string]string
+	}{
+		{
+			name: "no args",
+			want: map[string]string{"bucket": "default-bucket", "org": "default-org"},
+		},
+		{
+			name: "explicit bucket",
+			args: map[string]values.Value{"bucket": values.NewString("b")},
+			want: map[string]string{"bucket": "b", "org": "default-org"},
+		},
+		{
+			name: "explicit ids",
+			args: map[string]values.Value{
+				"bucketID": values.NewString("1234"),
+				"orgID":    values.NewString("5678"),
+			},
+			want: map[string]string{"bucketID": "1234", "orgID": "5678"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]string
+			fn := values.NewFunction("from", semantic.NewFunctionType(semantic.BasicInt, nil), func(ctx context.Context, args values.Object) (values.Value, error) {
+				got = make(map[string]string)
+				args.Range(func(k string, v values.Value) {
+					got[k] = v.Str()
+				})
+				return values.NewInt(0), nil
+			}, false)
+
+			wrapped := withDefaultArgs("from", fn, defaults)
+			if _, err := wrapped.Call(context.Background(), values.NewObjectWithValues(tt.args)); err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(tt.want, got) {
+				t.Fatalf("unexpected arguments -want/+got:\n%s", cmp.Diff(tt.want, got))
+			}
+		})
+	}
+}
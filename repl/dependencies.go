@@ -0,0 +1,31 @@
+package repl
+
+import (
+	"context"
+
+	"github.com/influxdata/flux/dependency"
+)
+
+// WithDependencies injects deps into the evaluation of every line and
+// the execution of every query of the session, after the dependencies
+// of the session context. An embedder can pass a flux.Deps with its own
+// HTTP client, secret service, filesystem or URL validator to control
+// what the side-effecting functions of Flux, such as http.get and
+// secrets.get, are able to reach. A dependency injected later replaces
+// one of the same kind, so deps take the place of any in the context.
+func WithDependencies(deps ...dependency.Interface) Option {
+	return option(func(r *ScopeHolder) {
+		r.deps = append(r.deps, deps...)
+	})
+}
+
+// injectDependencies injects the dependencies of the session into the
+// execution of a query. The returned function must be called once the
+// query has finished.
+func (r *ScopeHolder) injectDependencies(ctx context.Context) (context.Context, func()) {
+	if len(r.deps) == 0 {
+		return ctx, func() {}
+	}
+	ctx, span := dependency.Inject(ctx, r.deps...)
+	return ctx, span.Finish
+}
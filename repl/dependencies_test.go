@@ -0,0 +1,32 @@
+package repl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/mock"
+)
+
+func TestWithDependencies_Inject(t *testing.T) {
+	secrets := mock.SecretService{"token": "s3cr3t"}
+	r := newTestHolder(WithDependencies(flux.Deps{Deps: flux.WrappedDeps{SecretService: secrets}}))
+
+	ctx, finish := r.injectDependencies(context.Background())
+	defer finish()
+	ss, err := flux.GetDependencies(ctx).SecretService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ss.LoadSecret(ctx, "token"); err != nil || got != "s3cr3t" {
+		t.Fatalf("expected the secret of the session dependencies, got %q, %v", got, err)
+	}
+}
+
+func TestWithDependencies_None(t *testing.T) {
+	r := newTestHolder()
+	ctx := context.Background()
+	if got, _ := r.injectDependencies(ctx); got != ctx {
+		t.Fatal("expected the context to be kept without session dependencies")
+	}
+}
@@ -0,0 +1,106 @@
+package repl
+
+import (
+	"strings"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+// DescribeRequest names a function to describe.
+// Members of imported packages are named as package.member.
+type DescribeRequest struct {
+	Name string `json:"name"`
+}
+
+// DescribeResponse is the response to Service.Describe.
+type DescribeResponse struct {
+	Name       string      `json:"name"`
+	Signature  string      `json:"signature"`
+	Parameters []Parameter `json:"parameters,omitempty"`
+}
+
+// Parameter describes a parameter of a function.
+type Parameter struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Pipe     bool   `json:"pipe,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+// Describe reports the signature of a function in scope.
+func (s *Service) Describe(req DescribeRequest, resp *DescribeResponse) error {
+	desc, err := s.r.Describe(req.Name)
+	if err != nil {
+		return err
+	}
+	*resp = desc
+	return nil
+}
+
+// Describe returns the inferred signature and the parameters of the
+// function bound to name in the session scope.
+func (r *ScopeHolder) Describe(name string) (DescribeResponse, error) {
+	r.evalMu.Lock()
+	defer r.evalMu.Unlock()
+
+	v, err := r.lookupPath(name)
+	if err != nil {
+		return DescribeResponse{}, err
+	}
+	typ := v.Type()
+	if typ.Nature() != semantic.Function {
+		return DescribeResponse{}, errors.Newf(codes.Invalid, "%s is not a function: %s", name, typ)
+	}
+	args, err := typ.SortedArguments()
+	if err != nil {
+		return DescribeResponse{}, err
+	}
+	desc := DescribeResponse{
+		Name:       name,
+		Signature:  typ.String(),
+		Parameters: make([]Parameter, 0, len(args)),
+	}
+	for _, arg := range args {
+		argType, err := arg.TypeOf()
+		if err != nil {
+			return DescribeResponse{}, err
+		}
+		desc.Parameters = append(desc.Parameters, Parameter{
+			Name:     string(arg.Name()),
+			Type:     argType.String(),
+			Pipe:     arg.Pipe(),
+			Optional: arg.Optional(),
+		})
+	}
+	return desc, nil
+}
+
+// lookupPath returns the value bound to a name in the session scope,
+// following dots into packages and records.
+func (r *ScopeHolder) lookupPath(name string) (values.Value, error) {
+	parts := strings.Split(name, ".")
+	v, ok := r.scope.Lookup(parts[0])
+	for i := 1; ok && i < len(parts); i++ {
+		if o, isOpt := v.(*values.Option); isOpt {
+			v = o.Value
+		}
+		obj, isObj := v.(interface {
+			Get(name string) (values.Value, bool)
+		})
+		if !isObj {
+			ok = false
+			break
+		}
+		v, ok = obj.Get(parts[i])
+	}
+	if !ok {
+		return nil, errors.Newf(codes.NotFound, "%s is not defined", name)
+	}
+	if o, isOpt := v.(*values.Option); isOpt {
+		v = o.Value
+	}
+	return v, nil
+}
@@ -0,0 +1,57 @@
+package repl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+func TestScopeHolder_Describe(t *testing.T) {
+	typ := semantic.NewFunctionType(semantic.BasicInt, []semantic.ArgumentType{
+		{Name: []byte("x"), Type: semantic.BasicInt},
+		{Name: []byte("name"), Type: semantic.BasicString},
+	})
+	fn := values.NewFunction("add", typ, func(ctx context.Context, args values.Object) (values.Value, error) {
+		return values.NewInt(0), nil
+	}, false)
+
+	r := newTestHolder()
+	r.scope = values.NewScope()
+	r.scope.Set("add", fn)
+	r.scope.Set("pkg", values.NewObjectWithValues(map[string]values.Value{"add": fn}))
+	r.scope.Set("x", values.NewInt(1))
+
+	want := DescribeResponse{
+		Signature: typ.String(),
+		Parameters: []Parameter{
+			{Name: "name", Type: "string"},
+			{Name: "x", Type: "int"},
+		},
+	}
+	for _, name := range []string{"add", "pkg.add"} {
+		got, err := r.Describe(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want.Name = name
+		if !cmp.Equal(want, got) {
+			t.Fatalf("unexpected description of %s -want/+got:\n%s", name, cmp.Diff(want, got))
+		}
+	}
+
+	for name, code := range map[string]codes.Code{
+		"missing":     codes.NotFound,
+		"pkg.missing": codes.NotFound,
+		"x.y":         codes.NotFound,
+		"x":           codes.Invalid,
+	} {
+		if _, err := r.Describe(name); errors.Code(err) != code {
+			t.Errorf("unexpected error describing %s: got %v, want code %v", name, err, code)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package repl
+
+import (
+	"fmt"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/values"
+)
+
+// DiagnosticsResultName is the name of the result
+// that describes the error of an evaluation.
+const DiagnosticsResultName = "_diagnostics"
+
+// WithDiagnostics controls whether EvalTables reports an error as
+// a result of its own rather than failing. When enabled, a failing
+// evaluation returns the results of the queries that completed
+// followed by a result named DiagnosticsResultName, so that a client
+// such as a notebook can render the error along with the data.
+//
+// The diagnostics result holds a table with a severity, message and
+// location column and a row for each error, with its location in
+// the input in the form line:column-line:column when it has one.
+// It is disabled by default.
+func WithDiagnostics(enabled bool) Option {
+	return option(func(r *ScopeHolder) {
+		r.diagnostics = enabled
+	})
+}
+
+// diagnosticsColumns are the columns of the diagnostics table.
+var diagnosticsColumns = []flux.ColMeta{
+	{Label: "severity", Type: flux.TString},
+	{Label: "message", Type: flux.TString},
+	{Label: "location", Type: flux.TString},
+}
+
+// diagnosticsResult returns the diagnostics result that describes err.
+// The errors are those of its detail, or err itself if it has none.
+func diagnosticsResult(err error) TableResult {
+	table := Table{Key: execute.NewGroupKey(nil, nil), Columns: diagnosticsColumns}
+	addRow := func(message, location string) {
+		table.Rows = append(table.Rows, map[string]values.Value{
+			"severity": values.NewString("error"),
+			"message":  values.NewString(message),
+			"location": values.NewString(location),
+		})
+	}
+	detail := newFluxErrorDetail(err)
+	for _, span := range detail.Errors {
+		addRow(span.Message, fmt.Sprintf("%d:%d-%d:%d", span.Start.Line, span.Start.Column, span.End.Line, span.End.Column))
+	}
+	if len(table.Rows) == 0 {
+		addRow(detail.Message, "")
+	}
+	return TableResult{Name: DiagnosticsResultName, Tables: []Table{table}}
+}
@@ -0,0 +1,43 @@
+package repl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func diagnosticsRows(res TableResult) [][]string {
+	var rows [][]string
+	for _, row := range res.Tables[0].Rows {
+		rows = append(rows, []string{row["severity"].Str(), row["message"].Str(), row["location"].Str()})
+	}
+	return rows
+}
+
+func TestDiagnosticsResult(t *testing.T) {
+	err := errors.New(codes.Invalid, "error @1:1-1:2: undefined identifier x\n\nerror @2:5-2:9: expected int, got string")
+	res := diagnosticsResult(err)
+	if res.Name != DiagnosticsResultName {
+		t.Fatalf("unexpected result name: %s", res.Name)
+	}
+	want := [][]string{
+		{"error", "undefined identifier x", "1:1-1:2"},
+		{"error", "expected int, got string", "2:5-2:9"},
+	}
+	if got := diagnosticsRows(res); !cmp.Equal(want, got) {
+		t.Fatalf("unexpected diagnostics -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestDiagnosticsResult_NoLocation(t *testing.T) {
+	res := diagnosticsResult(errors.New(codes.Internal, "query failed"))
+	want := [][]string{{"error", "query failed", ""}}
+	if got := diagnosticsRows(res); !cmp.Equal(want, got) {
+		t.Fatalf("unexpected diagnostics -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if got, want := len(res.Tables[0].Columns), 3; got != want {
+		t.Fatalf("unexpected number of columns: got %d, want %d", got, want)
+	}
+}
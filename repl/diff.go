@@ -0,0 +1,323 @@
+package repl
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+// MaxDiffRows is the largest number of rows that the queries of each
+// side of a diff may read into memory, unless the row limit of the
+// session is lower.
+const MaxDiffRows = 100000
+
+// DiffColumn is the column of a diff table that tells whether a row
+// was removed, with "-", or added, with "+". A row that changed is
+// shown as removed and added again with its new values.
+const DiffColumn = "_diff"
+
+// DiffRequest is the params object for Service.Diff. The queries of
+// Before are compared with those of After or, when After is empty, with
+// themselves run as of BeforeNow and AfterNow.
+type DiffRequest struct {
+	Before    string     `json:"before"`
+	After     string     `json:"after,omitempty"`
+	BeforeNow *time.Time `json:"beforeNow,omitempty"`
+	AfterNow  *time.Time `json:"afterNow,omitempty"`
+	// On names the columns that identify a row within its table,
+	// as described by ScopeHolder.Diff.
+	On []string `json:"on,omitempty"`
+}
+
+// DiffResponse is the response to Service.Diff.
+type DiffResponse struct {
+	Results []DiffOutput `json:"results"`
+}
+
+// DiffOutput is the difference between the results of a name.
+type DiffOutput struct {
+	Name    string `json:"name"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	Changed int    `json:"changed"`
+	// Rows holds the rows of the diff table by column label.
+	Rows []map[string]interface{} `json:"rows"`
+}
+
+// Diff returns the difference between the results of two queries, or
+// of one query at two now times, as described by ScopeHolder.Diff.
+func (s *Service) Diff(req DiffRequest, resp *DiffResponse) error {
+	var (
+		diffs []ResultDiff
+		err   error
+	)
+	switch {
+	case req.After != "":
+		if req.BeforeNow != nil || req.AfterNow != nil {
+			return errors.New(codes.Invalid, "now times are only allowed when a query is compared with itself")
+		}
+		diffs, err = s.r.Diff(s.r.ctx, req.Before, req.After, req.On)
+	case req.BeforeNow != nil && req.AfterNow != nil:
+		diffs, err = s.r.DiffAt(s.r.ctx, req.Before, *req.BeforeNow, *req.AfterNow, req.On)
+	default:
+		return errors.New(codes.Invalid, "either after or both beforeNow and afterNow are required")
+	}
+	if err != nil {
+		return err
+	}
+	outputs := make([]DiffOutput, len(diffs))
+	for i, d := range diffs {
+		out := DiffOutput{Name: d.Name, Added: d.Added, Removed: d.Removed, Changed: d.Changed}
+		for _, row := range d.Table.Rows {
+			r := make(map[string]interface{}, len(row))
+			for label, v := range row {
+				r[label] = diffValue(v)
+			}
+			out.Rows = append(out.Rows, r)
+		}
+		outputs[i] = out
+	}
+	*resp = DiffResponse{Results: outputs}
+	return nil
+}
+
+// diffValue converts v into a value that encodes to JSON.
+func diffValue(v values.Value) interface{} {
+	if v.IsNull() {
+		return nil
+	}
+	switch v.Type().Nature() {
+	case semantic.Time:
+		return v.Time().Time()
+	case semantic.Duration:
+		return v.Duration().String()
+	}
+	return values.Unwrap(v)
+}
+
+// ResultDiff is the difference between two results of the same name.
+type ResultDiff struct {
+	Name string
+	// Added, Removed and Changed count the rows
+	// that only the second result has, those that only
+	// the first result has and those that differ.
+	Added, Removed, Changed int
+	// Table holds the rows that differ, with the DiffColumn
+	// ahead of the columns of either result.
+	Table Table
+}
+
+// Diff runs the queries of the Flux sources before and after as of
+// the same now and returns the rows that differ between their results,
+// so that the effect of a change to a query can be checked.
+//
+// Results are compared by name, and their tables by group key. Within
+// a table, a row is identified by its values in the on columns, _time
+// by default, and the rows that share them are paired in order. The
+// rows of tables that have none of the on columns are paired in order.
+// Rows whose values differ in any column are reported as changed.
+//
+// Names bound by the sources are discarded afterwards.
+// The rows of each source are limited to MaxDiffRows.
+func (r *ScopeHolder) Diff(ctx context.Context, before, after string, on []string) ([]ResultDiff, error) {
+	ctx, end := r.beginLine(ctx)
+	defer end()
+	now, err := r.currentNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.diffSide(ctx, before, now)
+	if err != nil {
+		return nil, errors.Wrap(err, codes.Inherit, "failed to run the first query")
+	}
+	a, err := r.diffSide(ctx, after, now)
+	if err != nil {
+		return nil, errors.Wrap(err, codes.Inherit, "failed to run the second query")
+	}
+	return diffResults(b, a, on), nil
+}
+
+// DiffAt runs the queries of the Flux source t as of the now times
+// before and after, and returns the rows that differ between their
+// results as Diff does, so that changes to the data that a query reads
+// over time can be detected.
+func (r *ScopeHolder) DiffAt(ctx context.Context, t string, before, after time.Time, on []string) ([]ResultDiff, error) {
+	var (
+		results [2][]TableResult
+		rows    [2]int
+	)
+	if err := r.evalAt(withRowLimit(ctx, MaxDiffRows), t, []time.Time{before, after}, func(ctx context.Context, i int, s *flux.Spec) error {
+		queryResults, queryRows, err := r.runTables(ctx, s, rows[i])
+		if err != nil {
+			return err
+		}
+		results[i] = append(results[i], queryResults...)
+		rows[i] = queryRows
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return diffResults(results[0], results[1], on), nil
+}
+
+// diffSide evaluates t in a nested scope and
+// reads the results of its queries as of now.
+func (r *ScopeHolder) diffSide(ctx context.Context, t string, now time.Time) ([]TableResult, error) {
+	var (
+		results []TableResult
+		rows    int
+	)
+	err := r.evalAt(withRowLimit(ctx, MaxDiffRows), t, []time.Time{now}, func(ctx context.Context, _ int, s *flux.Spec) error {
+		queryResults, queryRows, err := r.runTables(ctx, s, rows)
+		if err != nil {
+			return err
+		}
+		results, rows = append(results, queryResults...), queryRows
+		return nil
+	})
+	return results, err
+}
+
+// diffResults compares the results of before and after by name, in the
+// order of before and then of the results that only after has.
+func diffResults(before, after []TableResult, on []string) []ResultDiff {
+	if on == nil {
+		on = []string{execute.DefaultTimeColLabel}
+	}
+	afterByName := make(map[string]TableResult, len(after))
+	for _, res := range after {
+		afterByName[res.Name] = res
+	}
+	seen := make(map[string]bool, len(before))
+	var diffs []ResultDiff
+	for _, res := range before {
+		seen[res.Name] = true
+		diffs = append(diffs, diffTables(res.Name, res.Tables, afterByName[res.Name].Tables, on))
+	}
+	for _, res := range after {
+		if !seen[res.Name] {
+			diffs = append(diffs, diffTables(res.Name, nil, res.Tables, on))
+		}
+	}
+	return diffs
+}
+
+// keyedRow is a row along with the string that identifies it.
+type keyedRow struct {
+	id  string
+	row map[string]values.Value
+}
+
+// diffTables compares the rows of the tables of the result name.
+func diffTables(name string, before, after []Table, on []string) ResultDiff {
+	d := ResultDiff{Name: name}
+	cols := []flux.ColMeta{{Label: DiffColumn, Type: flux.TString}}
+	have := map[string]bool{DiffColumn: true}
+	for _, tables := range [][]Table{before, after} {
+		for _, tbl := range tables {
+			for _, c := range tbl.Columns {
+				if !have[c.Label] {
+					have[c.Label] = true
+					cols = append(cols, c)
+				}
+			}
+		}
+	}
+	d.Table = Table{Key: execute.NewGroupKey(nil, nil), Columns: cols}
+
+	emit := func(op string, row map[string]values.Value) {
+		out := make(map[string]values.Value, len(row)+1)
+		for label, v := range row {
+			out[label] = v
+		}
+		out[DiffColumn] = values.NewString(op)
+		d.Table.Rows = append(d.Table.Rows, out)
+	}
+
+	afterRows := keyRows(after, on)
+	byID := make(map[string]map[string]values.Value, len(afterRows))
+	for _, kr := range afterRows {
+		byID[kr.id] = kr.row
+	}
+	matched := make(map[string]bool)
+	for _, kr := range keyRows(before, on) {
+		a, ok := byID[kr.id]
+		if !ok {
+			d.Removed++
+			emit("-", kr.row)
+			continue
+		}
+		matched[kr.id] = true
+		if !equalRows(kr.row, a) {
+			d.Changed++
+			emit("-", kr.row)
+			emit("+", a)
+		}
+	}
+	for _, kr := range afterRows {
+		if !matched[kr.id] {
+			d.Added++
+			emit("+", kr.row)
+		}
+	}
+	return d
+}
+
+// keyRows identifies each row of tables by its group key, its values in
+// the on columns and how many rows of the same table came before it
+// with the same values.
+func keyRows(tables []Table, on []string) []keyedRow {
+	var rows []keyedRow
+	for _, tbl := range tables {
+		counts := make(map[string]int)
+		for _, row := range tbl.Rows {
+			var sb strings.Builder
+			sb.WriteString(tbl.Key.String())
+			for _, label := range on {
+				if v, ok := row[label]; ok {
+					sb.WriteString("|" + label + "=" + v.Type().String() + ":")
+					if !v.IsNull() {
+						sb.WriteString(values.DisplayString(v))
+					}
+				}
+			}
+			id := sb.String()
+			n := counts[id]
+			counts[id]++
+			rows = append(rows, keyedRow{id: id + "#" + strconv.Itoa(n), row: row})
+		}
+	}
+	return rows
+}
+
+// equalRows reports whether a and b have the same values.
+func equalRows(a, b map[string]values.Value) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for label, v := range a {
+		w, ok := b[label]
+		if !ok || !equalValues(v, w) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalValues(v, w values.Value) bool {
+	if v.IsNull() || w.IsNull() {
+		return v.IsNull() && w.IsNull()
+	}
+	if v.Type().Nature() != w.Type().Nature() {
+		return false
+	}
+	return v.Equal(w)
+}
@@ -0,0 +1,118 @@
+package repl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/values"
+)
+
+// diffTestTable returns a table of host a with a row at each second
+// from the start of 2021 for each of vs.
+func diffTestTable(host string, vs ...int64) Table {
+	key := execute.NewGroupKey(
+		[]flux.ColMeta{{Label: "host", Type: flux.TString}},
+		[]values.Value{values.NewString(host)},
+	)
+	tbl := Table{Key: key, Columns: []flux.ColMeta{
+		{Label: "_time", Type: flux.TTime},
+		{Label: "host", Type: flux.TString},
+		{Label: "_value", Type: flux.TInt},
+	}}
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, v := range vs {
+		tbl.Rows = append(tbl.Rows, map[string]values.Value{
+			"_time":  values.NewTime(values.ConvertTime(start.Add(time.Duration(i) * time.Second))),
+			"host":   values.NewString(host),
+			"_value": values.NewInt(v),
+		})
+	}
+	return tbl
+}
+
+// diffRows returns the diff column and value of each row of d.
+func diffRows(d ResultDiff) []string {
+	var rows []string
+	for _, row := range d.Table.Rows {
+		rows = append(rows, row[DiffColumn].Str()+values.DisplayString(row["_value"]))
+	}
+	return rows
+}
+
+func TestDiffResults_Changed(t *testing.T) {
+	before := []TableResult{{Name: "_result", Tables: []Table{diffTestTable("a", 1, 2, 3)}}}
+	after := []TableResult{{Name: "_result", Tables: []Table{diffTestTable("a", 1, 2, 4)}}}
+
+	diffs := diffResults(before, after, nil)
+	if len(diffs) != 1 {
+		t.Fatalf("expected one result, got %d", len(diffs))
+	}
+	d := diffs[0]
+	if d.Added != 0 || d.Removed != 0 || d.Changed != 1 {
+		t.Fatalf("expected one changed row, got %+v", d)
+	}
+	if want, got := []string{"-3", "+4"}, diffRows(d); !cmp.Equal(want, got) {
+		t.Fatalf("unexpected diff rows -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if got := d.Table.Columns[0].Label; got != DiffColumn {
+		t.Fatalf("expected the diff column first, got %s", got)
+	}
+}
+
+func TestDiffResults_AddedRemoved(t *testing.T) {
+	before := []TableResult{{Name: "_result", Tables: []Table{diffTestTable("a", 1, 2)}}}
+	after := []TableResult{{Name: "_result", Tables: []Table{diffTestTable("a", 1, 2, 3), diffTestTable("b", 5)}}}
+
+	d := diffResults(before, after, nil)[0]
+	if d.Added != 2 || d.Removed != 0 || d.Changed != 0 {
+		t.Fatalf("expected two added rows, got %+v", d)
+	}
+	if want, got := []string{"+3", "+5"}, diffRows(d); !cmp.Equal(want, got) {
+		t.Fatalf("unexpected diff rows -want/+got:\n%s", cmp.Diff(want, got))
+	}
+
+	d = diffResults(after, before, nil)[0]
+	if d.Added != 0 || d.Removed != 2 || d.Changed != 0 {
+		t.Fatalf("expected two removed rows, got %+v", d)
+	}
+}
+
+func TestDiffResults_Position(t *testing.T) {
+	// Without any of the on columns, rows are paired in order.
+	before := []TableResult{{Name: "_result", Tables: []Table{diffTestTable("a", 1, 2)}}}
+	after := []TableResult{{Name: "_result", Tables: []Table{diffTestTable("a", 2, 2)}}}
+
+	d := diffResults(before, after, []string{"missing"})[0]
+	if d.Changed != 1 {
+		t.Fatalf("expected the first row to change, got %+v", d)
+	}
+	if want, got := []string{"-1", "+2"}, diffRows(d); !cmp.Equal(want, got) {
+		t.Fatalf("unexpected diff rows -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestDiffResults_Names(t *testing.T) {
+	before := []TableResult{{Name: "a", Tables: []Table{diffTestTable("a", 1)}}}
+	after := []TableResult{{Name: "b", Tables: []Table{diffTestTable("a", 1)}}}
+
+	diffs := diffResults(before, after, nil)
+	if len(diffs) != 2 || diffs[0].Name != "a" || diffs[1].Name != "b" {
+		t.Fatalf("expected a diff for each name, got %+v", diffs)
+	}
+	if diffs[0].Removed != 1 || diffs[1].Added != 1 {
+		t.Fatalf("expected the rows of a result of one side only to differ, got %+v", diffs)
+	}
+}
+
+func TestWithRowLimit(t *testing.T) {
+	ctx := withRowLimit(newTestHolder().ctx, 10)
+	if got := newTestHolder().tableRowLimit(ctx); got != 10 {
+		t.Fatalf("expected the limit of the context, got %d", got)
+	}
+	if got := newTestHolder(WithRowLimit(5)).tableRowLimit(ctx); got != 5 {
+		t.Fatalf("expected the lower limit of the session, got %d", got)
+	}
+}
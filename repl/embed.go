@@ -0,0 +1,83 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/values"
+)
+
+// NewEmbedded creates a ScopeHolder for use as a library
+// from within a Go program rather than over JSON-RPC.
+func NewEmbedded(opts ...Option) *ScopeHolder {
+	return New(context.Background(), opts...)
+}
+
+// EvalString evaluates the Flux source t in the session scope.
+// The output of every expression statement, including the tables
+// produced by any queries, is collected into the returned Result.
+func (r *ScopeHolder) EvalString(ctx context.Context, t string) (Result, error) {
+	ctx, end := r.beginLine(ctx)
+	defer end()
+	return r.evalString(ctx, t, r.scope)
+}
+
+// evalString evaluates t in scope and collects its output like EvalString.
+func (r *ScopeHolder) evalString(ctx context.Context, t string, scope values.Scope) (Result, error) {
+	ctx, end := r.beginLine(ctx)
+	defer end()
+	ctx, stop := r.watch(ctx)
+	defer stop()
+
+	ses, _, err := r.evalInScope(ctx, t, scope)
+	if err != nil {
+		return Result{}, err
+	}
+	return r.collectResult(ctx, ses)
+}
+
+// collectResult runs the queries of the expression statements of an
+// evaluated source and returns their output along with that of the
+// other expression statements.
+func (r *ScopeHolder) collectResult(ctx context.Context, ses []interpreter.SideEffect) (Result, error) {
+	var c resultCollector
+	if err := r.runExpressions(ctx, ses, nil, &c); err != nil {
+		return Result{}, err
+	}
+	res := c.res
+	res.Output = c.buf.String()
+	res.LiveSources = statsLiveSources(res.Stats)
+	res.QueryIDs = statsQueryIDs(res.Stats)
+	res.ColumnStats = statsColumnStats(res.Stats)
+	res.ResultRows = statsResultRows(res.Stats)
+	res.Rows = totalRows(res.ResultRows)
+	res.EmptyResults = statsEmptyResults(res.Stats)
+	res.SessionMemory = r.MemoryUsage()
+	return res, nil
+}
+
+// resultCollector gathers the output of the expression statements
+// of an evaluated source into a Result.
+type resultCollector struct {
+	res Result
+	buf bytes.Buffer
+}
+
+func (c *resultCollector) value(typ, text string) {
+	c.res.Type = typ
+	c.buf.WriteString(text)
+	c.buf.WriteByte('\n')
+}
+
+func (c *resultCollector) tables(typ, text string, stats flux.Statistics) {
+	c.res.Type = typ
+	c.buf.WriteString(text)
+	c.res.Stats = c.res.Stats.Add(stats)
+}
+
+func (c *resultCollector) alias(typ string, alias *resultAlias) {
+	c.res.Type = typ
+	c.res.Aliases = aliasNames(c.res.Aliases, alias)
+}
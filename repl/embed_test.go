@@ -0,0 +1,44 @@
+package repl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+func TestScopeHolder_CollectResult(t *testing.T) {
+	ses := []interpreter.SideEffect{
+		{Node: &semantic.ExpressionStatement{}, Value: values.NewInt(1)},
+		{Node: &semantic.NativeVariableAssignment{}, Value: values.NewInt(2)},
+		{Node: &semantic.ExpressionStatement{}, Value: values.NewString("a")},
+	}
+	r := newTestHolder()
+
+	// The lines of a session and EvalString see the same output.
+	line, err := r.runSideEffects(context.Background(), ses, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Output{
+		{Kind: OutputValue, Type: "int", Text: "1"},
+		{Kind: OutputValue, Type: "string", Text: "a"},
+	}
+	if !cmp.Equal(want, line.output) {
+		t.Fatalf("unexpected line output -want/+got:\n%s", cmp.Diff(want, line.output))
+	}
+
+	res, err := r.collectResult(context.Background(), ses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := res.Output, "1\na\n"; got != want {
+		t.Fatalf("unexpected output: got %q, want %q", got, want)
+	}
+	if got, want := res.Type, "string"; got != want {
+		t.Fatalf("unexpected type: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,64 @@
+package repl
+
+import (
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/metadata"
+)
+
+// emptyResultsKey is the statistics metadata key under which
+// the names of the results that produced no rows are recorded.
+const emptyResultsKey = "flux/empty-results"
+
+// WithEmptyResults controls whether the results of a query that
+// produce no rows are named along with its output, so that a client
+// can tell a result with no data apart from one that was never run.
+// The results a query is expected to produce are those of its yields,
+// and "_result" for a stream that is not yielded, as reported by
+// Yields. A result that the query does not produce at all is empty
+// like one whose tables have no rows.
+// It is disabled by default.
+func WithEmptyResults(enabled bool) Option {
+	return option(func(r *ScopeHolder) {
+		r.emptyResults = enabled
+	})
+}
+
+// statsEmptyResults returns the names of the empty results
+// recorded in stats, in the order they are expected.
+func statsEmptyResults(stats flux.Statistics) []string {
+	var names []string
+	for _, v := range stats.Metadata.GetAll(emptyResultsKey) {
+		if name, ok := v.(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// addEmptyResults records in stats the results expected of spec that
+// produced no rows according to rows, if the session reports them.
+func (r *ScopeHolder) addEmptyResults(stats flux.Statistics, spec *flux.Spec, rows *rowCounter) flux.Statistics {
+	if !r.emptyResults {
+		return stats
+	}
+	expected, err := yieldNames(spec)
+	if err != nil {
+		return stats
+	}
+	produced := make(map[string]int64, len(rows.results))
+	for _, rr := range rows.results {
+		produced[rr.Name] += rr.Rows
+	}
+	seen := make(map[string]bool, len(expected))
+	for _, name := range expected {
+		if seen[name] || produced[name] > 0 {
+			continue
+		}
+		seen[name] = true
+		if stats.Metadata == nil {
+			stats.Metadata = make(metadata.Metadata)
+		}
+		stats.Metadata.Add(emptyResultsKey, name)
+	}
+	return stats
+}
@@ -0,0 +1,52 @@
+package repl
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/stdlib/universe"
+)
+
+func TestAddEmptyResults(t *testing.T) {
+	s := &flux.Spec{
+		Operations: []*flux.Operation{
+			{ID: "test0", Spec: testOpSpec{}},
+			{ID: "yield1", Spec: &universe.YieldOpSpec{Name: "full"}},
+			{ID: "test2", Spec: testOpSpec{}},
+			{ID: "yield3", Spec: &universe.YieldOpSpec{Name: "none"}},
+			{ID: "test4", Spec: testOpSpec{}},
+			{ID: "yield5", Spec: &universe.YieldOpSpec{Name: "missing"}},
+		},
+		Edges: []flux.Edge{
+			{Parent: "test0", Child: "yield1"},
+			{Parent: "test2", Child: "yield3"},
+			{Parent: "test4", Child: "yield5"},
+		},
+	}
+	// The result "missing" was never produced.
+	rows := &rowCounter{results: []*ResultRows{
+		{Name: "full", Rows: 2},
+		{Name: "none"},
+	}}
+
+	got := statsEmptyResults(newTestHolder(WithEmptyResults(true)).addEmptyResults(flux.Statistics{}, s, rows))
+	sort.Strings(got)
+	if want := []string{"missing", "none"}; !cmp.Equal(want, got) {
+		t.Fatalf("unexpected empty results -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if got := statsEmptyResults(newTestHolder().addEmptyResults(flux.Statistics{}, s, rows)); got != nil {
+		t.Fatalf("expected no empty results unless enabled, got %v", got)
+	}
+}
+
+func TestLineResult_Response_EmptyResults(t *testing.T) {
+	var resp Response
+	if err := (lineResult{empty: []string{"a"}}).response(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a"}; !cmp.Equal(want, resp.EmptyResults) {
+		t.Fatalf("unexpected empty results -want/+got:\n%s", cmp.Diff(want, resp.EmptyResults))
+	}
+}
@@ -0,0 +1,173 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/internal/spec"
+	"github.com/influxdata/flux/semantic"
+)
+
+// MaxEvalAtTimes is the largest number of now times
+// that a source is evaluated at by EvalAt.
+const MaxEvalAtTimes = 1000
+
+// EvalAtRequest is the params object for Service.EvalAt.
+// The input is evaluated at each time in nows or, when nows is
+// empty, at each time from start to stop stepped by every.
+type EvalAtRequest struct {
+	Input string      `json:"input"`
+	Nows  []time.Time `json:"nows,omitempty"`
+	Start time.Time   `json:"start"`
+	Stop  time.Time   `json:"stop"`
+	// Every is a duration such as "1h30m".
+	Every string `json:"every,omitempty"`
+}
+
+// EvalAtResponse is the response to Service.EvalAt.
+type EvalAtResponse struct {
+	Runs []NowOutput `json:"runs"`
+}
+
+// NowOutput is the output of the queries of an input evaluated at Now.
+type NowOutput struct {
+	Now    time.Time `json:"now"`
+	Output string    `json:"output"`
+}
+
+// EvalAt evaluates the input at several now times
+// as described by ScopeHolder.EvalAt.
+func (s *Service) EvalAt(req EvalAtRequest, resp *EvalAtResponse) error {
+	nows := req.Nows
+	if len(nows) == 0 {
+		every, err := time.ParseDuration(req.Every)
+		if err != nil {
+			return errors.Wrap(err, codes.Invalid, "malformed params: invalid every")
+		}
+		if nows, err = NowSteps(req.Start, req.Stop, every); err != nil {
+			return err
+		}
+	}
+	bufs := make([]bytes.Buffer, len(nows))
+	if err := s.r.evalAt(s.r.ctx, req.Input, nows, func(ctx context.Context, i int, sp *flux.Spec) error {
+		_, err := s.r.doQuery(ctx, sp, []ResultSink{{Writer: &bufs[i], Encoder: resultFormatter{r: s.r}}})
+		return err
+	}); err != nil {
+		return err
+	}
+	runs := make([]NowOutput, len(nows))
+	for i, now := range nows {
+		runs[i] = NowOutput{Now: now, Output: bufs[i].String()}
+	}
+	*resp = EvalAtResponse{Runs: runs}
+	return nil
+}
+
+// NowResult is the results of the queries of a source evaluated at Now.
+type NowResult struct {
+	Now     time.Time
+	Results []TableResult
+}
+
+// NowSteps returns the times from start to stop, both included,
+// stepped by every, for use with EvalAt.
+func NowSteps(start, stop time.Time, every time.Duration) ([]time.Time, error) {
+	if every <= 0 {
+		return nil, errors.Newf(codes.Invalid, "every must be positive, got %s", every)
+	}
+	if stop.Before(start) {
+		return nil, errors.Newf(codes.Invalid, "stop %s is before start %s", stop, start)
+	}
+	if n := stop.Sub(start)/every + 1; n > MaxEvalAtTimes {
+		return nil, errors.Newf(codes.Invalid, "%d now times exceed the limit of %d", n, MaxEvalAtTimes)
+	}
+	var nows []time.Time
+	for now := start; !now.After(stop); now = now.Add(every) {
+		nows = append(nows, now)
+	}
+	return nows, nil
+}
+
+// EvalAt evaluates the Flux source t once and runs the queries it
+// produces as of each of nows, so that a query over a range relative to
+// now can be seen as it would have evaluated at each of those times.
+// The results are returned for each time, in order, with their rows read
+// into memory as EvalTables does. The row limit applies to each time.
+//
+// Names bound by t are discarded afterwards. Expression statements that
+// do not produce tables are evaluated but not returned. At most
+// MaxEvalAtTimes times may be given.
+func (r *ScopeHolder) EvalAt(ctx context.Context, t string, nows []time.Time) ([]NowResult, error) {
+	results := make([]NowResult, len(nows))
+	rows := make([]int, len(nows))
+	for i, now := range nows {
+		results[i].Now = now
+	}
+	if err := r.evalAt(ctx, t, nows, func(ctx context.Context, i int, s *flux.Spec) error {
+		queryResults, queryRows, err := r.runTables(ctx, s, rows[i])
+		if err != nil {
+			return err
+		}
+		results[i].Results = append(results[i].Results, queryResults...)
+		rows[i] = queryRows
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// evalAt evaluates t in a scope nested within the session scope and
+// calls run with the index of each of nows and the spec of each query
+// of t as of that time, in the context of the evaluation.
+func (r *ScopeHolder) evalAt(ctx context.Context, t string, nows []time.Time, run func(ctx context.Context, i int, s *flux.Spec) error) error {
+	if len(nows) == 0 {
+		return errors.New(codes.Invalid, "at least one now time is required")
+	}
+	if len(nows) > MaxEvalAtTimes {
+		return errors.Newf(codes.Invalid, "%d now times exceed the limit of %d", len(nows), MaxEvalAtTimes)
+	}
+
+	ctx, end := r.beginLine(ctx)
+	defer end()
+	ctx, stop := r.watch(ctx)
+	defer stop()
+
+	ses, err := r.evalNested(ctx, t)
+	if err != nil {
+		return err
+	}
+	var tables []*flux.TableObject
+	for _, se := range ses {
+		if _, ok := se.Node.(*semantic.ExpressionStatement); !ok {
+			continue
+		}
+		if to, ok := se.Value.(*flux.TableObject); ok {
+			tables = append(tables, to)
+		}
+	}
+
+	for i, now := range nows {
+		specs := make([]*flux.Spec, len(tables))
+		for j, to := range tables {
+			s, err := spec.FromTableObject(ctx, to, now)
+			if err != nil {
+				return err
+			}
+			specs[j] = s
+		}
+		if err := checkYields(specs, r.disambiguateYields); err != nil {
+			return err
+		}
+		for _, s := range specs {
+			if err := run(ctx, i, s); err != nil {
+				return errors.Wrapf(err, codes.Inherit, "failed to run the query at %s", now.Format(time.RFC3339Nano))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package repl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func TestNowSteps(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	got, err := NowSteps(start, start.Add(2*time.Hour), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{start, start.Add(time.Hour), start.Add(2 * time.Hour)}
+	if !cmp.Equal(want, got) {
+		t.Fatalf("unexpected steps -want/+got:\n%s", cmp.Diff(want, got))
+	}
+
+	for _, tt := range []struct {
+		name  string
+		stop  time.Time
+		every time.Duration
+	}{
+		{name: "zero every", stop: start.Add(time.Hour), every: 0},
+		{name: "stop before start", stop: start.Add(-time.Hour), every: time.Hour},
+		{name: "too many", stop: start.Add(MaxEvalAtTimes * time.Minute), every: time.Minute},
+	} {
+		if _, err := NowSteps(start, tt.stop, tt.every); errors.Code(err) != codes.Invalid {
+			t.Errorf("%s: expected an invalid error, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestScopeHolder_EvalAt_Bounds(t *testing.T) {
+	r := newTestHolder()
+	for _, n := range []int{0, MaxEvalAtTimes + 1} {
+		if _, err := r.EvalAt(context.Background(), "x = 1", make([]time.Time, n)); errors.Code(err) != codes.Invalid {
+			t.Fatalf("expected an invalid error for %d now times, got %v", n, err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+)
+
+// WithPanicOutput sets where the stack of an evaluation that panics is
+// written, for diagnosis. It defaults to os.Stderr. The evaluation
+// fails with an internal error, rather than crashing the process along
+// with every other session served by it.
+func WithPanicOutput(w io.Writer) Option {
+	return option(func(r *ScopeHolder) {
+		r.panicOutput = w
+	})
+}
+
+// EvalPanic is the cause of the error of an evaluation that panicked.
+// It is found in the error with errors.As.
+type EvalPanic struct {
+	Value interface{}
+	// Stack is the stack of the goroutine that panicked.
+	Stack []byte
+}
+
+func (e *EvalPanic) Error() string {
+	return fmt.Sprintf("interpreter panicked: %v", e.Value)
+}
+
+// recoverEval calls eval and returns an internal error in place of a
+// panic, after writing the panic and its stack to the panic output.
+func (r *ScopeHolder) recoverEval(eval func() ([]interpreter.SideEffect, error)) (ses []interpreter.SideEffect, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			p := &EvalPanic{Value: v, Stack: debug.Stack()}
+			out := r.panicOutput
+			if out == nil {
+				out = os.Stderr
+			}
+			fmt.Fprintf(out, "Error: %s\n%s", p, p.Stack)
+			ses, err = nil, errors.Wrap(p, codes.Internal)
+		}
+	}()
+	return eval()
+}
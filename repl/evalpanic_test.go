@@ -0,0 +1,49 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+)
+
+func TestRecoverEval(t *testing.T) {
+	var out bytes.Buffer
+	r := newTestHolder(WithPanicOutput(&out))
+
+	ses, err := r.recoverEval(func() ([]interpreter.SideEffect, error) {
+		panic("malformed graph")
+	})
+	if ses != nil {
+		t.Fatalf("expected no side effects, got %v", ses)
+	}
+	if got := errors.Code(err); got != codes.Internal {
+		t.Fatalf("expected an internal error, got %v: %v", got, err)
+	}
+	var p *EvalPanic
+	if !errors.As(err, &p) || p.Value != "malformed graph" || len(p.Stack) == 0 {
+		t.Fatalf("expected the panic value and stack in the error, got %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "interpreter panicked: malformed graph") || !strings.Contains(got, "goroutine") {
+		t.Fatalf("expected the panic to be logged with its stack, got:\n%s", got)
+	}
+}
+
+func TestRecoverEval_NoPanic(t *testing.T) {
+	var out bytes.Buffer
+	r := newTestHolder(WithPanicOutput(&out))
+
+	want := []interpreter.SideEffect{{}}
+	ses, err := r.recoverEval(func() ([]interpreter.SideEffect, error) {
+		return want, nil
+	})
+	if err != nil || len(ses) != 1 {
+		t.Fatalf("expected the side effects of the evaluation, got %v, %v", ses, err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("expected nothing to be logged, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,24 @@
+package repl_test
+
+import (
+	"context"
+	"fmt"
+
+	_ "github.com/influxdata/flux/fluxinit/static"
+	"github.com/influxdata/flux/repl"
+)
+
+func ExampleScopeHolder_EvalString() {
+	r := repl.NewEmbedded()
+	ctx := context.Background()
+
+	if _, err := r.EvalString(ctx, "x = 40"); err != nil {
+		panic(err)
+	}
+	res, err := r.EvalString(ctx, "x + 2")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(res.Type, ": ", res.Output)
+	// Output: int: 42
+}
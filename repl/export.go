@@ -0,0 +1,258 @@
+package repl
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+// ExportRequest is the params object for Service.Export.
+type ExportRequest struct {
+	// Pattern limits the export to the bindings whose names match it,
+	// as described by ExportMatching. Every binding is exported when
+	// it is empty.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// ExportResponse is the response to Service.Export.
+type ExportResponse struct {
+	Script string `json:"script"`
+}
+
+// Export returns the session bindings as a Flux script.
+func (s *Service) Export(req ExportRequest, resp *ExportResponse) error {
+	*resp = ExportResponse{Script: s.r.ExportMatching(req.Pattern)}
+	return nil
+}
+
+// Export returns a Flux script that recreates the bindings made in the
+// session, excluding those from the prelude. Each binding is written as
+// a name = <literal> statement, in order of name, so exporting the same
+// session twice gives identical scripts. Bindings whose values have no
+// literal form, such as functions and streams, are skipped with a comment
+// explaining why.
+func (r *ScopeHolder) Export() string {
+	return r.ExportMatching("")
+}
+
+// ExportMatching is like Export, but only writes the bindings whose
+// names match pattern. A pattern without a * matches the names that
+// start with it, whereas in one with a * each * matches any run of
+// characters and the rest of the pattern must match the whole name.
+// An empty pattern matches every name.
+func (r *ScopeHolder) ExportMatching(pattern string) string {
+	r.evalMu.Lock()
+	defer r.evalMu.Unlock()
+
+	var sb strings.Builder
+	for _, b := range matchBindings(localBindings(r.scope), pattern) {
+		v, opt := b.v, ""
+		if o, ok := v.(*values.Option); ok {
+			v, opt = o.Value, "option "
+		}
+		lit, err := fluxLiteral(v)
+		if err != nil {
+			fmt.Fprintf(&sb, "// %s was skipped: %s\n", b.name, err)
+			continue
+		}
+		fmt.Fprintf(&sb, "%s%s = %s\n", opt, b.name, lit)
+	}
+	return sb.String()
+}
+
+// binding is a name and the value bound to it in a scope.
+type binding struct {
+	name string
+	v    values.Value
+}
+
+// localBindings returns the bindings made directly in scope sorted by name.
+// A scope ranges over its bindings in no particular order.
+func localBindings(scope values.Scope) []binding {
+	var bindings []binding
+	scope.LocalRange(func(k string, v values.Value) {
+		bindings = append(bindings, binding{name: k, v: v})
+	})
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].name < bindings[j].name
+	})
+	return bindings
+}
+
+// matchBindings returns the bindings whose names match pattern
+// as described by ExportMatching, in order.
+func matchBindings(bindings []binding, pattern string) []binding {
+	if pattern == "" {
+		return bindings
+	}
+	var matched []binding
+	for _, b := range bindings {
+		if matchName(b.name, pattern) {
+			matched = append(matched, b)
+		}
+	}
+	return matched
+}
+
+// matchName reports whether name matches pattern, which is
+// a prefix or a glob in which * matches any run of characters.
+func matchName(name, pattern string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return strings.HasPrefix(name, pattern)
+	}
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return strings.HasSuffix(name, last)
+}
+
+// identifier matches the names that can be used as record keys without quotes.
+var identifier = regexp.MustCompile(`^[\p{L}_][\p{L}\p{Nd}_]*$`)
+
+// fluxLiteral returns Flux source that evaluates to v.
+func fluxLiteral(v values.Value) (string, error) {
+	if v.IsNull() {
+		return "", fmt.Errorf("null values have no literal form")
+	}
+	switch v.Type().Nature() {
+	case semantic.String:
+		return quoteString(v.Str()), nil
+	case semantic.Int:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case semantic.UInt:
+		return fmt.Sprintf("uint(v: %d)", v.UInt()), nil
+	case semantic.Float:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			return `float(v: "NaN")`, nil
+		case math.IsInf(f, 1):
+			return `float(v: "+Inf")`, nil
+		case math.IsInf(f, -1):
+			return `float(v: "-Inf")`, nil
+		}
+		s := strconv.FormatFloat(f, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s, nil
+	case semantic.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case semantic.Time:
+		return v.Time().Time().UTC().Format(time.RFC3339Nano), nil
+	case semantic.Duration:
+		return v.Duration().String(), nil
+	case semantic.Regexp:
+		return "/" + strings.ReplaceAll(v.Regexp().String(), "/", `\/`) + "/", nil
+	case semantic.Array:
+		var elems []string
+		var err error
+		v.Array().Range(func(i int, e values.Value) {
+			if err != nil {
+				return
+			}
+			var lit string
+			if lit, err = fluxLiteral(e); err == nil {
+				elems = append(elems, lit)
+			}
+		})
+		if err != nil {
+			return "", err
+		}
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	case semantic.Object:
+		obj := v.Object()
+		keys := make([]string, 0, obj.Len())
+		obj.Range(func(k string, _ values.Value) {
+			keys = append(keys, k)
+		})
+		sort.Strings(keys)
+		props := make([]string, 0, len(keys))
+		for _, k := range keys {
+			e, _ := obj.Get(k)
+			lit, err := fluxLiteral(e)
+			if err != nil {
+				return "", err
+			}
+			if !identifier.MatchString(k) {
+				k = quoteString(k)
+			}
+			props = append(props, k+": "+lit)
+		}
+		return "{" + strings.Join(props, ", ") + "}", nil
+	case semantic.Dictionary:
+		d := v.Dict()
+		if d.Len() == 0 {
+			return "", fmt.Errorf("empty dictionaries have no literal form")
+		}
+		var entries []string
+		var err error
+		d.Range(func(k, e values.Value) {
+			if err != nil {
+				return
+			}
+			var key, lit string
+			if key, err = fluxLiteral(k); err != nil {
+				return
+			}
+			if lit, err = fluxLiteral(e); err == nil {
+				entries = append(entries, key+": "+lit)
+			}
+		})
+		if err != nil {
+			return "", err
+		}
+		return "[" + strings.Join(entries, ", ") + "]", nil
+	case semantic.Function:
+		return "", fmt.Errorf("functions have no literal form")
+	case semantic.Stream:
+		return "", fmt.Errorf("streams have no literal form")
+	default:
+		return "", fmt.Errorf("values of type %s have no literal form", v.Type())
+	}
+}
+
+// quoteString returns s as a Flux string literal.
+func quoteString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '$':
+			if i+1 < len(s) && s[i+1] == '{' {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -0,0 +1,217 @@
+package repl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+func TestFluxLiteral(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		v    values.Value
+		want string
+	}{
+		{name: "string", v: values.NewString("a \"b\"\n${c}"), want: `"a \"b\"\n\${c}"`},
+		{name: "int", v: values.NewInt(-3), want: "-3"},
+		{name: "uint", v: values.NewUInt(3), want: "uint(v: 3)"},
+		{name: "float", v: values.NewFloat(2), want: "2.0"},
+		{name: "fraction", v: values.NewFloat(2.5), want: "2.5"},
+		{name: "nan", v: values.NewFloat(math.NaN()), want: `float(v: "NaN")`},
+		{name: "bool", v: values.NewBool(true), want: "true"},
+		{
+			name: "time",
+			v:    values.NewTime(values.ConvertTime(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))),
+			want: "2021-01-02T03:04:05Z",
+		},
+		{name: "duration", v: values.NewDuration(values.ConvertDurationNsecs(90 * time.Minute)), want: "1h30m"},
+		{name: "regexp", v: values.NewRegexp(regexp.MustCompile("a/b")), want: `/a\/b/`},
+		{
+			name: "array",
+			v: values.NewArrayWithBacking(semantic.NewArrayType(semantic.BasicInt), []values.Value{
+				values.NewInt(1), values.NewInt(2),
+			}),
+			want: "[1, 2]",
+		},
+		{
+			name: "record",
+			v: values.NewObjectWithValues(map[string]values.Value{
+				"a":   values.NewInt(1),
+				"b c": values.NewString("x"),
+			}),
+			want: `{a: 1, "b c": "x"}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fluxLiteral(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("unexpected literal: got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeHolder_Export(t *testing.T) {
+	prelude := values.NewScope()
+	prelude.Set("builtin", values.NewInt(0))
+
+	r := newTestHolder()
+	r.scope = prelude.Nest(nil)
+	r.scope.Set("b", values.NewString("hello"))
+	r.scope.Set("a", values.NewInt(1))
+	r.scope.Set("opt", &values.Option{Value: values.NewBool(true)})
+	r.scope.Set("fn", values.NewFunction(
+		"fn",
+		semantic.NewFunctionType(semantic.BasicInt, nil),
+		func(ctx context.Context, args values.Object) (values.Value, error) {
+			return values.NewInt(0), nil
+		},
+		false,
+	))
+
+	want := strings.Join([]string{
+		"a = 1",
+		`b = "hello"`,
+		"// fn was skipped: functions have no literal form",
+		"option opt = true",
+		"",
+	}, "\n")
+	if got := r.Export(); got != want {
+		t.Fatalf("unexpected export:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestScopeHolder_Export_Overrides(t *testing.T) {
+	prelude := values.NewScope()
+	prelude.Set("from", values.NewFunction(
+		"from",
+		semantic.NewFunctionType(semantic.BasicInt, nil),
+		func(ctx context.Context, args values.Object) (values.Value, error) {
+			return values.NewInt(0), nil
+		},
+		false,
+	))
+
+	r := newTestHolder(WithDefaultBucket("b"))
+	r.nestSessionScope(prelude)
+	r.setNow(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	r.scope.Set("a", values.NewInt(1))
+
+	if got, want := r.Export(), "a = 1\n"; got != want {
+		t.Fatalf("unexpected export:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	for _, name := range []string{"from", "now"} {
+		if _, ok := r.scope.Lookup(name); !ok {
+			t.Errorf("expected %s to be visible from the session scope", name)
+		}
+	}
+
+	r.nestSessionScope(prelude)
+	if _, ok := r.scope.LocalLookup("from"); ok {
+		t.Error("expected from to be bound outside of the rebuilt session scope")
+	}
+	if _, ok := prelude.LocalLookup("now"); ok {
+		t.Error("expected the prelude to be left alone")
+	}
+}
+
+func TestScopeHolder_Export_Deterministic(t *testing.T) {
+	r := newTestHolder()
+	r.scope = values.NewScope().Nest(nil)
+	for i := 0; i < 50; i++ {
+		r.scope.Set(fmt.Sprintf("v%d", i), values.NewObjectWithValues(map[string]values.Value{
+			"a": values.NewInt(int64(i)),
+			"b": values.NewString("x"),
+			"c": values.NewBool(i%2 == 0),
+		}))
+	}
+
+	want := r.Export()
+	for i := 0; i < 10; i++ {
+		if got := r.Export(); got != want {
+			t.Fatalf("exports of the same scope differ:\nfirst:\n%s\nthen:\n%s", want, got)
+		}
+	}
+}
+
+func TestMatchName(t *testing.T) {
+	for _, tt := range []struct {
+		name, pattern string
+		want          bool
+	}{
+		{name: "cpu", pattern: "", want: true},
+		{name: "cpu_load", pattern: "cpu", want: true},
+		{name: "load_cpu", pattern: "cpu", want: false},
+		{name: "cpu_load", pattern: "cpu*", want: true},
+		{name: "load_cpu", pattern: "*cpu", want: true},
+		{name: "load_cpu_1", pattern: "*cpu", want: false},
+		{name: "load_cpu_1", pattern: "*cpu*", want: true},
+		{name: "cpu_load_1m", pattern: "cpu*1m", want: true},
+		{name: "cpu_1m_load", pattern: "cpu*1m", want: false},
+		{name: "cpu_mem_disk", pattern: "cpu*mem*disk", want: true},
+		{name: "cpu_disk_mem", pattern: "cpu*mem*disk", want: false},
+		// The parts around a * cannot overlap.
+		{name: "aba", pattern: "ab*ba", want: false},
+		{name: "x", pattern: "*", want: true},
+	} {
+		if got := matchName(tt.name, tt.pattern); got != tt.want {
+			t.Errorf("matchName(%q, %q) = %v, want %v", tt.name, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestScopeHolder_ExportMatching(t *testing.T) {
+	r := newTestHolder()
+	r.scope = values.NewScope().Nest(nil)
+	for _, name := range []string{"cpu_load", "cpu_idle", "mem_used", "disk_used", "host"} {
+		r.scope.Set(name, values.NewInt(1))
+	}
+
+	for _, tt := range []struct {
+		pattern string
+		want    []string
+	}{
+		{pattern: "cpu", want: []string{"cpu_idle = 1", "cpu_load = 1"}},
+		{pattern: "*_used", want: []string{"disk_used = 1", "mem_used = 1"}},
+		{pattern: "nothing"},
+	} {
+		want := strings.Join(append(tt.want, ""), "\n")
+		if len(tt.want) == 0 {
+			want = ""
+		}
+		if got := r.ExportMatching(tt.pattern); got != want {
+			t.Errorf("unexpected export for %q:\ngot:\n%s\nwant:\n%s", tt.pattern, got, want)
+		}
+	}
+}
+
+func TestService_Export_Pattern(t *testing.T) {
+	r := newTestHolder()
+	r.scope = values.NewScope().Nest(nil)
+	r.scope.Set("a1", values.NewInt(1))
+	r.scope.Set("b1", values.NewInt(2))
+	send := serveTestService(t, &Service{r: r})
+
+	resp := send(`{"method": "Service.Export", "id": 1, "params": [{"pattern": "b*"}]}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	var got ExportResponse
+	if err := json.Unmarshal(resp.Result, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "b1 = 2\n"; got.Script != want {
+		t.Fatalf("unexpected script: got %q, want %q", got.Script, want)
+	}
+}
@@ -0,0 +1,142 @@
+package repl
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/csv"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+// WithFloatPrecision sets the number of digits after the decimal point
+// used when formatting float results, both in tables and for float
+// values that are displayed directly. By default, the smallest number
+// of digits necessary to represent the value exactly is used.
+//
+// The precision does not apply to CSV results, set by WithCSVResults,
+// WithCSVDialect or returned by EvalCSV, which always write floats
+// with every digit so that they can be read back exactly.
+func WithFloatPrecision(n int) Option {
+	return option(func(r *ScopeHolder) {
+		r.floatPrecision = &n
+	})
+}
+
+// WithCSVResults writes the tables of each result as annotated CSV,
+// with the datatype, group and default annotations, instead of
+// formatting them for display.
+func WithCSVResults() Option {
+	return WithCSVDialect(csv.DefaultEncoderConfig())
+}
+
+// WithCSVDialect writes the tables of each result as CSV in the
+// dialect described by c instead of formatting them for display.
+// Leaving out every annotation produces plain CSV.
+func WithCSVDialect(c csv.ResultEncoderConfig) Option {
+	return option(func(r *ScopeHolder) {
+		r.csvConfig = &c
+	})
+}
+
+// WithColumnOrder writes the columns of formatted tables in the order
+// that the query produces them, for consumers that depend on it. By
+// default, the columns of the group key are written first. Results
+// written as CSV always keep the order of the query.
+func WithColumnOrder() Option {
+	return option(func(r *ScopeHolder) {
+		r.preserveColumnOrder = true
+	})
+}
+
+// WithMaxDisplaySize limits the number of bytes written when displaying
+// a value that is not a table. Output beyond the limit is dropped and
+// replaced with a truncation marker. A limit of zero, the default,
+// displays values in full.
+func WithMaxDisplaySize(n int) Option {
+	return option(func(r *ScopeHolder) {
+		r.maxDisplaySize = n
+	})
+}
+
+// truncatedMarker is written in place of display output beyond the limit.
+const truncatedMarker = "... (truncated)"
+
+// errDisplayTruncated stops displaying a value once the limit is reached.
+var errDisplayTruncated = errors.New(codes.ResourceExhausted, "display output truncated")
+
+// limitWriter writes at most n more bytes to w.
+type limitWriter struct {
+	w io.Writer
+	n int
+}
+
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	if len(p) <= lw.n {
+		n, err := lw.w.Write(p)
+		lw.n -= n
+		return n, err
+	}
+	n, err := lw.w.Write(p[:lw.n])
+	lw.n -= n
+	if err == nil {
+		err = errDisplayTruncated
+	}
+	return n, err
+}
+
+// WithResultWriter sets where the tables produced by queries run
+// over JSON-RPC or through Input are written, in addition to being
+// returned with the other output of the line. The default is os.Stdout,
+// except when serving over stdin and stdout with Run.
+func WithResultWriter(w io.Writer) Option {
+	return option(func(r *ScopeHolder) {
+		r.resultWriter = w
+	})
+}
+
+// formatOptions returns the options used to format result tables.
+func (r *ScopeHolder) formatOptions() *execute.FormatOptions {
+	opts := execute.DefaultFormatOptions()
+	opts.FloatPrecision = r.floatPrecision
+	opts.PreserveColumnOrder = r.preserveColumnOrder
+	return opts
+}
+
+// display writes the value to w, truncating the output
+// if it exceeds the configured limit.
+func (r *ScopeHolder) display(w io.Writer, v values.Value) error {
+	if r.maxDisplaySize <= 0 {
+		return r.displayValue(w, v)
+	}
+	err := r.displayValue(&limitWriter{w: w, n: r.maxDisplaySize}, v)
+	if err == errDisplayTruncated {
+		_, err = io.WriteString(w, truncatedMarker)
+	}
+	return err
+}
+
+// displayValue writes the value to w.
+// Floats are formatted with the configured precision.
+// Floats nested within composite values use the default format.
+func (r *ScopeHolder) displayValue(w io.Writer, v values.Value) error {
+	if r.floatPrecision != nil && !v.IsNull() && v.Type().Nature() == semantic.Float {
+		_, err := io.WriteString(w, strconv.FormatFloat(v.Float(), 'f', *r.floatPrecision, 64))
+		return err
+	}
+	return values.Display(w, v)
+}
+
+// writeCSVResult writes the tables of result to w as CSV,
+// followed by the blank line that separates results.
+func (r *ScopeHolder) writeCSVResult(w io.Writer, result flux.Result) error {
+	if _, err := csv.NewResultEncoder(*r.csvConfig).Encode(w, result); err != nil {
+		return errors.Wrapf(err, codes.Inherit, "failed to encode result %q", result.Name())
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
@@ -0,0 +1,235 @@
+package repl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/csv"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+func TestDisplay_FloatPrecision(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		v    values.Value
+		want string
+	}{
+		{
+			name: "default",
+			v:    values.NewFloat(1.0 / 3.0),
+			want: "0.3333333333333333",
+		},
+		{
+			name: "precision",
+			opts: []Option{WithFloatPrecision(3)},
+			v:    values.NewFloat(1.0 / 3.0),
+			want: "0.333",
+		},
+		{
+			name: "non-float",
+			opts: []Option{WithFloatPrecision(3)},
+			v:    values.NewInt(7),
+			want: "7",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newTestHolder(tc.opts...)
+			var sb strings.Builder
+			if err := r.display(&sb, tc.v); err != nil {
+				t.Fatal(err)
+			}
+			if got := sb.String(); got != tc.want {
+				t.Fatalf("unexpected output -want/+got:\n\t- %s\n\t+ %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDisplay_MaxDisplaySize(t *testing.T) {
+	ints := func(n int) values.Value {
+		vs := make([]values.Value, n)
+		for i := range vs {
+			vs[i] = values.NewInt(int64(i))
+		}
+		return values.NewArrayWithBacking(semantic.NewArrayType(semantic.BasicInt), vs)
+	}
+
+	t.Run("small", func(t *testing.T) {
+		r := newTestHolder(WithMaxDisplaySize(100))
+		var sb strings.Builder
+		if err := r.display(&sb, ints(3)); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := sb.String(), values.DisplayString(ints(3)); got != want {
+			t.Fatalf("unexpected output -want/+got:\n\t- %s\n\t+ %s", want, got)
+		}
+	})
+
+	t.Run("large", func(t *testing.T) {
+		r := newTestHolder(WithMaxDisplaySize(100))
+		var sb strings.Builder
+		if err := r.display(&sb, ints(100000)); err != nil {
+			t.Fatal(err)
+		}
+		got := sb.String()
+		if want := 100 + len(truncatedMarker); len(got) != want {
+			t.Fatalf("unexpected output length: got %d, want %d", len(got), want)
+		}
+		if full := values.DisplayString(ints(100000)); !strings.HasPrefix(full, strings.TrimSuffix(got, truncatedMarker)) {
+			t.Fatalf("expected truncated output to be a prefix of the full output, got:\n%s", got)
+		}
+		if !strings.HasSuffix(got, truncatedMarker) {
+			t.Fatalf("expected output to end with the truncation marker, got:\n%s", got)
+		}
+	})
+}
+
+func TestWriteResult_TableError(t *testing.T) {
+	cols := []flux.ColMeta{
+		{Label: "t0", Type: flux.TString},
+		{Label: "_value", Type: flux.TInt},
+	}
+	result := &executetest.Result{
+		Nm: "data",
+		Tbls: []*executetest.Table{
+			{
+				KeyCols: []string{"t0"},
+				ColMeta: cols,
+				Data:    [][]interface{}{{"a", int64(1)}},
+			},
+			{
+				KeyCols: []string{"t0"},
+				ColMeta: cols,
+				Data:    [][]interface{}{{"b", int64(2)}},
+				Err:     errors.New(codes.Unavailable, "source failed"),
+			},
+		},
+	}
+
+	var buf strings.Builder
+	err := newTestHolder().writeResult(&buf, result)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if got, want := errors.Code(err), codes.Unavailable; got != want {
+		t.Errorf("unexpected error code: got %v, want %v", got, want)
+	}
+	for _, want := range []string{`result "data"`, "t0=b", "source failed"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got: %s", want, err)
+		}
+	}
+	if !strings.Contains(buf.String(), "Result: data\n") {
+		t.Errorf("expected the first table to be written, got:\n%s", buf.String())
+	}
+}
+
+func TestWriteResult_CSV(t *testing.T) {
+	newResult := func() flux.Result {
+		return &executetest.Result{
+			Nm: "_result",
+			Tbls: []*executetest.Table{{
+				KeyCols: []string{"t0"},
+				ColMeta: []flux.ColMeta{
+					{Label: "t0", Type: flux.TString},
+					{Label: "_value", Type: flux.TInt},
+				},
+				Data: [][]interface{}{{"a", int64(1)}, {"a", int64(2)}},
+			}},
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		opt  Option
+		want string
+	}{
+		{
+			name: "annotated",
+			opt:  WithCSVResults(),
+			want: "#datatype,string,long,string,long\r\n" +
+				"#group,false,false,true,false\r\n" +
+				"#default,_result,,,\r\n" +
+				",result,table,t0,_value\r\n" +
+				",,0,a,1\r\n" +
+				",,0,a,2\r\n" +
+				"\r\n",
+		},
+		{
+			name: "plain",
+			opt:  WithCSVDialect(csv.ResultEncoderConfig{Delimiter: ';'}),
+			want: ";result;table;t0;_value\r\n" +
+				";_result;0;a;1\r\n" +
+				";_result;0;a;2\r\n" +
+				"\r\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf strings.Builder
+			if err := newTestHolder(tc.opt).writeResult(&buf, newResult()); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("unexpected output -want/+got:\n%s", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
+
+func TestWriteResult_CSVFloatPrecision(t *testing.T) {
+	res := &executetest.Result{
+		Nm: "_result",
+		Tbls: []*executetest.Table{{
+			ColMeta: []flux.ColMeta{{Label: "_value", Type: flux.TFloat}},
+			Data:    [][]interface{}{{1.2345}},
+		}},
+	}
+	var buf strings.Builder
+	r := newTestHolder(WithFloatPrecision(1), WithCSVResults())
+	if err := r.writeResult(&buf, res); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.Contains(got, ",1.2345\r\n") {
+		t.Errorf("expected the float in full precision, got:\n%s", got)
+	}
+}
+
+func TestWriteResult_ColumnOrder(t *testing.T) {
+	newResult := func() flux.Result {
+		return &executetest.Result{
+			Nm: "_result",
+			Tbls: []*executetest.Table{{
+				KeyCols: []string{"t0"},
+				ColMeta: []flux.ColMeta{
+					{Label: "_value", Type: flux.TInt},
+					{Label: "t1", Type: flux.TString},
+					{Label: "t0", Type: flux.TString},
+				},
+				Data: [][]interface{}{{int64(1), "b", "a"}},
+			}},
+		}
+	}
+	header := func(r *ScopeHolder) []string {
+		t.Helper()
+		var buf strings.Builder
+		if err := r.writeResult(&buf, newResult()); err != nil {
+			t.Fatal(err)
+		}
+		// The header follows the result and table lines.
+		return strings.Fields(strings.Split(buf.String(), "\n")[2])
+	}
+
+	if want, got := []string{"t0:string", "_value:int", "t1:string"}, header(newTestHolder()); !cmp.Equal(want, got) {
+		t.Fatalf("unexpected default column order -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if want, got := []string{"_value:int", "t1:string", "t0:string"}, header(newTestHolder(WithColumnOrder())); !cmp.Equal(want, got) {
+		t.Fatalf("unexpected preserved column order -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
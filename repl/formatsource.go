@@ -0,0 +1,45 @@
+package repl
+
+import (
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/libflux/go/libflux"
+)
+
+// FormatRequest is the params object for Service.Format.
+type FormatRequest struct {
+	Input string `json:"input"`
+}
+
+// FormatResponse is the response to Service.Format.
+type FormatResponse struct {
+	// Formatted is the input in the canonical form of Flux source.
+	Formatted string `json:"formatted"`
+}
+
+// Format reports the canonical form of the input, for an
+// editor to offer formatting it. Nothing is evaluated.
+func (s *Service) Format(req FormatRequest, resp *FormatResponse) error {
+	formatted, err := s.r.Format(req.Input)
+	if err != nil {
+		return err
+	}
+	*resp = FormatResponse{Formatted: formatted}
+	return nil
+}
+
+// Format returns t formatted as the Flux formatter does, with its
+// comments kept. The input is only parsed, so it may use names that
+// are not in scope, but an input that does not parse is an error.
+func (r *ScopeHolder) Format(t string) (string, error) {
+	pkg := libflux.ParseString(t)
+	defer pkg.Free()
+	if err := pkg.GetError(); err != nil {
+		return "", errors.Wrap(err, codes.Invalid, "cannot format input that does not parse")
+	}
+	formatted, err := pkg.Format()
+	if err != nil {
+		return "", errors.Wrap(err, codes.Inherit, "failed to format the input")
+	}
+	return formatted, nil
+}
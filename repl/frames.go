@@ -0,0 +1,330 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net/rpc"
+	"sync"
+
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/array"
+	"github.com/apache/arrow/go/v7/arrow/ipc"
+	arrowmemory "github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// The encodings of results that a client can negotiate with Service.Hello.
+const (
+	// EncodingJSON returns the tables of an input as text in its
+	// response. It is the encoding of a connection until another is
+	// negotiated.
+	EncodingJSON = "json"
+	// EncodingArrow sends the tables of an input in table frames
+	// ahead of its response, as described by Service.Hello.
+	EncodingArrow = "arrow"
+)
+
+// The kinds of frame sent on a connection that negotiated EncodingArrow.
+const (
+	// FrameJSON holds a JSON-RPC response.
+	FrameJSON byte = 'J'
+	// FrameTable holds a table of a result.
+	FrameTable byte = 'T'
+)
+
+// frameHeaderLen is the length of the kind and body length of a frame.
+const frameHeaderLen = 5
+
+// groupKeyMetadata is the schema metadata key of a table frame that
+// holds the labels of the group key columns, as a JSON array.
+const groupKeyMetadata = "flux.group_key"
+
+// HelloRequest is the params object for Service.Hello.
+type HelloRequest struct {
+	// Encodings lists the encodings of results that the client
+	// accepts, in the order it prefers them.
+	Encodings []string `json:"encodings"`
+}
+
+// HelloResponse is the response to Service.Hello.
+type HelloResponse struct {
+	// Encoding is the encoding of results from here on.
+	Encoding string `json:"encoding"`
+}
+
+// Hello negotiates the encoding of the results of the connection. The
+// first of the encodings of the request that the server supports is
+// chosen, or EncodingJSON if there is none.
+//
+// Once EncodingArrow is chosen, everything the server sends after the
+// response to Hello, which is plain JSON ending in a newline, is framed. A frame is a kind
+// byte followed by the length of its body as a big-endian uint32 and
+// the body itself. A FrameJSON holds a JSON-RPC response, and requests
+// are still sent as plain JSON. A FrameTable holds a table produced by
+// an input to DidOutput, and is sent ahead of the response to the input,
+// which carries no text for its tables. Its body is the length of the
+// name of the result as a big-endian uint16, the name, and an Arrow IPC
+// stream with the schema of the table and its rows. Times are sent as
+// UTC timestamps in nanoseconds, and the schema metadata holds the
+// labels of the group key under "flux.group_key" as a JSON array.
+//
+// The encoding can only be negotiated once, before it is switched.
+func (s *Service) Hello(req HelloRequest, resp *HelloResponse) error {
+	if s.frames != nil && s.frames.isFramed() {
+		return errors.New(codes.FailedPrecondition, "the encoding of results was already negotiated")
+	}
+	encoding := EncodingJSON
+	for _, e := range req.Encodings {
+		if e == EncodingJSON || (e == EncodingArrow && s.frames != nil) {
+			encoding = e
+			break
+		}
+	}
+	*resp = HelloResponse{Encoding: encoding}
+	return nil
+}
+
+// ReadFrame reads a frame sent by a server after EncodingArrow was
+// negotiated, and returns its kind and body.
+func ReadFrame(r io.Reader) (byte, []byte, error) {
+	var header [frameHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, errors.Wrap(err, codes.Invalid, "truncated frame")
+	}
+	return header[0], body, nil
+}
+
+// appendFrame appends a frame of kind holding body to buf.
+func appendFrame(buf []byte, kind byte, body []byte) ([]byte, error) {
+	if uint64(len(body)) > math.MaxUint32 {
+		return nil, errors.Newf(codes.ResourceExhausted, "a frame of %d bytes is too large", len(body))
+	}
+	var header [frameHeaderLen]byte
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], uint32(len(body)))
+	return append(append(buf, header[:]...), body...), nil
+}
+
+// frameConn is the connection of a session, which frames what the
+// RPC server writes to it once EncodingArrow is negotiated.
+type frameConn struct {
+	io.ReadWriteCloser
+
+	mu     sync.Mutex
+	framed bool
+}
+
+func (c *frameConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.framed {
+		return c.ReadWriteCloser.Write(p)
+	}
+	frame, err := appendFrame(nil, FrameJSON, p)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := c.ReadWriteCloser.Write(frame); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *frameConn) setFramed() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.framed = true
+}
+
+func (c *frameConn) isFramed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.framed
+}
+
+// tableSink returns the sink that sends the tables
+// of a query in table frames on the connection.
+func (c *frameConn) tableSink() ResultSink {
+	return ResultSink{Writer: frameWriter{c: c}, Encoder: tableFrameEncoder{}}
+}
+
+// frameWriter writes frames encoded in full to the connection as they
+// are, so that they are not interleaved with the responses of the server.
+type frameWriter struct {
+	c *frameConn
+}
+
+func (w frameWriter) Write(p []byte) (int, error) {
+	w.c.mu.Lock()
+	defer w.c.mu.Unlock()
+	return w.c.ReadWriteCloser.Write(p)
+}
+
+// frameCodec switches the connection to frames once the response
+// to a Hello that chose EncodingArrow has been written.
+type frameCodec struct {
+	rpc.ServerCodec
+	conn *frameConn
+}
+
+func (c *frameCodec) WriteResponse(resp *rpc.Response, body interface{}) error {
+	if err := c.ServerCodec.WriteResponse(resp, body); err != nil {
+		return err
+	}
+	if h, ok := body.(*HelloResponse); ok && resp.Error == "" && h.Encoding == EncodingArrow {
+		c.conn.setFramed()
+	}
+	return nil
+}
+
+type tableSinkKey struct{}
+
+// withTableSink makes the queries run in ctx encode their results
+// to sink in place of the formatted output of the session.
+func withTableSink(ctx context.Context, sink ResultSink) context.Context {
+	return context.WithValue(ctx, tableSinkKey{}, sink)
+}
+
+// tableFrameEncoder encodes each table of a result in a table frame.
+type tableFrameEncoder struct{}
+
+func (tableFrameEncoder) Encode(w io.Writer, result flux.Result) (int64, error) {
+	var n int64
+	err := result.Tables().Do(func(tbl flux.Table) error {
+		frame, err := encodeTableFrame(result.Name(), tbl)
+		if err != nil {
+			return errors.Wrapf(err, codes.Inherit, "failed to encode table %s of result %q", tbl.Key(), result.Name())
+		}
+		m, err := w.Write(frame)
+		n += int64(m)
+		return err
+	})
+	return n, err
+}
+
+// encodeTableFrame returns the table frame of tbl of the result name.
+func encodeTableFrame(name string, tbl flux.Table) ([]byte, error) {
+	if len(name) > math.MaxUint16 {
+		return nil, errors.Newf(codes.Invalid, "a result name of %d bytes is too long", len(name))
+	}
+	var body bytes.Buffer
+	var nameLen [2]byte
+	binary.BigEndian.PutUint16(nameLen[:], uint16(len(name)))
+	body.Write(nameLen[:])
+	body.WriteString(name)
+
+	schema, err := arrowSchema(tbl)
+	if err != nil {
+		return nil, err
+	}
+	mem := arrowmemory.NewGoAllocator()
+	iw := ipc.NewWriter(&body, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+	if err := tbl.Do(func(cr flux.ColReader) error {
+		cols := make([]arrow.Array, len(cr.Cols()))
+		for j := range cols {
+			cols[j] = arrowColumn(mem, cr, j)
+		}
+		rec := array.NewRecord(schema, cols, int64(cr.Len()))
+		for _, col := range cols {
+			col.Release()
+		}
+		defer rec.Release()
+		return iw.Write(rec)
+	}); err != nil {
+		_ = iw.Close()
+		return nil, err
+	}
+	if err := iw.Close(); err != nil {
+		return nil, err
+	}
+	return appendFrame(nil, FrameTable, body.Bytes())
+}
+
+var timestampType = &arrow.TimestampType{Unit: arrow.Nanosecond, TimeZone: "UTC"}
+
+// arrowSchema returns the Arrow schema of the columns of tbl.
+func arrowSchema(tbl flux.Table) (*arrow.Schema, error) {
+	fields := make([]arrow.Field, len(tbl.Cols()))
+	for i, col := range tbl.Cols() {
+		var typ arrow.DataType
+		switch col.Type {
+		case flux.TInt:
+			typ = arrow.PrimitiveTypes.Int64
+		case flux.TUInt:
+			typ = arrow.PrimitiveTypes.Uint64
+		case flux.TFloat:
+			typ = arrow.PrimitiveTypes.Float64
+		case flux.TString:
+			typ = arrow.BinaryTypes.String
+		case flux.TBool:
+			typ = arrow.FixedWidthTypes.Boolean
+		case flux.TTime:
+			typ = timestampType
+		default:
+			return nil, errors.Newf(codes.Unimplemented, "column %q of type %s cannot be sent in a table frame", col.Label, col.Type)
+		}
+		fields[i] = arrow.Field{Name: col.Label, Type: typ, Nullable: true}
+	}
+	key := make([]string, len(tbl.Key().Cols()))
+	for i, col := range tbl.Key().Cols() {
+		key[i] = col.Label
+	}
+	keyJSON, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	md := arrow.NewMetadata([]string{groupKeyMetadata}, []string{string(keyJSON)})
+	return arrow.NewSchema(fields, &md), nil
+}
+
+// arrowColumn returns the Arrow array of column j of cr, which the
+// caller must release. Columns other than strings and times are Arrow
+// arrays already and are shared rather than copied.
+func arrowColumn(mem arrowmemory.Allocator, cr flux.ColReader, j int) arrow.Array {
+	switch cr.Cols()[j].Type {
+	case flux.TInt:
+		a := cr.Ints(j)
+		a.Retain()
+		return a
+	case flux.TUInt:
+		a := cr.UInts(j)
+		a.Retain()
+		return a
+	case flux.TFloat:
+		a := cr.Floats(j)
+		a.Retain()
+		return a
+	case flux.TBool:
+		a := cr.Bools(j)
+		a.Retain()
+		return a
+	case flux.TTime:
+		d := cr.Times(j).Data()
+		data := array.NewData(timestampType, d.Len(), d.Buffers(), nil, d.NullN(), d.Offset())
+		defer data.Release()
+		return array.MakeFromData(data)
+	default:
+		vs := cr.Strings(j)
+		b := array.NewStringBuilder(mem)
+		defer b.Release()
+		b.Reserve(vs.Len())
+		for i := 0; i < vs.Len(); i++ {
+			if vs.IsNull(i) {
+				b.AppendNull()
+				continue
+			}
+			b.Append(vs.Value(i))
+		}
+		return b.NewArray()
+	}
+}
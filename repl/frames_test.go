@@ -0,0 +1,190 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/array"
+	"github.com/apache/arrow/go/v7/arrow/ipc"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/values"
+)
+
+// serveFramedService serves svc on a connection that can switch to
+// frames, and returns the client end of the connection.
+func serveFramedService(t *testing.T, svc *Service) net.Conn {
+	t.Helper()
+	server := rpc.NewServer()
+	if err := server.Register(svc); err != nil {
+		t.Fatal(err)
+	}
+	serverConn, clientConn := net.Pipe()
+	svc.frames = &frameConn{ReadWriteCloser: serverConn}
+	go server.ServeCodec(&frameCodec{ServerCodec: jsonrpc.NewServerCodec(svc.frames), conn: svc.frames})
+	t.Cleanup(func() { _ = clientConn.Close() })
+	return clientConn
+}
+
+func TestService_Hello_Arrow(t *testing.T) {
+	svc := &Service{c: make(chan InputRequest), res: make(chan lineResult)}
+	framed := make(chan bool, 1)
+	go func() {
+		for in := range svc.c {
+			framed <- in.tableSink != nil
+			svc.res <- lineResult{}
+		}
+	}()
+	conn := serveFramedService(t, svc)
+
+	if _, err := conn.Write([]byte(`{"method": "Service.Hello", "id": 1, "params": [{"encodings": ["msgpack", "arrow", "json"]}]}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp rpcResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		t.Fatal(err)
+	}
+	var hello HelloResponse
+	if err := json.Unmarshal(resp.Result, &hello); err != nil {
+		t.Fatal(err)
+	}
+	if hello.Encoding != EncodingArrow {
+		t.Fatalf("expected %q to be negotiated, got %q", EncodingArrow, hello.Encoding)
+	}
+
+	// Responses are framed from here on.
+	call := func(req string) rpcResponse {
+		t.Helper()
+		if _, err := conn.Write([]byte(req + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		kind, body, err := ReadFrame(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kind != FrameJSON {
+			t.Fatalf("expected a JSON frame, got %q", kind)
+		}
+		var resp rpcResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if resp := call(`{"method": "Service.DidOutput", "id": 2, "params": [{"input": "1"}]}`); resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if !<-framed {
+		t.Fatal("expected the tables of the input to be sent in table frames")
+	}
+	if resp := call(`{"method": "Service.Hello", "id": 3, "params": [{"encodings": ["json"]}]}`); resp.Error == nil {
+		t.Fatal("expected an error negotiating the encoding again")
+	}
+}
+
+func TestService_Hello_JSON(t *testing.T) {
+	// Without a connection that can be framed, only JSON is offered.
+	call := serveTestService(t, &Service{})
+	for _, req := range []string{
+		`{"method": "Service.Hello", "id": 1, "params": [{"encodings": ["arrow"]}]}`,
+		`{"method": "Service.Hello", "id": 2, "params": [{"encodings": ["msgpack"]}]}`,
+	} {
+		resp := call(req)
+		if resp.Error != nil {
+			t.Fatalf("unexpected error: %v", resp.Error)
+		}
+		var hello HelloResponse
+		if err := json.Unmarshal(resp.Result, &hello); err != nil {
+			t.Fatal(err)
+		}
+		if hello.Encoding != EncodingJSON {
+			t.Fatalf("expected %q to be negotiated, got %q", EncodingJSON, hello.Encoding)
+		}
+	}
+}
+
+func TestTableFrameEncoder_RoundTrip(t *testing.T) {
+	cols := []flux.ColMeta{
+		{Label: "_time", Type: flux.TTime},
+		{Label: "host", Type: flux.TString},
+		{Label: "_value", Type: flux.TFloat},
+		{Label: "ok", Type: flux.TBool},
+	}
+	result := &executetest.Result{
+		Nm: "cpu",
+		Tbls: []*executetest.Table{{
+			KeyCols: []string{"host"},
+			ColMeta: cols,
+			Data: [][]interface{}{
+				{values.ConvertTime(time.Unix(10, 0)), "a", 1.5, true},
+				{values.ConvertTime(time.Unix(20, 0)), "a", nil, false},
+			},
+		}},
+	}
+	var buf bytes.Buffer
+	if _, err := (tableFrameEncoder{}).Encode(&buf, result); err != nil {
+		t.Fatal(err)
+	}
+
+	kind, body, err := ReadFrame(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kind != FrameTable {
+		t.Fatalf("expected a table frame, got %q", kind)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected one frame for the table, got %d bytes more", buf.Len())
+	}
+	n := binary.BigEndian.Uint16(body)
+	if name := string(body[2 : 2+n]); name != "cpu" {
+		t.Fatalf("expected the frame of result %q, got %q", "cpu", name)
+	}
+
+	rdr, err := ipc.NewReader(bytes.NewReader(body[2+n:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdr.Release()
+	md := rdr.Schema().Metadata()
+	if i := md.FindKey(groupKeyMetadata); i < 0 || md.Values()[i] != `["host"]` {
+		t.Fatalf("unexpected schema metadata %v", md)
+	}
+	if !rdr.Next() {
+		t.Fatalf("expected a record, got %v", rdr.Err())
+	}
+	rec := rdr.Record()
+	if rec.NumRows() != 2 || rec.NumCols() != 4 {
+		t.Fatalf("expected 2 rows of 4 columns, got %d of %d", rec.NumRows(), rec.NumCols())
+	}
+	times := rec.Column(0).(*array.Timestamp)
+	if got := times.Value(1); got != arrow.Timestamp(time.Unix(20, 0).UnixNano()) {
+		t.Errorf("unexpected time %v", got)
+	}
+	if got := rec.Column(1).(*array.String).Value(0); got != "a" {
+		t.Errorf("unexpected host %q", got)
+	}
+	floats := rec.Column(2).(*array.Float64)
+	if floats.Value(0) != 1.5 || !floats.IsNull(1) {
+		t.Errorf("unexpected values %v", floats)
+	}
+	if bools := rec.Column(3).(*array.Boolean); !bools.Value(0) || bools.Value(1) {
+		t.Errorf("unexpected bools %v", bools)
+	}
+	if rdr.Next() {
+		t.Fatal("expected a single record")
+	}
+}
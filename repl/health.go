@@ -0,0 +1,125 @@
+package repl
+
+import (
+	"sync"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// HealthState is the stage of its life that a session is in.
+type HealthState string
+
+const (
+	// HealthStarting means the session is still loading the prelude,
+	// the analyzer or the init directory.
+	HealthStarting HealthState = "starting"
+	// HealthReady means the session accepts input.
+	HealthReady HealthState = "ready"
+	// HealthDraining means the session is shutting down, and any
+	// query that is still running is being interrupted.
+	HealthDraining HealthState = "draining"
+)
+
+// healthWindow is the number of recent queries
+// that the error rate is computed over.
+const healthWindow = 100
+
+// HealthResponse is the response to Service.Health.
+type HealthResponse struct {
+	State HealthState `json:"state"`
+	// ActiveQueries is the number of queries that are running.
+	ActiveQueries int `json:"activeQueries"`
+	// RecentQueries is the number of queries, up to the last 100,
+	// that ErrorRate is computed over.
+	RecentQueries int `json:"recentQueries"`
+	// ErrorRate is the fraction of the recent queries that failed.
+	// Queries that were canceled by the user are not failures.
+	ErrorRate float64 `json:"errorRate"`
+	// AnalyzerResets is the number of times the analyzer was
+	// recreated, by ResetAnalyzer or after it panicked.
+	AnalyzerResets int `json:"analyzerResets"`
+}
+
+// Health reports whether the session is ready for input, as described
+// by ScopeHolder.Health.
+func (s *Service) Health(req struct{}, resp *HealthResponse) error {
+	*resp = s.r.Health()
+	return nil
+}
+
+// Health reports whether the session is ready for input, along with
+// the number of queries that are running and the error rate of the
+// most recent ones, so that a supervisor can decide whether to send
+// requests to it.
+func (r *ScopeHolder) Health() HealthResponse {
+	resp := HealthResponse{ActiveQueries: len(r.queries.ids())}
+	resp.State, resp.RecentQueries, resp.ErrorRate = r.health.report()
+	resp.AnalyzerResets = r.health.analyzerResets()
+	if r.ctx.Err() != nil {
+		resp.State = HealthDraining
+	}
+	return resp
+}
+
+// healthTracker records whether the session has started and
+// the outcome of its most recent queries.
+type healthTracker struct {
+	mu    sync.Mutex
+	ready bool
+	// failed holds whether each recent query failed, as a ring
+	// of up to healthWindow entries starting at next once full.
+	failed []bool
+	next   int
+	resets int
+}
+
+func (h *healthTracker) setReady() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ready = true
+}
+
+// record records the outcome of a query that finished with err.
+func (h *healthTracker) record(err error) {
+	failed := err != nil && errors.Code(err) != codes.Canceled
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.failed) < healthWindow {
+		h.failed = append(h.failed, failed)
+		return
+	}
+	h.failed[h.next] = failed
+	h.next = (h.next + 1) % healthWindow
+}
+
+func (h *healthTracker) recordAnalyzerReset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resets++
+}
+
+func (h *healthTracker) analyzerResets() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.resets
+}
+
+func (h *healthTracker) report() (state HealthState, recent int, errorRate float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	state = HealthStarting
+	if h.ready {
+		state = HealthReady
+	}
+	var n int
+	for _, failed := range h.failed {
+		if failed {
+			n++
+		}
+	}
+	if len(h.failed) > 0 {
+		errorRate = float64(n) / float64(len(h.failed))
+	}
+	return state, len(h.failed), errorRate
+}
@@ -0,0 +1,91 @@
+package repl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func TestScopeHolder_Health_States(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := newTestHolder()
+	r.ctx = ctx
+
+	if got, want := r.Health().State, HealthStarting; got != want {
+		t.Fatalf("unexpected state before the session is ready: got %s, want %s", got, want)
+	}
+	r.health.setReady()
+	if got, want := r.Health().State, HealthReady; got != want {
+		t.Fatalf("unexpected state once the session is ready: got %s, want %s", got, want)
+	}
+	cancel()
+	if got, want := r.Health().State, HealthDraining; got != want {
+		t.Fatalf("unexpected state once the session shuts down: got %s, want %s", got, want)
+	}
+}
+
+func TestScopeHolder_Health_Queries(t *testing.T) {
+	r := newTestHolder()
+	r.health.setReady()
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.queries.add(cancel)
+
+	r.health.record(nil)
+	r.health.record(errors.New(codes.Internal, "boom"))
+	r.health.record(errors.New(codes.Canceled, "canceled by the user"))
+	r.health.record(nil)
+	r.health.recordAnalyzerReset()
+
+	want := HealthResponse{State: HealthReady, ActiveQueries: 1, RecentQueries: 4, ErrorRate: 0.25, AnalyzerResets: 1}
+	if got := r.Health(); !cmp.Equal(want, got) {
+		t.Fatalf("unexpected health -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestHealthTracker_Window(t *testing.T) {
+	var h healthTracker
+	for i := 0; i < 150; i++ {
+		h.record(errors.New(codes.Internal, "boom"))
+	}
+	for i := 0; i < healthWindow-10; i++ {
+		h.record(nil)
+	}
+	_, recent, rate := h.report()
+	if recent != healthWindow {
+		t.Fatalf("expected the window to hold %d queries, got %d", healthWindow, recent)
+	}
+	if want := 0.1; rate != want {
+		t.Fatalf("unexpected error rate: got %v, want %v", rate, want)
+	}
+}
+
+func TestService_Health(t *testing.T) {
+	r := newTestHolder()
+	r.health.setReady()
+	send := serveTestService(t, &Service{r: r})
+
+	resp := send(`{"method": "Service.Health", "id": 1, "params": [{}]}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(resp.Result, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"state":          "ready",
+		"activeQueries":  0.0,
+		"recentQueries":  0.0,
+		"errorRate":      0.0,
+		"analyzerResets": 0.0,
+	}
+	if !cmp.Equal(want, got) {
+		t.Fatalf("unexpected response -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
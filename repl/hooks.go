@@ -0,0 +1,60 @@
+package repl
+
+import (
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// WithResultHook runs hook on each table of the results of a query
+// before they are encoded, so that an embedder can rework every result
+// the same way, such as to redact a sensitive column or to convert
+// units. The table that hook returns is encoded in place of the one it
+// is given, which hook must read or release with Done if it does not
+// return it. A hook error aborts the query.
+//
+// Hooks run in the order of the options that add them, each on the
+// table that the previous returned.
+func WithResultHook(hook func(flux.Table) (flux.Table, error)) Option {
+	return option(func(r *ScopeHolder) {
+		r.resultHooks = append(r.resultHooks, hook)
+	})
+}
+
+// hookResult returns a result with the tables of result passed
+// through the result hooks of the session, if it has any.
+func (r *ScopeHolder) hookResult(result flux.Result) flux.Result {
+	if len(r.resultHooks) == 0 {
+		return result
+	}
+	return &hookedResult{Result: result, hooks: r.resultHooks}
+}
+
+// hookedResult is a result whose tables are passed through hooks.
+type hookedResult struct {
+	flux.Result
+	hooks []func(flux.Table) (flux.Table, error)
+}
+
+func (r *hookedResult) Tables() flux.TableIterator {
+	return hookedTables{TableIterator: r.Result.Tables(), name: r.Name(), hooks: r.hooks}
+}
+
+type hookedTables struct {
+	flux.TableIterator
+	name  string
+	hooks []func(flux.Table) (flux.Table, error)
+}
+
+func (t hookedTables) Do(f func(flux.Table) error) error {
+	return t.TableIterator.Do(func(tbl flux.Table) error {
+		key := tbl.Key()
+		for _, hook := range t.hooks {
+			var err error
+			if tbl, err = hook(tbl); err != nil {
+				return errors.Wrapf(err, codes.Inherit, "result hook failed on table %s of result %q", key, t.name)
+			}
+		}
+		return f(tbl)
+	})
+}
@@ -0,0 +1,99 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/csv"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/memory"
+)
+
+// redactColumn returns a result hook that drops the column label
+// from every table.
+func redactColumn(label string) func(flux.Table) (flux.Table, error) {
+	return func(tbl flux.Table) (flux.Table, error) {
+		builder := execute.NewColListTableBuilder(tbl.Key(), memory.DefaultAllocator)
+		for _, c := range tbl.Cols() {
+			if c.Label == label {
+				continue
+			}
+			if _, err := builder.AddCol(c); err != nil {
+				return nil, err
+			}
+		}
+		colMap := execute.ColMap(nil, builder, tbl.Cols())
+		if err := execute.AppendMappedTable(tbl, builder, colMap); err != nil {
+			return nil, err
+		}
+		return builder.Table()
+	}
+}
+
+func hookTestResult() flux.Result {
+	cols := []flux.ColMeta{
+		{Label: "host", Type: flux.TString},
+		{Label: "password", Type: flux.TString},
+		{Label: "_value", Type: flux.TInt},
+	}
+	return &executetest.Result{
+		Nm: "_result",
+		Tbls: []*executetest.Table{
+			{KeyCols: []string{"host"}, ColMeta: cols, Data: [][]interface{}{{"a", "hunter2", int64(1)}}},
+			{KeyCols: []string{"host"}, ColMeta: cols, Data: [][]interface{}{{"b", "swordfish", int64(2)}}},
+		},
+	}
+}
+
+func TestScopeHolder_HookResult_Redact(t *testing.T) {
+	r := newTestHolder(WithResultHook(redactColumn("password")))
+
+	var buf bytes.Buffer
+	enc := csv.NewResultEncoder(csv.DefaultEncoderConfig())
+	if _, err := enc.Encode(&buf, r.hookResult(hookTestResult())); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, s := range []string{"password", "hunter2", "swordfish"} {
+		if strings.Contains(got, s) {
+			t.Fatalf("expected %q to be redacted from every table, got:\n%s", s, got)
+		}
+	}
+	for _, s := range []string{"a,1", "b,2"} {
+		if !strings.Contains(got, s) {
+			t.Fatalf("expected the other columns to be kept, got:\n%s", got)
+		}
+	}
+}
+
+func TestScopeHolder_HookResult_Error(t *testing.T) {
+	r := newTestHolder(WithResultHook(func(tbl flux.Table) (flux.Table, error) {
+		tbl.Done()
+		return nil, errors.New(codes.PermissionDenied, "column not allowed")
+	}))
+
+	enc := csv.NewResultEncoder(csv.DefaultEncoderConfig())
+	_, err := enc.Encode(&bytes.Buffer{}, r.hookResult(hookTestResult()))
+	if err == nil {
+		t.Fatal("expected the hook error")
+	}
+	if got := errors.Code(err); got != codes.PermissionDenied {
+		t.Fatalf("expected the code of the hook error, got %v: %v", got, err)
+	}
+	if !strings.Contains(err.Error(), `result "_result"`) || !strings.Contains(err.Error(), "host=a") {
+		t.Fatalf("expected the error to name the table and result, got: %v", err)
+	}
+}
+
+func TestScopeHolder_HookResult_None(t *testing.T) {
+	r := newTestHolder()
+	result := hookTestResult()
+	if got := r.hookResult(result); got != result {
+		t.Fatal("expected the result to be unchanged without hooks")
+	}
+}
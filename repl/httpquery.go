@@ -0,0 +1,175 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+const (
+	// DefaultMaxQueryBytes is the largest query that an
+	// HTTPQueryLoader fetches unless it sets another limit.
+	DefaultMaxQueryBytes = 1 << 20
+	// DefaultQueryFetchTimeout is how long an HTTPQueryLoader waits
+	// for a query unless it sets another timeout.
+	DefaultQueryFetchTimeout = 30 * time.Second
+)
+
+// WithHTTPQueryLoader sets the loader that fetches the input given
+// as an @http:// or @https:// URL, whether it is evaluated or prepared.
+// By default no input is fetched over HTTP.
+func WithHTTPQueryLoader(l *HTTPQueryLoader) Option {
+	return option(func(r *ScopeHolder) {
+		r.queryLoader = l
+	})
+}
+
+// HTTPQueryLoader loads queries as LoadQuery does, and also fetches
+// those given as an @http:// or @https:// URL, such as the canned
+// queries of an orchestrator that are hosted in one place.
+//
+// Only the hosts in AllowedHosts are fetched from, following
+// redirects, so that a query cannot make the process request
+// internal services. The zero HTTPQueryLoader allows no host.
+type HTTPQueryLoader struct {
+	// Client makes the requests. http.DefaultClient is used if it is nil.
+	Client *http.Client
+	// AllowedHosts are the hosts that queries may be fetched from,
+	// as a host name or a host and port. A host name allows every port.
+	AllowedHosts []string
+	// MaxBytes limits the size of a query, both as it is fetched
+	// and once it is decompressed.
+	// DefaultMaxQueryBytes is used if it is zero or less.
+	MaxBytes int64
+	// Timeout limits how long fetching a query may take.
+	// DefaultQueryFetchTimeout is used if it is zero or less.
+	Timeout time.Duration
+}
+
+// LoadQuery returns the Flux query q as LoadQuery does, except
+// that a q of "@" followed by an HTTP URL is fetched from the URL.
+func (l *HTTPQueryLoader) LoadQuery(q string) (string, error) {
+	return loadQuery(q, l)
+}
+
+// isQueryURL reports whether the query file s is an HTTP URL.
+func isQueryURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// fetch returns the query at rawURL.
+// A nil loader fetches nothing.
+func (l *HTTPQueryLoader) fetch(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", errors.Wrapf(err, codes.Invalid, "invalid query URL %q", rawURL)
+	}
+	if err := l.checkHost(u); err != nil {
+		return "", err
+	}
+
+	timeout := l.Timeout
+	if timeout <= 0 {
+		timeout = DefaultQueryFetchTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", errors.Wrapf(err, codes.Invalid, "invalid query URL %q", rawURL)
+	}
+	resp, err := l.client().Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", errors.Wrapf(err, codes.DeadlineExceeded, "timed out fetching query from %s after %v", u.Redacted(), timeout)
+		}
+		// A redirect to a host that is not allowed.
+		if uerr, ok := err.(*url.Error); ok {
+			if ferr, ok := uerr.Err.(*errors.Error); ok {
+				return "", ferr
+			}
+		}
+		return "", errors.Wrapf(err, codes.Unavailable, "failed to fetch query from %s", u.Redacted())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		code := codes.Unavailable
+		if resp.StatusCode == http.StatusNotFound {
+			code = codes.NotFound
+		}
+		return "", errors.Newf(code, "failed to fetch query from %s: %s", u.Redacted(), resp.Status)
+	}
+
+	max := l.MaxBytes
+	if max <= 0 {
+		max = DefaultMaxQueryBytes
+	}
+	if resp.ContentLength > max {
+		return "", errors.Newf(codes.ResourceExhausted, "query at %s is %d bytes, more than the limit of %d", u.Redacted(), resp.ContentLength, max)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, max+1))
+	if err != nil {
+		return "", errors.Wrapf(err, codes.Unavailable, "failed to fetch query from %s", u.Redacted())
+	}
+	if int64(len(data)) > max {
+		return "", errors.Newf(codes.ResourceExhausted, "query at %s is more than the limit of %d bytes", u.Redacted(), max)
+	}
+
+	if strings.HasSuffix(path.Base(u.Path), ".gz") || bytes.HasPrefix(data, gzipMagic) {
+		data, err = gunzip(data, max)
+		if err != nil {
+			return "", errors.Wrapf(err, codes.Invalid, "failed to decompress query from %s", u.Redacted())
+		}
+		if int64(len(data)) > max {
+			return "", errors.Newf(codes.ResourceExhausted, "query at %s is more than the limit of %d bytes once decompressed", u.Redacted(), max)
+		}
+	}
+	return string(data), nil
+}
+
+// client returns the client of the loader, which
+// refuses to follow a redirect to a host it does not allow.
+func (l *HTTPQueryLoader) client() *http.Client {
+	c := http.DefaultClient
+	if l.Client != nil {
+		c = l.Client
+	}
+	checked := *c
+	checked.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := l.checkHost(req.URL); err != nil {
+			return err
+		}
+		if c.CheckRedirect != nil {
+			return c.CheckRedirect(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New(codes.Unavailable, "stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &checked
+}
+
+// checkHost returns a permission denied error
+// unless the loader allows the host of u.
+func (l *HTTPQueryLoader) checkHost(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Newf(codes.Invalid, "cannot fetch a query over %q", u.Scheme)
+	}
+	if l != nil {
+		for _, host := range l.AllowedHosts {
+			if strings.EqualFold(host, u.Host) || strings.EqualFold(host, u.Hostname()) {
+				return nil
+			}
+		}
+	}
+	return errors.Newf(codes.PermissionDenied, "fetching queries from host %q is not allowed", u.Host)
+}
@@ -0,0 +1,91 @@
+package repl
+
+import (
+	"io"
+	"net/rpc"
+	"sync"
+	"time"
+)
+
+// WithIdleTimeout shuts the RPC server down once it has gone for d
+// without a request, which frees the session when a client goes away
+// without closing its connection. The server only times out while no
+// request is in flight, and shuts down as if the client had closed
+// the connection. A zero d, the default, never times out.
+func WithIdleTimeout(d time.Duration) Option {
+	return option(func(r *ScopeHolder) {
+		r.idleTimeout = d
+	})
+}
+
+// idleCodec wraps a server codec to close it once no request
+// has been read or answered for the timeout.
+type idleCodec struct {
+	rpc.ServerCodec
+	timeout time.Duration
+
+	mu       sync.Mutex
+	inflight int
+	closed   bool
+	timer    *time.Timer
+}
+
+func newIdleCodec(codec rpc.ServerCodec, timeout time.Duration) *idleCodec {
+	c := &idleCodec{ServerCodec: codec, timeout: timeout}
+	c.timer = time.AfterFunc(timeout, c.expire)
+	return c
+}
+
+func (c *idleCodec) ReadRequestHeader(req *rpc.Request) error {
+	if err := c.ServerCodec.ReadRequestHeader(req); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		// The timeout expired while the header was being read.
+		return io.EOF
+	}
+	c.inflight++
+	c.timer.Stop()
+	return nil
+}
+
+func (c *idleCodec) WriteResponse(resp *rpc.Response, body interface{}) error {
+	err := c.ServerCodec.WriteResponse(resp, body)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight--
+	if c.inflight == 0 && !c.closed {
+		c.timer.Reset(c.timeout)
+	}
+	return err
+}
+
+func (c *idleCodec) Close() error {
+	c.stop()
+	return c.ServerCodec.Close()
+}
+
+// expire closes the codec unless a request is in flight,
+// in which case the timer is restarted once it is answered.
+func (c *idleCodec) expire() {
+	c.mu.Lock()
+	if c.inflight > 0 || c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	c.mu.Unlock()
+	// Closing the connection unblocks the pending read, which makes
+	// the server stop as it does when the client closes its end.
+	_ = c.ServerCodec.Close()
+}
+
+// stop prevents the codec from timing out.
+func (c *idleCodec) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.timer.Stop()
+}
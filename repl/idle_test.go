@@ -0,0 +1,82 @@
+package repl
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+// startServe serves r on one end of a pipe and returns the other end
+// along with a channel that is closed once serve returns.
+func startServe(t *testing.T, r *ScopeHolder) (net.Conn, <-chan struct{}) {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	t.Cleanup(func() { _ = clientConn.Close() })
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.serve(serverConn)
+	}()
+	return clientConn, done
+}
+
+func TestScopeHolder_Serve_IdleTimeout(t *testing.T) {
+	r := newTestHolder(WithIdleTimeout(50 * time.Millisecond))
+	_, done := startServe(t, r)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after the idle timeout")
+	}
+}
+
+func TestScopeHolder_Serve_IdleTimeout_Reset(t *testing.T) {
+	r := newTestHolder(WithIdleTimeout(100 * time.Millisecond))
+	conn, done := startServe(t, r)
+
+	dec := json.NewDecoder(conn)
+	start := time.Now()
+	for time.Since(start) < 300*time.Millisecond {
+		// Any request resets the timer, even one that fails.
+		if _, err := conn.Write([]byte(`{"method": "Service.Missing", "id": 1, "params": [{}]}` + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		var resp rpcResponse
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error == nil {
+			t.Fatal("expected an error for a missing method")
+		}
+		select {
+		case <-done:
+			t.Fatal("serve returned while requests were still being sent")
+		case <-time.After(25 * time.Millisecond):
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after the idle timeout")
+	}
+}
+
+func TestScopeHolder_Serve_NoIdleTimeout(t *testing.T) {
+	r := newTestHolder()
+	conn, done := startServe(t, r)
+
+	select {
+	case <-done:
+		t.Fatal("serve returned without an idle timeout")
+	case <-time.After(100 * time.Millisecond):
+	}
+	_ = conn.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after the connection was closed")
+	}
+}
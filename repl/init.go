@@ -0,0 +1,74 @@
+package repl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// InitDirEnvVar is the environment variable that the flux command
+// consults for a directory of initialization scripts.
+const InitDirEnvVar = "FLUX_REPL_INIT_DIR"
+
+// WithInitDir configures a directory of .flux files that are evaluated
+// into the session scope, in lexical order, when the REPL starts.
+func WithInitDir(dir string) Option {
+	return option(func(r *ScopeHolder) {
+		r.initDir = dir
+	})
+}
+
+// WithStrictInit controls whether an error loading a file from the
+// init directory aborts startup. By default, errors are reported
+// and the remaining files are still loaded.
+func WithStrictInit(strict bool) Option {
+	return option(func(r *ScopeHolder) {
+		r.strictInit = strict
+	})
+}
+
+// InitErrors returns the errors encountered while loading the
+// init directory, one for each file that failed to load.
+func (r *ScopeHolder) InitErrors() []error {
+	return r.initErrors
+}
+
+// loadInitDir evaluates each .flux file in the init directory.
+// An error is only returned when strict initialization is enabled.
+func (r *ScopeHolder) loadInitDir() error {
+	if r.initDir == "" {
+		return nil
+	}
+
+	files, err := getFluxFiles(r.initDir + string(os.PathSeparator))
+	if err != nil {
+		return errors.Wrapf(err, codes.Invalid, "could not read init directory %q", r.initDir)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		if err := r.loadInitFile(file); err != nil {
+			if r.strictInit {
+				return err
+			}
+			r.initErrors = append(r.initErrors, err)
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+	}
+	return nil
+}
+
+func (r *ScopeHolder) loadInitFile(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return errors.Wrapf(err, codes.Invalid, "could not read init file %q", file)
+	}
+	if _, err := r.Eval(string(data)); err != nil {
+		return errors.Wrapf(err, codes.Inherit, "could not load init file %q", file)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package repl
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/influxdata/flux/fluxinit/static"
+)
+
+func writeInitFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestInitDir(t *testing.T) {
+	dir := writeInitFiles(t, map[string]string{
+		"a.flux":   "x = 1",
+		"b.flux":   "y = x + 1",
+		"c.flux":   "z = undefined + 1",
+		"skip.txt": "not flux",
+	})
+
+	r := New(context.Background(), WithInitDir(dir))
+	if got, want := len(r.InitErrors()), 1; got != want {
+		t.Fatalf("unexpected number of init errors -want/+got:\n\t- %d\n\t+ %d", want, got)
+	}
+
+	y, ok := r.scope.Lookup("y")
+	if !ok {
+		t.Fatal("expected y to be defined by the init directory")
+	}
+	if got, want := y.Int(), int64(2); got != want {
+		t.Fatalf("unexpected value for y -want/+got:\n\t- %d\n\t+ %d", want, got)
+	}
+	if _, ok := r.scope.Lookup("z"); ok {
+		t.Fatal("expected z to be undefined after a failed load")
+	}
+}
+
+func TestInitDir_Strict(t *testing.T) {
+	dir := writeInitFiles(t, map[string]string{
+		"a.flux": "x = ",
+	})
+
+	r := New(context.Background())
+	WithInitDir(dir).applyOption(r)
+	WithStrictInit(true).applyOption(r)
+	if err := r.loadInitDir(); err == nil {
+		t.Fatal("expected error loading init directory in strict mode")
+	}
+}
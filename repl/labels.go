@@ -0,0 +1,64 @@
+package repl
+
+import (
+	"context"
+	"sort"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/metadata"
+)
+
+// labelKeyPrefix prefixes the statistics metadata key under which
+// each label of a query is recorded.
+const labelKeyPrefix = "flux/label/"
+
+type labelsKey struct{}
+
+// WithQueryLabels returns a context that attaches labels, such as the
+// ID of a dashboard or the user that asked, to the queries run with it.
+// Each label is recorded in the statistics metadata of the queries under
+// "flux/label/" followed by its key, and tagged on their spans with
+// "label." followed by its key. Labels add to and replace those already
+// attached to ctx.
+func WithQueryLabels(ctx context.Context, labels map[string]string) context.Context {
+	if len(labels) == 0 {
+		return ctx
+	}
+	merged := make(map[string]string, len(labels))
+	for k, v := range queryLabels(ctx) {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, labelsKey{}, merged)
+}
+
+// queryLabels returns the labels attached to ctx with WithQueryLabels.
+func queryLabels(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(labelsKey{}).(map[string]string)
+	return labels
+}
+
+// addLabels records the labels in the statistics metadata.
+func addLabels(stats flux.Statistics, labels map[string]string) flux.Statistics {
+	if len(labels) == 0 {
+		return stats
+	}
+	if stats.Metadata == nil {
+		stats.Metadata = make(metadata.Metadata)
+	}
+	for _, k := range sortedLabelKeys(labels) {
+		stats.Metadata.Add(labelKeyPrefix+k, labels[k])
+	}
+	return stats
+}
+
+func sortedLabelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
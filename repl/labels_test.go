@@ -0,0 +1,59 @@
+package repl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestWithQueryLabels(t *testing.T) {
+	ctx := WithQueryLabels(context.Background(), map[string]string{"dashboard": "cpu", "user": "a"})
+	ctx = WithQueryLabels(ctx, map[string]string{"user": "b"})
+
+	want := map[string]string{"dashboard": "cpu", "user": "b"}
+	if got := queryLabels(ctx); !cmp.Equal(want, got) {
+		t.Fatalf("unexpected labels -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if got := queryLabels(context.Background()); got != nil {
+		t.Fatalf("expected no labels, got %v", got)
+	}
+}
+
+func TestAddLabels(t *testing.T) {
+	stats := addLabels(flux.Statistics{}, map[string]string{"dashboard": "cpu", "user": "a"})
+	for k, want := range map[string]string{"flux/label/dashboard": "cpu", "flux/label/user": "a"} {
+		if got := stats.Metadata.GetAll(k); !cmp.Equal([]interface{}{want}, got) {
+			t.Errorf("unexpected metadata for %s: %v", k, got)
+		}
+	}
+
+	// Statistics combined over several queries keep the labels of each.
+	stats = stats.Add(addLabels(flux.Statistics{}, map[string]string{"user": "b"}))
+	if got, want := stats.Metadata.GetAll("flux/label/user"), []interface{}{"a", "b"}; !cmp.Equal(want, got) {
+		t.Fatalf("unexpected combined labels -want/+got:\n%s", cmp.Diff(want, got))
+	}
+
+	if stats := addLabels(flux.Statistics{}, nil); stats.Metadata != nil {
+		t.Fatalf("expected no metadata without labels, got %v", stats.Metadata)
+	}
+}
+
+func TestScopeHolder_StartSpan_Labels(t *testing.T) {
+	tracer := mocktracer.New()
+	r := newTestHolder(WithTracer(tracer))
+
+	ctx := WithQueryLabels(context.Background(), map[string]string{"dashboard": "cpu"})
+	span, _ := r.startSpan(ctx, "repl.execute")
+	finishSpan(span, nil)
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 finished span, got %d", len(spans))
+	}
+	if got := spans[0].Tag("label.dashboard"); got != "cpu" {
+		t.Fatalf("expected the span to be tagged with the label, got %v", got)
+	}
+}
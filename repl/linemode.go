@@ -0,0 +1,39 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RunLineMode is a plain alternative to Run for use in shell pipelines.
+// Each line read from in is evaluated in the session scope and its
+// formatted output, tables included, is written to w.
+// Errors are written to errW and do not stop the loop.
+// RunLineMode returns once in is exhausted, or with the error
+// that prevented reading or writing.
+func (r *ScopeHolder) RunLineMode(in io.Reader, w, errW io.Writer) error {
+	br := bufio.NewReader(in)
+	for {
+		line, readErr := br.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+
+		if line = strings.TrimSpace(line); line != "" {
+			res, err := r.EvalString(r.ctx, line)
+			if err != nil {
+				if _, err := fmt.Fprintln(errW, "Error:", err); err != nil {
+					return err
+				}
+			} else if _, err := io.WriteString(w, res.Output); err != nil {
+				return err
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
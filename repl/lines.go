@@ -0,0 +1,50 @@
+package repl
+
+import "sync"
+
+// lineSink receives the result of each line of input that the session
+// processes on behalf of a client, such as the RPC service.
+type lineSink interface {
+	deliver(res lineResult)
+}
+
+// chanSink delivers each result on a channel, blocking until it is
+// received. It is the sink of the RPC server loop, whose service
+// methods wait on the channel for the result of their input.
+type chanSink chan lineResult
+
+func (s chanSink) deliver(res lineResult) {
+	s <- res
+}
+
+// returnSink keeps each result until it is taken, so that a caller
+// that processes lines itself can return their results directly.
+// Its zero value is ready to use.
+type returnSink struct {
+	mu      sync.Mutex
+	results []lineResult
+}
+
+func (s *returnSink) deliver(res lineResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, res)
+}
+
+// take returns the results delivered since it was last called, in order.
+func (s *returnSink) take() []lineResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := s.results
+	s.results = nil
+	return results
+}
+
+// multiSink delivers each result to every sink in turn.
+type multiSink []lineSink
+
+func (s multiSink) deliver(res lineResult) {
+	for _, sink := range s {
+		sink.deliver(res)
+	}
+}
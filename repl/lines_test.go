@@ -0,0 +1,59 @@
+package repl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func TestChanSink(t *testing.T) {
+	c := make(chan lineResult)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		chanSink(c).deliver(lineResult{outputs: []string{"2"}})
+	}()
+	res := <-c
+	<-done
+	if want := []string{"2"}; !cmp.Equal(want, res.outputs) {
+		t.Fatalf("unexpected outputs -want/+got:\n%s", cmp.Diff(want, res.outputs))
+	}
+}
+
+func TestReturnSink(t *testing.T) {
+	var s returnSink
+	boom := errors.New(codes.Invalid, "boom")
+	s.deliver(lineResult{outputs: []string{"1"}})
+	s.deliver(lineResult{err: boom})
+
+	results := s.take()
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if want := []string{"1"}; !cmp.Equal(want, results[0].outputs) {
+		t.Fatalf("unexpected outputs -want/+got:\n%s", cmp.Diff(want, results[0].outputs))
+	}
+	if results[1].err != boom {
+		t.Fatalf("unexpected error: %v", results[1].err)
+	}
+	if results := s.take(); len(results) != 0 {
+		t.Fatalf("expected the results to be taken once, got %d", len(results))
+	}
+}
+
+func TestMultiSink(t *testing.T) {
+	var first, second returnSink
+	c := make(chan lineResult, 1)
+	multiSink{&first, &second, chanSink(c)}.deliver(lineResult{outputs: []string{"x"}})
+
+	for i, s := range []*returnSink{&first, &second} {
+		if results := s.take(); len(results) != 1 || results[0].outputs[0] != "x" {
+			t.Fatalf("unexpected results of sink %d: %v", i, results)
+		}
+	}
+	if res := <-c; res.outputs[0] != "x" {
+		t.Fatalf("unexpected result on the channel: %v", res.outputs)
+	}
+}
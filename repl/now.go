@@ -0,0 +1,52 @@
+package repl
+
+import (
+	"context"
+	"time"
+
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+// WithFrozenNow sets the now option to the time the session is created,
+// so that every query in the session is evaluated against the same
+// reference time. Use SnapshotNow to move it forward.
+// An explicit option now = ... in the input still takes precedence.
+func WithFrozenNow() Option {
+	return option(func(r *ScopeHolder) {
+		r.freezeNow = true
+	})
+}
+
+// NowResponse is the response to Service.SnapshotNow.
+type NowResponse struct {
+	Now time.Time `json:"now"`
+}
+
+// SnapshotNow sets the now option to the current time.
+func (s *Service) SnapshotNow(req struct{}, resp *NowResponse) error {
+	*resp = NowResponse{Now: s.r.SnapshotNow()}
+	return nil
+}
+
+// SnapshotNow sets the now option to the current time
+// and returns the time that was set.
+func (r *ScopeHolder) SnapshotNow() time.Time {
+	r.evalMu.Lock()
+	defer r.evalMu.Unlock()
+	return r.setNow(time.Now())
+}
+
+// setNow sets the now option to a function that always returns t.
+// The option is bound in the overrides of the session rather than
+// updating the one from the prelude, which may be shared with other
+// sessions, or binding it in the session scope, where it would be
+// mistaken for a binding of the session.
+func (r *ScopeHolder) setNow(t time.Time) time.Time {
+	v := values.NewFunction("now", semantic.NewFunctionType(semantic.BasicTime, nil),
+		func(ctx context.Context, args values.Object) (values.Value, error) {
+			return values.NewTime(values.ConvertTime(t)), nil
+		}, false)
+	r.overrides.Set("now", &values.Option{Value: v})
+	return t
+}
@@ -0,0 +1,46 @@
+package repl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influxdata/flux/values"
+)
+
+func lookupNow(t *testing.T, r *ScopeHolder) time.Time {
+	t.Helper()
+	v, ok := r.scope.Lookup("now")
+	if !ok {
+		t.Fatal("now option not set")
+	}
+	now, err := v.Function().Call(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return now.Time().Time()
+}
+
+func TestScopeHolder_SnapshotNow(t *testing.T) {
+	r := newTestHolder()
+	r.nestSessionScope(values.NewScope())
+
+	frozen := r.setNow(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	if got := lookupNow(t, r); !got.Equal(frozen) {
+		t.Fatalf("unexpected now: got %v, want %v", got, frozen)
+	}
+	if _, ok := r.scope.LocalLookup("now"); ok {
+		t.Fatal("expected now to be bound outside of the session scope")
+	}
+	if got := lookupNow(t, r); !got.Equal(frozen) {
+		t.Fatalf("expected now to stay frozen: got %v, want %v", got, frozen)
+	}
+
+	snapshot := r.SnapshotNow()
+	if !snapshot.After(frozen) {
+		t.Fatalf("expected the snapshot to move now forward, got %v", snapshot)
+	}
+	if got := lookupNow(t, r); !got.Equal(snapshot) {
+		t.Fatalf("unexpected now: got %v, want %v", got, snapshot)
+	}
+}
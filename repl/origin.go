@@ -0,0 +1,97 @@
+package repl
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+)
+
+// OriginRequest names a symbol whose origin to report.
+// Members of imported packages are named as package.member.
+type OriginRequest struct {
+	Name string `json:"name"`
+}
+
+// OriginResponse reports where a symbol in scope comes from.
+type OriginResponse struct {
+	Name string `json:"name"`
+	// Package is the path of the package the symbol was imported from.
+	Package string `json:"package,omitempty"`
+	// Session is set when the symbol is bound by the session rather
+	// than the prelude. Package is then the package of the prelude
+	// name that it hides, if any.
+	Session bool `json:"session,omitempty"`
+}
+
+// Origin reports where a symbol in scope comes from.
+func (s *Service) Origin(req OriginRequest, resp *OriginResponse) error {
+	origin, err := s.r.Origin(req.Name)
+	if err != nil {
+		return err
+	}
+	*resp = origin
+	return nil
+}
+
+// Origin reports which package the symbol bound to name in the session
+// scope comes from. The prelude is made of several packages flattened
+// into one scope, and this tells, for instance, that filter comes from
+// "universe". When packages of the prelude bind the same name, the
+// package whose binding is visible is reported.
+//
+// A not found error is returned if name is not bound in scope.
+func (r *ScopeHolder) Origin(name string) (OriginResponse, error) {
+	r.evalMu.Lock()
+	defer r.evalMu.Unlock()
+
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		return r.memberOrigin(name, name[:i], name[i+1:])
+	}
+
+	if _, ok := r.scope.Lookup(name); !ok {
+		return OriginResponse{}, errors.Newf(codes.NotFound, "%s is not defined", name)
+	}
+	pkg, _ := r.origins.lookup(name)
+	_, session := r.scope.LocalLookup(name)
+	return OriginResponse{Name: name, Package: pkg, Session: session}, nil
+}
+
+// memberOrigin reports the origin of a member of an imported package.
+func (r *ScopeHolder) memberOrigin(name, pkgName, member string) (OriginResponse, error) {
+	v, ok := r.scope.Lookup(pkgName)
+	if !ok {
+		return OriginResponse{}, errors.Newf(codes.NotFound, "%s is not defined", pkgName)
+	}
+	pkg, ok := v.(*interpreter.Package)
+	if !ok {
+		return OriginResponse{}, errors.Newf(codes.Invalid, "%s is not a package", pkgName)
+	}
+	if _, ok := pkg.Get(member); !ok {
+		return OriginResponse{}, errors.Newf(codes.NotFound, "%s is not defined", name)
+	}
+	return OriginResponse{Name: name, Package: pkg.Path()}, nil
+}
+
+// preludeOrigins maps each name of the prelude to the path of the
+// package it was imported from. Its zero value is ready to use.
+type preludeOrigins struct {
+	mu   sync.Mutex
+	pkgs map[string]string
+}
+
+// set replaces the origins with those of a newly imported prelude.
+func (o *preludeOrigins) set(pkgs map[string]string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pkgs = pkgs
+}
+
+func (o *preludeOrigins) lookup(name string) (string, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	pkg, ok := o.pkgs[name]
+	return pkg, ok
+}
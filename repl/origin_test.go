@@ -0,0 +1,68 @@
+package repl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/values"
+)
+
+func TestScopeHolder_Origin(t *testing.T) {
+	for _, lazy := range []bool{false, true} {
+		var opts []Option
+		if lazy {
+			opts = append(opts, WithPrelude("universe"))
+		}
+		r := newTestHolder(opts...)
+		r.importer = &preludeImporter{imported: make(map[string]int)}
+		prelude, err := r.newPreludeScope(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.scope = prelude.Nest(nil)
+		r.scope.Set("x", values.NewInt(1))
+		r.scope.Set("universe", values.NewInt(2))
+		r.scope.Set("strings", interpreter.NewPackageWithValues("strings", "strings", values.NewObjectWithValues(map[string]values.Value{
+			"title": values.NewString("title"),
+		})))
+
+		last := runtime.PreludeList[len(runtime.PreludeList)-1]
+		// Packages that bind the same name are reported by the one
+		// that is visible, which is the only one imported up front
+		// when the prelude is lazy.
+		shared := last
+		if lazy {
+			shared = "universe"
+		}
+		for _, want := range []OriginResponse{
+			{Name: "shared", Package: shared},
+			{Name: last, Package: last},
+			{Name: "x", Session: true},
+			{Name: "universe", Package: "universe", Session: true},
+			{Name: "strings.title", Package: "strings"},
+		} {
+			got, err := r.Origin(want.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(want, got) {
+				t.Fatalf("unexpected origin of %s (lazy %v) -want/+got:\n%s", want.Name, lazy, cmp.Diff(want, got))
+			}
+		}
+
+		for name, code := range map[string]codes.Code{
+			"missing":         codes.NotFound,
+			"strings.missing": codes.NotFound,
+			"x.y":             codes.Invalid,
+		} {
+			if _, err := r.Origin(name); errors.Code(err) != code {
+				t.Fatalf("unexpected error for %s: got %v want code %v", name, err, code)
+			}
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package repl
+
+// OutputKind tells what an Output holds.
+type OutputKind string
+
+const (
+	// OutputValue is the displayed value of an expression statement
+	// that does not produce tables.
+	OutputValue OutputKind = "value"
+	// OutputTables is the formatted results of the query of an
+	// expression statement that produces tables.
+	OutputTables OutputKind = "tables"
+)
+
+// Output is the output of an expression statement of a line. The
+// outputs of a line are delivered together, in the order of their
+// statements, whether they are values or tables, so that a client
+// receives everything that a line produces in one place.
+type Output struct {
+	Kind OutputKind `json:"kind"`
+	// Type is the Flux type of the value of the statement.
+	Type string `json:"type"`
+	// Text is the displayed value, or the results of the query as
+	// they are written to the session output. It is empty for a
+	// query whose result repeats an earlier one; see Response.Aliases.
+	Text string `json:"text"`
+}
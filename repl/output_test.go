@@ -0,0 +1,30 @@
+package repl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLineResult_Response_Output(t *testing.T) {
+	output := []Output{
+		{Kind: OutputValue, Type: "int", Text: "2"},
+		{Kind: OutputTables, Type: "stream[{_value: int}]", Text: "Result: _result\n"},
+	}
+	var resp Response
+	if err := (lineResult{outputs: []string{"2"}, output: output}).response(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(output, resp.Output) {
+		t.Fatalf("unexpected output -want/+got:\n%s", cmp.Diff(output, resp.Output))
+	}
+
+	data, err := json.Marshal(resp.Output[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"kind":"value","type":"int","text":"2"}`; got != want {
+		t.Fatalf("unexpected encoding of an output -want/+got:\n\t- %s\n\t+ %s", want, got)
+	}
+}
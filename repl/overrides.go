@@ -0,0 +1,22 @@
+package repl
+
+import "github.com/influxdata/flux/values"
+
+// nestSessionScope replaces the session scope with an empty one nested
+// within new overrides of prelude. The bindings the options of the
+// session make in place of the prelude live in the overrides rather
+// than the session scope, so that they are not exported as bindings
+// of the session and are made once each time the scope is rebuilt.
+func (r *ScopeHolder) nestSessionScope(prelude values.Scope) {
+	r.prelude = prelude
+	r.overrides = r.newOverrides(prelude)
+	r.scope = r.overrides.Nest(nil)
+}
+
+// newOverrides returns a scope nested within prelude that binds the
+// version of from with the default bucket and org of the session.
+func (r *ScopeHolder) newOverrides(prelude values.Scope) values.Scope {
+	scope := prelude.Nest(nil)
+	r.bindDefaultSource(scope)
+	return scope
+}
@@ -0,0 +1,298 @@
+package repl
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+const (
+	// defaultPageSize is the number of rows in a page when none is requested.
+	defaultPageSize = 1000
+
+	// maxOpenPages is the number of buffered results kept for pagination.
+	// Opening another result invalidates the cursors of the oldest one.
+	maxOpenPages = 16
+
+	// defaultPageRowLimit is the number of rows of a result that
+	// FetchPage buffers when the session has no row limit.
+	defaultPageRowLimit = 100000
+)
+
+// PageRequest is the request for Service.FetchPage.
+type PageRequest struct {
+	// Query is evaluated and buffered when Cursor is empty.
+	// It must produce exactly one table.
+	Query string `json:"query"`
+	// Cursor resumes a previously buffered result.
+	Cursor string `json:"cursor"`
+	// Size is the maximum number of rows to return.
+	Size int `json:"size"`
+}
+
+// PageColumn describes a column in a PageResponse.
+type PageColumn struct {
+	Label string `json:"label"`
+	Type  string `json:"type"`
+}
+
+// PageResponse is a bounded set of rows from a buffered result.
+type PageResponse struct {
+	Columns []PageColumn    `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+	// Cursor fetches the next page.
+	// It is empty when there are no more rows.
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// FetchPage returns a page of rows from the single table produced by a query.
+//
+// The query is executed once, when a request without a cursor is made,
+// and its rows are buffered. The result may hold at most as many rows
+// as WithRowLimit allows, or 100000 if the session has no row limit.
+// Each response but the last carries a cursor for the next page, and
+// fetching the same cursor again returns the same page for as long as
+// the result is open.
+//
+// The result is dropped once its last page is returned, so none of its
+// cursors can be fetched after that, not even the one of the last page.
+// It is also dropped when more than 16 results are open at once, for
+// the oldest result, when the session is reset, and when it is deleted
+// as a resource of kind "cursor". Using the cursor of a dropped result
+// returns a not found error.
+func (s *Service) FetchPage(req PageRequest, resp *PageResponse) error {
+	page, err := s.r.FetchPage(s.r.ctx, req)
+	if err != nil {
+		return err
+	}
+	*resp = page
+	return nil
+}
+
+// FetchPage returns a page of rows. See Service.FetchPage.
+func (r *ScopeHolder) FetchPage(ctx context.Context, req PageRequest) (PageResponse, error) {
+	size := req.Size
+	if size <= 0 {
+		size = defaultPageSize
+	}
+
+	if req.Cursor != "" {
+		id, offset, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return PageResponse{}, err
+		}
+		return r.pages.page(id, offset, size)
+	}
+
+	buf, err := r.bufferTable(ctx, req.Query)
+	if err != nil {
+		return PageResponse{}, err
+	}
+	id := r.pages.add(buf)
+	return r.pages.page(id, 0, size)
+}
+
+// bufferTable evaluates the query and materializes the single table it produces.
+func (r *ScopeHolder) bufferTable(ctx context.Context, t string) (*pagedTable, error) {
+	ses, _, err := r.evalWithFluxError(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	specs, err := r.tableSpecs(ctx, ses)
+	if err != nil {
+		return nil, err
+	}
+	if len(specs) != 1 {
+		return nil, errors.Newf(codes.Invalid, "pagination requires exactly one query, found %d", len(specs))
+	}
+
+	limit := r.rowLimit
+	if limit <= 0 {
+		limit = defaultPageRowLimit
+	}
+	var (
+		buf    *pagedTable
+		tables int
+	)
+	if _, err := r.runQuery(ctx, specs[0], func(result flux.Result) error {
+		return result.Tables().Do(func(tbl flux.Table) error {
+			tables++
+			if tables > 1 {
+				return errors.New(codes.Invalid, "pagination requires a query that produces a single table")
+			}
+			buf = newPagedTable(tbl.Cols())
+			return tbl.Do(func(cr flux.ColReader) error {
+				if len(buf.rows)+cr.Len() > limit {
+					return errors.Newf(codes.ResourceExhausted, "the result has more than %d rows to page through", limit)
+				}
+				for i := 0; i < cr.Len(); i++ {
+					row := make([]interface{}, len(cr.Cols()))
+					for j := range row {
+						row[j] = rowValue(execute.ValueForRow(cr, i, j))
+					}
+					buf.rows = append(buf.rows, row)
+				}
+				return nil
+			})
+		})
+	}); err != nil {
+		return nil, err
+	}
+	if buf == nil {
+		return nil, errors.New(codes.Invalid, "pagination requires a query that produces a single table")
+	}
+	return buf, nil
+}
+
+// rowValue converts a column value into its JSON representation.
+func rowValue(v values.Value) interface{} {
+	if !v.IsNull() && v.Type().Nature() == semantic.Time {
+		return v.Time().Time()
+	}
+	return values.Unwrap(v)
+}
+
+// pagedTable is a buffered table being paged through.
+type pagedTable struct {
+	columns []PageColumn
+	rows    [][]interface{}
+	created time.Time
+}
+
+func newPagedTable(cols []flux.ColMeta) *pagedTable {
+	columns := make([]PageColumn, len(cols))
+	for i, c := range cols {
+		columns[i] = PageColumn{Label: c.Label, Type: c.Type.String()}
+	}
+	return &pagedTable{columns: columns}
+}
+
+// pageStore holds the buffered tables with open cursors.
+// The zero value is ready to use.
+type pageStore struct {
+	mu     sync.Mutex
+	nextID int
+	order  []int
+	tables map[int]*pagedTable
+}
+
+func (s *pageStore) add(t *pagedTable) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tables == nil {
+		s.tables = make(map[int]*pagedTable)
+	}
+	s.nextID++
+	id := s.nextID
+	t.created = time.Now()
+	s.tables[id] = t
+	s.order = append(s.order, id)
+	for len(s.order) > maxOpenPages {
+		delete(s.tables, s.order[0])
+		s.order = s.order[1:]
+	}
+	return id
+}
+
+func (s *pageStore) page(id, offset, size int) (PageResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tables[id]
+	if !ok || offset > len(t.rows) {
+		return PageResponse{}, errors.New(codes.NotFound, "page cursor is no longer valid")
+	}
+
+	end := offset + size
+	if end > len(t.rows) {
+		end = len(t.rows)
+	}
+	resp := PageResponse{
+		Columns: t.columns,
+		Rows:    t.rows[offset:end],
+	}
+	if end < len(t.rows) {
+		resp.Cursor = encodeCursor(id, end)
+	} else {
+		s.remove(id)
+	}
+	return resp, nil
+}
+
+// resources lists the open results, identified by the number
+// that their cursors are made from.
+func (s *pageStore) resources() []Resource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resources := make([]Resource, 0, len(s.tables))
+	for id, t := range s.tables {
+		resources = append(resources, Resource{Kind: ResourceCursor, ID: strconv.Itoa(id), Created: t.created, Status: "open"})
+	}
+	return resources
+}
+
+// drop drops the open result listed under id,
+// and reports whether there was one.
+func (s *pageStore) drop(id string) bool {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tables[n]; !ok {
+		return false
+	}
+	s.remove(n)
+	return true
+}
+
+// clear drops every open result.
+func (s *pageStore) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tables, s.order = nil, nil
+}
+
+// remove drops a table. The lock must be held.
+func (s *pageStore) remove(id int) {
+	delete(s.tables, id)
+	for i, v := range s.order {
+		if v == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func encodeCursor(id, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%d", id, offset)))
+}
+
+func decodeCursor(cursor string) (id, offset int, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, errors.New(codes.Invalid, "malformed page cursor")
+	}
+	parts := strings.SplitN(string(data), ".", 2)
+	if len(parts) != 2 {
+		return 0, 0, errors.New(codes.Invalid, "malformed page cursor")
+	}
+	if id, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, errors.New(codes.Invalid, "malformed page cursor")
+	}
+	if offset, err = strconv.Atoi(parts[1]); err != nil || offset < 0 {
+		return 0, 0, errors.New(codes.Invalid, "malformed page cursor")
+	}
+	return id, offset, nil
+}
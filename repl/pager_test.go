@@ -0,0 +1,92 @@
+package repl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func newTestPagedTable(n int) *pagedTable {
+	t := &pagedTable{columns: []PageColumn{{Label: "_value", Type: "int"}}}
+	for i := 0; i < n; i++ {
+		t.rows = append(t.rows, []interface{}{int64(i)})
+	}
+	return t
+}
+
+func TestPageStore(t *testing.T) {
+	var s pageStore
+	id := s.add(newTestPagedTable(5))
+
+	first, err := s.page(id, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]interface{}{{int64(0)}, {int64(1)}}; !cmp.Equal(want, first.Rows) {
+		t.Fatalf("unexpected rows -want/+got:\n%s", cmp.Diff(want, first.Rows))
+	}
+	if first.Cursor == "" {
+		t.Fatal("expected a cursor for the next page")
+	}
+
+	// Fetching the same cursor twice returns the same page.
+	for i := 0; i < 2; i++ {
+		cid, offset, err := decodeCursor(first.Cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := s.page(cid, offset, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := [][]interface{}{{int64(2)}, {int64(3)}}; !cmp.Equal(want, second.Rows) {
+			t.Fatalf("unexpected rows -want/+got:\n%s", cmp.Diff(want, second.Rows))
+		}
+	}
+
+	last, err := s.page(id, 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Cursor != "" || len(last.Rows) != 1 {
+		t.Fatalf("expected a final page with one row and no cursor, got %v", last)
+	}
+
+	// The last page invalidates the cursor.
+	if _, err := s.page(id, 0, 2); errors.Code(err) != codes.NotFound {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestPageStore_Eviction(t *testing.T) {
+	var s pageStore
+	first := s.add(newTestPagedTable(1))
+	for i := 0; i < maxOpenPages; i++ {
+		s.add(newTestPagedTable(1))
+	}
+	if _, err := s.page(first, 0, 1); errors.Code(err) != codes.NotFound {
+		t.Fatalf("expected oldest result to be evicted, got %v", err)
+	}
+}
+
+func TestPageStore_Clear(t *testing.T) {
+	var s pageStore
+	id := s.add(newTestPagedTable(5))
+	s.clear()
+	if _, err := s.page(id, 0, 1); errors.Code(err) != codes.NotFound {
+		t.Fatalf("expected the result to be dropped, got %v", err)
+	}
+	if got := s.resources(); len(got) != 0 {
+		t.Fatalf("expected no open results, got %v", got)
+	}
+}
+
+func TestDecodeCursor_Malformed(t *testing.T) {
+	for _, cursor := range []string{"!!!", encodeCursor(1, 0)[:2], "MQ"} {
+		if _, _, err := decodeCursor(cursor); errors.Code(err) != codes.Invalid {
+			t.Errorf("expected invalid error for cursor %q, got %v", cursor, err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/values"
+)
+
+// ParamsRequest is the params object for Service.EvalParams.
+type ParamsRequest struct {
+	Input string `json:"input"`
+	// Params maps the name of each parameter to its value.
+	// JSON numbers without a fraction or exponent become ints,
+	// other numbers floats. Times are passed as strings and
+	// converted in the query, for example with time(v: start).
+	Params map[string]interface{} `json:"params"`
+	// Labels are attached to the queries run by the input
+	// as described by WithQueryLabels.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// UnmarshalJSON decodes the params object, keeping the
+// distinction between integer and float parameters.
+func (req *ParamsRequest) UnmarshalJSON(data []byte) error {
+	type raw ParamsRequest
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode((*raw)(req)); err != nil {
+		return errors.Wrap(err, codes.Invalid, "malformed params")
+	}
+	return nil
+}
+
+// EvalParams evaluates the input like DidOutput, with the given
+// parameters bound for the duration of the input.
+func (s *Service) EvalParams(req ParamsRequest, resp *Response) error {
+	ctx, end := s.r.beginLine(WithQueryLabels(s.r.ctx, req.Labels))
+	defer end()
+	scope, err := s.r.bindParams(ctx, req.Params)
+	if err != nil {
+		return err
+	}
+	res, fluxError, err := s.r.executeLineIn(ctx, req.Input, scope)
+	s.r.setLineError(&res, fluxError, err)
+	return res.response(resp)
+}
+
+// EvalWithParams evaluates the Flux source t like EvalString, with
+// each parameter in params bound to its name in a scope that is nested
+// within the session scope and discarded afterwards. Parameters are
+// bound as values rather than spliced into t, so they cannot change
+// the meaning of the query and are type checked along with it.
+//
+// Parameters may be strings, integers, floats, booleans, times,
+// durations, values.Value or, as decoded from JSON, json.Number.
+func (r *ScopeHolder) EvalWithParams(ctx context.Context, t string, params map[string]interface{}) (Result, error) {
+	ctx, end := r.beginLine(ctx)
+	defer end()
+	scope, err := r.bindParams(ctx, params)
+	if err != nil {
+		return Result{}, err
+	}
+	return r.evalString(ctx, t, scope)
+}
+
+// bindParams returns a scope nested within the session scope
+// with each parameter bound to its name.
+func (r *ScopeHolder) bindParams(ctx context.Context, params map[string]interface{}) (values.Scope, error) {
+	bindings, err := paramBindings(params)
+	if err != nil {
+		return nil, err
+	}
+	scope := r.nestScope()
+	if _, _, err := r.evalInScope(ctx, bindings, scope); err != nil {
+		return nil, err
+	}
+	return scope, nil
+}
+
+// paramBindings returns Flux source that binds each parameter.
+func paramBindings(params map[string]interface{}) (string, error) {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		if !identifier.MatchString(name) {
+			return "", errors.Newf(codes.Invalid, "parameter name %q is not an identifier", name)
+		}
+		v, err := paramValue(params[name])
+		if err != nil {
+			return "", errors.Wrapf(err, codes.Invalid, "parameter %s", name)
+		}
+		lit, err := fluxLiteral(v)
+		if err != nil {
+			return "", errors.Wrapf(err, codes.Invalid, "parameter %s", name)
+		}
+		fmt.Fprintf(&sb, "%s = %s\n", name, lit)
+	}
+	return sb.String(), nil
+}
+
+// paramValue converts a parameter into a Flux value.
+func paramValue(v interface{}) (values.Value, error) {
+	switch v := v.(type) {
+	case values.Value:
+		return v, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return values.NewInt(i), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return values.NewFloat(f), nil
+	case int:
+		return values.NewInt(int64(v)), nil
+	case uint:
+		return values.NewUInt(uint64(v)), nil
+	case time.Time:
+		return values.NewTime(values.ConvertTime(v)), nil
+	case time.Duration:
+		return values.NewDuration(values.ConvertDurationNsecs(v)), nil
+	case string, int64, uint64, float64, bool:
+		return values.New(v), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+}
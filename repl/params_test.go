@@ -0,0 +1,62 @@
+package repl
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/values"
+)
+
+func TestParamBindings(t *testing.T) {
+	got, err := paramBindings(map[string]interface{}{
+		"bucket": `telegraf" |> drop()`,
+		"n":      5,
+		"start":  time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		"every":  time.Minute,
+		"ratio":  0.5,
+		"v":      values.NewBool(true),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `bucket = "telegraf\" |> drop()"
+every = 1m
+n = 5
+ratio = 0.5
+start = 2021-01-01T00:00:00Z
+v = true
+`
+	if got != want {
+		t.Fatalf("unexpected bindings -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestParamBindings_Invalid(t *testing.T) {
+	for name, params := range map[string]map[string]interface{}{
+		"name":  {"a b": 1},
+		"type":  {"x": struct{}{}},
+		"value": {"x": values.NewNull(values.NewInt(0).Type())},
+	} {
+		if _, err := paramBindings(params); errors.Code(err) != codes.Invalid {
+			t.Errorf("expected an invalid %s to be rejected, got %v", name, err)
+		}
+	}
+}
+
+func TestParamsRequest_UnmarshalJSON(t *testing.T) {
+	var req ParamsRequest
+	if err := json.Unmarshal([]byte(`{"input": "n", "params": {"n": 1, "f": 1.5, "s": "a"}}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	bindings, err := paramBindings(req.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "f = 1.5\nn = 1\ns = \"a\"\n"; bindings != want {
+		t.Fatalf("unexpected bindings -want/+got:\n%s", cmp.Diff(want, bindings))
+	}
+}
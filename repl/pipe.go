@@ -0,0 +1,292 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/values"
+)
+
+// ProducerName is the name by which the consumer passed to EvalPiped
+// refers to the result of the producer.
+const ProducerName = "producer"
+
+// defaultPipeRowLimit is the number of rows of a producer that
+// EvalPiped holds in memory when the session has no row limit.
+const defaultPipeRowLimit = 100000
+
+// pipedKind is the kind of the source that reads
+// the tables of a producer from memory.
+const pipedKind = "repl.piped"
+
+func init() {
+	plan.RegisterProcedureSpec(pipedKind, newPipedProcedure, pipedKind)
+	execute.RegisterSource(pipedKind, createPipedSource)
+}
+
+// PipeRequest is the params object for Service.EvalPiped.
+type PipeRequest struct {
+	Producer string `json:"producer"`
+	Consumer string `json:"consumer"`
+	// Labels are attached to the queries of both
+	// as described by WithQueryLabels.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// EvalPiped runs the producer and evaluates the consumer
+// like DidOutput, as described by ScopeHolder.EvalPiped.
+func (s *Service) EvalPiped(req PipeRequest, resp *Response) error {
+	ctx, end := s.r.beginLine(WithQueryLabels(s.r.ctx, req.Labels))
+	defer end()
+	scope, release, err := s.r.pipeProducer(ctx, req.Producer)
+	if err != nil {
+		return err
+	}
+	defer release()
+	res, fluxError, err := s.r.executeLineIn(ctx, req.Consumer, scope)
+	s.r.setLineError(&res, fluxError, err)
+	return res.response(resp)
+}
+
+// EvalPiped runs the query in the Flux source producer once, reads the
+// tables of its only result into memory and then evaluates the Flux
+// source consumer like EvalString, with those tables bound to
+// ProducerName in a scope that is nested within the session scope.
+// The consumer may read the producer any number of times without
+// running it again.
+//
+// The producer may hold at most as many rows as WithRowLimit allows,
+// or 100000 if the session has no row limit.
+func (r *ScopeHolder) EvalPiped(ctx context.Context, producer, consumer string) (Result, error) {
+	ctx, end := r.beginLine(ctx)
+	defer end()
+	scope, release, err := r.pipeProducer(ctx, producer)
+	if err != nil {
+		return Result{}, err
+	}
+	defer release()
+	return r.evalString(ctx, consumer, scope)
+}
+
+// pipeProducer runs the producer and returns a scope with its tables
+// bound to ProducerName, along with a function that releases the
+// tables once the scope is no longer used.
+func (r *ScopeHolder) pipeProducer(ctx context.Context, producer string) (values.Scope, func(), error) {
+	tables, err := r.materialize(ctx, producer)
+	if err != nil {
+		return nil, nil, err
+	}
+	release := func() { releaseTables(tables) }
+
+	scope, err := r.bindProducer(ctx, tables)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return scope, release, nil
+}
+
+func releaseTables(tables []flux.BufferedTable) {
+	for _, tbl := range tables {
+		tbl.Done()
+	}
+}
+
+// materialize runs the single query of the Flux source t and returns
+// the tables of its result read into memory.
+func (r *ScopeHolder) materialize(ctx context.Context, t string) ([]flux.BufferedTable, error) {
+	ctx, stop := r.watch(ctx)
+	defer stop()
+
+	ses, err := r.evalNested(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	specs, err := r.tableSpecs(ctx, ses)
+	if err != nil {
+		return nil, err
+	}
+	if len(specs) != 1 {
+		return nil, errors.Newf(codes.Invalid, "the producer must run exactly one query, found %d", len(specs))
+	}
+
+	limit := r.rowLimit
+	if limit <= 0 {
+		limit = defaultPipeRowLimit
+	}
+	var tables []flux.BufferedTable
+	if _, err := r.retryQuery(ctx, func() (flux.Statistics, error) {
+		releaseTables(tables)
+		tables = nil
+		rows, results := 0, 0
+		return r.runQuery(ctx, specs[0], func(result flux.Result) error {
+			if results++; results > 1 {
+				return errors.New(codes.Invalid, "the producer must produce exactly one result")
+			}
+			return result.Tables().Do(func(tbl flux.Table) error {
+				buf, err := execute.CopyTable(tbl)
+				if err != nil {
+					return err
+				}
+				tables = append(tables, buf)
+				for i := 0; i < buf.BufferN(); i++ {
+					rows += buf.Buffer(i).Len()
+				}
+				if rows > limit {
+					return errors.Newf(codes.ResourceExhausted, "the producer returned more than %d rows", limit)
+				}
+				return nil
+			})
+		})
+	}); err != nil {
+		releaseTables(tables)
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return nil, errors.New(codes.Invalid, "the producer returned no tables")
+	}
+	return tables, nil
+}
+
+// bindProducer returns a scope nested within the session scope
+// with the tables bound to ProducerName.
+//
+// The binding is first made in Flux with a single row that has the
+// columns of the tables, so that the consumer is type checked against
+// them, and its value is then replaced by a stream of the tables.
+func (r *ScopeHolder) bindProducer(ctx context.Context, tables []flux.BufferedTable) (values.Scope, error) {
+	row, err := sampleRow(tables)
+	if err != nil {
+		return nil, err
+	}
+	lit, err := fluxLiteral(row)
+	if err != nil {
+		return nil, err
+	}
+	src := fmt.Sprintf("import \"array\"\n%s = array.from(rows: [%s])\n", ProducerName, lit)
+
+	scope := r.nestScope()
+	if _, _, err := r.evalInScope(ctx, src, scope); err != nil {
+		return nil, err
+	}
+	v, _ := scope.Lookup(ProducerName)
+	obj, ok := v.(*flux.TableObject)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "expected %s to be a stream, got %T", ProducerName, v)
+	}
+	piped := *obj
+	piped.Kind = pipedKind
+	piped.Spec = &pipedOpSpec{tables: tables}
+	scope.Set(ProducerName, &piped)
+	return scope, nil
+}
+
+// sampleRow returns a record with a zero value for every column of the tables.
+func sampleRow(tables []flux.BufferedTable) (values.Object, error) {
+	cols := make(map[string]values.Value)
+	types := make(map[string]flux.ColType)
+	for _, tbl := range tables {
+		for _, c := range tbl.Cols() {
+			if typ, ok := types[c.Label]; ok {
+				if typ != c.Type {
+					return nil, errors.Newf(codes.Invalid, "column %q of the producer has type %s in one table and %s in another", c.Label, typ, c.Type)
+				}
+				continue
+			}
+			v, err := zeroValue(c.Type)
+			if err != nil {
+				return nil, errors.Wrapf(err, codes.Inherit, "column %q of the producer", c.Label)
+			}
+			types[c.Label] = c.Type
+			cols[c.Label] = v
+		}
+	}
+	return values.NewObjectWithValues(cols), nil
+}
+
+func zeroValue(typ flux.ColType) (values.Value, error) {
+	switch typ {
+	case flux.TBool:
+		return values.NewBool(false), nil
+	case flux.TInt:
+		return values.NewInt(0), nil
+	case flux.TUInt:
+		return values.NewUInt(0), nil
+	case flux.TFloat:
+		return values.NewFloat(0), nil
+	case flux.TString:
+		return values.NewString(""), nil
+	case flux.TTime:
+		return values.NewTime(0), nil
+	default:
+		return nil, errors.Newf(codes.Invalid, "unsupported column type %s", typ)
+	}
+}
+
+// pipedOpSpec is the operation that reads the tables of a producer.
+type pipedOpSpec struct {
+	tables []flux.BufferedTable
+}
+
+func (s *pipedOpSpec) Kind() flux.OperationKind {
+	return pipedKind
+}
+
+type pipedProcedureSpec struct {
+	plan.DefaultCost
+	tables []flux.BufferedTable
+}
+
+func newPipedProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*pipedOpSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", qs)
+	}
+	return &pipedProcedureSpec{tables: spec.tables}, nil
+}
+
+func (s *pipedProcedureSpec) Kind() plan.ProcedureKind {
+	return pipedKind
+}
+
+func (s *pipedProcedureSpec) Copy() plan.ProcedureSpec {
+	return &pipedProcedureSpec{tables: s.tables}
+}
+
+func createPipedSource(ps plan.ProcedureSpec, id execute.DatasetID, a execute.Administration) (execute.Source, error) {
+	spec, ok := ps.(*pipedProcedureSpec)
+	if !ok {
+		return nil, errors.Newf(codes.Internal, "invalid spec type %T", ps)
+	}
+	return &pipedSource{id: id, tables: spec.tables}, nil
+}
+
+// pipedSource produces copies of the tables of a producer, so that
+// they can be read again by the next query of the consumer.
+type pipedSource struct {
+	execute.ExecutionNode
+	id     execute.DatasetID
+	ts     []execute.Transformation
+	tables []flux.BufferedTable
+}
+
+func (s *pipedSource) AddTransformation(t execute.Transformation) {
+	s.ts = append(s.ts, t)
+}
+
+func (s *pipedSource) Run(ctx context.Context) {
+	for _, t := range s.ts {
+		var err error
+		for _, tbl := range s.tables {
+			if err = t.Process(s.id, tbl.Copy()); err != nil {
+				break
+			}
+		}
+		t.Finish(s.id, err)
+	}
+}
@@ -0,0 +1,137 @@
+package repl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/lang"
+	"github.com/influxdata/flux/plan"
+)
+
+func bufferTables(t *testing.T, tbls ...*executetest.Table) []flux.BufferedTable {
+	t.Helper()
+	var tables []flux.BufferedTable
+	for _, tbl := range tbls {
+		buf, err := execute.CopyTable(tbl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, buf)
+	}
+	t.Cleanup(func() { releaseTables(tables) })
+	return tables
+}
+
+func TestPipedSource(t *testing.T) {
+	cols := []flux.ColMeta{
+		{Label: "host", Type: flux.TString},
+		{Label: "_value", Type: flux.TInt},
+	}
+	data := func() []*executetest.Table {
+		return []*executetest.Table{
+			{KeyCols: []string{"host"}, ColMeta: cols, Data: [][]interface{}{{"a", int64(1)}, {"a", int64(2)}}},
+			{KeyCols: []string{"host"}, ColMeta: cols, Data: [][]interface{}{{"b", int64(3)}}},
+		}
+	}
+	tables := bufferTables(t, data()...)
+	want := data()
+	executetest.NormalizeTables(want)
+
+	// Each query of the consumer runs the source again,
+	// and every run must see all of the tables.
+	for i := 0; i < 2; i++ {
+		src, err := createPipedSource(&pipedProcedureSpec{tables: tables}, executetest.RandomDatasetID(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store := executetest.NewDataStore()
+		src.AddTransformation(store)
+		src.Run(context.Background())
+		if err := store.Err(); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := executetest.TablesFromCache(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		executetest.NormalizeTables(got)
+		if !cmp.Equal(want, got) {
+			t.Fatalf("unexpected tables in run %d -want/+got:\n%s", i, cmp.Diff(want, got))
+		}
+	}
+}
+
+func TestScopeHolder_PipedPlanCache(t *testing.T) {
+	cols := []flux.ColMeta{{Label: "_value", Type: flux.TInt}}
+	r := newTestHolder(WithPlanCache(4))
+	for i := int64(0); i < 2; i++ {
+		tables := bufferTables(t, &executetest.Table{ColMeta: cols, Data: [][]interface{}{{i}}})
+		spec := &flux.Spec{Operations: []*flux.Operation{{ID: "piped0", Spec: &pipedOpSpec{tables: tables}}}}
+		program, hit, err := r.compileCached(context.Background(), spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hit {
+			t.Fatal("expected the plan of the piped tables not to be cached")
+		}
+		var got []flux.BufferedTable
+		_ = program.(*lang.Program).PlanSpec.TopDownWalk(func(n plan.Node) error {
+			got = n.ProcedureSpec().(*pipedProcedureSpec).tables
+			return nil
+		})
+		if len(got) != 1 || got[0] != tables[0] {
+			t.Fatalf("expected the plan to read the tables of producer %d", i)
+		}
+	}
+	if n := r.plans.len(); n != 0 {
+		t.Fatalf("expected no cached plans, got %d", n)
+	}
+}
+
+func TestSampleRow(t *testing.T) {
+	tables := bufferTables(t,
+		&executetest.Table{
+			ColMeta: []flux.ColMeta{
+				{Label: "_time", Type: flux.TTime},
+				{Label: "_value", Type: flux.TFloat},
+			},
+		},
+		&executetest.Table{
+			ColMeta: []flux.ColMeta{
+				{Label: "_value", Type: flux.TFloat},
+				{Label: "host", Type: flux.TString},
+				{Label: "ok", Type: flux.TBool},
+			},
+		},
+	)
+	row, err := sampleRow(tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := fluxLiteral(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{_time: 1970-01-01T00:00:00Z, _value: 0.0, host: "", ok: false}`; got != want {
+		t.Fatalf("unexpected sample row: got %s, want %s", got, want)
+	}
+}
+
+func TestSampleRow_Conflict(t *testing.T) {
+	tables := bufferTables(t,
+		&executetest.Table{ColMeta: []flux.ColMeta{{Label: "_value", Type: flux.TFloat}}},
+		&executetest.Table{ColMeta: []flux.ColMeta{{Label: "_value", Type: flux.TString}}},
+	)
+	if _, err := sampleRow(tables); err == nil {
+		t.Fatal("expected an error for a column with two types")
+	} else if got, want := errors.Code(err), codes.Invalid; got != want {
+		t.Fatalf("unexpected error code: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package repl
+
+import (
+	"context"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/lang"
+	"github.com/influxdata/flux/plan"
+)
+
+// PlanResponse is the response to Service.Plan.
+type PlanResponse struct {
+	Plans []*plan.Graph `json:"plans"`
+}
+
+// Plan reports the execution plan of each query in the input.
+func (s *Service) Plan(req InputRequest, resp *PlanResponse) error {
+	plans, err := s.r.Plan(s.r.ctx, req.Input)
+	if err != nil {
+		return err
+	}
+	*resp = PlanResponse{Plans: plans}
+	return nil
+}
+
+// Plan returns the execution plan of each query that evaluating t
+// would run, in order, without executing them.
+// Bindings made by t are not kept in scope.
+func (r *ScopeHolder) Plan(ctx context.Context, t string) ([]*plan.Graph, error) {
+	ses, err := r.evalNested(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	specs, err := r.tableSpecs(ctx, ses)
+	if err != nil {
+		return nil, err
+	}
+
+	plans := make([]*plan.Graph, 0, len(specs))
+	for _, s := range specs {
+		program, err := r.compile(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan.NewGraph(program.(*lang.Program).PlanSpec))
+	}
+	return plans, nil
+}
+
+// WithMaxPlanNodes limits the number of nodes in the plan of a query.
+// Queries whose plan has more nodes fail before they are executed.
+// There is no limit by default.
+func WithMaxPlanNodes(n int) Option {
+	return option(func(r *ScopeHolder) {
+		r.maxPlanNodes = n
+	})
+}
+
+// checkPlanSize reports an error if ps has more nodes than allowed.
+func (r *ScopeHolder) checkPlanSize(ps *plan.Spec) error {
+	if r.maxPlanNodes <= 0 {
+		return nil
+	}
+	var n int
+	_ = ps.TopDownWalk(func(plan.Node) error {
+		n++
+		return nil
+	})
+	if n > r.maxPlanNodes {
+		return errors.Newf(codes.ResourceExhausted, "query plan has %d nodes, more than the limit of %d", n, r.maxPlanNodes)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package repl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/plan/plantest"
+	"github.com/influxdata/flux/stdlib/array"
+	"github.com/influxdata/flux/stdlib/universe"
+)
+
+// pipelinePlan returns the plan of a query that reads an array and
+// passes it through n filters.
+func pipelinePlan(n int) *plan.Spec {
+	spec := &plantest.PlanSpec{
+		Nodes: []plan.Node{plan.CreateLogicalNode("array", &array.FromProcedureSpec{})},
+	}
+	for i := 1; i <= n; i++ {
+		spec.Nodes = append(spec.Nodes, plan.CreateLogicalNode(plan.NodeID(fmt.Sprintf("filter%d", i)), &universe.FilterProcedureSpec{}))
+		spec.Edges = append(spec.Edges, [2]int{i - 1, i})
+	}
+	return plantest.CreatePlanSpec(spec)
+}
+
+func TestCheckPlanSize(t *testing.T) {
+	ps := pipelinePlan(100)
+	if err := newTestHolder().checkPlanSize(ps); err != nil {
+		t.Fatalf("expected no limit by default, got %v", err)
+	}
+	if err := newTestHolder(WithMaxPlanNodes(101)).checkPlanSize(ps); err != nil {
+		t.Fatalf("expected a plan at the limit to be allowed, got %v", err)
+	}
+	err := newTestHolder(WithMaxPlanNodes(10)).checkPlanSize(ps)
+	if errors.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected a resource exhausted error, got %v", err)
+	}
+	if want := "query plan has 101 nodes, more than the limit of 10"; err.Error() != want {
+		t.Fatalf("unexpected error: got %q, want %q", err, want)
+	}
+}
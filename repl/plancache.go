@@ -0,0 +1,267 @@
+package repl
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/lang"
+	"github.com/influxdata/flux/metadata"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/runtime"
+)
+
+// WithPlanCache enables a cache of up to size physical plans.
+// Queries that only differ in the numbers, times and durations that
+// they pass to functions, or in their now time, such as a dashboard
+// panel refreshed with new parameters, reuse the cached plan rebound
+// to their own values instead of being planned again. A query whose
+// values differ in a part of the plan that the planner rewrote, such
+// as a range merged into its source, is planned again. The queries of
+// the consumer of EvalPiped are never cached. A size of zero or less
+// disables the cache.
+func WithPlanCache(size int) Option {
+	return option(func(r *ScopeHolder) {
+		if size <= 0 {
+			r.plans = nil
+			return
+		}
+		r.plans = newPlanCache(size)
+	})
+}
+
+// planCacheMetaKey is the statistics metadata key under which whether
+// the plan of a query was found in the plan cache is recorded,
+// as "hit" or "miss".
+const planCacheMetaKey = "flux/plan-cache"
+
+// CacheStatsRequest is the params object for Service.CacheStats.
+type CacheStatsRequest struct {
+	// Reset sets the counters back to zero once they are reported.
+	Reset bool `json:"reset"`
+}
+
+// CacheStatsResponse is the response to Service.CacheStats.
+type CacheStatsResponse struct {
+	// Plans is nil when the session has no plan cache.
+	Plans *PlanCacheStats `json:"plans,omitempty"`
+}
+
+// PlanCacheStats reports how well the plan cache is doing.
+type PlanCacheStats struct {
+	// Hits, Misses and Evictions count the lookups that found a plan,
+	// those that did not and the plans dropped to make room for others
+	// since the counters were last reset.
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	// Entries is the number of cached plans, of at most Capacity.
+	Entries  int `json:"entries"`
+	Capacity int `json:"capacity"`
+	// Bytes estimates the memory held by the cached plans from the
+	// size of the query specs that they were planned from.
+	Bytes int64 `json:"bytes"`
+}
+
+// CacheStats reports the statistics of the caches of the session
+// as described by ScopeHolder.CacheStats.
+func (s *Service) CacheStats(req CacheStatsRequest, resp *CacheStatsResponse) error {
+	*resp = s.r.CacheStats(req.Reset)
+	return nil
+}
+
+// CacheStats reports the statistics of the caches of the session so
+// that their sizes can be tuned. If reset is set, the counters are set
+// back to zero once they are reported.
+func (r *ScopeHolder) CacheStats(reset bool) CacheStatsResponse {
+	var resp CacheStatsResponse
+	if r.plans != nil {
+		stats := r.plans.stats(reset)
+		resp.Plans = &stats
+	}
+	return resp
+}
+
+// planCache is a least recently used cache of physical plans,
+// kept as templates that are rebound to the spec they are looked up for.
+type planCache struct {
+	mu      sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+
+	hits, misses, evictions int64
+	// bytes is the sum of the sizes of the entries.
+	bytes int64
+}
+
+type planCacheEntry struct {
+	key  string
+	t    *planTemplate
+	size int
+}
+
+func newPlanCache(size int) *planCache {
+	return &planCache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached plan of the spec whose form is f, rebound to
+// its values. It reports false if there is no plan under the key of f
+// or if the plan cannot be rebound to the spec.
+func (c *planCache) get(f *specForm) (*plan.Spec, bool, error) {
+	c.mu.Lock()
+	var t *planTemplate
+	if e, ok := c.entries[f.key]; ok {
+		c.lru.MoveToFront(e)
+		t = e.Value.(*planCacheEntry).t
+	}
+	c.mu.Unlock()
+
+	var (
+		ps  *plan.Spec
+		ok  bool
+		err error
+	)
+	if t != nil {
+		if ps, ok, err = t.rebind(f); err != nil {
+			return nil, false, err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return ps, ok, nil
+}
+
+// add caches t under key. The size of the entry
+// is estimated to be size bytes.
+func (c *planCache) add(key string, t *planTemplate, size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		entry := e.Value.(*planCacheEntry)
+		c.bytes += int64(size - entry.size)
+		entry.t, entry.size = t, size
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&planCacheEntry{key: key, t: t, size: size})
+	c.bytes += int64(size)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		entry := oldest.Value.(*planCacheEntry)
+		delete(c.entries, entry.key)
+		c.bytes -= int64(entry.size)
+		c.evictions++
+	}
+}
+
+func (c *planCache) stats(reset bool) PlanCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := PlanCacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Entries:   c.lru.Len(),
+		Capacity:  c.size,
+		Bytes:     c.bytes,
+	}
+	if reset {
+		c.hits, c.misses, c.evictions = 0, 0, 0
+	}
+	return stats
+}
+
+func (c *planCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// compile turns the spec into a program, consulting the plan cache if one is enabled.
+func (r *ScopeHolder) compile(ctx context.Context, spec *flux.Spec) (flux.Program, error) {
+	program, _, err := r.compileCached(ctx, spec)
+	return program, err
+}
+
+// compileCached compiles the spec like compile and
+// reports whether its plan was found in the plan cache.
+func (r *ScopeHolder) compileCached(ctx context.Context, spec *flux.Spec) (flux.Program, bool, error) {
+	if r.previewLimit > 0 {
+		spec = limitSpec(spec, r.previewLimit)
+	}
+	c := Compiler{
+		Spec:          spec,
+		disabledRules: r.rules.names(),
+	}
+	if r.plans == nil || readsMemory(spec) {
+		program, err := c.Compile(ctx, runtime.Default)
+		return program, false, err
+	}
+
+	f, err := newSpecForm(spec)
+	if err != nil {
+		return nil, false, err
+	}
+	// Plans made without some of the rules are cached apart.
+	if len(c.disabledRules) > 0 {
+		f.key += "\x00disabled:" + strings.Join(c.disabledRules, ",")
+	}
+	ps, ok, err := r.plans.get(f)
+	if err != nil {
+		return nil, false, err
+	}
+	if ok {
+		return &lang.Program{PlanSpec: ps}, true, nil
+	}
+
+	program, err := c.Compile(ctx, runtime.Default)
+	if err != nil {
+		return nil, false, err
+	}
+	t, err := newPlanTemplate(program.(*lang.Program).PlanSpec, f)
+	if err != nil {
+		return nil, false, err
+	}
+	r.plans.add(f.key, t, len(f.key)+f.size)
+	return program, false, nil
+}
+
+// readsMemory reports whether spec reads tables held in memory for
+// a single query, such as those of the producer of EvalPiped. Its plan
+// is not cached, since it would refer to tables that are released once
+// the query is done.
+func readsMemory(spec *flux.Spec) bool {
+	for _, op := range spec.Operations {
+		if op.Spec.Kind() == pipedKind {
+			return true
+		}
+	}
+	return false
+}
+
+// addPlanCacheLookup records in stats whether the plan of a query
+// was found in the plan cache.
+func addPlanCacheLookup(stats flux.Statistics, hit bool) flux.Statistics {
+	if stats.Metadata == nil {
+		stats.Metadata = make(metadata.Metadata)
+	}
+	lookup := "miss"
+	if hit {
+		lookup = "hit"
+	}
+	stats.Metadata.Add(planCacheMetaKey, lookup)
+	return stats
+}
@@ -0,0 +1,59 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/influxdata/flux"
+	_ "github.com/influxdata/flux/fluxinit/static"
+	"github.com/influxdata/flux/internal/spec"
+	"github.com/influxdata/flux/runtime"
+)
+
+// dashboardQuery is representative of a panel that is refreshed
+// repeatedly, over a range and at a window period chosen by the user.
+const dashboardQuery = `
+import "array"
+
+array.from(rows: [{_time: 2022-01-01T00:00:00Z, _value: 1.0}])
+	|> range(start: -%dh)
+	|> filter(fn: (r) => r._value > 0.0)
+	|> aggregateWindow(every: %dm, fn: mean)
+	|> limit(n: %d)
+	|> yield(name: "panel")
+`
+
+func benchmarkCompile(b *testing.B, opts ...Option) {
+	ctx := context.Background()
+	now := time.Date(2022, 1, 1, 1, 0, 0, 0, time.UTC)
+	// Each refresh happens at a new now, with one of a few ranges
+	// and window periods.
+	specs := make([]*flux.Spec, 64)
+	for i := range specs {
+		query := fmt.Sprintf(dashboardQuery, 1+i%4, 1+i%3, 10+i)
+		s, err := spec.FromScript(ctx, runtime.Default, now.Add(time.Duration(i)*time.Minute), query)
+		if err != nil {
+			b.Fatal(err)
+		}
+		specs[i] = s
+	}
+
+	r := newTestHolder(opts...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.compile(ctx, specs[i%len(specs)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompile_NoPlanCache(b *testing.B) {
+	benchmarkCompile(b)
+}
+
+func BenchmarkCompile_PlanCache(b *testing.B) {
+	benchmarkCompile(b, WithPlanCache(16))
+}
@@ -0,0 +1,343 @@
+package repl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/lang"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/stdlib/array"
+	"github.com/influxdata/flux/stdlib/influxdata/influxdb"
+	"github.com/influxdata/flux/stdlib/sql"
+	"github.com/influxdata/flux/stdlib/universe"
+	"github.com/influxdata/flux/values"
+)
+
+// testForm returns the form of a spec of a single operation,
+// keyed by key.
+func testForm(t *testing.T, key string) *specForm {
+	t.Helper()
+	f, err := newSpecForm(&flux.Spec{
+		Operations: []*flux.Operation{{ID: "test0", Spec: testOpSpec{}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.key = key
+	return f
+}
+
+func TestPlanCache_Eviction(t *testing.T) {
+	c := newPlanCache(2)
+	a, b, d := plan.NewPlanSpec(), plan.NewPlanSpec(), plan.NewPlanSpec()
+	fa, fb, fd := testForm(t, "a"), testForm(t, "b"), testForm(t, "d")
+	get := func(f *specForm) (*plan.Spec, bool) {
+		ps, ok, err := c.get(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ps, ok
+	}
+
+	c.add("a", &planTemplate{ps: a, form: fa}, 10)
+	c.add("b", &planTemplate{ps: b, form: fb}, 20)
+	if got, ok := get(fa); !ok || got != a {
+		t.Fatal("expected a to be cached")
+	}
+
+	// b is now the least recently used entry.
+	c.add("d", &planTemplate{ps: d, form: fd}, 30)
+	if _, ok := get(fb); ok {
+		t.Fatal("expected b to be evicted")
+	}
+	if got, ok := get(fd); !ok || got != d {
+		t.Fatal("expected d to be cached")
+	}
+	if got, want := c.len(), 2; got != want {
+		t.Fatalf("unexpected cache size -want/+got:\n\t- %d\n\t+ %d", want, got)
+	}
+
+	want := PlanCacheStats{Hits: 2, Misses: 1, Evictions: 1, Entries: 2, Capacity: 2, Bytes: 40}
+	if got := c.stats(true); !cmp.Equal(want, got) {
+		t.Fatalf("unexpected stats -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	want = PlanCacheStats{Entries: 2, Capacity: 2, Bytes: 40}
+	if got := c.stats(false); !cmp.Equal(want, got) {
+		t.Fatalf("unexpected stats once reset -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestService_CacheStats(t *testing.T) {
+	send := serveTestService(t, &Service{r: newTestHolder()})
+	resp := send(`{"method": "Service.CacheStats", "id": 1, "params": [{}]}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if got, want := string(resp.Result), `{}`; got != want {
+		t.Fatalf("expected no stats without a plan cache: got %s, want %s", got, want)
+	}
+
+	r := newTestHolder(WithPlanCache(4))
+	f := testForm(t, "a")
+	r.plans.add("a", &planTemplate{ps: plan.NewPlanSpec(), form: f}, 8)
+	if _, _, err := r.plans.get(f); err != nil {
+		t.Fatal(err)
+	}
+	send = serveTestService(t, &Service{r: r})
+	resp = send(`{"method": "Service.CacheStats", "id": 2, "params": [{"reset": true}]}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	var got CacheStatsResponse
+	if err := json.Unmarshal(resp.Result, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := CacheStatsResponse{Plans: &PlanCacheStats{Hits: 1, Entries: 1, Capacity: 4, Bytes: 8}}
+	if !cmp.Equal(want, got) {
+		t.Fatalf("unexpected response -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if hits := r.CacheStats(false).Plans.Hits; hits != 0 {
+		t.Fatalf("expected the counters to be reset, got %d hits", hits)
+	}
+}
+
+type testOpSpec struct{}
+
+func (testOpSpec) Kind() flux.OperationKind { return "test" }
+
+func TestSpecForm_Key(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	newSpec := func(now time.Time, start time.Duration, n int64, column string) *flux.Spec {
+		return &flux.Spec{
+			Operations: []*flux.Operation{
+				{ID: "range0", Spec: &universe.RangeOpSpec{
+					Start:       flux.Time{IsRelative: true, Relative: start},
+					Stop:        flux.Now,
+					TimeColumn:  column,
+					StartColumn: "_start",
+					StopColumn:  "_stop",
+				}},
+				{ID: "limit1", Spec: &universe.LimitOpSpec{N: n}},
+			},
+			Edges: []flux.Edge{{Parent: "range0", Child: "limit1"}},
+			Now:   now,
+		}
+	}
+	key := func(spec *flux.Spec) string {
+		f, err := newSpecForm(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f.key
+	}
+
+	want := key(newSpec(now, -time.Hour, 5, "_time"))
+	for _, spec := range []*flux.Spec{
+		newSpec(now, -time.Hour, 5, "_time"),
+		newSpec(now.Add(time.Minute), -time.Hour, 5, "_time"),
+		newSpec(now, -2*time.Hour, 10, "_time"),
+	} {
+		if got := key(spec); got != want {
+			t.Fatalf("expected specs that only differ in their values to have the same key: got %s, want %s", got, want)
+		}
+	}
+	if key(newSpec(now, -time.Hour, 5, "time")) == want {
+		t.Fatal("expected specs with different columns to have different keys")
+	}
+}
+
+func TestScopeHolder_PlanCacheRebind(t *testing.T) {
+	// limitSpec limits a range of the source, which the planner
+	// plans as a node for each operation unless it merges the range
+	// into the source.
+	limitSpec := func(source flux.OperationSpec, now time.Time, start time.Duration, n int64) *flux.Spec {
+		return &flux.Spec{
+			Operations: []*flux.Operation{
+				{ID: "from0", Spec: source},
+				{ID: "range1", Spec: &universe.RangeOpSpec{
+					Start:       flux.Time{IsRelative: true, Relative: start},
+					Stop:        flux.Now,
+					TimeColumn:  "_time",
+					StartColumn: "_start",
+					StopColumn:  "_stop",
+				}},
+				{ID: "limit2", Spec: &universe.LimitOpSpec{N: n}},
+			},
+			Edges: []flux.Edge{
+				{Parent: "from0", Child: "range1"},
+				{Parent: "range1", Child: "limit2"},
+			},
+			Now: now,
+		}
+	}
+	host := "http://localhost:8086"
+	bucket := &influxdb.FromOpSpec{
+		Org:    &influxdb.NameOrID{Name: "o"},
+		Bucket: influxdb.NameOrID{Name: "b"},
+		Host:   &host,
+	}
+	table := &sql.FromSQLOpSpec{DriverName: "sqlite3", DataSourceName: "file::memory:", Query: "SELECT 1"}
+	now := sortLimitSpec().Now
+	type lookup struct {
+		spec *flux.Spec
+		hit  bool
+	}
+	// Each plan is compared with the plan made without the cache.
+	r, uncached := newTestHolder(WithPlanCache(4)), newTestHolder()
+	check := func(lookups []lookup) {
+		t.Helper()
+		for i, l := range lookups {
+			program, hit, err := r.compileCached(context.Background(), l.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hit != l.hit {
+				t.Fatalf("unexpected plan cache lookup %d: got hit %v, want %v", i, hit, l.hit)
+			}
+			want, err := uncached.compile(context.Background(), l.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, exp := planNodes(t, program), planNodes(t, want)
+			if !cmp.Equal(exp, got) {
+				t.Fatalf("unexpected plan of lookup %d -want/+got:\n%s", i, cmp.Diff(exp, got))
+			}
+		}
+	}
+
+	// The range is merged into the bucket source,
+	// so only the limit can be rebound.
+	check([]lookup{
+		{spec: limitSpec(bucket, now, -time.Hour, 5)},
+		{spec: limitSpec(bucket, now, -time.Hour, 5), hit: true},
+		{spec: limitSpec(bucket, now, -time.Hour, 10), hit: true},
+		{spec: limitSpec(bucket, now.Add(time.Hour), -time.Hour, 10)},
+		{spec: limitSpec(bucket, now.Add(time.Hour), -time.Hour, 20), hit: true},
+		{spec: limitSpec(bucket, now.Add(time.Hour), -2*time.Hour, 20)},
+	})
+
+	// The range of a table is planned apart from its source,
+	// so it is rebound along with its time bounds.
+	check([]lookup{
+		{spec: limitSpec(table, now, -time.Hour, 5)},
+		{spec: limitSpec(table, now.Add(time.Hour), -time.Hour, 5), hit: true},
+		{spec: limitSpec(table, now, -2*time.Hour, 10), hit: true},
+	})
+
+	// The sort and limit are planned as a single sortLimit,
+	// which cannot be rebound to a new limit.
+	for _, tc := range []struct {
+		n   int64
+		hit bool
+	}{{n: 5}, {n: 10}, {n: 10, hit: true}} {
+		spec := sortLimitSpec()
+		spec.Operations[3].Spec.(*universe.LimitOpSpec).N = tc.n
+		if _, hit, err := r.compileCached(context.Background(), spec); err != nil {
+			t.Fatal(err)
+		} else if hit != tc.hit {
+			t.Fatalf("unexpected plan cache lookup with a limit of %d: got hit %v, want %v", tc.n, hit, tc.hit)
+		}
+	}
+}
+
+// planNode is the part of a plan node that planNodes compares.
+type planNode struct {
+	ID      plan.NodeID
+	Kind    plan.ProcedureKind
+	Spec    string
+	Bounds  *plan.Bounds
+	Trigger plan.TriggerKind
+	Preds   []plan.NodeID
+}
+
+// planNodes returns the nodes of the plan of program by ID,
+// including the now time of the plan under the empty ID.
+func planNodes(t *testing.T, program flux.Program) map[plan.NodeID]planNode {
+	t.Helper()
+	ps := program.(*lang.Program).PlanSpec
+	nodes := map[plan.NodeID]planNode{"": {Spec: ps.Now.String()}}
+	_ = ps.TopDownWalk(func(n plan.Node) error {
+		pn := n.(*plan.PhysicalPlanNode)
+		node := planNode{
+			ID:      n.ID(),
+			Kind:    n.Kind(),
+			Spec:    fmt.Sprintf("%+v", pn.Spec),
+			Bounds:  n.Bounds(),
+			Trigger: pn.TriggerSpec.Kind(),
+		}
+		for _, pred := range n.Predecessors() {
+			node.Preds = append(node.Preds, pred.ID())
+		}
+		nodes[n.ID()] = node
+		return nil
+	})
+	return nodes
+}
+
+func TestScopeHolder_PlanCacheValues(t *testing.T) {
+	// rowsSpec reads rows that the JSON of its spec leaves out.
+	rowsSpec := func(a int64) *flux.Spec {
+		row := values.NewObjectWithValues(map[string]values.Value{"a": values.NewInt(a)})
+		rows := values.NewArrayWithBacking(semantic.NewArrayType(row.Type()), []values.Value{row})
+		return &flux.Spec{
+			Operations: []*flux.Operation{{ID: "from0", Spec: &array.FromOpSpec{Rows: rows}}},
+			Now:        time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+	}
+	// closureSpec maps a table with a function that returns the value
+	// of x in its scope, which the JSON of its spec leaves out too.
+	closureSpec := func(x int64) *flux.Spec {
+		scope := values.NewScope()
+		scope.Set("x", values.NewInt(x))
+		fn := interpreter.ResolvedFunction{
+			Fn: &semantic.FunctionExpression{Block: &semantic.Block{Body: []semantic.Statement{
+				&semantic.ReturnStatement{Argument: &semantic.IdentifierExpression{Name: semantic.NewSymbol("x")}},
+			}}},
+			Scope: scope,
+		}
+		spec := rowsSpec(1)
+		spec.Operations = append(spec.Operations, &flux.Operation{ID: "map1", Spec: &universe.MapOpSpec{Fn: fn}})
+		spec.Edges = []flux.Edge{{Parent: "from0", Child: "map1"}}
+		return spec
+	}
+	// spec returns the procedure spec of the node id in the plan of program.
+	spec := func(program flux.Program, id plan.NodeID) plan.ProcedureSpec {
+		var spec plan.ProcedureSpec
+		_ = program.(*lang.Program).PlanSpec.TopDownWalk(func(n plan.Node) error {
+			if n.ID() == id {
+				spec = n.ProcedureSpec()
+			}
+			return nil
+		})
+		return spec
+	}
+
+	r := newTestHolder(WithPlanCache(4))
+	for _, a := range []int64{1, 2} {
+		program, _, err := r.compileCached(context.Background(), rowsSpec(a))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows := spec(program, "from0").(*array.FromProcedureSpec).Rows
+		if got, _ := rows.Get(0).Object().Get("a"); got.Int() != a {
+			t.Fatalf("unexpected rows in the plan: got a = %v, want %d", got, a)
+		}
+	}
+	for _, x := range []int64{1, 2} {
+		program, _, err := r.compileCached(context.Background(), closureSpec(x))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn := spec(program, "map1").(*universe.MapProcedureSpec).Fn
+		if got, ok := fn.Scope.Lookup("x"); !ok || got.Int() != x {
+			t.Fatalf("unexpected scope of the function in the plan: got x = %v, want %d", got, x)
+		}
+	}
+}
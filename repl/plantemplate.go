@@ -0,0 +1,293 @@
+package repl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/values"
+)
+
+// The plan cache is keyed by the shape of a spec rather than by the
+// spec itself: its operations and the edges between them, with the
+// literals that the planner rules do not look at left out. These are
+// the numbers, times and durations given to the operations, and the
+// now time of the spec. Strings and booleans, which name columns and
+// turn on behaviors that decide whether a rule applies, are kept, as
+// are the functions passed to the operations.
+//
+// The key is made from the JSON of the operation specs, which leaves
+// out values such as the rows of array.from and the scopes of the
+// functions passed to the operations. The specs that share a key are
+// therefore compared deeply, as sameContent does, before a cached plan
+// is reused for them.
+//
+// A cached plan is then rebound to the literals of the spec that hit
+// it. The plan nodes of the operations whose procedure specs differ
+// are given the procedure specs of the new spec, and the time bounds
+// and triggers of the plan are computed again, as the planner does.
+// This is only done for the nodes that the planner left as they were
+// created, since a node that a rule rewrote, such as a range merged
+// into its source, has a procedure spec that no longer relates to
+// its operation. A spec whose literals differ in such a node is
+// planned again.
+
+// specForm is the form of a spec that is compared against the plans
+// in the cache.
+type specForm struct {
+	spec *flux.Spec
+	// key identifies the shape of the spec and size is the size
+	// of the spec as JSON.
+	key  string
+	size int
+	// ops holds each operation spec by node ID.
+	ops map[plan.NodeID]flux.OperationSpec
+	// logical holds the procedure spec that each operation is turned
+	// into before planning. It is only made once it is needed.
+	logical map[plan.NodeID]plan.ProcedureSpec
+}
+
+// newSpecForm returns the form of spec.
+func newSpecForm(spec *flux.Spec) (*specForm, error) {
+	type shapeOp struct {
+		ID   flux.OperationID   `json:"id"`
+		Kind flux.OperationKind `json:"kind"`
+		Spec interface{}        `json:"spec"`
+	}
+	shape := struct {
+		Operations []shapeOp               `json:"operations"`
+		Edges      []flux.Edge             `json:"edges"`
+		Resources  flux.ResourceManagement `json:"resources"`
+	}{Edges: spec.Edges, Resources: spec.Resources}
+
+	f := &specForm{spec: spec, ops: make(map[plan.NodeID]flux.OperationSpec, len(spec.Operations))}
+	for _, op := range spec.Operations {
+		data, err := json.Marshal(op.Spec)
+		if err != nil {
+			return nil, err
+		}
+		f.ops[plan.NodeID(op.ID)] = op.Spec
+		f.size += len(data)
+		shape.Operations = append(shape.Operations, shapeOp{ID: op.ID, Kind: op.Spec.Kind(), Spec: withoutLiterals(op.Spec)})
+	}
+	data, err := json.Marshal(shape)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	f.key = hex.EncodeToString(sum[:])
+	f.size += len(data)
+	return f, nil
+}
+
+// logicalSpecs returns the procedure spec of each operation of the
+// spec by node ID.
+func (f *specForm) logicalSpecs() (map[plan.NodeID]plan.ProcedureSpec, error) {
+	if f.logical != nil {
+		return f.logical, nil
+	}
+	ps, err := plan.NewLogicalPlanner().CreateInitialPlan(f.spec)
+	if err != nil {
+		return nil, err
+	}
+	logical := make(map[plan.NodeID]plan.ProcedureSpec, len(f.ops))
+	if err := ps.BottomUpWalk(func(n plan.Node) error {
+		logical[n.ID()] = n.ProcedureSpec()
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	f.logical = logical
+	return logical, nil
+}
+
+var (
+	fluxTimeType       = reflect.TypeOf(flux.Time{})
+	fluxDurationType   = reflect.TypeOf(flux.Duration{})
+	goTimeType         = reflect.TypeOf(time.Time{})
+	goDurationType     = reflect.TypeOf(time.Duration(0))
+	valuesTimeType     = reflect.TypeOf(values.Time(0))
+	valuesDurationType = reflect.TypeOf(values.Duration{})
+)
+
+// withoutLiterals returns a copy of the operation spec s with its
+// numbers, times and durations set to zero, for the key of the plan
+// cache. Fields that s refers to through pointers, interfaces, maps
+// and slices are left as they are.
+func withoutLiterals(s flux.OperationSpec) interface{} {
+	v := reflect.ValueOf(s)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return s
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return s
+	}
+	c := reflect.New(v.Type())
+	c.Elem().Set(v)
+	clearLiterals(c.Elem())
+	return c.Interface()
+}
+
+// clearLiterals zeroes the literal fields of the struct v,
+// and those of the structs it holds.
+func clearLiterals(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if !f.CanSet() {
+			continue
+		}
+		switch t := f.Type(); {
+		case t == fluxTimeType, t == fluxDurationType, t == goTimeType, t == goDurationType,
+			t == valuesTimeType, t == valuesDurationType:
+			f.Set(reflect.Zero(t))
+		case t.Kind() == reflect.Struct:
+			clearLiterals(f)
+		case isNumber(t.Kind()):
+			f.Set(reflect.Zero(t))
+		}
+	}
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// planTemplate is a cached plan along with the form of the spec
+// it was planned from.
+type planTemplate struct {
+	ps   *plan.Spec
+	form *specForm
+}
+
+// newPlanTemplate returns a template of ps, the plan of the spec
+// whose form is f.
+func newPlanTemplate(ps *plan.Spec, f *specForm) (*planTemplate, error) {
+	// The procedure specs are made apart from those in ps,
+	// which the planner rules may have changed in place.
+	if _, err := f.logicalSpecs(); err != nil {
+		return nil, err
+	}
+	return &planTemplate{ps: ps, form: f}, nil
+}
+
+// rebind returns the plan of the template rebound to the literals of
+// the spec whose form is f, which has the same key. It reports false
+// if the literals differ in a node that the planner rewrote.
+func (t *planTemplate) rebind(f *specForm) (*plan.Spec, bool, error) {
+	now := f.spec.Now
+	sameNow := now.Equal(t.form.spec.Now)
+	changed := make(map[plan.NodeID]plan.PhysicalProcedureSpec)
+	for id, op := range f.ops {
+		if sameNow && reflect.DeepEqual(op, t.form.ops[id]) {
+			continue
+		}
+		logical, err := f.logicalSpecs()
+		if err != nil {
+			return nil, false, err
+		}
+		old := t.form.logical[id]
+		if reflect.DeepEqual(old, logical[id]) {
+			continue
+		}
+		spec, ok := logical[id].(plan.PhysicalProcedureSpec)
+		if !ok {
+			return nil, false, nil
+		}
+		changed[id] = spec
+	}
+	if len(changed) > 0 && !t.untouched(changed) {
+		return nil, false, nil
+	}
+	if len(changed) == 0 {
+		if sameNow {
+			return t.ps, true, nil
+		}
+		ps := *t.ps
+		ps.Now = now
+		return &ps, true, nil
+	}
+	ps, err := clonePlan(t.ps, now, changed)
+	if err != nil {
+		return nil, false, err
+	}
+	return ps, true, nil
+}
+
+// untouched reports whether the planner left the node of each
+// operation in changed as it was created from the operation.
+func (t *planTemplate) untouched(changed map[plan.NodeID]plan.PhysicalProcedureSpec) bool {
+	found := 0
+	_ = t.ps.BottomUpWalk(func(n plan.Node) error {
+		if _, ok := changed[n.ID()]; !ok {
+			return nil
+		}
+		pn, ok := n.(*plan.PhysicalPlanNode)
+		if ok && reflect.DeepEqual(pn.Spec, t.form.logical[n.ID()]) {
+			found++
+		}
+		return nil
+	})
+	return found == len(changed)
+}
+
+// clonePlan returns a copy of the physical plan ps with the given
+// now time, in which the node with each ID in specs has the procedure
+// spec given for it. The time bounds and triggers of the copy are
+// computed from its procedure specs.
+func clonePlan(ps *plan.Spec, now time.Time, specs map[plan.NodeID]plan.PhysicalProcedureSpec) (*plan.Spec, error) {
+	var nodes []*plan.PhysicalPlanNode
+	clones := make(map[plan.Node]*plan.PhysicalPlanNode)
+	if err := ps.BottomUpWalk(func(n plan.Node) error {
+		pn := n.(*plan.PhysicalPlanNode)
+		spec, ok := specs[pn.ID()]
+		if !ok {
+			spec = pn.Spec
+		}
+		c := plan.CreatePhysicalNode(pn.ID(), spec)
+		c.Source = pn.Source
+		c.TriggerSpec = pn.TriggerSpec
+		c.RequiredAttrs = pn.RequiredAttrs
+		c.OutputAttrs = pn.OutputAttrs
+		nodes = append(nodes, pn)
+		clones[pn] = c
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	for _, pn := range nodes {
+		c := clones[pn]
+		for _, pred := range pn.Predecessors() {
+			c.AddPredecessors(clones[pred])
+		}
+		for _, succ := range pn.Successors() {
+			c.AddSuccessors(clones[succ])
+		}
+	}
+
+	cp := plan.NewPlanSpec()
+	cp.Resources = ps.Resources
+	cp.Now = now
+	for root := range ps.Roots {
+		cp.Roots[clones[root]] = struct{}{}
+	}
+	if err := cp.BottomUpWalk(plan.ComputeBounds); err != nil {
+		return nil, err
+	}
+	if err := cp.TopDownWalk(plan.SetTriggerSpec); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
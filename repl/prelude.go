@@ -0,0 +1,210 @@
+package repl
+
+import (
+	"context"
+	"sync"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/values"
+)
+
+// WithPrelude limits the prelude packages imported when the session is
+// created to paths, each of which must be in runtime.PreludeList.
+// The rest of the prelude is imported the first time a name that is not
+// in scope is looked up, so short-lived sessions that need only a few
+// packages start faster without losing access to the others.
+func WithPrelude(paths ...string) Option {
+	return option(func(r *ScopeHolder) {
+		r.lazyPrelude = true
+		r.preludePaths = paths
+	})
+}
+
+// WithImporter replaces the importer of the packages of the prelude and
+// of the import statements of the session, which is the standard library
+// by default. An importer that does I/O, such as one that fetches
+// packages over the network, should be paired with a deadline on the
+// context of New, which aborts the import of the prelude once it passes.
+func WithImporter(importer interpreter.Importer) Option {
+	return option(func(r *ScopeHolder) {
+		r.importer = importer
+	})
+}
+
+// newPreludeScope returns the scope holding the prelude.
+// The import is abandoned with the error of ctx once it is done.
+func (r *ScopeHolder) newPreludeScope(ctx context.Context) (values.Scope, error) {
+	if !r.lazyPrelude {
+		scope := values.NewScope()
+		origins, err := importPrelude(ctx, r.importer, scope, runtime.PreludeList)
+		if err != nil {
+			return nil, err
+		}
+		r.origins.set(origins)
+		return scope, nil
+	}
+
+	eager := make(map[string]bool, len(r.preludePaths))
+	for _, p := range r.preludePaths {
+		eager[p] = true
+	}
+	paths := make([]string, 0, len(r.preludePaths))
+	for _, p := range runtime.PreludeList {
+		if eager[p] {
+			paths = append(paths, p)
+			delete(eager, p)
+		}
+	}
+	for p := range eager {
+		return nil, errors.Newf(codes.Invalid, "%q is not a prelude package", p)
+	}
+
+	s := &lazyPreludeScope{Scope: values.NewScope(), importer: r.importer, origins: &r.origins}
+	origins, err := importPrelude(ctx, r.importer, s.Scope, paths)
+	if err != nil {
+		return nil, err
+	}
+	r.origins.set(origins)
+	s.loaded = len(paths) == len(runtime.PreludeList)
+	return s, nil
+}
+
+// importPrelude binds the members of each package in paths into scope,
+// in order, so that later packages shadow earlier ones. It returns the
+// path of the package that each name was bound from.
+func importPrelude(ctx context.Context, importer interpreter.Importer, scope values.Scope, paths []string) (map[string]string, error) {
+	origins := make(map[string]string)
+	for _, p := range paths {
+		pkg, err := importPackage(ctx, importer, p)
+		if err != nil {
+			return nil, err
+		}
+		pkg.Range(func(name string, v values.Value) {
+			scope.Set(name, v)
+			origins[name] = p
+		})
+	}
+	return origins, nil
+}
+
+// importPackage imports the package of path, giving up with the error of
+// ctx once it is done. Importers cannot be interrupted, so an import
+// that is given up on carries on in the background until it returns.
+// A panic of the importer is raised again in the caller.
+func importPackage(ctx context.Context, importer interpreter.Importer, path string) (*interpreter.Package, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrapf(err, codes.Canceled, "import of %q canceled", path)
+	}
+	if ctx.Done() == nil {
+		return importer.ImportPackageObject(path)
+	}
+
+	type imported struct {
+		pkg *interpreter.Package
+		err error
+		// panicked holds the value that the importer panicked with.
+		panicked interface{}
+	}
+	done := make(chan imported, 1)
+	go func() {
+		var res imported
+		defer func() {
+			if p := recover(); p != nil {
+				res.panicked = p
+			}
+			done <- res
+		}()
+		res.pkg, res.err = importer.ImportPackageObject(path)
+	}()
+	select {
+	case res := <-done:
+		if res.panicked != nil {
+			panic(res.panicked)
+		}
+		return res.pkg, res.err
+	case <-ctx.Done():
+		return nil, errors.Wrapf(ctx.Err(), codes.Canceled, "import of %q canceled", path)
+	}
+}
+
+// lazyPreludeScope is a root scope that holds part of the prelude and
+// imports all of it the first time a name it does not hold is needed.
+type lazyPreludeScope struct {
+	values.Scope
+	importer interpreter.Importer
+	// origins is updated once the whole prelude is imported.
+	origins *preludeOrigins
+
+	mu     sync.Mutex
+	loaded bool
+}
+
+// load replaces the partial prelude with the whole of it.
+func (s *lazyPreludeScope) load() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loaded {
+		return
+	}
+	scope := values.NewScope()
+	// A lookup has no context to bound the import with,
+	// and no way to report that it was abandoned.
+	origins, err := importPrelude(context.Background(), s.importer, scope, runtime.PreludeList)
+	if err != nil {
+		// The same packages were imported successfully when the
+		// session was created, so this only fails if the runtime is broken.
+		panic(err)
+	}
+	s.Scope, s.loaded = scope, true
+	s.origins.set(origins)
+}
+
+func (s *lazyPreludeScope) scope() values.Scope {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Scope
+}
+
+func (s *lazyPreludeScope) Lookup(name string) (values.Value, bool) {
+	if v, ok := s.scope().Lookup(name); ok {
+		return v, true
+	}
+	s.load()
+	return s.scope().Lookup(name)
+}
+
+func (s *lazyPreludeScope) LocalLookup(name string) (values.Value, bool) {
+	return s.Lookup(name)
+}
+
+func (s *lazyPreludeScope) Set(name string, v values.Value) {
+	s.load()
+	s.scope().Set(name, v)
+}
+
+func (s *lazyPreludeScope) Nest(obj values.Object) values.Scope {
+	return values.NewNestedScope(s, obj)
+}
+
+func (s *lazyPreludeScope) Size() int {
+	s.load()
+	return s.scope().Size()
+}
+
+func (s *lazyPreludeScope) Range(f func(k string, v values.Value)) {
+	s.load()
+	s.scope().Range(f)
+}
+
+func (s *lazyPreludeScope) LocalRange(f func(k string, v values.Value)) {
+	s.load()
+	s.scope().LocalRange(f)
+}
+
+func (s *lazyPreludeScope) Copy() values.Scope {
+	s.load()
+	return s.scope().Copy()
+}
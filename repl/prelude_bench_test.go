@@ -0,0 +1,29 @@
+package repl
+
+import (
+	"context"
+	"testing"
+
+	_ "github.com/influxdata/flux/fluxinit/static"
+	"github.com/influxdata/flux/runtime"
+)
+
+func benchmarkPrelude(b *testing.B, opts ...Option) {
+	r := newTestHolder(opts...)
+	r.importer = runtime.StdLib()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.newPreludeScope(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPrelude_Full(b *testing.B) {
+	benchmarkPrelude(b)
+}
+
+func BenchmarkPrelude_Minimal(b *testing.B) {
+	benchmarkPrelude(b, WithPrelude())
+}
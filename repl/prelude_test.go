@@ -0,0 +1,114 @@
+package repl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+// preludeImporter serves a package for each path in the prelude
+// holding a single value named after it, plus a value named shared
+// whose value is the path of the last package to bind it.
+type preludeImporter struct {
+	imported map[string]int
+}
+
+func (imp *preludeImporter) Import(path string) (semantic.MonoType, error) {
+	pkg, err := imp.ImportPackageObject(path)
+	if err != nil {
+		return semantic.MonoType{}, err
+	}
+	return pkg.Type(), nil
+}
+
+func (imp *preludeImporter) ImportPackageObject(path string) (*interpreter.Package, error) {
+	imp.imported[path]++
+	return interpreter.NewPackageWithValues(path, path, values.NewObjectWithValues(map[string]values.Value{
+		path:     values.NewString(path),
+		"shared": values.NewString(path),
+	})), nil
+}
+
+func TestWithPrelude(t *testing.T) {
+	imp := &preludeImporter{imported: make(map[string]int)}
+	r := newTestHolder(WithPrelude("universe"))
+	r.importer = imp
+
+	scope, err := r.newPreludeScope(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imp.imported) != 1 || imp.imported["universe"] != 1 {
+		t.Fatalf("expected only universe to be imported, got %v", imp.imported)
+	}
+	if v, ok := scope.Lookup("universe"); !ok || v.Str() != "universe" {
+		t.Fatalf("unexpected universe binding: %v", v)
+	}
+	if len(imp.imported) != 1 {
+		t.Fatalf("expected a bound name not to import the rest of the prelude, got %v", imp.imported)
+	}
+
+	last := runtime.PreludeList[len(runtime.PreludeList)-1]
+	if v, ok := scope.Nest(nil).Lookup(last); !ok || v.Str() != last {
+		t.Fatalf("expected %s to be imported lazily, got %v", last, v)
+	}
+	for _, p := range runtime.PreludeList {
+		if imp.imported[p] == 0 {
+			t.Errorf("expected %s to be imported", p)
+		}
+	}
+	if v, _ := scope.Lookup("shared"); v.Str() != last {
+		t.Fatalf("expected the prelude to be bound in order, got shared = %v", v)
+	}
+	if _, ok := scope.Lookup("missing"); ok {
+		t.Fatal("expected missing to stay undefined")
+	}
+}
+
+func TestWithPrelude_NotInPrelude(t *testing.T) {
+	r := newTestHolder(WithPrelude("strings"))
+	r.importer = &preludeImporter{imported: make(map[string]int)}
+	if _, err := r.newPreludeScope(context.Background()); errors.Code(err) != codes.Invalid {
+		t.Fatalf("expected an invalid error, got %v", err)
+	}
+}
+
+// blockingImporter blocks every import until release is closed.
+type blockingImporter struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (imp *blockingImporter) ImportPackageObject(path string) (*interpreter.Package, error) {
+	select {
+	case imp.started <- struct{}{}:
+	default:
+	}
+	<-imp.release
+	return interpreter.NewPackage(path), nil
+}
+
+func TestWithImporter_Canceled(t *testing.T) {
+	imp := &blockingImporter{started: make(chan struct{}, 1), release: make(chan struct{})}
+	defer close(imp.release)
+	r := newTestHolder(WithImporter(imp))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-imp.started
+		cancel()
+	}()
+	_, err := r.newPreludeScope(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the import to be canceled, got %v", err)
+	}
+	if got := errors.Code(err); got != codes.Canceled {
+		t.Fatalf("expected a canceled error, got %v", got)
+	}
+}
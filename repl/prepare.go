@@ -0,0 +1,427 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/semantic"
+)
+
+// PrepareRequest is the params object for Service.Prepare.
+type PrepareRequest struct {
+	// Name is the handle that the query is prepared under. Preparing
+	// another query under the same name replaces it.
+	Name  string `json:"name"`
+	Input string `json:"input"`
+	// Params gives a value for each parameter of the query, decoded
+	// like the params of ParamsRequest. It fixes the names and types
+	// of the parameters that the query is executed with.
+	Params map[string]interface{} `json:"params"`
+}
+
+// UnmarshalJSON decodes the params object, keeping the
+// distinction between integer and float parameters.
+func (req *PrepareRequest) UnmarshalJSON(data []byte) error {
+	type raw PrepareRequest
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode((*raw)(req)); err != nil {
+		return errors.Wrap(err, codes.Invalid, "malformed params")
+	}
+	return nil
+}
+
+// PrepareResponse is the response to Service.Prepare.
+type PrepareResponse struct {
+	// Handle names the query for Service.Execute.
+	Handle string `json:"handle"`
+	// Params maps the name of each parameter to its Flux type.
+	Params map[string]string `json:"params"`
+}
+
+// ExecuteRequest is the params object for Service.Execute.
+type ExecuteRequest struct {
+	Handle string `json:"handle"`
+	// Params gives a value for each parameter of the query, of the
+	// type it was prepared with.
+	Params map[string]interface{} `json:"params"`
+	// Labels are attached to the queries run by the query
+	// as described by WithQueryLabels.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// UnmarshalJSON decodes the params object, keeping the
+// distinction between integer and float parameters.
+func (req *ExecuteRequest) UnmarshalJSON(data []byte) error {
+	type raw ExecuteRequest
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode((*raw)(req)); err != nil {
+		return errors.Wrap(err, codes.Invalid, "malformed params")
+	}
+	return nil
+}
+
+// Prepare analyzes a query and keeps it under a name
+// as described by ScopeHolder.Prepare.
+func (s *Service) Prepare(req PrepareRequest, resp *PrepareResponse) error {
+	types, err := s.r.Prepare(s.r.ctx, req.Name, req.Input, req.Params)
+	if err != nil {
+		return err
+	}
+	*resp = PrepareResponse{Handle: req.Name, Params: types}
+	return nil
+}
+
+// Execute runs a prepared query as described by ScopeHolder.Execute
+// and responds like DidOutput.
+func (s *Service) Execute(req ExecuteRequest, resp *Response) error {
+	var res lineResult
+	err := s.r.execute(WithQueryLabels(s.r.ctx, req.Labels), req.Handle, req.Params, func(ctx context.Context, ses []interpreter.SideEffect) error {
+		var err error
+		res, err = s.r.runSideEffects(ctx, ses, s.r.resultWriter)
+		return err
+	})
+	s.r.setLineError(&res, nil, err)
+	return res.response(resp)
+}
+
+// Prepare analyzes the Flux source t with each parameter in params
+// bound to its name, as EvalWithParams does, and keeps the analyzed
+// query under name so that Execute can run it any number of times
+// without analyzing it again. This saves most of the cost of a query
+// that is run many times with different parameters, such as that of
+// a dashboard panel. The query is planned again on each execution,
+// since its plan depends on the parameters, unless the session has a
+// plan cache and the parameters repeat.
+//
+// The values in params fix the type of each parameter, which are
+// returned by name. A query prepared under name earlier is replaced.
+func (r *ScopeHolder) Prepare(ctx context.Context, name, t string, params map[string]interface{}) (map[string]string, error) {
+	if name == "" {
+		return nil, errors.New(codes.Invalid, "a prepared query requires a name")
+	}
+	if t != "" && t[0] == '@' {
+		q, err := loadQuery(t, r.queryLoader)
+		if err != nil {
+			return nil, err
+		}
+		t = q
+	}
+
+	ctx, end := r.beginLine(ctx)
+	defer end()
+	scope, err := r.bindParams(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	r.evalMu.Lock()
+	defer r.evalMu.Unlock()
+	pkg, fluxError, err := r.analyzeIn(ctx, t, scope)
+	if fluxError != nil {
+		return nil, errors.Wrap(fluxError.GoError(), codes.Invalid, "failed to analyze the query")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := &preparedQuery{pkg: pkg, params: make(map[string]string, len(params)), created: time.Now()}
+	for name := range params {
+		v, _ := scope.Lookup(name)
+		p.params[name] = v.Type().String()
+	}
+	p.uses = r.usedTypes(pkg, p.params)
+	r.prepared.set(name, p)
+
+	types := make(map[string]string, len(p.params))
+	for name, typ := range p.params {
+		types[name] = typ
+	}
+	return types, nil
+}
+
+// Execute runs the query prepared under handle with each parameter in
+// params bound to its name, and returns its output as EvalWithParams
+// does. The parameters must be those the query was prepared with, with
+// values of the same types.
+//
+// A prepared query is dropped once a name that it uses is bound again
+// in the session scope with another type, since its analysis no longer
+// holds. Executing it then fails with a failed precondition error, and
+// it must be prepared again. A reset drops every prepared query.
+func (r *ScopeHolder) Execute(ctx context.Context, handle string, params map[string]interface{}) (Result, error) {
+	var res Result
+	err := r.execute(ctx, handle, params, func(ctx context.Context, ses []interpreter.SideEffect) error {
+		var err error
+		res, err = r.collectResult(ctx, ses)
+		return err
+	})
+	return res, err
+}
+
+// execute evaluates the query prepared under handle in the context of
+// a line, and calls run with the side effects of the evaluation.
+func (r *ScopeHolder) execute(ctx context.Context, handle string, params map[string]interface{}, run func(ctx context.Context, ses []interpreter.SideEffect) error) error {
+	ctx, end := r.beginLine(ctx)
+	defer end()
+	ctx, stop := r.watch(ctx)
+	defer stop()
+
+	p, ok := r.prepared.get(handle)
+	if !ok {
+		return errors.Newf(codes.NotFound, "no query is prepared under %q", handle)
+	}
+	if err := p.checkParams(params); err != nil {
+		return err
+	}
+	ses, err := r.evalPrepared(ctx, handle, p, params)
+	if err != nil {
+		return err
+	}
+	return run(ctx, ses)
+}
+
+// evalPrepared evaluates the prepared query p in a scope nested within
+// the session scope with params bound, after checking that the names
+// it uses still have the types it was analyzed with.
+func (r *ScopeHolder) evalPrepared(ctx context.Context, handle string, p *preparedQuery, params map[string]interface{}) ([]interpreter.SideEffect, error) {
+	r.evalMu.Lock()
+	defer r.evalMu.Unlock()
+
+	if name, ok := r.staleUse(p); ok {
+		r.prepared.drop(handle, p)
+		return nil, errors.Newf(codes.FailedPrecondition, "prepared query %q is out of date since %s was bound again; prepare it again", handle, name)
+	}
+
+	// Allow the evaluation itself to be interrupted
+	// without discarding the session scope.
+	ctx, cancelFunc := context.WithCancel(ctx)
+	r.setCancel(cancelFunc)
+	defer cancelFunc()
+	defer r.clearCancel()
+
+	// The parameters are bound directly, rather than by evaluating
+	// Flux source that binds them, which would be analyzed again.
+	scope := r.nestScope()
+	for name, v := range params {
+		pv, err := paramValue(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, codes.Invalid, "parameter %s", name)
+		}
+		scope.Set(name, pv)
+	}
+	return r.evalPackage(ctx, p.pkg, scope)
+}
+
+// usedTypes returns the type in the session scope of each name that
+// pkg refers to without binding it, other than the parameters, with
+// an empty type for a name that is not bound. The caller must hold
+// evalMu.
+func (r *ScopeHolder) usedTypes(pkg *semantic.Package, params map[string]string) map[string]string {
+	uses := make(map[string]string)
+	v := &freeNameVisitor{use: func(name string) {
+		if _, ok := params[name]; ok {
+			return
+		}
+		uses[name] = r.scopeType(name)
+	}}
+	semantic.Walk(v, pkg)
+	return uses
+}
+
+// freeNameVisitor calls use for each identifier that is not bound by
+// the import or the statement of a file, by a function parameter or
+// by a statement of a function body that encloses it.
+type freeNameVisitor struct {
+	use   func(name string)
+	bound []map[string]bool
+}
+
+func (v *freeNameVisitor) Visit(n semantic.Node) semantic.Visitor {
+	switch n := n.(type) {
+	case *semantic.File:
+		names := statementNames(n.Body)
+		for _, imp := range n.Imports {
+			names[importName(imp)] = true
+		}
+		v.bound = append(v.bound, names)
+	case *semantic.FunctionExpression:
+		names := make(map[string]bool)
+		if n.Parameters != nil {
+			for _, p := range n.Parameters.List {
+				names[p.Key.Name.Name()] = true
+			}
+		}
+		v.bound = append(v.bound, names)
+	case *semantic.Block:
+		v.bound = append(v.bound, statementNames(n.Body))
+	case *semantic.IdentifierExpression:
+		name := n.Name.Name()
+		for _, names := range v.bound {
+			if names[name] {
+				return v
+			}
+		}
+		v.use(name)
+	}
+	return v
+}
+
+func (v *freeNameVisitor) Done(n semantic.Node) {
+	switch n.(type) {
+	case *semantic.File, *semantic.FunctionExpression, *semantic.Block:
+		v.bound = v.bound[:len(v.bound)-1]
+	}
+}
+
+// statementNames returns the names that the statements bind.
+func statementNames(body []semantic.Statement) map[string]bool {
+	names := make(map[string]bool)
+	for _, s := range body {
+		var n semantic.Node = s
+		if o, ok := s.(*semantic.OptionStatement); ok {
+			n = o.Assignment
+		}
+		if a, ok := n.(*semantic.NativeVariableAssignment); ok {
+			names[a.Identifier.Name.Name()] = true
+		}
+	}
+	return names
+}
+
+// importName returns the name that the import binds.
+func importName(imp *semantic.ImportDeclaration) string {
+	if imp.As != nil && imp.As.Name.Name() != "" {
+		return imp.As.Name.Name()
+	}
+	return path.Base(imp.Path.Value)
+}
+
+// staleUse returns a name used by p whose type in the session scope
+// has changed since p was prepared. The caller must hold evalMu.
+func (r *ScopeHolder) staleUse(p *preparedQuery) (string, bool) {
+	names := make([]string, 0, len(p.uses))
+	for name := range p.uses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if r.scopeType(name) != p.uses[name] {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// scopeType returns the type of the value bound to name in the
+// session scope, or an empty string if it is not bound.
+func (r *ScopeHolder) scopeType(name string) string {
+	v, ok := r.scope.Lookup(name)
+	if !ok {
+		return ""
+	}
+	return v.Type().String()
+}
+
+// preparedQuery is a query analyzed by Prepare.
+type preparedQuery struct {
+	pkg *semantic.Package
+	// params maps the name of each parameter to its type.
+	params map[string]string
+	// uses maps each other name the query refers to without
+	// binding it to its type in the session scope when it was prepared.
+	uses    map[string]string
+	created time.Time
+}
+
+// checkParams checks that params are the parameters of p.
+func (p *preparedQuery) checkParams(params map[string]interface{}) error {
+	for name := range p.params {
+		if _, ok := params[name]; !ok {
+			return errors.Newf(codes.Invalid, "missing parameter %s", name)
+		}
+	}
+	for name, v := range params {
+		typ, ok := p.params[name]
+		if !ok {
+			return errors.Newf(codes.Invalid, "unknown parameter %s", name)
+		}
+		pv, err := paramValue(v)
+		if err != nil {
+			return errors.Wrapf(err, codes.Invalid, "parameter %s", name)
+		}
+		if got := pv.Type().String(); got != typ {
+			return errors.Newf(codes.Invalid, "parameter %s is of type %s, the query was prepared with %s", name, got, typ)
+		}
+	}
+	return nil
+}
+
+// preparedQueries holds the prepared queries of a session by name.
+// Its zero value is ready to use.
+type preparedQueries struct {
+	mu      sync.Mutex
+	queries map[string]*preparedQuery
+}
+
+func (q *preparedQueries) set(name string, p *preparedQuery) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.queries == nil {
+		q.queries = make(map[string]*preparedQuery)
+	}
+	q.queries[name] = p
+}
+
+func (q *preparedQueries) get(name string) (*preparedQuery, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	p, ok := q.queries[name]
+	return p, ok
+}
+
+// drop removes p from under name, unless it has been replaced.
+func (q *preparedQueries) drop(name string, p *preparedQuery) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.queries[name] == p {
+		delete(q.queries, name)
+	}
+}
+
+// remove removes the query prepared under name
+// and reports whether there was one.
+func (q *preparedQueries) remove(name string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.queries[name]
+	delete(q.queries, name)
+	return ok
+}
+
+// resources lists the prepared queries.
+func (q *preparedQueries) resources() []Resource {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	resources := make([]Resource, 0, len(q.queries))
+	for name, p := range q.queries {
+		resources = append(resources, Resource{Kind: ResourcePrepared, ID: name, Created: p.created, Status: "ready"})
+	}
+	return resources
+}
+
+// clear removes every prepared query.
+func (q *preparedQueries) clear() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.queries = nil
+}
@@ -0,0 +1,121 @@
+package repl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/semantic"
+)
+
+func TestPreparedQuery_CheckParams(t *testing.T) {
+	p := &preparedQuery{params: map[string]string{"threshold": "int", "host": "string"}}
+
+	for _, tc := range []struct {
+		name    string
+		params  map[string]interface{}
+		wantErr bool
+	}{
+		{name: "ok", params: map[string]interface{}{"threshold": json.Number("3"), "host": "a"}},
+		{name: "missing", params: map[string]interface{}{"threshold": json.Number("3")}, wantErr: true},
+		{name: "unknown", params: map[string]interface{}{"threshold": json.Number("3"), "host": "a", "extra": true}, wantErr: true},
+		{name: "type", params: map[string]interface{}{"threshold": json.Number("3.5"), "host": "a"}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := p.checkParams(tc.params)
+			if !tc.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if got := errors.Code(err); got != codes.Invalid {
+				t.Fatalf("expected an invalid error, got %v: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestPreparedQueries(t *testing.T) {
+	var q preparedQueries
+	first, second := &preparedQuery{}, &preparedQuery{}
+	q.set("panel", first)
+	q.set("panel", second)
+
+	// Dropping a query that has been replaced keeps its replacement.
+	q.drop("panel", first)
+	if got, ok := q.get("panel"); !ok || got != second {
+		t.Fatal("expected the replacement to be kept")
+	}
+	q.drop("panel", second)
+	if _, ok := q.get("panel"); ok {
+		t.Fatal("expected the query to be dropped")
+	}
+
+	q.set("panel", first)
+	q.clear()
+	if _, ok := q.get("panel"); ok {
+		t.Fatal("expected every query to be cleared")
+	}
+}
+
+func TestService_Execute_NotFound(t *testing.T) {
+	call := serveTestService(t, &Service{r: newTestHolder()})
+	resp := call(`{"method": "Service.Execute", "id": 1, "params": [{"handle": "missing"}]}`)
+	if resp.Error == nil {
+		t.Fatal("expected an error for a handle that was never prepared")
+	}
+}
+
+func TestExecuteRequest_UnmarshalJSON(t *testing.T) {
+	var req ExecuteRequest
+	if err := json.Unmarshal([]byte(`{"handle": "panel", "params": {"n": 1}}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := req.Params["n"].(json.Number); !ok {
+		t.Fatalf("expected a json.Number param, got %T", req.Params["n"])
+	}
+}
+
+func TestFreeNameVisitor(t *testing.T) {
+	ident := func(name string) *semantic.IdentifierExpression {
+		return &semantic.IdentifierExpression{Name: semantic.NewSymbol(name)}
+	}
+	key := func(name string) *semantic.Identifier {
+		return &semantic.Identifier{Name: semantic.NewSymbol(name)}
+	}
+	// import "strings"
+	// x = 1
+	// (r) => { y = r
+	//          return r._value + y + scale }
+	// strings.title
+	// x
+	pkg := &semantic.Package{Files: []*semantic.File{{
+		Imports: []*semantic.ImportDeclaration{{Path: &semantic.StringLiteral{Value: "strings"}}},
+		Body: []semantic.Statement{
+			&semantic.NativeVariableAssignment{Identifier: key("x"), Init: &semantic.IntegerLiteral{Value: 1}},
+			&semantic.ExpressionStatement{Expression: &semantic.FunctionExpression{
+				Parameters: &semantic.FunctionParameters{List: []*semantic.FunctionParameter{{Key: key("r")}}},
+				Block: &semantic.Block{Body: []semantic.Statement{
+					&semantic.NativeVariableAssignment{Identifier: key("y"), Init: ident("r")},
+					&semantic.ReturnStatement{Argument: &semantic.BinaryExpression{
+						Operator: ast.AdditionOperator,
+						Left:     &semantic.MemberExpression{Object: ident("r"), Property: semantic.NewSymbol("_value")},
+						Right:    &semantic.BinaryExpression{Operator: ast.AdditionOperator, Left: ident("y"), Right: ident("scale")},
+					}},
+				}},
+			}},
+			&semantic.ExpressionStatement{Expression: &semantic.MemberExpression{Object: ident("strings"), Property: semantic.NewSymbol("title")}},
+			&semantic.ExpressionStatement{Expression: ident("x")},
+		},
+	}}}
+
+	var got []string
+	semantic.Walk(&freeNameVisitor{use: func(name string) { got = append(got, name) }}, pkg)
+	if want := []string{"scale"}; !cmp.Equal(want, got) {
+		t.Fatalf("unexpected free names -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,75 @@
+package repl
+
+import (
+	"fmt"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/stdlib/universe"
+)
+
+// WithPreviewLimit limits each result of every query to its first n
+// rows per table by adding a limit to the query before it is planned,
+// rather than reading every row and discarding the rest. Sources that
+// limits are pushed down into stop reading early, and in any case the
+// transformations after the limit and the output only see n rows.
+//
+// A result that already ends in a limit is left as it is, as are
+// the streams that go through a yield on their way to another
+// transformation. A limit of zero or less, the default, disables it.
+func WithPreviewLimit(n int64) Option {
+	return option(func(r *ScopeHolder) {
+		r.previewLimit = n
+	})
+}
+
+// previewLimitPrefix starts the IDs of the limits added to a query.
+const previewLimitPrefix = "repl.previewLimit"
+
+// limitSpec returns a copy of s in which the stream of each result
+// that does not end in a limit goes through a limit of n rows.
+// The operations of s are shared by the copy.
+func limitSpec(s *flux.Spec, n int64) *flux.Spec {
+	ls := &flux.Spec{
+		Operations: append([]*flux.Operation(nil), s.Operations...),
+		Edges:      append([]flux.Edge(nil), s.Edges...),
+		Resources:  s.Resources,
+		Now:        s.Now,
+	}
+	var added int
+	newLimit := func() *flux.Operation {
+		o := &flux.Operation{
+			ID:   flux.OperationID(fmt.Sprintf("%s%d", previewLimitPrefix, added)),
+			Spec: &universe.LimitOpSpec{N: n},
+		}
+		added++
+		ls.Operations = append(ls.Operations, o)
+		return o
+	}
+	isLimit := func(o *flux.Operation) bool {
+		return o.Spec.Kind() == universe.LimitKind
+	}
+
+	for _, o := range s.Operations {
+		if len(s.Children(o.ID)) > 0 {
+			continue
+		}
+		if o.Spec.Kind() != universe.YieldKind {
+			if !isLimit(o) {
+				ls.Edges = append(ls.Edges, flux.Edge{Parent: o.ID, Child: newLimit().ID})
+			}
+			continue
+		}
+		parents := s.Parents(o.ID)
+		if len(parents) != 1 || isLimit(parents[0]) {
+			continue
+		}
+		l := newLimit()
+		for i, e := range ls.Edges {
+			if e.Child == o.ID {
+				ls.Edges[i].Child = l.ID
+			}
+		}
+		ls.Edges = append(ls.Edges, flux.Edge{Parent: l.ID, Child: o.ID})
+	}
+	return ls
+}
@@ -0,0 +1,82 @@
+package repl
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/stdlib/universe"
+)
+
+func TestLimitSpec(t *testing.T) {
+	s := &flux.Spec{
+		Operations: []*flux.Operation{
+			// A yielded stream.
+			{ID: "test0", Spec: testOpSpec{}},
+			{ID: "yield1", Spec: &universe.YieldOpSpec{Name: "a"}},
+			// A stream yielded as _result.
+			{ID: "test2", Spec: testOpSpec{}},
+			// A stream that is already limited.
+			{ID: "test3", Spec: testOpSpec{}},
+			{ID: "limit4", Spec: &universe.LimitOpSpec{N: 10}},
+			{ID: "yield5", Spec: &universe.YieldOpSpec{Name: "b"}},
+			// A stream yielded on its way to another transformation.
+			{ID: "test6", Spec: testOpSpec{}},
+			{ID: "yield7", Spec: &universe.YieldOpSpec{Name: "c"}},
+			{ID: "test8", Spec: testOpSpec{}},
+		},
+		Edges: []flux.Edge{
+			{Parent: "test0", Child: "yield1"},
+			{Parent: "test3", Child: "limit4"},
+			{Parent: "limit4", Child: "yield5"},
+			{Parent: "test6", Child: "yield7"},
+			{Parent: "yield7", Child: "test8"},
+		},
+	}
+	edges := len(s.Edges)
+
+	ls := limitSpec(s, 2)
+	parentOf := func(id flux.OperationID) []flux.OperationID {
+		var ids []flux.OperationID
+		for _, o := range ls.Parents(id) {
+			ids = append(ids, o.ID)
+		}
+		return ids
+	}
+	for _, tt := range []struct {
+		id   flux.OperationID
+		want []flux.OperationID
+	}{
+		{id: "yield1", want: []flux.OperationID{"repl.previewLimit0"}},
+		{id: "repl.previewLimit0", want: []flux.OperationID{"test0"}},
+		{id: "repl.previewLimit1", want: []flux.OperationID{"test2"}},
+		{id: "yield5", want: []flux.OperationID{"limit4"}},
+		{id: "test8", want: []flux.OperationID{"yield7"}},
+		{id: "repl.previewLimit2", want: []flux.OperationID{"test8"}},
+	} {
+		if got := parentOf(tt.id); !cmp.Equal(tt.want, got) {
+			t.Errorf("unexpected parents of %s -want/+got:\n%s", tt.id, cmp.Diff(tt.want, got))
+		}
+	}
+	if got, want := len(ls.Operations), len(s.Operations)+3; got != want {
+		t.Fatalf("expected three limits to be added, got %d operations, want %d", got, want)
+	}
+	for _, o := range ls.Operations[len(s.Operations):] {
+		if spec := o.Spec.(*universe.LimitOpSpec); spec.N != 2 {
+			t.Errorf("unexpected limit of %s: got %d, want 2", o.ID, spec.N)
+		}
+	}
+
+	names, err := yieldNames(ls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	if want := []string{"_result", "_result", "a", "b", "c"}; !cmp.Equal(want, names) {
+		t.Fatalf("expected the results to be unchanged -want/+got:\n%s", cmp.Diff(want, names))
+	}
+	if len(s.Edges) != edges {
+		t.Fatal("expected the original spec to be left as it is")
+	}
+}
@@ -0,0 +1,225 @@
+package repl
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/metadata"
+)
+
+// queryIDKey is the statistics metadata key under which the ID
+// of a query is recorded.
+const queryIDKey = "flux/query-id"
+
+// QueryRequest is the request for the Service methods that
+// refer to a query that is running.
+type QueryRequest struct {
+	ID string `json:"id"`
+}
+
+// QueriesResponse is the response to Service.Queries.
+type QueriesResponse struct {
+	IDs []string `json:"ids"`
+}
+
+// Queries lists the IDs of the queries that are running.
+func (s *Service) Queries(req struct{}, resp *QueriesResponse) error {
+	*resp = QueriesResponse{IDs: s.r.ActiveQueries()}
+	return nil
+}
+
+// CancelQuery cancels the running query with the given ID.
+// The ID may be one returned along with the output of an input,
+// as described by ScopeHolder.CancelQuery.
+func (s *Service) CancelQuery(req QueryRequest, resp *struct{}) error {
+	return s.r.CancelQuery(req.ID)
+}
+
+// CancelAllResponse is the response to Service.CancelAll.
+type CancelAllResponse struct {
+	Canceled int `json:"canceled"`
+}
+
+// CancelAll cancels every running query.
+func (s *Service) CancelAll(req struct{}, resp *CancelAllResponse) error {
+	*resp = CancelAllResponse{Canceled: s.r.CancelAll()}
+	return nil
+}
+
+// ActiveQueries returns the IDs of the queries that are running.
+func (r *ScopeHolder) ActiveQueries() []string {
+	return r.queries.ids()
+}
+
+// CancelQuery cancels the running query with the given ID.
+// Canceling one of the last queries that finished does nothing, so
+// that the IDs returned along with the output of an input, once its
+// queries have finished, can still be canceled. A not found error is
+// returned if no such query is running or finished recently.
+func (r *ScopeHolder) CancelQuery(id string) error {
+	return r.queries.cancel(id)
+}
+
+// CancelAll cancels every running query and returns how many it canceled.
+func (r *ScopeHolder) CancelAll() int {
+	return r.queries.cancelAll(cancelUser)
+}
+
+// finishedQueries is how many of the queries that finished last
+// the registry remembers.
+const finishedQueries = 1024
+
+// queryRegistry tracks the queries that are running, and the IDs
+// of those that finished last. Its zero value is ready to use.
+type queryRegistry struct {
+	mu      sync.Mutex
+	next    uint64
+	queries map[string]*runningQuery
+	// finished holds the IDs of the queries that finished last,
+	// oldest first.
+	finished []string
+}
+
+// runningQuery is a query in the registry.
+type runningQuery struct {
+	cancel context.CancelFunc
+	// reason is set before cancel is called by the registry.
+	reason  cancelReason
+	started time.Time
+}
+
+// add registers a running query and returns its unique ID.
+func (qr *queryRegistry) add(cancel context.CancelFunc) string {
+	qr.mu.Lock()
+	defer qr.mu.Unlock()
+	if qr.queries == nil {
+		qr.queries = make(map[string]*runningQuery)
+	}
+	qr.next++
+	id := strconv.FormatUint(qr.next, 10)
+	qr.queries[id] = &runningQuery{cancel: cancel, started: time.Now()}
+	return id
+}
+
+// remove forgets a query once it has finished,
+// only remembering that it finished.
+func (qr *queryRegistry) remove(id string) {
+	qr.mu.Lock()
+	defer qr.mu.Unlock()
+	delete(qr.queries, id)
+	if len(qr.finished) == finishedQueries {
+		qr.finished = qr.finished[1:]
+	}
+	qr.finished = append(qr.finished, id)
+}
+
+// hasFinished reports whether the query with the given ID is one of
+// the queries that finished last. The caller must hold mu.
+func (qr *queryRegistry) hasFinished(id string) bool {
+	for _, f := range qr.finished {
+		if f == id {
+			return true
+		}
+	}
+	return false
+}
+
+// cancel cancels the query with the given ID at the request of the user.
+func (qr *queryRegistry) cancel(id string) error {
+	qr.mu.Lock()
+	q, ok := qr.queries[id]
+	if ok {
+		q.reason = cancelUser
+	}
+	finished := !ok && qr.hasFinished(id)
+	qr.mu.Unlock()
+	if finished {
+		return nil
+	}
+	if !ok {
+		return errors.Newf(codes.NotFound, "no running query with id %q", id)
+	}
+	q.cancel()
+	return nil
+}
+
+// reason returns why the query with the given ID was canceled
+// by the registry, if it was.
+func (qr *queryRegistry) reason(id string) cancelReason {
+	qr.mu.Lock()
+	defer qr.mu.Unlock()
+	if q, ok := qr.queries[id]; ok {
+		return q.reason
+	}
+	return cancelUnknown
+}
+
+// cancelAll cancels the queries that are registered when it is called
+// for the given reason. Queries that finish while they are being
+// canceled are unaffected, since canceling a finished query does nothing.
+func (qr *queryRegistry) cancelAll(reason cancelReason) int {
+	qr.mu.Lock()
+	cancels := make([]context.CancelFunc, 0, len(qr.queries))
+	for _, q := range qr.queries {
+		q.reason = reason
+		cancels = append(cancels, q.cancel)
+	}
+	qr.mu.Unlock()
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+	return len(cancels)
+}
+
+func (qr *queryRegistry) ids() []string {
+	qr.mu.Lock()
+	defer qr.mu.Unlock()
+	ids := make([]string, 0, len(qr.queries))
+	for id := range qr.queries {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.ParseUint(ids[i], 10, 64)
+		b, _ := strconv.ParseUint(ids[j], 10, 64)
+		return a < b
+	})
+	return ids
+}
+
+// resources lists the queries that are running.
+func (qr *queryRegistry) resources() []Resource {
+	qr.mu.Lock()
+	defer qr.mu.Unlock()
+	resources := make([]Resource, 0, len(qr.queries))
+	for id, q := range qr.queries {
+		resources = append(resources, Resource{Kind: ResourceQuery, ID: id, Created: q.started, Status: "running"})
+	}
+	return resources
+}
+
+// addQueryID records the query ID in the statistics metadata.
+func addQueryID(stats flux.Statistics, id string) flux.Statistics {
+	if stats.Metadata == nil {
+		stats.Metadata = make(metadata.Metadata)
+	}
+	stats.Metadata.Add(queryIDKey, id)
+	return stats
+}
+
+// statsQueryIDs returns the IDs of the queries recorded in stats, in order.
+func statsQueryIDs(stats flux.Statistics) []string {
+	var ids []string
+	for _, v := range stats.Metadata.GetAll(queryIDKey) {
+		if id, ok := v.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,163 @@
+package repl
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func TestQueryRegistry(t *testing.T) {
+	var qr queryRegistry
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	id1 := qr.add(cancel1)
+	id2 := qr.add(cancel2)
+	if id1 == id2 {
+		t.Fatalf("expected unique query ids, got %q twice", id1)
+	}
+	if got, want := qr.ids(), []string{id1, id2}; !cmp.Equal(want, got) {
+		t.Fatalf("unexpected query ids -want/+got:\n%s", cmp.Diff(want, got))
+	}
+
+	if err := qr.cancel(id2); err != nil {
+		t.Fatal(err)
+	}
+	if ctx2.Err() == nil {
+		t.Error("expected the second query to be canceled")
+	}
+	if ctx1.Err() != nil {
+		t.Error("expected the first query to keep running")
+	}
+
+	qr.remove(id1)
+	if err := qr.cancel(id1); err != nil {
+		t.Fatalf("unexpected error canceling a query that has finished: %v", err)
+	}
+	if got, want := qr.ids(), []string{id2}; !cmp.Equal(want, got) {
+		t.Fatalf("unexpected query ids -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if err := qr.cancel("unknown"); err == nil {
+		t.Fatal("expected an error canceling an unknown query")
+	} else if got, want := errors.Code(err), codes.NotFound; got != want {
+		t.Fatalf("unexpected error code: got %v, want %v", got, want)
+	}
+
+	// IDs are not reused once a query has finished.
+	if id3 := qr.add(cancel1); id3 == id1 || id3 == id2 {
+		t.Fatalf("expected a new query id, got %q", id3)
+	}
+}
+
+func TestQueryRegistry_Finished(t *testing.T) {
+	var qr queryRegistry
+	first := qr.add(func() {})
+	qr.remove(first)
+	for i := 0; i < finishedQueries; i++ {
+		qr.remove(qr.add(func() {}))
+	}
+	if len(qr.finished) != finishedQueries {
+		t.Fatalf("expected %d finished queries to be remembered, got %d", finishedQueries, len(qr.finished))
+	}
+	if err := qr.cancel(first); errors.Code(err) != codes.NotFound {
+		t.Fatalf("expected the oldest finished query to be forgotten, got %v", err)
+	}
+	if err := qr.cancel(qr.finished[0]); err != nil {
+		t.Fatalf("unexpected error canceling a query that has finished: %v", err)
+	}
+}
+
+func TestService_CancelQuery(t *testing.T) {
+	r := newTestHolder()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	id := r.queries.add(cancel)
+
+	send := serveTestService(t, &Service{r: r})
+
+	resp := send(`{"method": "Service.Queries", "id": 1, "params": [{}]}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	var queries QueriesResponse
+	if err := json.Unmarshal(resp.Result, &queries); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{id}; !cmp.Equal(want, queries.IDs) {
+		t.Fatalf("unexpected query ids -want/+got:\n%s", cmp.Diff(want, queries.IDs))
+	}
+
+	resp = send(`{"method": "Service.CancelQuery", "id": 2, "params": [{"id": "` + id + `"}]}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("expected the query to be canceled")
+	}
+
+	resp = send(`{"method": "Service.CancelQuery", "id": 3, "params": [{"id": "unknown"}]}`)
+	if resp.Error == nil {
+		t.Fatal("expected an error canceling an unknown query")
+	}
+}
+
+func TestService_CancelAll(t *testing.T) {
+	r := newTestHolder()
+	var ctxs []context.Context
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ctxs = append(ctxs, ctx)
+		r.queries.add(cancel)
+	}
+
+	send := serveTestService(t, &Service{r: r})
+	resp := send(`{"method": "Service.CancelAll", "id": 1, "params": [{}]}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	var canceled CancelAllResponse
+	if err := json.Unmarshal(resp.Result, &canceled); err != nil {
+		t.Fatal(err)
+	}
+	if canceled.Canceled != 3 {
+		t.Fatalf("expected 3 queries to be canceled, got %d", canceled.Canceled)
+	}
+	for i, ctx := range ctxs {
+		if ctx.Err() == nil {
+			t.Errorf("expected query %d to be canceled", i)
+		}
+	}
+}
+
+func TestQueryRegistry_CancelAllConcurrent(t *testing.T) {
+	var qr queryRegistry
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		id := qr.add(cancel)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-ctx.Done()
+			qr.remove(id)
+		}()
+		if i%2 == 0 {
+			// Some queries finish on their own during the sweep.
+			go cancel()
+		}
+	}
+	qr.cancelAll(cancelUser)
+	wg.Wait()
+	if ids := qr.ids(); len(ids) != 0 {
+		t.Fatalf("expected every query to finish, got %v still running", ids)
+	}
+}
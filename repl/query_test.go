@@ -0,0 +1,226 @@
+package repl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/mock"
+)
+
+// countingQuery records how many times Done is called.
+type countingQuery struct {
+	mock.Query
+	done int
+}
+
+func (q *countingQuery) Done() {
+	q.done++
+	q.Query.Done()
+}
+
+func newCountingQuery(n int) *countingQuery {
+	q := &countingQuery{}
+	q.SetStatistics(flux.Statistics{TotalAllocated: 1})
+	q.ProduceResults(func(results chan<- flux.Result, canceled <-chan struct{}) {
+		for i := 0; i < n; i++ {
+			select {
+			case results <- &executetest.Result{Nm: "_result"}:
+			case <-canceled:
+				return
+			}
+		}
+	})
+	return q
+}
+
+func TestDrainQuery(t *testing.T) {
+	q := newCountingQuery(3)
+	var n int
+	stats, err := drainQuery(q, func(flux.Result) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 results, got %d", n)
+	}
+	if q.done != 1 {
+		t.Errorf("expected Done to be called once, got %d", q.done)
+	}
+	if got, want := stats.TotalAllocated, int64(1); got != want {
+		t.Errorf("expected statistics to be read after Done: got %d, want %d", got, want)
+	}
+}
+
+func TestDrainQuery_Error(t *testing.T) {
+	q := newCountingQuery(3)
+	_, err := drainQuery(q, func(flux.Result) error {
+		return errors.New(codes.Internal, "write failed")
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if q.done != 1 {
+		t.Errorf("expected Done to be called once, got %d", q.done)
+	}
+}
+
+func writeQueryFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLoadQuery(t *testing.T) {
+	const query = "x = 1\nx + 1\n"
+	for _, tt := range []struct {
+		name string
+		file string
+		data []byte
+	}{
+		{name: "plain", file: "q.flux", data: []byte(query)},
+		{name: "gzip extension", file: "q.flux.gz", data: gzipped(t, query)},
+		{name: "gzip magic", file: "q.flux", data: gzipped(t, query)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeQueryFile(t, tt.file, tt.data)
+			got, err := LoadQuery("@" + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != query {
+				t.Fatalf("unexpected query: got %q, want %q", got, query)
+			}
+		})
+	}
+}
+
+func TestLoadQuery_CorruptGzip(t *testing.T) {
+	data := gzipped(t, "x = 1")
+	path := writeQueryFile(t, "q.flux.gz", data[:len(data)-4])
+	_, err := LoadQuery("@" + path)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if got, want := errors.Code(err), codes.Invalid; got != want {
+		t.Fatalf("unexpected error code: got %v, want %v", got, want)
+	}
+}
+
+func TestHTTPQueryLoader(t *testing.T) {
+	const query = "x = 1\nx + 1\n"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/q.flux", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, query)
+	})
+	mux.HandleFunc("/q.flux.gz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(gzipped(t, query))
+	})
+	mux.HandleFunc("/big.flux", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, strings.Repeat("x", 64))
+	})
+	// bomb is small enough to be fetched, but not once it is decompressed.
+	bomb := gzipped(t, strings.Repeat("x", 4096))
+	if len(bomb) > 48 {
+		t.Fatalf("expected the compressed query to fit the limit, got %d bytes", len(bomb))
+	}
+	mux.HandleFunc("/bomb.flux.gz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(bomb)
+	})
+	mux.HandleFunc("/away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://example.com/q.flux", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	l := &HTTPQueryLoader{AllowedHosts: []string{host}, MaxBytes: 48}
+	for _, p := range []string{"/q.flux", "/q.flux.gz"} {
+		got, err := l.LoadQuery("@" + srv.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != query {
+			t.Fatalf("unexpected query from %s: got %q, want %q", p, got, query)
+		}
+	}
+
+	for _, tt := range []struct {
+		name   string
+		loader *HTTPQueryLoader
+		q      string
+		code   codes.Code
+	}{
+		{name: "disallowed host", loader: &HTTPQueryLoader{AllowedHosts: []string{"example.com"}}, q: "@" + srv.URL + "/q.flux", code: codes.PermissionDenied},
+		{name: "no hosts", loader: &HTTPQueryLoader{}, q: "@" + srv.URL + "/q.flux", code: codes.PermissionDenied},
+		{name: "redirect to disallowed host", loader: l, q: "@" + srv.URL + "/away", code: codes.PermissionDenied},
+		{name: "too large", loader: l, q: "@" + srv.URL + "/big.flux", code: codes.ResourceExhausted},
+		{name: "too large once decompressed", loader: l, q: "@" + srv.URL + "/bomb.flux.gz", code: codes.ResourceExhausted},
+		{name: "not found", loader: l, q: "@" + srv.URL + "/missing.flux", code: codes.NotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.loader.LoadQuery(tt.q)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := errors.Code(err); got != tt.code {
+				t.Fatalf("unexpected error code: got %v, want %v: %v", got, tt.code, err)
+			}
+		})
+	}
+
+	// LoadQuery fetches nothing.
+	if _, err := LoadQuery("@" + srv.URL + "/q.flux"); errors.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected LoadQuery to refuse to fetch a query, got %v", err)
+	}
+}
+
+func TestScopeHolder_EvalInScope_HTTPQueryLoader(t *testing.T) {
+	var fetched int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		_, _ = io.WriteString(w, "1")
+	}))
+	defer srv.Close()
+
+	// The input is loaded with the loader of the session before it is
+	// analyzed, so a host that is not allowed is never requested.
+	r := newTestHolder(WithHTTPQueryLoader(&HTTPQueryLoader{AllowedHosts: []string{"example.com"}}))
+	_, _, err := r.evalInScope(context.Background(), "@"+srv.URL+"/q.flux", nil)
+	if got, want := errors.Code(err), codes.PermissionDenied; got != want {
+		t.Fatalf("unexpected error code: got %v, want %v: %v", got, want, err)
+	}
+	if fetched != 0 {
+		t.Fatalf("expected no request to a host that is not allowed, got %d", fetched)
+	}
+}
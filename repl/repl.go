@@ -3,7 +3,9 @@ package repl
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,67 +14,202 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/csv"
 	"github.com/influxdata/flux/dependency"
 	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
 	"github.com/influxdata/flux/internal/spec"
 	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/iocounter"
 	"github.com/influxdata/flux/lang"
 	"github.com/influxdata/flux/libflux/go/libflux"
 	"github.com/influxdata/flux/memory"
 	"github.com/influxdata/flux/runtime"
 	"github.com/influxdata/flux/semantic"
 	"github.com/influxdata/flux/values"
+	"github.com/opentracing/opentracing-go"
 )
 
 type ScopeHolder struct {
 	ctx context.Context
 
-	scope    values.Scope
+	// evalMu serializes use of the analyzer and interpreter,
+	// which may be reached concurrently from RPC methods.
+	evalMu sync.Mutex
+	// lineMu is held for reading while a line is evaluated and its
+	// output delivered, and for writing by Reset. See reset.go.
+	lineMu sync.RWMutex
+	epoch  lineEpoch
+
+	scope values.Scope
+	// overrides is the scope that the session scope is nested within.
+	// It binds the names of the prelude that the options of the
+	// session replace, such as from and now. See overrides.go.
+	overrides values.Scope
+	// prelude is the scope that overrides is nested within.
+	prelude  values.Scope
 	itrp     *interpreter.Interpreter
 	analyzer *libflux.Analyzer
-	importer interpreter.Importer
+	// analyzerBroken is set once the session analyzer has panicked,
+	// so that it is recreated before the next analysis.
+	analyzerBroken bool
+	importer       interpreter.Importer
+
+	// history holds the lines evaluated in the session scope, in order.
+	// See analyzer.go.
+	history []string
 
 	cancelMu   sync.Mutex
 	cancelFunc context.CancelFunc
 
-	resChan chan string
+	queries queryRegistry
+
+	// querySem bounds the number of in-flight queries.
+	// It is nil when there is no limit.
+	querySem     chan struct{}
+	queueQueries bool
+	memoryLimit  int64
+	sessionAlloc *memory.ResourceAllocator
+
+	plans *planCache
+
+	floatPrecision      *int
+	preserveColumnOrder bool
+	maxDisplaySize      int
+	resultWriter        io.Writer
+
+	pages pageStore
+
+	defaultBucket string
+	defaultOrg    string
+
+	disambiguateYields bool
+
+	freezeNow bool
+
+	tracer       opentracing.Tracer
+	phaseTimings bool
+
+	lazyPrelude  bool
+	preludePaths []string
+	origins      preludeOrigins
+
+	rowLimit int
+
+	queryRetries int
+	retryBackoff time.Duration
+
+	maxPlanNodes int
+
+	watchdog *Watchdog
+
+	csvConfig *csv.ResultEncoderConfig
+
+	idleTimeout time.Duration
+
+	schema *Schema
+
+	fluxErrors bool
+
+	columnStats bool
+
+	emptyResults bool
+
+	queryLoader *HTTPQueryLoader
+
+	rules plannerRules
+
+	dedupeResults bool
+
+	previewLimit int64
+
+	diagnostics bool
+
+	randomSeed    *int64
+	sourceTimeout time.Duration
+
+	maxResultBytes int64
+
+	resultHooks []func(flux.Table) (flux.Table, error)
+
+	prepared preparedQueries
+
+	maxCallDepth int
+
+	panicOutput io.Writer
+
+	deps []dependency.Interface
+
+	health healthTracker
+
+	resultSinks []ResultSink
+	sinkMu      sync.Mutex
+	sinkErrors  []error
+
+	initDir    string
+	strictInit bool
+	initErrors []error
+
+	// lines receives the result of each line processed by input.
+	// Serving the session adds the sink of the RPC service to it.
+	lines lineSink
 }
 
 type Option interface {
-	applyOption()
+	applyOption(r *ScopeHolder)
 }
 
+// New creates a session like NewWithError, and panics if the
+// session cannot be initialized.
 func New(ctx context.Context, opts ...Option) *ScopeHolder {
-	scope := values.NewScope()
-	importer := runtime.StdLib()
-	for _, p := range runtime.PreludeList {
-		pkg, err := importer.ImportPackageObject(p)
-		if err != nil {
-			panic(err)
-		}
-		pkg.Range(scope.Set)
+	r, err := NewWithError(ctx, opts...)
+	if err != nil {
+		panic(err)
 	}
+	return r
+}
 
+// NewWithError creates a session, returning an error if the analyzer
+// cannot be created, the prelude cannot be imported, or the init
+// directory fails to load WithStrictInit.
+func NewWithError(ctx context.Context, opts ...Option) (*ScopeHolder, error) {
 	analyzer, err := libflux.NewAnalyzerWithOptions(libflux.NewOptions(ctx))
 	if err != nil {
-		panic(err)
+		return nil, errors.Wrap(err, codes.Inherit, "failed to create the analyzer")
 	}
 
 	repl := &ScopeHolder{
-		ctx:      ctx,
-		scope:    scope,
-		itrp:     interpreter.NewInterpreter(nil, &lang.ExecOptsConfig{}),
-		analyzer: analyzer,
-		importer: importer,
+		ctx:          ctx,
+		itrp:         interpreter.NewInterpreter(nil, &lang.ExecOptsConfig{}),
+		analyzer:     analyzer,
+		importer:     runtime.StdLib(),
+		resultWriter: os.Stdout,
+		sessionAlloc: &memory.ResourceAllocator{},
+		maxCallDepth: DefaultMaxCallDepth,
 	}
 	for _, opt := range opts {
-		opt.applyOption()
+		opt.applyOption(repl)
+	}
+	prelude, err := repl.newPreludeScope(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, codes.Inherit, "failed to import the prelude")
 	}
-	return repl
+	repl.nestSessionScope(prelude)
+	if repl.freezeNow {
+		repl.setNow(time.Now())
+	}
+	if err := repl.loadInitDir(); err != nil {
+		return nil, errors.Wrap(err, codes.Inherit, "failed to load the init directory")
+	}
+	repl.health.setReady()
+	return repl, nil
 }
 
 // type Request struct {
@@ -103,8 +240,43 @@ type Request struct {
 	} `json:"params"`
 }
 
+// Result is the outcome of evaluating Flux with EvalString.
 type Result struct {
-	Message string
+	// Output is the formatted output of each expression statement
+	// in the evaluated source, tables included.
+	Output string
+	// Type is the Flux type of the last expression statement.
+	// It is empty if the source contained no expression statements.
+	Type string
+	// Stats holds the combined statistics of any queries
+	// that were run during the evaluation.
+	Stats flux.Statistics
+	// LiveSources holds the names of the external data sources,
+	// such as from or sql.from, read by the queries that were run.
+	// It is empty when the queries only operated on in-memory data.
+	LiveSources []string
+	// QueryIDs holds the ID of each query that was run, in order.
+	QueryIDs []string
+	// ColumnStats summarizes the columns of each result of the queries
+	// that were run, in order, when the session was created
+	// WithColumnStats.
+	ColumnStats []ResultStats
+	// ResultRows holds the number of rows of each result of the queries
+	// that were run, in order, and Rows their total.
+	ResultRows []ResultRows
+	Rows       int64
+	// EmptyResults names the results of the queries that were run
+	// that produced no rows, in order, when the session was created
+	// WithEmptyResults.
+	EmptyResults []string
+	// Aliases maps the name of each result that was not produced,
+	// because it repeats an earlier result, to the name of that result
+	// when the session was created WithDedupeResults.
+	Aliases map[string]string
+	// SessionMemory reports the memory allocated by every query of the
+	// session once the evaluation finished, whereas the allocations in
+	// Stats only cover the queries of this evaluation.
+	SessionMemory MemoryUsage
 }
 
 type End struct{}
@@ -125,73 +297,216 @@ func (rw rwCloser) Close() error {
 }
 
 type Response struct {
+	// Result is the last output produced by the input.
 	Result string
+	// Results holds the output of each expression statement
+	// in the input, in order.
+	Results []string
+	// QueryIDs holds the ID of each query that was run, in order.
+	QueryIDs []string `json:",omitempty"`
+	// LiveSources holds the names of the external data sources,
+	// such as from or sql.from, read by the queries of the input.
+	LiveSources []string `json:",omitempty"`
+	// Errors holds the location of each compile error in the input.
+	// When it is set, the input was not evaluated.
+	Errors []ErrorSpan `json:",omitempty"`
+	// FluxError holds the analyzer error in full when the session
+	// was created WithFluxErrors.
+	FluxError *FluxErrorDetail `json:",omitempty"`
+	// Timings reports how long each phase of evaluating the input
+	// took when the session was created WithPhaseTimings.
+	Timings *PhaseTimings `json:",omitempty"`
+	// ColumnStats summarizes the columns of each result of the input
+	// when the session was created WithColumnStats.
+	ColumnStats []ResultStats `json:",omitempty"`
+	// ResultRows holds the number of rows of each result of the
+	// input, in order, and Rows their total.
+	ResultRows []ResultRows `json:",omitempty"`
+	Rows       int64        `json:",omitempty"`
+	// EmptyResults names the results of the input that produced
+	// no rows, in order, when the session was created
+	// WithEmptyResults.
+	EmptyResults []string `json:",omitempty"`
+	// Aliases maps the name of each result of the input that was not
+	// returned, because it repeats an earlier result, to the name of
+	// that result when the session was created WithDedupeResults.
+	Aliases map[string]string `json:",omitempty"`
+	// Output holds the output of each expression statement in the
+	// input, in order, with values and tables alike.
+	Output []Output `json:",omitempty"`
 }
 
-type Testing struct {
-	A string `json:"input"`
+// lineResult is the outcome of executing a line of input from the RPC service.
+type lineResult struct {
+	outputs     []string
+	output      []Output
+	queryIDs    []string
+	liveSources []string
+	spans       []ErrorSpan
+	fluxError   *FluxErrorDetail
+	timings     *PhaseTimings
+	columnStats []ResultStats
+	resultRows  []ResultRows
+	empty       []string
+	aliases     map[string]string
+	err         error
 }
 
-type Item struct { //return type
-	Title string `json:"title"`
-	Body  string `json:"body"`
-	Param Params `json:"params"`
-}
+// InputRequest is the params object for the Service methods
+// that take Flux source. Following JSON-RPC 1.0, the object is
+// passed as the only element of the params array:
+//
+//	{"method": "Service.DidOutput", "id": 1, "params": [{"input": "x = 1"}]}
+//
+// The input field is required and no other fields are allowed.
+type InputRequest struct {
+	Input string `json:"input"`
+	// Labels are attached to the queries run by the input
+	// as described by WithQueryLabels.
+	Labels map[string]string `json:"labels,omitempty"`
+	// MaxResultBytes limits the output of each query run by the input
+	// further than the limit of the session, as described by
+	// WithResultByteLimit. It cannot raise the limit of the session.
+	MaxResultBytes int64 `json:"maxResultBytes,omitempty"`
 
-type Thing struct {
-	Name string `json:"name"`
+	// tableSink sends the tables of the input in table frames,
+	// once the connection negotiated EncodingArrow.
+	tableSink *ResultSink
 }
 
-type Params struct {
-	Input Input `json:"params"`
-}
-type Input []struct {
-	Text string `json:"input"`
+// UnmarshalJSON decodes and validates the params object.
+func (req *InputRequest) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Input          *string           `json:"input"`
+		Labels         map[string]string `json:"labels"`
+		MaxResultBytes int64             `json:"maxResultBytes"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&raw); err != nil {
+		return errors.Wrap(err, codes.Invalid, "malformed params")
+	}
+	if raw.Input == nil {
+		return errors.New(codes.Invalid, `malformed params: missing required field "input"`)
+	}
+	req.Input = *raw.Input
+	req.Labels = raw.Labels
+	req.MaxResultBytes = raw.MaxResultBytes
+	return nil
 }
 
 type Service struct {
-	c   chan string
-	res chan string
+	c   chan InputRequest
+	res chan lineResult
+	r   *ScopeHolder
+	// store is the store that the session is served from, if any,
+	// and token the token that resumes the session.
+	store *SessionStore
+	token string
+	// frames is the connection of the session, if it is served on one.
+	frames *frameConn
 }
 
-// {"jsonrpc":"2.0", "method": "Service.DidOutput", "id": "1", "title":"testing","body":"dog", "params":[{"input":"x=1"}]}
-// {"jsonrpc":"2.0", "method": "Service.DidOutput", "id": "1", "title":"testing","body":"dog", "params":[{"input":"x"}]}
-// {"jsonrpc":"2.0", "method": "Service.Hello", "id": "1", "params":[1]}
-// {"jsonrpc":"2.0", "method": "Service.Hello", "id": "1", "params":[], "name":"wez"}
+// DidOutput evaluates the input in the session scope.
+func (s *Service) DidOutput(req InputRequest, resp *Response) error {
+	if s.frames != nil && s.frames.isFramed() {
+		sink := s.frames.tableSink()
+		req.tableSink = &sink
+	}
+	s.c <- req
+	return (<-s.res).response(resp)
+}
 
-func (s *Service) DidOutput(req Testing, resp *Response) error {
-	s.c <- req.A
-	result := <-s.res
-	*resp = Response{result}
+// response fills in resp from the result of a line or returns its
+// error. Compile errors are reported in resp rather than returned.
+func (result lineResult) response(resp *Response) error {
+	if len(result.spans) > 0 || result.fluxError != nil {
+		*resp = Response{Errors: result.spans, FluxError: result.fluxError}
+		return nil
+	}
+	if result.err != nil {
+		return result.err
+	}
+	*resp = Response{Results: result.outputs, QueryIDs: result.queryIDs, LiveSources: result.liveSources, Timings: result.timings, ColumnStats: result.columnStats, ResultRows: result.resultRows, Rows: totalRows(result.resultRows), EmptyResults: result.empty, Aliases: result.aliases, Output: result.output}
+	if n := len(result.outputs); n > 0 {
+		resp.Result = result.outputs[n-1]
+	}
 	return nil
 }
 
 type API int
 
+// Run serves the session over JSON-RPC on stdin and stdout.
+// It returns once the client closes stdin.
+//
+// The tables of each input are returned in its response along with
+// the values, so they are not also written to stdout, which carries
+// the responses, unless the session has another result writer.
 func (r *ScopeHolder) Run() {
-	// var api = new(API)
-	s := rpc.NewServer()
-	c := make(chan string)
-	//for the input result
-	calc_chan := make(chan string)
-	r.resChan = calc_chan
-
-	serv := Service{c, calc_chan}
-	s.Register(&serv)
+	if r.resultWriter == os.Stdout {
+		r.resultWriter = io.Discard
+	}
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT)
+	defer func() {
+		signal.Stop(sigs)
+		close(sigs)
+	}()
 	go func() {
 		for range sigs {
 			r.cancel()
 		}
 	}()
 
-	go s.ServeCodec(jsonrpc.NewServerCodec(rwCloser{os.Stdin, os.Stdout})) //somehow need to get the input that is being
-	for {
-		res := <-c
-		r.input(res) //check if something is outputted and send back through the channel
+	r.serve(rwCloser{os.Stdin, os.Stdout})
+}
+
+// serve handles JSON-RPC requests read from conn, evaluating each input
+// in turn. It returns once the client closes its end of conn, or the
+// idle timeout expires, and every request that was already read has
+// been answered.
+func (r *ScopeHolder) serve(conn io.ReadWriteCloser) {
+	r.serveSession(conn, nil, "")
+}
+
+// serveSession serves the session from st on conn, reporting token
+// to the client as the token that resumes it.
+func (r *ScopeHolder) serveSession(conn io.ReadWriteCloser, st *SessionStore, token string) {
+	s := rpc.NewServer()
+	c := make(chan InputRequest)
+	//for the input result
+	calc_chan := make(chan lineResult)
+	// Any sink the session already has sees each result
+	// before it is sent to the RPC service. The sink is removed once
+	// the connection closes, so that the session can be served again.
+	defer func(lines lineSink) { r.lines = lines }(r.lines)
+	if r.lines != nil {
+		r.lines = multiSink{r.lines, chanSink(calc_chan)}
+	} else {
+		r.lines = chanSink(calc_chan)
 	}
 
+	fc := &frameConn{ReadWriteCloser: conn}
+	serv := Service{c: c, res: calc_chan, r: r, store: st, token: token, frames: fc}
+	s.Register(&serv)
+
+	var codec rpc.ServerCodec = &frameCodec{ServerCodec: jsonrpc.NewServerCodec(fc), conn: fc}
+	if r.idleTimeout > 0 {
+		codec = newIdleCodec(codec, r.idleTimeout)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.ServeCodec(codec)
+	}()
+	for {
+		select {
+		case res := <-c:
+			r.input(res) //check if something is outputted and send back through the channel
+		case <-done:
+			return
+		}
+	}
 }
 
 func newServer() {
@@ -217,144 +532,470 @@ func (r *ScopeHolder) clearCancel() {
 }
 
 func (r *ScopeHolder) Input(t string) (*libflux.FluxError, error) {
-	a, err := r.executeLine(t)
+	_, a, err := r.executeLine(t)
 	return a, err
 }
 
-// input processes a line of input and prints the result.
-func (r *ScopeHolder) input(t string) {
-	if fluxError, err := r.executeLine(t); err != nil {
-		if fluxError != nil {
+// Output processes a line of input like Input and returns the
+// displayed value of each scalar expression statement in order.
+// Unlike the RPC service it does not need Run to be consuming results.
+func (r *ScopeHolder) Output(t string) ([]string, error) {
+	res, _, err := r.executeLine(t)
+	if err != nil {
+		return nil, err
+	}
+	return res.outputs, nil
+}
 
-			fluxError.Print()
-		} else {
-			fmt.Println("Error:", err)
-		}
+// input processes a line of input and delivers its result to the line
+// sink of the session. Results are dropped when the session has no
+// sink, since it is not being served.
+func (r *ScopeHolder) input(req InputRequest) {
+	ctx, end := r.beginLine(WithResultByteLimit(WithQueryLabels(r.ctx, req.Labels), req.MaxResultBytes))
+	defer end()
+	if req.tableSink != nil {
+		ctx = withTableSink(ctx, *req.tableSink)
+	}
+	res, fluxError, err := r.executeLineIn(ctx, req.Input, r.scope)
+	r.setLineError(&res, fluxError, err)
+	if r.lines != nil {
+		r.lines.deliver(res)
 	}
 }
 
 func (r *ScopeHolder) Eval(t string) ([]interpreter.SideEffect, error) {
-	s, _, err := r.evalWithFluxError(t)
+	s, _, err := r.evalWithFluxError(r.ctx, t)
 	return s, err
 }
 
-func (r *ScopeHolder) evalWithFluxError(t string) ([]interpreter.SideEffect, *libflux.FluxError, error) {
+func (r *ScopeHolder) evalWithFluxError(ctx context.Context, t string) ([]interpreter.SideEffect, *libflux.FluxError, error) {
+	return r.evalInScope(ctx, t, r.scope)
+}
+
+// evalNested evaluates t in a scope nested within the session scope
+// so that any bindings it creates are discarded afterwards.
+func (r *ScopeHolder) evalNested(ctx context.Context, t string) ([]interpreter.SideEffect, error) {
+	ses, _, err := r.evalInScope(ctx, t, r.nestScope())
+	return ses, err
+}
+
+func (r *ScopeHolder) evalInScope(ctx context.Context, t string, scope values.Scope) ([]interpreter.SideEffect, *libflux.FluxError, error) {
 	if t == "" {
 		return nil, nil, nil
 	}
 
 	if t[0] == '@' {
-		q, err := LoadQuery(t)
+		q, err := loadQuery(t, r.queryLoader)
 		if err != nil {
 			return nil, nil, err
 		}
 		t = q
 	}
 
-	pkg, fluxError, err := r.analyzeLine(t)
+	r.evalMu.Lock()
+	defer r.evalMu.Unlock()
+
+	// Allow the evaluation itself to be interrupted
+	// without discarding the session scope.
+	ctx, cancelFunc := context.WithCancel(ctx)
+	r.setCancel(cancelFunc)
+	defer cancelFunc()
+	defer r.clearCancel()
+
+	pkg, fluxError, err := r.analyzeIn(ctx, t, scope)
+	if err != nil {
+		return nil, fluxError, err
+	}
+	var x []interpreter.SideEffect
+	if scope == r.scope {
+		x, err = r.evalLine(ctx, t, pkg)
+	} else {
+		x, err = r.evalPackage(ctx, pkg, scope)
+	}
+	return x, nil, err
+}
+
+// analyzeIn analyzes t with the analyzer of scope.
+// The caller must hold evalMu.
+func (r *ScopeHolder) analyzeIn(ctx context.Context, t string, scope values.Scope) (*semantic.Package, *libflux.FluxError, error) {
+	analyzer, err := r.analyzerFor(scope, t)
 	if err != nil {
+		return nil, nil, err
+	}
+	analyzeSpan, _ := r.startSpan(ctx, "repl.analyze")
+	pkg, fluxError, err := r.analyzeLine(ctx, analyzer, t)
+	finishSpan(analyzeSpan, err)
+	if err != nil {
+		r.discardAnalyzer(scope, analyzer, err)
 		return nil, fluxError, err
 	}
+	return pkg, nil, nil
+}
 
-	ctx, span := dependency.Inject(r.ctx, execute.DefaultExecutionDependencies())
+// evalPackage evaluates the analyzed package pkg in scope.
+// The caller must hold evalMu.
+func (r *ScopeHolder) evalPackage(ctx context.Context, pkg *semantic.Package, scope values.Scope) ([]interpreter.SideEffect, error) {
+	deps := append([]dependency.Interface{r.executionDependencies()}, r.deps...)
+	ctx, span := dependency.Inject(ctx, deps...)
 	defer span.Finish()
+	ctx, finishTimeout := r.injectSourceTimeout(ctx)
+	defer finishTimeout()
 
-	x, err := r.itrp.Eval(ctx, pkg, r.scope, r.importer)
-	return x, nil, err
+	evalSpan, ctx := r.startSpan(ctx, "repl.eval")
+	start := time.Now()
+	x, err := r.recoverEval(func() ([]interpreter.SideEffect, error) {
+		return r.itrp.Eval(r.withCallDepthLimit(ctx), pkg, scope, r.importer)
+	})
+	recordPhase(ctx, phaseEval, start)
+	finishSpan(evalSpan, err)
+	return x, err
 }
 
 // executeLine processes a line of input.
-// If the input evaluates to a valid value, that value is returned.
-func (r *ScopeHolder) executeLine(t string) (*libflux.FluxError, error) {
-	ses, fluxError, err := r.evalWithFluxError(t)
+// The displayed value of each expression statement that does not
+// produce a table is returned, in order, along with the ID of each
+// query that was run.
+func (r *ScopeHolder) executeLine(t string) (lineResult, *libflux.FluxError, error) {
+	ctx, end := r.beginLine(r.ctx)
+	defer end()
+	return r.executeLineIn(ctx, t, r.scope)
+}
+
+// executeLineIn processes a line of input like executeLine in ctx,
+// which derives from the session context, binding any names it
+// defines in scope.
+func (r *ScopeHolder) executeLineIn(ctx context.Context, t string, scope values.Scope) (lineResult, *libflux.FluxError, error) {
+	ctx, end := r.beginLine(ctx)
+	defer end()
+	ctx, stop := r.watch(ctx)
+	defer stop()
+	span, ctx := r.startSpan(ctx, "repl.line")
+	span.SetTag("input_length", len(t))
+	ctx, timings := r.withPhaseTimings(ctx)
+	w := &iocounter.Writer{Writer: r.resultWriter}
+	res, fluxError, err := r.runLine(ctx, t, scope, w)
+	res.timings = timings
+	size := w.Count()
+	for _, o := range res.outputs {
+		size += int64(len(o))
+	}
+	span.SetTag("result_size", size)
+	finishSpan(span, err)
+	return res, fluxError, err
+}
+
+// runLine evaluates t in scope and runs any queries it produces,
+// writing their tables to w. The output of each expression statement
+// is also returned in order, tables included.
+func (r *ScopeHolder) runLine(ctx context.Context, t string, scope values.Scope, w io.Writer) (lineResult, *libflux.FluxError, error) {
+	ses, fluxError, err := r.evalInScope(ctx, t, scope)
+	if err != nil {
+		return lineResult{}, fluxError, err
+	}
+	res, err := r.runSideEffects(ctx, ses, w)
+	return res, nil, err
+}
+
+// runSideEffects runs the queries of the expression statements of an
+// evaluated line, writing their tables to w, and returns the output of
+// each expression statement in order.
+func (r *ScopeHolder) runSideEffects(ctx context.Context, ses []interpreter.SideEffect, w io.Writer) (lineResult, error) {
+	var res lineResult
+	if err := r.runExpressions(ctx, ses, w, &res); err != nil {
+		return lineResult{}, err
+	}
+	return res, nil
+}
+
+// expressionSink receives the output of each expression statement
+// of an evaluated source from runExpressions, in order.
+type expressionSink interface {
+	// value receives the displayed value of a statement
+	// that is not a stream.
+	value(typ, text string)
+	// tables receives the formatted tables of the query
+	// of a stream along with its statistics.
+	tables(typ, text string, stats flux.Statistics)
+	// alias receives a stream whose query was not run
+	// since its result repeats an earlier one.
+	alias(typ string, alias *resultAlias)
+}
+
+// runExpressions runs the queries of the expression statements of an
+// evaluated source, also writing their tables to w if it is not nil,
+// and hands the output of each expression statement to sink in order.
+// It is shared by the lines of a session and by EvalString, so that
+// both run their queries alike.
+func (r *ScopeHolder) runExpressions(ctx context.Context, ses []interpreter.SideEffect, w io.Writer, sink expressionSink) error {
+	specs, err := r.tableSpecs(ctx, ses)
 	if err != nil {
-		return fluxError, err
+		return err
+	}
+	if err := checkYields(specs, r.disambiguateYields); err != nil {
+		return err
 	}
+	aliases := r.resultAliases(ses, specs)
 
 	for _, se := range ses {
-		if _, ok := se.Node.(*semantic.ExpressionStatement); ok {
-			if t, ok := se.Value.(*flux.TableObject); ok {
-				now, ok := r.scope.Lookup("now")
-				if !ok {
-					return nil, fmt.Errorf("now option not set")
-				}
-				nowTime, err := now.Function().Call(r.ctx, nil)
-				if err != nil {
-					return nil, err
-				}
-				s, err := spec.FromTableObject(r.ctx, t, nowTime.Time().Time())
-				if err != nil {
-					return nil, err
-				}
-				if err := r.doQuery(r.ctx, s); err != nil {
-					return nil, err
-				}
-			} else {
-				//SEND THE THING HERE
-
-				// s := ""
-				var a []byte
-				buf := bytes.NewBuffer(a)
-				values.Display(buf, se.Value)
-				//send flux result
-
-				r.resChan <- buf.String()
-				// fmt.Println(buf.String(), "testing")
+		if _, ok := se.Node.(*semantic.ExpressionStatement); !ok {
+			continue
+		}
+		typ := se.Value.Type().String()
+		var buf bytes.Buffer
+		if _, ok := se.Value.(*flux.TableObject); ok {
+			s, alias := specs[0], aliases[0]
+			specs, aliases = specs[1:], aliases[1:]
+			if alias != nil {
+				sink.alias(typ, alias)
+				continue
+			}
+			var out io.Writer = &buf
+			if w != nil {
+				out = io.MultiWriter(w, &buf)
 			}
+			stats, err := r.doQuery(ctx, s, r.sinks(ctx, out))
+			if err != nil {
+				return err
+			}
+			sink.tables(typ, buf.String(), stats)
+		} else {
+			if err := r.display(&buf, se.Value); err != nil {
+				return err
+			}
+			sink.value(typ, buf.String())
 		}
 	}
-	return nil, nil
+	return nil
 }
 
-func (r *ScopeHolder) analyzeLine(t string) (*semantic.Package, *libflux.FluxError, error) {
-	pkg, fluxError := r.analyzer.AnalyzeString(t)
-	if fluxError != nil {
-		return nil, fluxError, fluxError.GoError()
+func (result *lineResult) value(typ, text string) {
+	result.outputs = append(result.outputs, text)
+	result.output = append(result.output, Output{Kind: OutputValue, Type: typ, Text: text})
+}
+
+func (result *lineResult) tables(typ, text string, stats flux.Statistics) {
+	result.queryIDs = append(result.queryIDs, statsQueryIDs(stats)...)
+	result.liveSources = mergeLiveSources(result.liveSources, statsLiveSources(stats))
+	result.columnStats = append(result.columnStats, statsColumnStats(stats)...)
+	result.resultRows = append(result.resultRows, statsResultRows(stats)...)
+	result.empty = append(result.empty, statsEmptyResults(stats)...)
+	result.output = append(result.output, Output{Kind: OutputTables, Type: typ, Text: text})
+}
+
+func (result *lineResult) alias(typ string, alias *resultAlias) {
+	result.aliases = aliasNames(result.aliases, alias)
+	result.output = append(result.output, Output{Kind: OutputTables, Type: typ})
+}
+
+// tableObjectSpec converts a table object into a query spec
+// using the current value of the now option.
+func (r *ScopeHolder) tableObjectSpec(ctx context.Context, t *flux.TableObject) (*flux.Spec, error) {
+	now, err := r.currentNow(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return spec.FromTableObject(ctx, t, now)
+}
 
-	bs, err := pkg.MarshalFB()
+// currentNow returns the time of the now option of the session scope.
+func (r *ScopeHolder) currentNow(ctx context.Context) (time.Time, error) {
+	now, ok := r.scope.Lookup("now")
+	if !ok {
+		return time.Time{}, fmt.Errorf("now option not set")
+	}
+	nowTime, err := now.Function().Call(ctx, nil)
 	if err != nil {
-		return nil, nil, err
+		return time.Time{}, err
+	}
+	return nowTime.Time().Time(), nil
+}
+
+// tableSpecs returns a query spec for each table object
+// produced by an expression statement.
+func (r *ScopeHolder) tableSpecs(ctx context.Context, ses []interpreter.SideEffect) ([]*flux.Spec, error) {
+	var specs []*flux.Spec
+	for _, se := range ses {
+		if _, ok := se.Node.(*semantic.ExpressionStatement); !ok {
+			continue
+		}
+		t, ok := se.Value.(*flux.TableObject)
+		if !ok {
+			continue
+		}
+		s, err := r.tableObjectSpec(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, s)
 	}
+	return specs, nil
+}
+
+func (r *ScopeHolder) analyzeLine(ctx context.Context, analyzer *libflux.Analyzer, t string) (*semantic.Package, *libflux.FluxError, error) {
+	bs, fluxError, err := r.analyzeFB(ctx, analyzer, t)
+	if err != nil {
+		return nil, fluxError, err
+	}
+
+	start := time.Now()
 	x, err := semantic.DeserializeFromFlatBuffer(bs)
+	recordPhase(ctx, phaseDeserialize, start)
 	return x, nil, err
 }
 
-func (r *ScopeHolder) doQuery(ctx context.Context, spec *flux.Spec) error {
+// analyzeFB analyzes t with analyzer and returns its semantic graph
+// serialized as a FlatBuffer.
+func (r *ScopeHolder) analyzeFB(ctx context.Context, analyzer *libflux.Analyzer, t string) ([]byte, *libflux.FluxError, error) {
+	start := time.Now()
+	defer recordPhase(ctx, phaseAnalyze, start)
+	pkg, fluxError, err := analyzeString(analyzer, t)
+	if err != nil {
+		return nil, fluxError, err
+	}
+	bs, err := pkg.MarshalFB()
+	return bs, nil, err
+}
+
+// doQuery executes the query spec and encodes its results to each of
+// the sinks, the first of which is the session output. Only the error
+// of the first sink is returned; see finishSinks.
+func (r *ScopeHolder) doQuery(ctx context.Context, spec *flux.Spec, sinks []ResultSink) (flux.Statistics, error) {
+	limit := r.resultByteLimit(ctx)
+	if r.queryRetries <= 0 {
+		capped, cw := capOutput(sinks, limit)
+		states := newSinkStates(capped)
+		prof := r.newColumnProfiler()
+		rows := &rowCounter{}
+		stats, err := r.runQuery(ctx, spec, func(result flux.Result) error {
+			return cw.check(encodeResult(states, rows.wrap(prof.wrap(r.hookResult(result)))))
+		})
+		if serr := r.finishSinks(states); err == nil {
+			err = serr
+		}
+		return r.addEmptyResults(rows.addTo(prof.addTo(stats)), spec, rows), err
+	}
+
+	// Output is only written to the sinks once the query has
+	// succeeded or run out of retries.
+	buffered, bufs := bufferSinks(sinks)
+	var (
+		states []*sinkState
+		prof   *columnProfiler
+		rows   *rowCounter
+	)
+	stats, err := r.retryQuery(ctx, func() (flux.Statistics, error) {
+		for _, buf := range bufs {
+			buf.Reset()
+		}
+		capped, cw := capOutput(buffered, limit)
+		states = newSinkStates(capped)
+		prof = r.newColumnProfiler()
+		rows = &rowCounter{}
+		return r.runQuery(ctx, spec, func(result flux.Result) error {
+			return cw.check(encodeResult(states, rows.wrap(prof.wrap(r.hookResult(result)))))
+		})
+	})
+	for i, s := range states {
+		if _, werr := bufs[i].WriteTo(sinks[i].Writer); s.err == nil {
+			s.err = werr
+		}
+	}
+	if serr := r.finishSinks(states); err == nil {
+		err = serr
+	}
+	return r.addEmptyResults(rows.addTo(prof.addTo(stats)), spec, rows), err
+}
+
+// writeResult writes the formatted tables of result to w.
+// Errors are annotated with the result and table that failed.
+func (r *ScopeHolder) writeResult(w io.Writer, result flux.Result) error {
+	if r.csvConfig != nil {
+		return r.writeCSVResult(w, result)
+	}
+	fmt.Fprintln(w, "Result:", result.Name())
+	return result.Tables().Do(func(tbl flux.Table) error {
+		if _, err := execute.NewFormatter(tbl, r.formatOptions()).WriteTo(w); err != nil {
+			return errors.Wrapf(err, codes.Inherit, "failed to write table %s of result %q", tbl.Key(), result.Name())
+		}
+		return nil
+	})
+}
+
+// runQuery executes the query spec and calls fn for each result it produces.
+func (r *ScopeHolder) runQuery(ctx context.Context, spec *flux.Spec, fn func(result flux.Result) error) (stats flux.Statistics, err error) {
+	defer func() { r.health.record(err) }()
+
 	// Setup cancel context
 	ctx, cancelFunc := context.WithCancel(ctx)
-	r.setCancel(cancelFunc)
 	defer cancelFunc()
-	defer r.clearCancel()
+	id := r.queries.add(cancelFunc)
+	defer r.queries.remove(id)
+	if !isDetached(ctx) {
+		r.setCancel(func() { _ = r.queries.cancel(id) })
+		defer r.clearCancel()
+	}
 
-	c := Compiler{
-		Spec: spec,
+	if err := r.acquireQuery(ctx); err != nil {
+		return flux.Statistics{}, err
 	}
+	defer r.releaseQuery()
 
-	program, err := c.Compile(ctx, runtime.Default)
+	planSpan, planCtx := r.startSpan(ctx, "repl.plan")
+	start := time.Now()
+	program, cached, err := r.compileCached(planCtx, spec)
+	recordPhase(ctx, phasePlan, start)
+	finishSpan(planSpan, err)
 	if err != nil {
-		return err
+		return flux.Statistics{}, err
 	}
-	alloc := &memory.ResourceAllocator{}
+	ps := program.(*lang.Program).PlanSpec
+	if err := r.checkPlanSize(ps); err != nil {
+		return flux.Statistics{}, err
+	}
+	alloc := r.newAllocator()
 
+	ctx, finishDeps := r.injectSeed(ctx)
+	defer finishDeps()
+	ctx, finishTimeout := r.injectSourceTimeout(ctx)
+	defer finishTimeout()
+	ctx, finishSessionDeps := r.injectDependencies(ctx)
+	defer finishSessionDeps()
+	execSpan, ctx := r.startSpan(ctx, "repl.execute")
+	start = time.Now()
 	qry, err := program.Start(ctx, alloc)
+	if err == nil {
+		stats, err = drainQuery(qry, fn)
+	}
+	recordPhase(ctx, phaseExecute, start)
 	if err != nil {
-		return err
+		err = r.queryError(ctx, id, err)
 	}
-	defer qry.Done()
+	finishSpan(execSpan, err)
+	if err != nil {
+		return stats, err
+	}
+	stats = addLabels(addQueryID(stats, id), queryLabels(ctx))
+	if r.plans != nil {
+		stats = addPlanCacheLookup(stats, cached)
+	}
+	return addLiveSources(stats, liveSources(ps)), nil
+}
 
+// drainQuery calls fn for each result of qry and then finishes it.
+// Done is called exactly once whether or not fn fails,
+// and always before the statistics and error of qry are read.
+func drainQuery(qry flux.Query, fn func(result flux.Result) error) (flux.Statistics, error) {
+	var err error
 	for result := range qry.Results() {
-		tables := result.Tables()
-		fmt.Println("Result:", result.Name())
-		if err := tables.Do(func(tbl flux.Table) error {
-			_, err := execute.NewFormatter(tbl, nil).WriteTo(os.Stdout)
-			return err
-		}); err != nil {
-			return err
+		if err = fn(result); err != nil {
+			break
 		}
 	}
 	qry.Done()
-	return qry.Err()
+	if err != nil {
+		return flux.Statistics{}, err
+	}
+	return qry.Statistics(), qry.Err()
 }
 
 func getFluxFiles(path string) ([]string, error) {
@@ -380,7 +1021,15 @@ func getDirs(path string) ([]string, error) {
 // if q is exactly "-", the query will be read from stdin;
 // and if the first character of q is "@",
 // the @ prefix is removed and the contents of the file specified by the rest of q are returned.
+// A file that ends in ".gz" or starts with the gzip magic bytes is decompressed first.
+// A file that is an http:// or https:// URL is not fetched; see HTTPQueryLoader.
 func LoadQuery(q string) (string, error) {
+	return loadQuery(q, nil)
+}
+
+// loadQuery returns the Flux query q as LoadQuery does,
+// with the queries at URLs fetched by loader.
+func loadQuery(q string, loader *HTTPQueryLoader) (string, error) {
 	if q == "-" {
 		data, err := ioutil.ReadAll(os.Stdin)
 		if err != nil {
@@ -390,17 +1039,45 @@ func LoadQuery(q string) (string, error) {
 	}
 
 	if len(q) > 0 && q[0] == '@' {
-		data, err := ioutil.ReadFile(q[1:])
+		path := q[1:]
+		if isQueryURL(path) {
+			return loader.fetch(path)
+		}
+		data, err := ioutil.ReadFile(path)
 		if err != nil {
 			return "", err
 		}
 
+		if strings.HasSuffix(path, ".gz") || bytes.HasPrefix(data, gzipMagic) {
+			data, err = gunzip(data, 0)
+			if err != nil {
+				return "", errors.Wrapf(err, codes.Invalid, "failed to decompress query file %s", path)
+			}
+		}
 		return string(data), nil
 	}
 
 	return q, nil
 }
 
+// gzipMagic is the header that starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// gunzip decompresses data. If max is positive, it stops reading once
+// the decompressed data is larger than max bytes, so that the caller
+// can refuse it without holding all of it.
+func gunzip(data []byte, max int64) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	if max <= 0 {
+		return ioutil.ReadAll(zr)
+	}
+	return ioutil.ReadAll(io.LimitReader(zr, max+1))
+}
+
 type option func(r *ScopeHolder)
 
 func (o option) applyOption(r *ScopeHolder) {
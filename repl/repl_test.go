@@ -0,0 +1,1916 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/csv"
+	_ "github.com/influxdata/flux/fluxinit/static"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/interpreter"
+	"github.com/influxdata/flux/lang"
+	"github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/mock"
+	"github.com/influxdata/flux/runtime"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/stdlib/influxdata/influxdb"
+	"github.com/influxdata/flux/stdlib/universe"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestScopeHolder_Yields(t *testing.T) {
+	r := New(context.Background())
+	got, err := r.Yields(context.Background(), `
+import "array"
+
+data = array.from(rows: [{_value: 1}])
+data |> yield(name: "a")
+data |> map(fn: (r) => ({r with _value: r._value + 1}))
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "_result"}; !cmp.Equal(want, got) {
+		t.Fatalf("unexpected yield names -want/+got:\n%s", cmp.Diff(want, got))
+	}
+
+	// The binding for data should not leak into the session scope.
+	if _, ok := r.scope.Lookup("data"); ok {
+		t.Fatal("expected data to not be defined in the session scope")
+	}
+}
+
+func TestScopeHolder_Yields_Duplicate(t *testing.T) {
+	r := New(context.Background())
+	_, err := r.Yields(context.Background(), `
+import "array"
+
+array.from(rows: [{_value: 1}]) |> yield(name: "x")
+array.from(rows: [{_value: 2}]) |> yield(name: "x")
+`)
+	if err == nil {
+		t.Fatal("expected error for duplicate yield names")
+	}
+	if got, want := errors.Code(err), codes.Invalid; got != want {
+		t.Fatalf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+}
+
+func TestScopeHolder_FloatPrecision(t *testing.T) {
+	r := New(context.Background(), WithFloatPrecision(2))
+	res, err := r.EvalString(context.Background(), `
+import "array"
+
+array.from(rows: [{_value: 2.0 / 3.0}])
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Output, "0.67") || strings.Contains(res.Output, "0.666") {
+		t.Fatalf("expected float formatted with two digits, got:\n%s", res.Output)
+	}
+}
+
+func TestScopeHolder_CancelEval(t *testing.T) {
+	r := New(context.Background())
+	if _, err := r.Eval("x = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each element of the outer map performs a full inner map,
+	// so this takes far longer to evaluate than the test allows.
+	arr := "[" + strings.Repeat("1, ", 2000) + "1]"
+	src := `
+import "array"
+
+big = ` + arr + `
+array.map(arr: big, fn: (x) => array.map(arr: big, fn: (y) => x * y))
+`
+	// Keep canceling until the evaluation returns in case the
+	// first cancellation arrives before evaluation has started.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.cancel()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	_, err := r.Eval(src)
+	if err == nil {
+		t.Fatal("expected evaluation to be canceled")
+	}
+	if got, want := errors.Code(err), codes.Canceled; got != want {
+		t.Fatalf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+
+	if _, ok := r.scope.Lookup("x"); !ok {
+		t.Fatal("expected x to remain in scope after cancellation")
+	}
+}
+
+func TestScopeHolder_FetchPage(t *testing.T) {
+	r := New(context.Background())
+	ctx := context.Background()
+
+	page, err := r.FetchPage(ctx, PageRequest{
+		Query: `
+import "array"
+
+array.from(rows: [{_value: 0}, {_value: 1}, {_value: 2}])
+`,
+		Size: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(page.Rows), 2; got != want {
+		t.Fatalf("unexpected number of rows -want/+got:\n\t- %d\n\t+ %d", want, got)
+	}
+
+	page, err = r.FetchPage(ctx, PageRequest{Cursor: page.Cursor, Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]interface{}{{int64(2)}}; !cmp.Equal(want, page.Rows) {
+		t.Fatalf("unexpected rows -want/+got:\n%s", cmp.Diff(want, page.Rows))
+	}
+	if page.Cursor != "" {
+		t.Fatal("expected no cursor after the last page")
+	}
+}
+
+func TestScopeHolder_FetchPage_RowLimit(t *testing.T) {
+	r := New(context.Background(), WithRowLimit(2))
+	ctx := context.Background()
+	query := `
+import "array"
+
+array.from(rows: [{_value: 0}, {_value: 1}, {_value: 2}])
+`
+	if _, err := r.FetchPage(ctx, PageRequest{Query: query}); errors.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected a resource exhausted error, got %v", err)
+	}
+
+	// A reset drops the results that are open.
+	r = New(context.Background())
+	page, err := r.FetchPage(ctx, PageRequest{Query: query, Size: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.FetchPage(ctx, PageRequest{Cursor: page.Cursor}); errors.Code(err) != codes.NotFound {
+		t.Fatalf("expected the cursor to be dropped by the reset, got %v", err)
+	}
+}
+
+func TestScopeHolder_ExecuteLine_MultipleStatements(t *testing.T) {
+	r := New(context.Background())
+	res, _, err := r.executeLine("x = 1\nx\nx + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := res.outputs
+	if want := []string{"1", "2"}; !cmp.Equal(want, outputs) {
+		t.Fatalf("unexpected outputs -want/+got:\n%s", cmp.Diff(want, outputs))
+	}
+}
+
+func TestScopeHolder_ErrorSpans(t *testing.T) {
+	r := New(context.Background())
+	_, fluxError, err := r.executeLine(`1 + "1"`)
+	if err == nil {
+		t.Fatal("expected a type error")
+	}
+	want := []ErrorSpan{{
+		Start:   Position{Line: 1, Column: 5},
+		End:     Position{Line: 1, Column: 8},
+		Message: "expected int but found string",
+	}}
+	if got := fluxErrorSpans(fluxError); !cmp.Equal(want, got) {
+		t.Fatalf("unexpected error spans -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestScopeHolder_Plan(t *testing.T) {
+	r := New(context.Background())
+	plans, err := r.Plan(context.Background(), `
+import "array"
+
+array.from(rows: [{_value: 1}])
+    |> filter(fn: (r) => r._value > 0)
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plans) != 1 {
+		t.Fatalf("expected one plan, got %d", len(plans))
+	}
+
+	g := plans[0]
+	var kinds []string
+	for _, n := range g.Nodes {
+		if !n.Physical {
+			t.Errorf("expected node %s to be physical", n.ID)
+		}
+		kinds = append(kinds, string(n.Kind))
+	}
+	if want := []string{"array.from", "filter"}; !cmp.Equal(want, kinds) {
+		t.Fatalf("unexpected node kinds -want/+got:\n%s", cmp.Diff(want, kinds))
+	}
+	if got, want := len(g.Edges), 1; got != want {
+		t.Fatalf("unexpected number of edges: got %d, want %d", got, want)
+	}
+}
+
+func TestScopeHolder_WithPreviewLimit(t *testing.T) {
+	const query = `
+import "array"
+
+array.from(rows: [{_value: 1}, {_value: 2}, {_value: 3}, {_value: 4}, {_value: 5}])
+    |> filter(fn: (r) => r._value > 0)
+`
+	r := New(context.Background(), WithPreviewLimit(2))
+	plans, err := r.Plan(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, n := range plans[0].Nodes {
+		kinds = append(kinds, string(n.Kind))
+	}
+	if want := []string{"array.from", "filter", "limit"}; !cmp.Equal(want, kinds) {
+		t.Fatalf("unexpected node kinds -want/+got:\n%s", cmp.Diff(want, kinds))
+	}
+
+	results, err := r.EvalTables(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(results[0].Tables[0].Rows); got != 2 {
+		t.Fatalf("expected the result to be limited to 2 rows, got %d", got)
+	}
+}
+
+func TestScopeHolder_WithDiagnostics(t *testing.T) {
+	const query = `
+import "array"
+
+array.from(rows: [{_value: 1}])
+array.from(rows: [{_value: 2}]) |> map(fn: (r) => ({r with _value: die(msg: "boom")}))
+`
+	if _, err := New(context.Background()).EvalTables(context.Background(), query); err == nil {
+		t.Fatal("expected the second query to fail")
+	}
+
+	results, err := New(context.Background(), WithDiagnostics(true)).EvalTables(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected the data and the diagnostics, got %d results", len(results))
+	}
+	if got := results[0].Tables[0].Rows[0]["_value"].Int(); got != 1 {
+		t.Fatalf("unexpected data: %d", got)
+	}
+	diag := results[1]
+	if diag.Name != DiagnosticsResultName || len(diag.Tables[0].Rows) != 1 {
+		t.Fatalf("unexpected diagnostics: %+v", diag)
+	}
+	if msg := diag.Tables[0].Rows[0]["message"].Str(); !strings.Contains(msg, "boom") {
+		t.Fatalf("expected the diagnostics to describe the error, got %q", msg)
+	}
+}
+
+func TestScopeHolder_DefaultBucket(t *testing.T) {
+	r := New(context.Background(), WithDefaultBucket("my-bucket"), WithDefaultOrg("my-org"))
+	for _, tt := range []struct {
+		query      string
+		wantBucket string
+	}{
+		{query: `from() |> range(start: -1h)`, wantBucket: "my-bucket"},
+		{query: `from(bucket: "other") |> range(start: -1h)`, wantBucket: "other"},
+	} {
+		ses, err := r.evalNested(context.Background(), tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		specs, err := r.tableSpecs(context.Background(), ses)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found bool
+		for _, op := range specs[0].Operations {
+			if spec, ok := op.Spec.(*influxdb.FromOpSpec); ok {
+				found = true
+				if got := spec.Bucket.Name; got != tt.wantBucket {
+					t.Errorf("%s: unexpected bucket: got %q, want %q", tt.query, got, tt.wantBucket)
+				}
+				if spec.Org == nil || spec.Org.Name != "my-org" {
+					t.Errorf("%s: expected the default org, got %v", tt.query, spec.Org)
+				}
+			}
+		}
+		if !found {
+			t.Fatalf("%s: expected a from operation", tt.query)
+		}
+	}
+}
+
+func TestScopeHolder_LiveSources(t *testing.T) {
+	r := New(context.Background())
+
+	ses, err := r.evalNested(context.Background(), `from(bucket: "telegraf") |> range(start: -1h)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	specs, err := r.tableSpecs(context.Background(), ses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := r.compile(context.Background(), specs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := liveSources(program.(*lang.Program).PlanSpec), []string{"from"}; !cmp.Equal(want, got) {
+		t.Fatalf("unexpected sources -want/+got:\n%s", cmp.Diff(want, got))
+	}
+
+	res, err := r.EvalString(context.Background(), `
+import "array"
+
+array.from(rows: [{_value: 1}])
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.LiveSources) != 0 {
+		t.Fatalf("expected no live sources, got %v", res.LiveSources)
+	}
+}
+
+func TestScopeHolder_DuplicateYields(t *testing.T) {
+	const query = `
+import "array"
+
+data = array.from(rows: [{_value: 1}])
+data |> yield(name: "x")
+data |> yield(name: "x")
+`
+	r := New(context.Background())
+	_, err := r.EvalString(context.Background(), query)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if got, want := errors.Code(err), codes.Invalid; got != want {
+		t.Fatalf("unexpected error code: got %v, want %v", got, want)
+	}
+
+	r = New(context.Background(), WithDisambiguateYields(true))
+	res, err := r.EvalString(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Result: x\n", "Result: x_1\n"} {
+		if !strings.Contains(res.Output, name) {
+			t.Errorf("expected output to contain %q, got:\n%s", name, res.Output)
+		}
+	}
+}
+
+func TestScopeHolder_RunLineMode(t *testing.T) {
+	r := New(context.Background())
+	in := strings.NewReader("x = 1\nx + 1\n\ny\nx * 3")
+	var out, errOut strings.Builder
+	if err := r.RunLineMode(in, &out, &errOut); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "2\n3\n"; got != want {
+		t.Errorf("unexpected output: got %q, want %q", got, want)
+	}
+	if got := errOut.String(); !strings.HasPrefix(got, "Error: ") || strings.Count(got, "\n") != 1 {
+		t.Errorf("expected a single error for the undefined identifier, got %q", got)
+	}
+}
+
+func TestScopeHolder_WithResultWriter(t *testing.T) {
+	var buf strings.Builder
+	r := New(context.Background(), WithResultWriter(&buf))
+	if _, err := r.Input(`
+import "array"
+
+array.from(rows: [{_value: 1}])
+`); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.HasPrefix(got, "Result: _result\n") {
+		t.Fatalf("expected the table to be written to the result writer, got:\n%s", got)
+	}
+}
+
+func TestScopeHolder_QueryIDs(t *testing.T) {
+	r := New(context.Background())
+	res, err := r.EvalString(context.Background(), `
+import "array"
+
+array.from(rows: [{_value: 1}])
+array.from(rows: [{_value: 2}])
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.QueryIDs) != 2 || res.QueryIDs[0] == res.QueryIDs[1] {
+		t.Fatalf("expected two unique query ids, got %v", res.QueryIDs)
+	}
+	if ids := r.ActiveQueries(); len(ids) != 0 {
+		t.Fatalf("expected no running queries, got %v", ids)
+	}
+	for _, id := range res.QueryIDs {
+		if err := r.CancelQuery(id); err != nil {
+			t.Fatalf("expected the returned id %s to stay resolvable, got %v", id, err)
+		}
+	}
+}
+
+func TestScopeHolder_FrozenNow(t *testing.T) {
+	r := New(context.Background(), WithFrozenNow())
+	const query = `
+import "array"
+
+array.from(rows: [{_time: 2021-01-01T00:00:00Z, _value: 1}])
+    |> range(start: -1h)
+`
+	var nows []time.Time
+	for i := 0; i < 2; i++ {
+		ses, err := r.evalNested(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		specs, err := r.tableSpecs(context.Background(), ses)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nows = append(nows, specs[0].Now)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !nows[0].Equal(nows[1]) {
+		t.Fatalf("expected both queries to use the same now, got %v and %v", nows[0], nows[1])
+	}
+	snapshot := r.SnapshotNow()
+	ses, err := r.evalNested(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	specs, err := r.tableSpecs(context.Background(), ses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := specs[0].Now; !got.Equal(snapshot) {
+		t.Fatalf("expected the query to use the snapshot: got %v, want %v", got, snapshot)
+	}
+}
+
+func TestScopeHolder_ExportSession(t *testing.T) {
+	r := New(context.Background())
+	if _, err := r.Input(`
+x = 1
+s = "a"
+f = (v) => v
+`); err != nil {
+		t.Fatal(err)
+	}
+	want := `// f was skipped: functions have no literal form
+s = "a"
+x = 1
+`
+	if got := r.Export(); got != want {
+		t.Fatalf("unexpected export:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestScopeHolder_EvalWithoutRun(t *testing.T) {
+	r := New(context.Background())
+	done := make(chan struct{})
+	var (
+		ses    []interpreter.SideEffect
+		output []string
+		err    error
+	)
+	go func() {
+		defer close(done)
+		if ses, err = r.Eval("1 + 1"); err != nil {
+			return
+		}
+		output, err = r.Output(`"a" + "b"`)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for evaluation without a running service")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ses) != 1 || ses[0].Value.Int() != 2 {
+		t.Fatalf("unexpected side effects: %v", ses)
+	}
+	if want := []string{"ab"}; !cmp.Equal(want, output) {
+		t.Fatalf("unexpected output -want/+got:\n%s", cmp.Diff(want, output))
+	}
+}
+
+func TestScopeHolder_WithTracer(t *testing.T) {
+	tracer := mocktracer.New()
+	r := New(context.Background(), WithTracer(tracer), WithResultWriter(ioutil.Discard))
+	input := `
+import "array"
+
+array.from(rows: [{_value: 1}])
+`
+	if _, err := r.Input(input); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	var line *mocktracer.MockSpan
+	for _, s := range tracer.FinishedSpans() {
+		if strings.HasPrefix(s.OperationName, "repl.") {
+			got = append(got, s.OperationName)
+		}
+		if s.OperationName == "repl.line" {
+			line = s
+		}
+	}
+	want := []string{"repl.analyze", "repl.eval", "repl.plan", "repl.execute", "repl.line"}
+	if !cmp.Equal(want, got) {
+		t.Fatalf("unexpected spans -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if got := line.Tag("input_length"); got != len(input) {
+		t.Fatalf("unexpected input length: %v", got)
+	}
+	if got, ok := line.Tag("result_size").(int64); !ok || got == 0 {
+		t.Fatalf("expected a result size to be recorded, got %v", line.Tag("result_size"))
+	}
+}
+
+func TestScopeHolder_DescribeFunctions(t *testing.T) {
+	r := New(context.Background())
+	if _, err := r.Input(`add = (a, b=1) => a + b`); err != nil {
+		t.Fatal(err)
+	}
+
+	filter, err := r.Describe("filter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range filter.Parameters {
+		names = append(names, p.Name)
+		if p.Name == "tables" && !p.Pipe {
+			t.Error("expected tables to be the pipe parameter of filter")
+		}
+	}
+	if want := []string{"fn", "onEmpty", "tables"}; !cmp.Equal(want, names) {
+		t.Fatalf("unexpected filter parameters -want/+got:\n%s", cmp.Diff(want, names))
+	}
+
+	add, err := r.Describe("add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Parameter{
+		{Name: "a", Type: "int"},
+		{Name: "b", Type: "int", Optional: true},
+	}
+	if !cmp.Equal(want, add.Parameters) {
+		t.Fatalf("unexpected add parameters -want/+got:\n%s", cmp.Diff(want, add.Parameters))
+	}
+	if add.Signature != "(a: int, ?b: int) => int" {
+		t.Fatalf("unexpected add signature: %s", add.Signature)
+	}
+}
+
+func TestScopeHolder_PhaseTimings(t *testing.T) {
+	r := New(context.Background(), WithPhaseTimings(true), WithResultWriter(ioutil.Discard))
+	res, _, err := r.executeLine(`
+import "array"
+
+array.from(rows: [{_value: 1}])
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timings := res.timings
+	if timings == nil {
+		t.Fatal("expected phase timings to be reported")
+	}
+	for name, d := range map[string]time.Duration{
+		"analyze":     timings.Analyze,
+		"deserialize": timings.Deserialize,
+		"eval":        timings.Eval,
+		"plan":        timings.Plan,
+		"execute":     timings.Execute,
+	} {
+		if d <= 0 {
+			t.Errorf("expected a %s timing, got %v", name, d)
+		}
+	}
+}
+
+func TestScopeHolder_EvalTables(t *testing.T) {
+	r := New(context.Background())
+	results, err := r.EvalTables(context.Background(), `
+import "array"
+
+array.from(rows: [{t: "a", v: 1}, {t: "b", v: 2}, {t: "a", v: 3}])
+	|> group(columns: ["t"])
+	|> yield(name: "grouped")
+1 + 1
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "grouped" {
+		t.Fatalf("expected a single result named grouped, got %v", results)
+	}
+
+	got := make(map[string][]int64)
+	for _, tbl := range results[0].Tables {
+		key := tbl.Key.ValueString(0)
+		for _, row := range tbl.Rows {
+			got[key] = append(got[key], row["v"].Int())
+		}
+	}
+	want := map[string][]int64{"a": {1, 3}, "b": {2}}
+	if !cmp.Equal(want, got) {
+		t.Fatalf("unexpected rows -want/+got:\n%s", cmp.Diff(want, got))
+	}
+
+	r = New(context.Background(), WithRowLimit(2))
+	if _, err := r.EvalTables(context.Background(), `
+import "array"
+
+array.from(rows: [{v: 1}, {v: 2}, {v: 3}])
+`); errors.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected the row limit to be exceeded, got %v", err)
+	}
+}
+
+func TestScopeHolder_Semantic(t *testing.T) {
+	r := New(context.Background())
+	fb, pkg, err := r.Semantic(context.Background(), `x = 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fb) == 0 {
+		t.Fatal("expected the flatbuffer to be returned")
+	}
+	stmt, ok := pkg.Files[0].Body[0].(*semantic.NativeVariableAssignment)
+	if !ok || stmt.Identifier.Name.Name() != "x" {
+		t.Fatalf("unexpected statement: %v", pkg.Files[0].Body[0])
+	}
+	if _, ok := r.scope.Lookup("x"); ok {
+		t.Fatal("expected the input not to be evaluated")
+	}
+}
+
+func TestScopeHolder_MaxPlanNodes(t *testing.T) {
+	var query strings.Builder
+	query.WriteString("import \"array\"\n\narray.from(rows: [{_value: 1}])\n")
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&query, "\t|> map(fn: (r) => ({r with _value: r._value + %d}))\n", i)
+	}
+
+	r := New(context.Background(), WithMaxPlanNodes(10), WithResultWriter(ioutil.Discard))
+	if _, err := r.Input(query.String()); errors.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected the plan to be rejected, got %v", err)
+	}
+	if _, err := r.Input("import \"array\"\n\narray.from(rows: [{_value: 1}])"); err != nil {
+		t.Fatalf("expected a small plan to run, got %v", err)
+	}
+}
+
+func TestScopeHolder_EvalSummary(t *testing.T) {
+	r := New(context.Background())
+	summary, err := r.EvalSummary(context.Background(), `
+import "array"
+
+array.from(rows: [{_value: 1}, {_value: 2}, {_value: 3}])
+	|> sum()
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Value == nil || summary.Value.Int() != 6 || summary.Type != "int" {
+		t.Fatalf("expected the sum as a single int, got %+v", summary)
+	}
+
+	summary, err = r.EvalSummary(context.Background(), `
+import "array"
+
+array.from(rows: [{_value: 1}, {_value: 2}])
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Value != nil || len(summary.Results) != 1 || len(summary.Results[0].Tables[0].Rows) != 2 {
+		t.Fatalf("expected the table to be returned in full, got %+v", summary)
+	}
+}
+
+func TestScopeHolder_EvalWithParams(t *testing.T) {
+	r := New(context.Background())
+	res, err := r.EvalWithParams(context.Background(), `
+import "strings"
+
+strings.toUpper(v: name) + " " + string(v: n + 1) + " " + string(v: start)
+`, map[string]interface{}{
+		"name":  "a",
+		"n":     1,
+		"start": time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "A 2 2021-01-01T00:00:00.000000000Z\n"; res.Output != want {
+		t.Fatalf("unexpected output: got %q, want %q", res.Output, want)
+	}
+	if _, ok := r.scope.Lookup("name"); ok {
+		t.Fatal("expected the parameters not to be kept in scope")
+	}
+
+	if _, err := r.EvalWithParams(context.Background(), `n + 1`, map[string]interface{}{
+		"n": "1",
+	}); err == nil {
+		t.Fatal("expected a type mismatch to be rejected")
+	}
+}
+
+func TestScopeHolder_Validate(t *testing.T) {
+	r := New(context.Background(), WithSchema(Schema{
+		Buckets: []BucketSchema{{
+			Name: "telegraf",
+			Measurements: []MeasurementSchema{{
+				Name:   "cpu",
+				Tags:   []string{"host"},
+				Fields: []FieldSchema{{Name: "usage_user", Type: "float"}},
+			}},
+		}},
+	}))
+
+	spans, err := r.Validate(context.Background(), `
+from(bucket: "telegraf")
+    |> range(start: -1h)
+    |> filter(fn: (r) => r._measurement == "cpu" and r.host == "a")
+`, r.schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 0 {
+		t.Fatalf("unexpected errors for a valid query: %v", spans)
+	}
+
+	spans, err = r.Validate(context.Background(), `
+from(bucket: "telegraf")
+    |> range(start: -1h)
+    |> filter(fn: (r) => r.region == "eu")
+`, r.schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ErrorSpan{{
+		Start:   Position{Line: 4, Column: 26},
+		End:     Position{Line: 4, Column: 34},
+		Message: `column "region" does not exist`,
+	}}
+	if !cmp.Equal(want, spans) {
+		t.Fatalf("unexpected errors -want/+got:\n%s", cmp.Diff(want, spans))
+	}
+}
+
+func TestScopeHolder_EvalPiped(t *testing.T) {
+	tracer := mocktracer.New()
+	r := New(context.Background(), WithTracer(tracer))
+	res, err := r.EvalPiped(context.Background(), `
+import "array"
+
+array.from(rows: [{host: "a", _value: 1}, {host: "b", _value: 2}, {host: "c", _value: 3}])
+`, `
+producer |> filter(fn: (r) => r._value > 1) |> yield(name: "high")
+producer |> filter(fn: (r) => r.host == "a") |> yield(name: "a")
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"a", "b", "c"} {
+		if !strings.Contains(res.Output, host) {
+			t.Fatalf("expected the output to contain the rows of host %q, got:\n%s", host, res.Output)
+		}
+	}
+
+	// The producer runs once, followed by one run for each query of the consumer.
+	var executed int
+	for _, s := range tracer.FinishedSpans() {
+		if s.OperationName == "repl.execute" {
+			executed++
+		}
+	}
+	if want := 3; executed != want {
+		t.Fatalf("unexpected number of queries run: got %d, want %d", executed, want)
+	}
+}
+
+func TestScopeHolder_EvalPiped_PlanCache(t *testing.T) {
+	// The consumers have the same shape, so a cached plan
+	// would read the tables of the first producer.
+	r := New(context.Background(), WithPlanCache(4))
+	for _, host := range []string{"a", "b"} {
+		res, err := r.EvalPiped(context.Background(), `
+import "array"
+
+array.from(rows: [{host: "`+host+`", _value: 1}])
+`, `producer |> yield(name: "hosts")`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(res.Output, host) {
+			t.Fatalf("expected the output to contain the rows of host %q, got:\n%s", host, res.Output)
+		}
+	}
+}
+
+func TestScopeHolder_AnalyzerState(t *testing.T) {
+	r := New(context.Background())
+	if _, err := r.Input(`x = 1`); err != nil {
+		t.Fatal(err)
+	}
+	output, err := r.Output(`x + 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2"}; !cmp.Equal(want, output) {
+		t.Fatalf("unexpected output -want/+got:\n%s", cmp.Diff(want, output))
+	}
+
+	// Names bound outside of the session scope, or by a line
+	// that failed, must not be visible to later lines.
+	if _, err := r.EvalWithParams(context.Background(), `n`, map[string]interface{}{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.Semantic(context.Background(), `s = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Input(`f = die(msg: "boom")`); err == nil {
+		t.Fatal("expected the line to fail")
+	}
+	for _, name := range []string{"n", "s", "f"} {
+		if _, err := r.Input(name); err == nil {
+			t.Fatalf("expected %s to be undefined", name)
+		}
+	}
+
+	if _, err := r.Input(`y = x + 1`); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := r.Output(`y`); err != nil {
+		t.Fatal(err)
+	} else if want := []string{"2"}; !cmp.Equal(want, output) {
+		t.Fatalf("unexpected output -want/+got:\n%s", cmp.Diff(want, output))
+	}
+}
+
+func TestScopeHolder_FailedLineBindsNothing(t *testing.T) {
+	r := New(context.Background())
+	if _, err := r.Input("x = 1\ny = die(msg: \"boom\")"); err == nil {
+		t.Fatal("expected the line to fail")
+	}
+	if _, ok := r.scope.Lookup("x"); ok {
+		t.Fatal("expected x to be left unbound by the line that failed")
+	}
+	if _, err := r.Input("x"); err == nil {
+		t.Fatal("expected x to be undefined")
+	}
+
+	// The line binds x once it evaluates, with the type
+	// the analyzer gives it.
+	if _, err := r.Input(`x = "a"`); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := r.Output(`x + "b"`); err != nil {
+		t.Fatal(err)
+	} else if want := []string{"ab"}; !cmp.Equal(want, output) {
+		t.Fatalf("unexpected output -want/+got:\n%s", cmp.Diff(want, output))
+	}
+}
+
+func TestScopeHolder_History(t *testing.T) {
+	r := New(context.Background())
+	for _, line := range []string{`1 + 1`, `x = 1`, `x + 1`, `import "strings"`, `die(msg: "boom")`} {
+		_, _ = r.Input(line)
+	}
+	// Only the lines that define something are replayed.
+	if want := []string{`x = 1`, `import "strings"`}; !cmp.Equal(want, r.history) {
+		t.Fatalf("unexpected history -want/+got:\n%s", cmp.Diff(want, r.history))
+	}
+}
+
+func TestDefinesNothing(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want bool
+	}{
+		{src: `1 + 1`, want: true},
+		{src: "1\n\"a\"", want: true},
+		{src: `x = 1`},
+		{src: `option now = () => 2021-01-01T00:00:00Z`},
+		{src: `import "strings"` + "\n1"},
+		{src: `x = (`},
+	} {
+		if got := definesNothing(tt.src); got != tt.want {
+			t.Errorf("definesNothing(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestScopeHolder_WithFluxErrors(t *testing.T) {
+	r := New(context.Background(), WithFluxErrors())
+	res, fluxError, err := r.executeLine("f = (")
+	r.setLineError(&res, fluxError, err)
+
+	var resp Response
+	if err := res.response(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.FluxError == nil || len(resp.FluxError.Errors) == 0 {
+		t.Fatalf("expected the flux error to be reported, got %+v", resp)
+	}
+	if got := resp.FluxError.Errors[0].Start; got.Line != 1 || got.Column == 0 {
+		t.Fatalf("unexpected error location: %+v", got)
+	}
+	if !strings.Contains(resp.FluxError.Message, "@1:") {
+		t.Fatalf("expected the message to be kept in full, got %q", resp.FluxError.Message)
+	}
+}
+
+func TestScopeHolder_WithResultSinks(t *testing.T) {
+	var out, a, b bytes.Buffer
+	r := New(context.Background(), WithResultWriter(&out), WithResultSinks(
+		ResultSink{Writer: &a, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())},
+		ResultSink{Writer: &b, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())},
+	))
+	if _, err := r.Input(`
+import "array"
+
+array.from(rows: [{_value: 1}, {_value: 2}])
+`); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Result: _result") {
+		t.Fatalf("expected the session output to hold the result, got:\n%s", out.String())
+	}
+	if a.Len() == 0 || a.String() != b.String() {
+		t.Fatalf("expected both sinks to hold the same result, got:\n%s\nand:\n%s", a.String(), b.String())
+	}
+	if errs := r.SinkErrors(); len(errs) != 0 {
+		t.Fatalf("unexpected sink errors: %v", errs)
+	}
+}
+
+func TestScopeHolder_SessionMemory(t *testing.T) {
+	session := &memory.ResourceAllocator{}
+	r := NewEmbedded(WithSessionAllocator(session))
+
+	var total int64
+	for i := 0; i < 2; i++ {
+		res, err := r.EvalString(context.Background(), `
+import "array"
+
+array.from(rows: [{_value: 1}, {_value: 2}, {_value: 3}])
+`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Stats.TotalAllocated == 0 {
+			t.Fatalf("expected query %d to report its allocations", i)
+		}
+		total += res.Stats.TotalAllocated
+		if got := res.SessionMemory.TotalAllocated; got != total {
+			t.Fatalf("unexpected session allocation after query %d: got %d, want %d", i, got, total)
+		}
+	}
+	if got := session.TotalAllocated(); got != total {
+		t.Fatalf("unexpected session allocator total: got %d, want %d", got, total)
+	}
+}
+
+func TestScopeHolder_Specs(t *testing.T) {
+	r := New(context.Background())
+	specs, err := r.Specs(context.Background(), `from(bucket: "telegraf") |> range(start: -1h) |> yield(name: "a")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 1 {
+		t.Fatalf("expected one spec, got %d", len(specs))
+	}
+
+	want := []*flux.Operation{
+		{
+			ID:   "from0",
+			Spec: &influxdb.FromOpSpec{Bucket: influxdb.NameOrID{Name: "telegraf"}},
+		},
+		{
+			ID: "range1",
+			Spec: &universe.RangeOpSpec{
+				Start:       flux.Time{Relative: -1 * time.Hour, IsRelative: true},
+				Stop:        flux.Time{IsRelative: true},
+				TimeColumn:  "_time",
+				StartColumn: "_start",
+				StopColumn:  "_stop",
+			},
+		},
+		{
+			ID:   "yield2",
+			Spec: &universe.YieldOpSpec{Name: "a"},
+		},
+	}
+	if got := specs[0].Operations; !cmp.Equal(want, got) {
+		t.Fatalf("unexpected operations -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	wantEdges := []flux.Edge{
+		{Parent: "from0", Child: "range1"},
+		{Parent: "range1", Child: "yield2"},
+	}
+	if got := specs[0].Edges; !cmp.Equal(wantEdges, got) {
+		t.Fatalf("unexpected edges -want/+got:\n%s", cmp.Diff(wantEdges, got))
+	}
+}
+
+func TestNewWithError(t *testing.T) {
+	dir := writeInitFiles(t, map[string]string{
+		"a.flux": "x = ",
+	})
+	for _, tt := range []struct {
+		name string
+		opts []Option
+		code codes.Code
+		msg  string
+	}{
+		{
+			name: "prelude",
+			opts: []Option{WithPrelude("not/a/prelude")},
+			code: codes.Invalid,
+			msg:  "failed to import the prelude",
+		},
+		{
+			name: "strict init",
+			opts: []Option{WithInitDir(dir), WithStrictInit(true)},
+			msg:  "failed to load the init directory",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewWithError(context.Background(), tt.opts...)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if r != nil {
+				t.Fatal("expected no session to be returned")
+			}
+			if !strings.Contains(err.Error(), tt.msg) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.code != codes.Inherit {
+				if got := errors.Code(err); got != tt.code {
+					t.Fatalf("unexpected error code: got %v, want %v", got, tt.code)
+				}
+			}
+		})
+	}
+
+	r, err := NewWithError(context.Background(), WithInitDir(dir))
+	if err != nil {
+		t.Fatalf("expected init errors to be reported without failing, got %v", err)
+	}
+	if len(r.InitErrors()) != 1 {
+		t.Fatalf("expected one init error, got %v", r.InitErrors())
+	}
+}
+
+func TestReplay(t *testing.T) {
+	transcript := []TranscriptStep{
+		{Input: "x = 1"},
+		{Input: "x + 1", Output: "2\n"},
+	}
+	divergences, err := Replay(context.Background(), transcript, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v (divergences: %+v)", err, divergences)
+	}
+
+	transcript[1].Output = "3\n"
+	divergences, err = Replay(context.Background(), transcript, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Divergence{{Step: 1, Input: "x + 1", Want: "3\n", Got: "2\n"}}
+	if !cmp.Equal(want, divergences) {
+		t.Fatalf("unexpected divergences -want/+got:\n%s", cmp.Diff(want, divergences))
+	}
+}
+
+func TestScopeHolder_QueryLabels(t *testing.T) {
+	r := New(context.Background())
+	ctx := WithQueryLabels(context.Background(), map[string]string{"dashboard": "cpu"})
+	res, err := r.EvalString(ctx, `
+import "array"
+
+array.from(rows: [{_value: 1}])
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := res.Stats.Metadata.GetAll("flux/label/dashboard"), []interface{}{"cpu"}; !cmp.Equal(want, got) {
+		t.Fatalf("unexpected labels in the statistics -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestScopeHolder_WithColumnStats(t *testing.T) {
+	r := New(context.Background(), WithColumnStats(true))
+	res, err := r.EvalString(context.Background(), `
+import "array"
+
+array.from(rows: [{_value: 3}, {_value: -1}, {_value: 8}])
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ResultStats{{
+		Name:    "_result",
+		Columns: []ColumnStats{{Label: "_value", Type: "int", Count: 3, Min: int64(-1), Max: int64(8)}},
+	}}
+	if !cmp.Equal(want, res.ColumnStats) {
+		t.Fatalf("unexpected column stats -want/+got:\n%s", cmp.Diff(want, res.ColumnStats))
+	}
+}
+
+func TestScopeHolder_ResultRows(t *testing.T) {
+	r := New(context.Background())
+	res, err := r.EvalString(context.Background(), `
+import "array"
+
+data = array.from(rows: [{_value: 3}, {_value: -1}, {_value: 8}])
+data |> yield(name: "all")
+data |> limit(n: 2) |> yield(name: "some")
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ResultRows{{Name: "all", Rows: 3}, {Name: "some", Rows: 2}}
+	if !cmp.Equal(want, res.ResultRows) {
+		t.Fatalf("unexpected row counts -want/+got:\n%s", cmp.Diff(want, res.ResultRows))
+	}
+	if res.Rows != 5 {
+		t.Fatalf("expected 5 rows in total, got %d", res.Rows)
+	}
+}
+
+func TestScopeHolder_WithEmptyResults(t *testing.T) {
+	r := New(context.Background(), WithEmptyResults(true))
+	res, err := r.EvalString(context.Background(), `
+import "array"
+
+data = array.from(rows: [{_value: 3}, {_value: -1}, {_value: 8}])
+data |> filter(fn: (r) => r._value > 100) |> yield(name: "big")
+data |> filter(fn: (r) => r._value > 0) |> yield(name: "positive")
+data |> filter(fn: (r) => r._value > 10) |> yield(name: "large")
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"big", "large"}; !cmp.Equal(want, res.EmptyResults) {
+		t.Fatalf("unexpected empty results -want/+got:\n%s", cmp.Diff(want, res.EmptyResults))
+	}
+}
+
+func TestScopeHolder_WithDedupeResults(t *testing.T) {
+	r := New(context.Background(), WithDedupeResults(true))
+	res, err := r.EvalString(context.Background(), `
+import "array"
+
+data = array.from(rows: [{_value: 1}, {_value: 2}])
+data |> yield(name: "a")
+data |> yield(name: "b")
+data |> limit(n: 1) |> yield(name: "c")
+data |> limit(n: 1) |> yield(name: "d")
+data |> limit(n: 2) |> yield(name: "e")
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"b": "a", "d": "c"}; !cmp.Equal(want, res.Aliases) {
+		t.Fatalf("unexpected aliases -want/+got:\n%s", cmp.Diff(want, res.Aliases))
+	}
+	if want, got := 3, len(res.QueryIDs); got != want {
+		t.Fatalf("expected %d queries to run, got %d", want, got)
+	}
+}
+
+func TestScopeHolder_ResetAnalyzer(t *testing.T) {
+	ctx := context.Background()
+	r := New(ctx)
+	if _, err := r.EvalString(ctx, "x = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A nil analyzer panics when it is used,
+	// standing in for one that is wedged.
+	r.analyzer = nil
+	if _, err := r.EvalString(ctx, "x + 1"); err == nil || !strings.Contains(err.Error(), "analyzer panicked") {
+		t.Fatalf("expected the analyzer to panic, got %v", err)
+	}
+	res, err := r.EvalString(ctx, "x + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2\n"; res.Output != want {
+		t.Fatalf("unexpected output after the analyzer was recreated: got %q, want %q", res.Output, want)
+	}
+
+	if err := r.ResetAnalyzer(); err != nil {
+		t.Fatal(err)
+	}
+	if res, err = r.EvalString(ctx, "x + 2"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "3\n"; res.Output != want {
+		t.Fatalf("unexpected output after resetting the analyzer: got %q, want %q", res.Output, want)
+	}
+	if got, want := r.Health().AnalyzerResets, 2; got != want {
+		t.Fatalf("unexpected number of analyzer resets: got %d, want %d", got, want)
+	}
+}
+
+func TestScopeHolder_EvalCSV(t *testing.T) {
+	const src = `
+import "array"
+
+array.from(rows: [{k: "a", _value: 1}, {k: "b", _value: 2}]) |> group(columns: ["k"])
+`
+	r := New(context.Background())
+	var (
+		got    bytes.Buffer
+		chunks int
+	)
+	if err := r.EvalCSV(context.Background(), src, func(c CSVChunk) error {
+		chunks++
+		got.Write(c.Data)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if chunks != 2 {
+		t.Fatalf("expected a chunk for each table, got %d", chunks)
+	}
+
+	// The output of a session with CSV results ends each result
+	// with a blank line.
+	res, err := New(context.Background(), WithCSVResults()).EvalString(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.TrimSuffix(res.Output, "\r\n"); got.String() != want {
+		t.Fatalf("unexpected CSV -want/+got:\n%s", cmp.Diff(want, got.String()))
+	}
+}
+
+func TestScopeHolder_Tail(t *testing.T) {
+	ctx := context.Background()
+	r := New(ctx)
+	var tables []TailedTable
+	s, err := r.Tail(ctx, `
+import "array"
+
+array.from(rows: [{k: "a", _value: 1}, {k: "b", _value: 2}]) |> group(columns: ["k"])
+`, func(tbl TailedTable) error {
+		tables = append(tables, tbl)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The source is finite, so the query ends on its own.
+	<-s.Done()
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 2 {
+		t.Fatalf("expected a call for each table, got %d", len(tables))
+	}
+
+	if _, err := r.Tail(ctx, "x = 1", func(TailedTable) error { return nil }); err == nil {
+		t.Fatal("expected an error for a source without a query")
+	}
+}
+
+func TestScopeHolder_CacheStats(t *testing.T) {
+	ctx := context.Background()
+	// The now time is part of the cache key.
+	r := New(ctx, WithPlanCache(4), WithFrozenNow())
+	const query = `
+import "array"
+
+array.from(rows: [{_value: 1}])
+`
+	var lookups []interface{}
+	for i := 0; i < 2; i++ {
+		res, err := r.EvalString(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lookups = append(lookups, res.Stats.Metadata.GetAll(planCacheMetaKey)...)
+	}
+	if want := []interface{}{"miss", "hit"}; !cmp.Equal(want, lookups) {
+		t.Fatalf("unexpected plan cache lookups -want/+got:\n%s", cmp.Diff(want, lookups))
+	}
+	stats := r.CacheStats(false).Plans
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Fatalf("expected the repeated query to hit the cache, got %+v", stats)
+	}
+}
+
+func TestScopeHolder_Input_ReturnSink(t *testing.T) {
+	r := New(context.Background())
+	sink := &returnSink{}
+	r.lines = sink
+
+	r.input(InputRequest{Input: "x = 1"})
+	r.input(InputRequest{Input: "x + 1"})
+	r.input(InputRequest{Input: "y +"})
+
+	results := sink.take()
+	if len(results) != 3 {
+		t.Fatalf("expected a result for each line, got %d", len(results))
+	}
+	if want := []string{"2"}; !cmp.Equal(want, results[1].outputs) {
+		t.Fatalf("unexpected outputs -want/+got:\n%s", cmp.Diff(want, results[1].outputs))
+	}
+	if results[2].err == nil {
+		t.Fatal("expected the last line to fail")
+	}
+}
+
+func TestScopeHolder_Reset(t *testing.T) {
+	ctx := context.Background()
+	r := New(ctx)
+	if _, err := r.EvalString(ctx, "x = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A query issued just before a reset is either fully
+	// delivered or canceled, never cut short without an error.
+	type outcome struct {
+		tables []TableResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		tables, err := r.EvalTables(ctx, `
+import "array"
+
+array.from(rows: [{_value: 1}, {_value: 2}, {_value: 3}])
+`)
+		done <- outcome{tables: tables, err: err}
+	}()
+	if err := r.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case o := <-done:
+		if o.err != nil {
+			if got, want := errors.Code(o.err), codes.Canceled; got != want {
+				t.Fatalf("unexpected error of the query interrupted by the reset: got %v want %v (%v)", got, want, o.err)
+			}
+		} else if len(o.tables) != 1 || len(o.tables[0].Tables) != 1 || len(o.tables[0].Tables[0].Rows) != 3 {
+			t.Fatalf("expected every row of the query, got %+v", o.tables)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the query")
+	}
+
+	// The bindings of the session are gone, but the prelude is not.
+	if _, err := r.EvalString(ctx, "x"); err == nil {
+		t.Fatal("expected x to be undefined after the reset")
+	}
+	res, err := r.EvalString(ctx, "y = 2\ny")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Output != "2\n" {
+		t.Fatalf("unexpected output after the reset: %q", res.Output)
+	}
+}
+
+func TestScopeHolder_WithRandomSeed(t *testing.T) {
+	ctx := context.Background()
+	const query = `
+import "array"
+
+array.from(rows: [{_value: 0}, {_value: 1}, {_value: 2}, {_value: 3}, {_value: 4}, {_value: 5}, {_value: 6}, {_value: 7}])
+    |> sample(n: 4, pos: -1)
+`
+	var outputs []string
+	for i := 0; i < 2; i++ {
+		r := New(ctx, WithRandomSeed(7))
+		res, err := r.EvalString(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, res.Output)
+	}
+	if outputs[0] != outputs[1] {
+		t.Fatalf("expected the same sample from the same seed, got:\n%s\nand:\n%s", outputs[0], outputs[1])
+	}
+}
+
+func TestScopeHolder_WithMaxResultBytes(t *testing.T) {
+	ctx := context.Background()
+	var out bytes.Buffer
+	r := New(ctx, WithMaxResultBytes(64), WithResultWriter(&out))
+	const query = `
+import "array"
+
+array.from(rows: [{_value: 1}, {_value: 2}, {_value: 3}, {_value: 4}, {_value: 5}, {_value: 6}])
+`
+	_, err := r.Input(query)
+	if got, want := errors.Code(err), codes.ResourceExhausted; got != want {
+		t.Fatalf("unexpected error code: got %v want %v (%v)", got, want, err)
+	}
+	if out.Len() != 64 {
+		t.Fatalf("expected the output up to the limit to be written, got %d bytes", out.Len())
+	}
+
+	// A request may raise the limit of the session.
+	res, err := r.EvalString(WithResultByteLimit(ctx, 1<<20), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Output, "6") {
+		t.Fatalf("expected the whole result, got:\n%s", res.Output)
+	}
+}
+
+func TestScopeHolder_Origin_Prelude(t *testing.T) {
+	ctx := context.Background()
+	r := New(ctx)
+	got, err := r.Origin("filter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (OriginResponse{Name: "filter", Package: "universe"}); got != want {
+		t.Fatalf("unexpected origin of filter: got %+v want %+v", got, want)
+	}
+}
+
+func TestScopeHolder_EvalAt(t *testing.T) {
+	ctx := context.Background()
+	r := New(ctx)
+	start := time.Date(2022, 1, 1, 0, 10, 0, 0, time.UTC)
+	nows := []time.Time{start, start.Add(time.Hour), start.Add(2 * time.Hour)}
+	results, err := r.EvalAt(ctx, `
+import "array"
+
+array.from(rows: [
+    {_time: 2022-01-01T00:00:00Z, _value: 1},
+    {_time: 2022-01-01T01:00:00Z, _value: 2},
+    {_time: 2022-01-01T02:00:00Z, _value: 3},
+])
+    |> range(start: -30m)
+`, nows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(nows) {
+		t.Fatalf("expected a result for each now, got %d", len(results))
+	}
+	// The relative range selects a different row at each time.
+	for i, res := range results {
+		if !res.Now.Equal(nows[i]) {
+			t.Fatalf("unexpected now of result %d: %s", i, res.Now)
+		}
+		if len(res.Results) != 1 || len(res.Results[0].Tables) != 1 || len(res.Results[0].Tables[0].Rows) != 1 {
+			t.Fatalf("expected one row at %s, got %+v", res.Now, res.Results)
+		}
+		if got, want := res.Results[0].Tables[0].Rows[0]["_value"].Int(), int64(i+1); got != want {
+			t.Fatalf("unexpected row at %s: got %d want %d", res.Now, got, want)
+		}
+	}
+}
+
+func TestScopeHolder_Input_Output(t *testing.T) {
+	ctx := context.Background()
+	var w bytes.Buffer
+	r := New(ctx, WithResultWriter(&w))
+	sink := &returnSink{}
+	r.lines = sink
+
+	r.input(InputRequest{Input: `
+import "array"
+
+1 + 1
+array.from(rows: [{_value: 1}])
+`})
+	results := sink.take()
+	if len(results) != 1 || results[0].err != nil {
+		t.Fatalf("expected one successful result, got %+v", results)
+	}
+	// The value and the tables are delivered together, in order.
+	output := results[0].output
+	if len(output) != 2 || output[0].Kind != OutputValue || output[1].Kind != OutputTables {
+		t.Fatalf("unexpected output: %+v", output)
+	}
+	if output[0].Text != "2" || output[0].Type != "int" {
+		t.Fatalf("unexpected value output: %+v", output[0])
+	}
+	if output[1].Text == "" || output[1].Text != w.String() {
+		t.Fatalf("expected the tables as written to the result writer, got:\n%s\nand:\n%s", output[1].Text, w.String())
+	}
+}
+
+func TestSessionStore_Resume_Bindings(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	st := NewSessionStore(time.Minute, func() (*ScopeHolder, error) {
+		return New(context.Background(), WithResultWriter(ioutil.Discard)), nil
+	})
+	st.now = func() time.Time { return now }
+
+	output := func(c sessionConn, input string) []string {
+		t.Helper()
+		resp := c.call(`{"method": "Service.DidOutput", "id": 1, "params": [{"input": "` + input + `"}]}`)
+		if resp.Error != nil {
+			t.Fatalf("unexpected error: %v", resp.Error)
+		}
+		var out Response
+		if err := json.Unmarshal(resp.Result, &out); err != nil {
+			t.Fatal(err)
+		}
+		return out.Results
+	}
+
+	first := connectSession(t, st, "")
+	token := sessionToken(t, first)
+	output(first, "x = 42")
+	first.close()
+
+	// The bindings of the session are there once it is resumed.
+	resumed := connectSession(t, st, token)
+	if got := output(resumed, "x"); len(got) != 1 || got[0] != "42" {
+		t.Fatalf("expected x to be bound in the resumed session, got %v", got)
+	}
+	resumed.close()
+
+	// An expired token starts a new session without them.
+	now = now.Add(2 * time.Minute)
+	fresh := connectSession(t, st, token)
+	resp := fresh.call(`{"method": "Service.DidOutput", "id": 1, "params": [{"input": "x"}]}`)
+	if resp.Error == nil {
+		var out Response
+		if err := json.Unmarshal(resp.Result, &out); err != nil {
+			t.Fatal(err)
+		}
+		if len(out.Errors) == 0 {
+			t.Fatalf("expected x to be undefined in a new session, got %v", out.Results)
+		}
+	}
+}
+
+func TestScopeHolder_WithResultHook(t *testing.T) {
+	var w bytes.Buffer
+	r := New(context.Background(), WithResultWriter(&w), WithResultHook(redactColumn("password")))
+	if _, _, err := r.executeLine(`
+import "array"
+
+array.from(rows: [{host: "a", password: "hunter2", _value: 1}])
+`); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.String(); strings.Contains(got, "password") || strings.Contains(got, "hunter2") {
+		t.Fatalf("expected the column to be redacted, got:\n%s", got)
+	}
+}
+
+func TestScopeHolder_Prepare_Execute(t *testing.T) {
+	ctx := context.Background()
+	r := New(ctx)
+	if _, err := r.EvalString(ctx, `scale = 10`); err != nil {
+		t.Fatal(err)
+	}
+	types, err := r.Prepare(ctx, "panel", `n * scale`, map[string]interface{}{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"n": "int"}; !cmp.Equal(want, types) {
+		t.Fatalf("unexpected parameter types -want/+got:\n%s", cmp.Diff(want, types))
+	}
+
+	for n, want := range map[int]string{1: "10\n", 2: "20\n", 7: "70\n"} {
+		res, err := r.Execute(ctx, "panel", map[string]interface{}{"n": n})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Output != want {
+			t.Fatalf("unexpected output for n = %d: want %q, got %q", n, want, res.Output)
+		}
+	}
+
+	// The query uses the current value of a name it uses.
+	if _, err := r.EvalString(ctx, `scale = 100`); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := r.Execute(ctx, "panel", map[string]interface{}{"n": 2}); err != nil {
+		t.Fatal(err)
+	} else if res.Output != "200\n" {
+		t.Fatalf("expected the new value of scale to be used, got %q", res.Output)
+	}
+
+	// Binding it with another type invalidates the query.
+	if _, err := r.EvalString(ctx, `scale = 1.5`); err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Execute(ctx, "panel", map[string]interface{}{"n": 2})
+	if got := errors.Code(err); got != codes.FailedPrecondition {
+		t.Fatalf("expected a failed precondition error, got %v: %v", got, err)
+	}
+	_, err = r.Execute(ctx, "panel", map[string]interface{}{"n": 2})
+	if got := errors.Code(err); got != codes.NotFound {
+		t.Fatalf("expected the query to be dropped, got %v: %v", got, err)
+	}
+}
+
+func TestScopeHolder_Prepare_Lambda(t *testing.T) {
+	ctx := context.Background()
+	r := New(ctx)
+	if _, err := r.EvalString(ctx, `r = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Prepare(ctx, "panel", `
+import "array"
+
+array.from(rows: [{_value: n}]) |> filter(fn: (r) => r._value > 0)
+`, map[string]interface{}{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The r of the lambda is its own, so binding the
+	// session r again leaves the query up to date.
+	if _, err := r.EvalString(ctx, `r = "r"`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Execute(ctx, "panel", map[string]interface{}{"n": 2}); err != nil {
+		t.Fatalf("expected the query to stay prepared, got %v", err)
+	}
+}
+
+func TestScopeHolder_ResultOrder(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		var w bytes.Buffer
+		r := New(context.Background(), WithResultWriter(&w))
+		if _, _, err := r.executeLine(`
+import "array"
+
+array.from(rows: [{_value: 1}])
+	|> yield(name: "c")
+	|> yield(name: "a")
+	|> yield(name: "b")
+`); err != nil {
+			t.Fatal(err)
+		}
+		out := w.String()
+		a, b, c := strings.Index(out, "Result: a"), strings.Index(out, "Result: b"), strings.Index(out, "Result: c")
+		if a < 0 || !(a < b && b < c) {
+			t.Fatalf("expected the results of the query in the order of their names, got:\n%s", out)
+		}
+	}
+}
+
+func TestScopeHolder_MaxCallDepth_Recursion(t *testing.T) {
+	ctx := context.Background()
+	r := New(ctx, WithMaxCallDepth(100))
+	if _, err := r.EvalString(ctx, `f = (n) => n`); err != nil {
+		t.Fatal(err)
+	}
+	// The new f calls the session binding of f, which is itself.
+	if _, err := r.EvalString(ctx, `f = (n) => f(n: n + 1)`); err != nil {
+		t.Fatal(err)
+	}
+	_, err := r.EvalString(ctx, `f(n: 0)`)
+	if got := errors.Code(err); got != codes.ResourceExhausted {
+		t.Fatalf("expected a resource exhausted error, got %v: %v", got, err)
+	}
+	// The session is still usable.
+	if res, err := r.EvalString(ctx, `1 + 1`); err != nil {
+		t.Fatal(err)
+	} else if res.Output != "2\n" {
+		t.Fatalf("unexpected output: %q", res.Output)
+	}
+}
+
+func TestScopeHolder_Diff(t *testing.T) {
+	ctx := context.Background()
+	r := New(ctx)
+	diffs, err := r.Diff(ctx, `
+import "array"
+
+array.from(rows: [{_time: 2021-01-01T00:00:00Z, _value: 1}, {_time: 2021-01-01T00:00:01Z, _value: 2}])
+`, `
+import "array"
+
+array.from(rows: [{_time: 2021-01-01T00:00:00Z, _value: 1}, {_time: 2021-01-01T00:00:01Z, _value: 3}])
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].Changed != 1 || diffs[0].Added != 0 || diffs[0].Removed != 0 {
+		t.Fatalf("expected exactly one changed row, got %+v", diffs)
+	}
+	if rows := diffs[0].Table.Rows; len(rows) != 2 || rows[0]["_value"].Int() != 2 || rows[1]["_value"].Int() != 3 {
+		t.Fatalf("unexpected diff rows: %v", rows)
+	}
+}
+
+// panicImporter panics on every import.
+type panicImporter struct{}
+
+func (panicImporter) ImportPackageObject(path string) (*interpreter.Package, error) {
+	panic("import of " + path + " panicked")
+}
+
+func TestScopeHolder_EvalPanic(t *testing.T) {
+	ctx := context.Background()
+	var out bytes.Buffer
+	r := New(ctx, WithPanicOutput(&out))
+	r.importer = panicImporter{}
+
+	_, _, err := r.executeLine(`import "array"`)
+	if got := errors.Code(err); got != codes.Internal {
+		t.Fatalf("expected an internal error, got %v: %v", got, err)
+	}
+	if !strings.Contains(out.String(), "interpreter panicked") {
+		t.Fatalf("expected the panic to be logged, got:\n%s", out.String())
+	}
+	// The session is still usable.
+	r.importer = runtime.StdLib()
+	if res, err := r.EvalString(ctx, `1 + 1`); err != nil {
+		t.Fatal(err)
+	} else if res.Output != "2\n" {
+		t.Fatalf("unexpected output: %q", res.Output)
+	}
+}
+
+func TestScopeHolder_WithDependencies_Secret(t *testing.T) {
+	ctx := context.Background()
+	secrets := mock.SecretService{"token": "s3cr3t"}
+	r := New(ctx, WithDependencies(flux.Deps{Deps: flux.WrappedDeps{SecretService: secrets}}))
+	res, err := r.EvalString(ctx, `
+import "influxdata/influxdb/secrets"
+
+secrets.get(key: "token")
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Output, "s3cr3t") {
+		t.Fatalf("expected the secret from the session dependencies, got:\n%s", res.Output)
+	}
+}
+
+func TestNewWithError_ImporterDeadline(t *testing.T) {
+	imp := &blockingImporter{started: make(chan struct{}, 1), release: make(chan struct{})}
+	defer close(imp.release)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	r, err := NewWithError(ctx, WithImporter(imp))
+	if r != nil || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected construction to stop at the deadline, got %v", err)
+	}
+}
+
+func TestScopeHolder_EvalCells(t *testing.T) {
+	ctx := context.Background()
+	r := New(ctx)
+	if _, err := r.EvalString(ctx, `x = 100`); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		cells []string
+		cell  string
+		want  string
+	}{
+		{cells: []string{`x = 1`, `y = x + 10`}, cell: `y`, want: "11"},
+		// Reordering the cells changes the bindings that the cell sees.
+		{cells: []string{`x = 10`, `y = x * 2`, `x = 1`}, cell: `x + y`, want: "21"},
+		{cells: []string{`x = 1`, `y = x * 2`, `x = 10`}, cell: `x + y`, want: "12"},
+	} {
+		res, err := r.EvalCells(ctx, tc.cells, tc.cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(res.Output); got != tc.want {
+			t.Errorf("cells %q: expected %s, got %s", tc.cells, tc.want, got)
+		}
+	}
+
+	// The cells see none of the session bindings, and leave none behind.
+	if _, err := r.EvalCells(ctx, nil, `x`); err == nil {
+		t.Fatal("expected the session binding of x to be out of scope")
+	}
+	if res, err := r.EvalString(ctx, `x`); err != nil || strings.TrimSpace(res.Output) != "100" {
+		t.Fatalf("expected the session binding to be kept, got %q, %v", res.Output, err)
+	}
+}
+
+func TestScopeHolder_Format(t *testing.T) {
+	ctx := context.Background()
+	r := New(ctx)
+	got, err := r.Format(`// cpu only
+from(bucket:"b")
+      |>   range(start:-1h)
+  |> filter(fn:(r)=>r._measurement=="cpu")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `// cpu only
+from(bucket: "b")
+    |> range(start: -1h)
+    |> filter(fn: (r) => r._measurement == "cpu")
+`
+	if got != want {
+		t.Errorf("unexpected formatted input -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if again, err := r.Format(got); err != nil || again != got {
+		t.Errorf("expected the canonical form to format to itself, got %q, %v", again, err)
+	}
+
+	if _, err := r.Format(`x = (`); err == nil {
+		t.Fatal("expected an error formatting input that does not parse")
+	} else if code := errors.Code(err); code != codes.Invalid {
+		t.Errorf("expected an invalid error, got %v: %v", code, err)
+	}
+}
+
+func TestScopeHolder_ExecuteLine_HTTPQuery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "x = 1\nx + 1")
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	r := New(context.Background(), WithHTTPQueryLoader(&HTTPQueryLoader{AllowedHosts: []string{host}}))
+	res, _, err := r.executeLine("@" + srv.URL + "/q.flux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2"}; !cmp.Equal(want, res.outputs) {
+		t.Fatalf("unexpected outputs -want/+got:\n%s", cmp.Diff(want, res.outputs))
+	}
+
+	r = New(context.Background(), WithHTTPQueryLoader(&HTTPQueryLoader{AllowedHosts: []string{"example.com"}}))
+	if _, _, err := r.executeLine("@" + srv.URL + "/q.flux"); errors.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected the host to be refused, got %v", err)
+	}
+}
+
+func TestScopeHolder_Yields_Implicit(t *testing.T) {
+	r := New(context.Background())
+	got, err := r.Yields(context.Background(), `
+import "array"
+
+array.from(rows: [{_value: 1}])
+array.from(rows: [{_value: 2}])
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"_result", "_result"}; !cmp.Equal(want, got) {
+		t.Fatalf("unexpected yield names -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
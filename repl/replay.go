@@ -0,0 +1,75 @@
+package repl
+
+import (
+	"context"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// TranscriptStep is an input of a recorded session
+// along with the outcome it had when it was recorded.
+type TranscriptStep struct {
+	Input string `json:"input"`
+	// Output is the output of the input as returned by EvalString.
+	Output string `json:"output"`
+	// Error is the message of the error that the input failed with.
+	// It is empty if the input succeeded.
+	Error string `json:"error,omitempty"`
+}
+
+// Divergence is a step of a replayed transcript
+// whose outcome differs from the recorded one.
+type Divergence struct {
+	// Step is the index of the step in the transcript.
+	Step  int
+	Input string
+	// Want and WantError are the recorded output and error message,
+	// and Got and GotError are those of the replay.
+	Want, WantError string
+	Got, GotError   string
+}
+
+// Replay evaluates the inputs of a transcript in order with
+// EvalString, in a new session created with opts, and reports
+// each step whose output or error differs from the recorded one.
+//
+// When strict is set, the replay stops at the first divergence,
+// which is reported along with an error.
+func Replay(ctx context.Context, steps []TranscriptStep, strict bool, opts ...Option) ([]Divergence, error) {
+	r, err := NewWithError(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return replaySteps(steps, strict, func(t string) (string, error) {
+		res, err := r.EvalString(ctx, t)
+		return res.Output, err
+	})
+}
+
+// replaySteps replays steps with eval as described by Replay.
+func replaySteps(steps []TranscriptStep, strict bool, eval func(t string) (string, error)) ([]Divergence, error) {
+	var divergences []Divergence
+	for i, step := range steps {
+		got, err := eval(step.Input)
+		var gotError string
+		if err != nil {
+			gotError = err.Error()
+		}
+		if got == step.Output && gotError == step.Error {
+			continue
+		}
+		divergences = append(divergences, Divergence{
+			Step:      i,
+			Input:     step.Input,
+			Want:      step.Output,
+			WantError: step.Error,
+			Got:       got,
+			GotError:  gotError,
+		})
+		if strict {
+			return divergences, errors.Newf(codes.FailedPrecondition, "step %d of the transcript diverged from its recorded outcome", i)
+		}
+	}
+	return divergences, nil
+}
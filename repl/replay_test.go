@@ -0,0 +1,88 @@
+package repl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// fakeEval evaluates a step by looking up its input in outcomes,
+// recording the inputs it was called with.
+type fakeEval struct {
+	outcomes map[string]TranscriptStep
+	inputs   []string
+}
+
+func (e *fakeEval) eval(t string) (string, error) {
+	e.inputs = append(e.inputs, t)
+	step := e.outcomes[t]
+	if step.Error != "" {
+		return step.Output, errors.New(codes.Invalid, step.Error)
+	}
+	return step.Output, nil
+}
+
+func replayTestTranscript() []TranscriptStep {
+	return []TranscriptStep{
+		{Input: "x = 1", Output: ""},
+		{Input: "x + 1", Output: "2\n"},
+		{Input: "y", Error: "undefined identifier y"},
+		{Input: "x * 3", Output: "3\n"},
+	}
+}
+
+func TestReplaySteps(t *testing.T) {
+	e := &fakeEval{outcomes: map[string]TranscriptStep{
+		"x + 1": {Output: "2\n"},
+		"y":     {Error: "undefined identifier y"},
+		"x * 3": {Output: "3\n"},
+	}}
+	divergences, err := replaySteps(replayTestTranscript(), true, e.eval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(divergences) != 0 {
+		t.Fatalf("unexpected divergences: %+v", divergences)
+	}
+	if got := len(e.inputs); got != 4 {
+		t.Fatalf("expected every step to be replayed, got %d", got)
+	}
+}
+
+func TestReplaySteps_Divergence(t *testing.T) {
+	outcomes := map[string]TranscriptStep{
+		"x + 1": {Output: "3\n"},
+		"y":     {Output: "4\n"},
+		"x * 3": {Output: "3\n"},
+	}
+	want := []Divergence{
+		{Step: 1, Input: "x + 1", Want: "2\n", Got: "3\n"},
+		{Step: 2, Input: "y", WantError: "undefined identifier y", Got: "4\n"},
+	}
+
+	e := &fakeEval{outcomes: outcomes}
+	got, err := replaySteps(replayTestTranscript(), false, e.eval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(want, got) {
+		t.Fatalf("unexpected divergences -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if len(e.inputs) != 4 {
+		t.Fatalf("expected every step to be replayed, got %d", len(e.inputs))
+	}
+
+	e = &fakeEval{outcomes: outcomes}
+	got, err = replaySteps(replayTestTranscript(), true, e.eval)
+	if got, want := errors.Code(err), codes.FailedPrecondition; got != want {
+		t.Fatalf("expected the strict replay to fail, got %v", err)
+	}
+	if !cmp.Equal(want[:1], got) {
+		t.Fatalf("unexpected divergences -want/+got:\n%s", cmp.Diff(want[:1], got))
+	}
+	if len(e.inputs) != 2 {
+		t.Fatalf("expected the strict replay to stop at the first divergence, got %d steps", len(e.inputs))
+	}
+}
@@ -0,0 +1,157 @@
+package repl
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/libflux/go/libflux"
+)
+
+// Reset restores the session as described by ScopeHolder.Reset.
+func (s *Service) Reset(req struct{}, resp *struct{}) error {
+	return s.r.Reset()
+}
+
+// Reset discards the bindings of the session and rebuilds its scope
+// as it was when the session was created, with the prelude, the
+// defaults and the init directory loaded again.
+//
+// Output is never lost to a reset. Reset is ordered after every line
+// that began to be evaluated before it was called: such a line either
+// completes, or has its running queries canceled with a canceled error,
+// and in both cases its result is delivered before the scope is
+// rebuilt. Tailed queries are canceled the same way. Lines that arrive
+// while the session is being reset wait for it, and are evaluated in
+// the new scope. The results that FetchPage buffered are dropped along
+// with the bindings, so their cursors can no longer be fetched.
+func (r *ScopeHolder) Reset() error {
+	done := r.drainLines()
+	defer done()
+	return r.rebuildScope()
+}
+
+// drainLines cancels the lines in progress and waits for them to
+// deliver their output. Lines that begin afterwards wait until the
+// returned function is called, and then run in a new epoch.
+func (r *ScopeHolder) drainLines() func() {
+	// Cancel the lines first, so that waiting for
+	// them does not wait on their queries.
+	r.epoch.cancel()
+	r.queries.cancelAll(cancelReset)
+	r.lineMu.Lock()
+	return func() {
+		r.epoch.renew()
+		r.lineMu.Unlock()
+	}
+}
+
+// rebuildScope replaces the session scope and analyzer with new ones.
+// The caller must hold lineMu for writing.
+func (r *ScopeHolder) rebuildScope() error {
+	r.evalMu.Lock()
+	analyzer, err := libflux.NewAnalyzerWithOptions(libflux.NewOptions(r.ctx))
+	if err != nil {
+		r.evalMu.Unlock()
+		return errors.Wrap(err, codes.Inherit, "failed to create the analyzer")
+	}
+	prelude, err := r.newPreludeScope(r.ctx)
+	if err != nil {
+		r.evalMu.Unlock()
+		return errors.Wrap(err, codes.Inherit, "failed to import the prelude")
+	}
+	r.analyzer = analyzer
+	r.analyzerBroken = false
+	r.history = nil
+	r.nestSessionScope(prelude)
+	if r.freezeNow {
+		r.setNow(time.Now())
+	}
+	r.initErrors = nil
+	r.prepared.clear()
+	r.pages.clear()
+	r.evalMu.Unlock()
+
+	// The init files are evaluated like any other source,
+	// which takes evalMu itself.
+	if err := r.loadInitDir(); err != nil {
+		return errors.Wrap(err, codes.Inherit, "failed to load the init directory")
+	}
+	return nil
+}
+
+// lineEpoch is the period between two resets of a session. The lines
+// that begin within an epoch are canceled when the session is reset.
+// Its zero value is ready to use.
+type lineEpoch struct {
+	mu   sync.Mutex
+	ctx  context.Context
+	stop context.CancelFunc
+}
+
+// current returns the context of the epoch, starting one if needed.
+func (e *lineEpoch) current() context.Context {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ctx == nil {
+		e.ctx, e.stop = context.WithCancel(context.Background())
+	}
+	return e.ctx
+}
+
+// cancel cancels the lines of the epoch. Lines that begin before
+// the epoch is renewed are canceled as soon as they begin.
+func (e *lineEpoch) cancel() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ctx == nil {
+		e.ctx, e.stop = context.WithCancel(context.Background())
+	}
+	e.stop()
+}
+
+// renew forgets the epoch, so that the next line starts a new one.
+func (e *lineEpoch) renew() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ctx, e.stop = nil, nil
+}
+
+// epochKey is the context key under which the context of the epoch
+// that a line began in is recorded.
+type epochKey struct{}
+
+// beginLine marks the start of the evaluation of a line in ctx, holding
+// off Reset until the returned function is called once the line's
+// output has been delivered. The returned context is canceled when
+// a reset is requested. A context that already belongs to a line is
+// returned as it is, so that a line may be evaluated by parts.
+func (r *ScopeHolder) beginLine(ctx context.Context) (context.Context, func()) {
+	if _, ok := ctx.Value(epochKey{}).(context.Context); ok {
+		return ctx, func() {}
+	}
+	r.lineMu.RLock()
+	epoch := r.epoch.current()
+	ctx, cancel := context.WithCancel(ctx)
+	ctx = context.WithValue(ctx, epochKey{}, epoch)
+	go func() {
+		select {
+		case <-epoch.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		cancel()
+		r.lineMu.RUnlock()
+	}
+}
+
+// resetCanceled reports whether ctx was canceled because
+// the session was reset while its line was being evaluated.
+func resetCanceled(ctx context.Context) bool {
+	epoch, ok := ctx.Value(epochKey{}).(context.Context)
+	return ok && epoch.Err() != nil
+}
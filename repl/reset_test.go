@@ -0,0 +1,82 @@
+package repl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func TestScopeHolder_DrainLines(t *testing.T) {
+	r := newTestHolder()
+	sink := &returnSink{}
+	r.lines = sink
+
+	// A line whose query streams until it is canceled.
+	started := make(chan struct{})
+	go func() {
+		ctx, end := r.beginLine(context.Background())
+		defer end()
+		close(started)
+		<-ctx.Done()
+		res := lineResult{}
+		if resetCanceled(ctx) {
+			res.err = r.queryError(ctx, "1", ctx.Err())
+		}
+		r.lines.deliver(res)
+	}()
+	<-started
+
+	done := r.drainLines()
+	// The line was canceled and its result delivered
+	// before the session could be rebuilt.
+	results := sink.take()
+	if len(results) != 1 {
+		t.Fatalf("expected the result of the line before draining returned, got %d results", len(results))
+	}
+	if got, want := errors.Code(results[0].err), codes.Canceled; got != want {
+		t.Fatalf("unexpected error code of the canceled line: got %v want %v (%v)", got, want, results[0].err)
+	}
+
+	// Lines wait for the reset to finish.
+	begun := make(chan bool, 1)
+	go func() {
+		ctx, end := r.beginLine(context.Background())
+		defer end()
+		begun <- ctx.Err() != nil || resetCanceled(ctx)
+	}()
+	select {
+	case <-begun:
+		t.Fatal("expected a line to wait for the reset")
+	case <-time.After(50 * time.Millisecond):
+	}
+	done()
+
+	select {
+	case canceled := <-begun:
+		if canceled {
+			t.Fatal("expected a line that begins after the reset not to be canceled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the line after the reset")
+	}
+}
+
+func TestScopeHolder_BeginLine_Nested(t *testing.T) {
+	r := newTestHolder()
+	ctx, end := r.beginLine(context.Background())
+	nested, endNested := r.beginLine(ctx)
+	if nested != ctx {
+		t.Fatal("expected a nested line to share the context of its line")
+	}
+	endNested()
+	end()
+
+	// Both have released the session, so it can be drained.
+	r.drainLines()()
+	if ctx.Err() == nil {
+		t.Fatal("expected the context of an ended line to be canceled")
+	}
+}
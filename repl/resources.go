@@ -0,0 +1,144 @@
+package repl
+
+import (
+	"sort"
+	"time"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// The kinds of the resources listed by Service.ListResources.
+const (
+	// ResourceQuery is a query that is running, identified by its ID.
+	ResourceQuery = "query"
+	// ResourcePrepared is a query prepared under a name by Prepare.
+	ResourcePrepared = "prepared"
+	// ResourceCursor is a result buffered by FetchPage whose pages
+	// are being fetched, identified by the number of the result.
+	ResourceCursor = "cursor"
+	// ResourceSession is a session of the SessionStore that the
+	// session is served from, identified by its token. The token of
+	// a session is only listed to the client that it was issued to.
+	ResourceSession = "session"
+)
+
+// Resource is a resource that a server holds on behalf of its clients.
+type Resource struct {
+	Kind string `json:"kind"`
+	// ID is empty for the sessions of other clients.
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	// Status is "running" for a query, "ready" for a prepared query,
+	// "open" for a cursor, and "attached" or "detached" for a session.
+	Status string `json:"status"`
+	// Expires is when a detached session is reaped.
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// ListResourcesRequest is the params object for Service.ListResources.
+type ListResourcesRequest struct {
+	// Kind limits the list to the resources of a kind.
+	// Every resource is listed when it is empty.
+	Kind string `json:"kind,omitempty"`
+}
+
+// ListResourcesResponse is the response to Service.ListResources.
+type ListResourcesResponse struct {
+	Resources []Resource `json:"resources"`
+}
+
+// ResourceRequest names a resource for Service.DeleteResource.
+type ResourceRequest struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+}
+
+// ListResources lists the resources of the session along with the
+// sessions of the store that it is served from, if any, so that the
+// client can see the state that the server holds. The sessions of
+// other clients are listed without their tokens, so that a client
+// cannot resume or drop them.
+func (s *Service) ListResources(req ListResourcesRequest, resp *ListResourcesResponse) error {
+	var resources []Resource
+	switch req.Kind {
+	case "", ResourceQuery, ResourcePrepared, ResourceCursor:
+		resources = s.r.Resources()
+	case ResourceSession:
+	default:
+		return errors.Newf(codes.Invalid, "unknown resource kind %q", req.Kind)
+	}
+	if s.store != nil && (req.Kind == "" || req.Kind == ResourceSession) {
+		resources = append(resources, s.store.resources(func(token string) bool {
+			return token == s.token
+		})...)
+	}
+	if req.Kind != "" {
+		filtered := resources[:0]
+		for _, res := range resources {
+			if res.Kind == req.Kind {
+				filtered = append(filtered, res)
+			}
+		}
+		resources = filtered
+	}
+	sortResources(resources)
+	*resp = ListResourcesResponse{Resources: resources}
+	return nil
+}
+
+// DeleteResource cancels a running query, drops a prepared query or
+// drops a buffered result of the session. The sessions of the store
+// that the session is served from can only be dropped by the operator
+// through SessionStore.Delete.
+func (s *Service) DeleteResource(req ResourceRequest, resp *struct{}) error {
+	if req.Kind == ResourceSession {
+		return errors.New(codes.PermissionDenied, "sessions can only be dropped by the operator of the server")
+	}
+	return s.r.DeleteResource(req.Kind, req.ID)
+}
+
+// Resources lists the queries of the session that are running, its
+// prepared queries and the results whose pages are being fetched,
+// by kind and then by creation time.
+func (r *ScopeHolder) Resources() []Resource {
+	resources := append(r.queries.resources(), r.prepared.resources()...)
+	resources = append(resources, r.pages.resources()...)
+	sortResources(resources)
+	return resources
+}
+
+// DeleteResource cancels the running query, drops the prepared query or
+// drops the buffered result of the given kind and ID. A not found error
+// is returned if there is no such resource.
+func (r *ScopeHolder) DeleteResource(kind, id string) error {
+	switch kind {
+	case ResourceQuery:
+		return r.CancelQuery(id)
+	case ResourcePrepared:
+		if !r.prepared.remove(id) {
+			return errors.Newf(codes.NotFound, "no query is prepared under %q", id)
+		}
+		return nil
+	case ResourceCursor:
+		if !r.pages.drop(id) {
+			return errors.Newf(codes.NotFound, "no result is open under %q", id)
+		}
+		return nil
+	default:
+		return errors.Newf(codes.Invalid, "resources of kind %q cannot be deleted from a session", kind)
+	}
+}
+
+func sortResources(resources []Resource) {
+	sort.SliceStable(resources, func(i, j int) bool {
+		a, b := resources[i], resources[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if !a.Created.Equal(b.Created) {
+			return a.Created.Before(b.Created)
+		}
+		return a.ID < b.ID
+	})
+}
@@ -0,0 +1,101 @@
+package repl
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestService_ListResources(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	st, _ := newTestStore(time.Minute, &now)
+	own := sessionToken(t, connectSession(t, st, ""))
+	detached := connectSession(t, st, "")
+	token := sessionToken(t, detached)
+	detached.close()
+
+	r := newTestHolder()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	id := r.queries.add(cancel)
+	r.prepared.set("panel", &preparedQuery{created: now})
+	cursor := strconv.Itoa(r.pages.add(newTestPagedTable(3)))
+	call := serveTestService(t, &Service{r: r, store: st, token: own})
+
+	list := func(kind string) []Resource {
+		t.Helper()
+		resp := call(`{"method": "Service.ListResources", "id": 1, "params": [{"kind": "` + kind + `"}]}`)
+		if resp.Error != nil {
+			t.Fatalf("unexpected error: %v", resp.Error)
+		}
+		var res ListResourcesResponse
+		if err := json.Unmarshal(resp.Result, &res); err != nil {
+			t.Fatal(err)
+		}
+		return res.Resources
+	}
+
+	resources := list("")
+	want := []struct{ kind, id, status string }{
+		{ResourceCursor, cursor, "open"},
+		{ResourcePrepared, "panel", "ready"},
+		{ResourceQuery, id, "running"},
+		{ResourceSession, "", "detached"},
+		{ResourceSession, own, "attached"},
+	}
+	if len(resources) != len(want) {
+		t.Fatalf("expected %d resources, got %v", len(want), resources)
+	}
+	for i, w := range want {
+		if got := resources[i]; got.Kind != w.kind || got.ID != w.id || got.Status != w.status {
+			t.Errorf("resource %d: expected %s %q %s, got %s %q %s", i, w.kind, w.id, w.status, got.Kind, got.ID, got.Status)
+		}
+	}
+	if exp := resources[3].Expires; exp == nil || !exp.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected the detached session to expire at %v, got %v", now.Add(time.Minute), exp)
+	}
+	if got := list(ResourceQuery); len(got) != 1 || got[0].ID != id {
+		t.Errorf("expected only the running query, got %v", got)
+	}
+
+	for _, req := range []string{
+		`{"method": "Service.DeleteResource", "id": 2, "params": [{"kind": "query", "id": "` + id + `"}]}`,
+		`{"method": "Service.DeleteResource", "id": 3, "params": [{"kind": "prepared", "id": "panel"}]}`,
+		`{"method": "Service.DeleteResource", "id": 4, "params": [{"kind": "cursor", "id": "` + cursor + `"}]}`,
+	} {
+		if resp := call(req); resp.Error != nil {
+			t.Fatalf("unexpected error: %v", resp.Error)
+		}
+	}
+	// The detached session is only dropped through the store.
+	if resp := call(`{"method": "Service.DeleteResource", "id": 4, "params": [{"kind": "session", "id": "` + token + `"}]}`); resp.Error == nil {
+		t.Error("expected an error deleting the session of another client")
+	}
+	if err := st.Delete(token); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() == nil {
+		t.Error("expected the query to be canceled")
+	}
+	r.queries.remove(id)
+	if got := list(""); len(got) != 1 || got[0].ID != own {
+		t.Errorf("expected only the own session once the others are deleted, got %v", got)
+	}
+	if resp := call(`{"method": "Service.DeleteResource", "id": 5, "params": [{"kind": "prepared", "id": "panel"}]}`); resp.Error == nil {
+		t.Error("expected an error deleting a prepared query twice")
+	}
+}
+
+func TestSessionStore_Delete_Attached(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	st, _ := newTestStore(time.Minute, &now)
+	token := sessionToken(t, connectSession(t, st, ""))
+	if err := st.Delete(token); err == nil {
+		t.Fatal("expected an error deleting an attached session")
+	}
+	if len(st.sessions) != 1 {
+		t.Fatalf("expected the attached session to be kept, got %d sessions", len(st.sessions))
+	}
+}
@@ -0,0 +1,95 @@
+package repl
+
+import (
+	"context"
+	"io"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// WithMaxResultBytes limits the number of bytes of output that the
+// results of a single query may be encoded to. A query whose output
+// grows past the limit is aborted with a resource exhausted error once
+// the output up to the limit has been written, rather than sending
+// unbounded data to the client. Only the session output counts towards
+// the limit, not that of the sinks added WithResultSink.
+//
+// A limit of zero or less, the default, means no limit.
+// WithResultByteLimit lowers the limit for a single request.
+func WithMaxResultBytes(n int64) Option {
+	return option(func(r *ScopeHolder) {
+		r.maxResultBytes = n
+	})
+}
+
+type resultByteLimitKey struct{}
+
+// WithResultByteLimit returns a context whose queries are limited to n
+// bytes of output as described by WithMaxResultBytes. The limit can
+// only tighten that of the session: the lower of the two applies.
+// A limit of zero or less leaves the limit of the session in place.
+func WithResultByteLimit(ctx context.Context, n int64) context.Context {
+	if n <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, resultByteLimitKey{}, n)
+}
+
+// resultByteLimit returns the output limit of the queries run in ctx.
+func (r *ScopeHolder) resultByteLimit(ctx context.Context) int64 {
+	n := r.maxResultBytes
+	if m, ok := ctx.Value(resultByteLimitKey{}).(int64); ok && m > 0 && (n <= 0 || m < n) {
+		n = m
+	}
+	return n
+}
+
+// capOutput returns sinks with the session output, which is the first
+// of them, limited to n bytes, along with the writer that limits it.
+// The sinks are returned unchanged, with a nil writer, if n is zero or less.
+func capOutput(sinks []ResultSink, n int64) ([]ResultSink, *capWriter) {
+	if n <= 0 || len(sinks) == 0 {
+		return sinks, nil
+	}
+	cw := &capWriter{w: sinks[0].Writer, limit: n}
+	capped := append([]ResultSink{{Writer: cw, Encoder: sinks[0].Encoder}}, sinks[1:]...)
+	return capped, cw
+}
+
+// capWriter writes at most limit bytes to w, and fails
+// every write once the limit is exceeded.
+type capWriter struct {
+	w       io.Writer
+	limit   int64
+	written int64
+	err     error
+}
+
+func (cw *capWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	if left := cw.limit - cw.written; int64(len(p)) > left {
+		n, err := cw.w.Write(p[:left])
+		cw.written += int64(n)
+		if err != nil {
+			return n, err
+		}
+		cw.err = errors.Newf(codes.ResourceExhausted, "result too large: the output of the query exceeds the limit of %d bytes", cw.limit)
+		return n, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.written += int64(n)
+	return n, err
+}
+
+// check returns the error of the writer once the limit is exceeded, so
+// that the query is aborted even while other sinks still take its output,
+// or err otherwise. A nil writer always returns err.
+func (cw *capWriter) check(err error) error {
+	if cw != nil && cw.err != nil {
+		return cw.err
+	}
+	return err
+}
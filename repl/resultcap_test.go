@@ -0,0 +1,71 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/csv"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func TestCapOutput(t *testing.T) {
+	var full bytes.Buffer
+	enc := csv.NewResultEncoder(csv.DefaultEncoderConfig())
+	if _, err := enc.Encode(&full, sinkTestResult()); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, audit bytes.Buffer
+	sinks, cw := capOutput([]ResultSink{
+		{Writer: &out, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())},
+		{Writer: &audit, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())},
+	}, 64)
+	states := newSinkStates(sinks)
+	err := cw.check(encodeResult(states, sinkTestResult()))
+	if got, want := errors.Code(err), codes.ResourceExhausted; got != want {
+		t.Fatalf("unexpected error code: got %v want %v (%v)", got, want, err)
+	}
+
+	// The output up to the limit was delivered before the error,
+	// and the other sinks are not limited.
+	if got, want := out.String(), full.String()[:64]; got != want {
+		t.Fatalf("unexpected partial output:\n%q\nwant:\n%q", got, want)
+	}
+	if audit.String() != full.String() {
+		t.Fatalf("expected the other sink to get the whole result, got:\n%s", audit.String())
+	}
+}
+
+func TestCapOutput_NoLimit(t *testing.T) {
+	var out bytes.Buffer
+	sinks := []ResultSink{{Writer: &out, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())}}
+	capped, cw := capOutput(sinks, 0)
+	if cw != nil || capped[0].Writer != &out {
+		t.Fatal("expected the sinks to be unchanged without a limit")
+	}
+	if err := cw.check(encodeResult(newSinkStates(capped), sinkTestResult())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScopeHolder_ResultByteLimit(t *testing.T) {
+	r := newTestHolder(WithMaxResultBytes(100))
+	ctx := context.Background()
+	if got := r.resultByteLimit(ctx); got != 100 {
+		t.Fatalf("expected the session limit, got %d", got)
+	}
+	if got := r.resultByteLimit(WithResultByteLimit(ctx, 10)); got != 10 {
+		t.Fatalf("expected the limit of the request, got %d", got)
+	}
+	if got := r.resultByteLimit(WithResultByteLimit(ctx, 0)); got != 100 {
+		t.Fatalf("expected a zero limit to leave the session limit, got %d", got)
+	}
+	if got := r.resultByteLimit(WithResultByteLimit(ctx, 1000)); got != 100 {
+		t.Fatalf("expected a request not to raise the session limit, got %d", got)
+	}
+	if got := newTestHolder().resultByteLimit(WithResultByteLimit(ctx, 1000)); got != 1000 {
+		t.Fatalf("expected the limit of the request without a session limit, got %d", got)
+	}
+}
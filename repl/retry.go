@@ -0,0 +1,50 @@
+package repl
+
+import (
+	"context"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// WithQueryRetries sets the number of times a query that fails with a
+// transient error is run again. The first retry waits for backoff and
+// each later one waits twice as long as the one before it.
+// Queries are not retried by default.
+//
+// While retries are enabled, the tables of a query are only written
+// once its last attempt has finished, so that a failed attempt does
+// not leave partial output behind.
+func WithQueryRetries(n int, backoff time.Duration) Option {
+	return option(func(r *ScopeHolder) {
+		r.queryRetries = n
+		r.retryBackoff = backoff
+	})
+}
+
+// isTransient reports whether err may succeed if the query is retried.
+func isTransient(err error) bool {
+	return errors.Code(err) == codes.Unavailable
+}
+
+// retryQuery calls run until it succeeds, fails with an error that is
+// not transient, or has been retried as many times as configured.
+func (r *ScopeHolder) retryQuery(ctx context.Context, run func() (flux.Statistics, error)) (flux.Statistics, error) {
+	backoff := r.retryBackoff
+	for attempt := 0; ; attempt++ {
+		stats, err := run()
+		if err == nil || attempt >= r.queryRetries || !isTransient(err) {
+			return stats, err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return stats, err
+		}
+		backoff *= 2
+	}
+}
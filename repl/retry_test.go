@@ -0,0 +1,85 @@
+package repl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// flakySource returns a query func that fails with err the first
+// failures times it is called and succeeds after that.
+func flakySource(failures int, err error) (func() (flux.Statistics, error), *int) {
+	var calls int
+	return func() (flux.Statistics, error) {
+		calls++
+		if calls <= failures {
+			return flux.Statistics{}, err
+		}
+		return flux.Statistics{TotalDuration: time.Second}, nil
+	}, &calls
+}
+
+func TestRetryQuery(t *testing.T) {
+	r := newTestHolder(WithQueryRetries(3, time.Millisecond))
+	run, calls := flakySource(2, errors.New(codes.Unavailable, "connection refused"))
+	stats, err := r.retryQuery(context.Background(), run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", *calls)
+	}
+	if stats.TotalDuration != time.Second {
+		t.Fatalf("expected the statistics of the successful attempt, got %v", stats)
+	}
+}
+
+func TestRetryQuery_NotTransient(t *testing.T) {
+	r := newTestHolder(WithQueryRetries(3, time.Millisecond))
+	run, calls := flakySource(2, errors.New(codes.Invalid, "type error"))
+	if _, err := r.retryQuery(context.Background(), run); errors.Code(err) != codes.Invalid {
+		t.Fatalf("expected an invalid error, got %v", err)
+	}
+	if *calls != 1 {
+		t.Fatalf("expected a single attempt, got %d", *calls)
+	}
+}
+
+func TestRetryQuery_Exhausted(t *testing.T) {
+	r := newTestHolder(WithQueryRetries(1, time.Millisecond))
+	run, calls := flakySource(2, errors.New(codes.Unavailable, "connection refused"))
+	if _, err := r.retryQuery(context.Background(), run); errors.Code(err) != codes.Unavailable {
+		t.Fatalf("expected an unavailable error, got %v", err)
+	}
+	if *calls != 2 {
+		t.Fatalf("expected 2 attempts, got %d", *calls)
+	}
+}
+
+func TestRetryQuery_Canceled(t *testing.T) {
+	r := newTestHolder(WithQueryRetries(3, time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	run, calls := flakySource(2, errors.New(codes.Unavailable, "connection refused"))
+	if _, err := r.retryQuery(ctx, run); errors.Code(err) != codes.Unavailable {
+		t.Fatalf("expected an unavailable error, got %v", err)
+	}
+	if *calls != 1 {
+		t.Fatalf("expected canceling to stop retries, got %d attempts", *calls)
+	}
+}
+
+func TestRetryQuery_Disabled(t *testing.T) {
+	r := newTestHolder()
+	run, calls := flakySource(1, errors.New(codes.Unavailable, "connection refused"))
+	if _, err := r.retryQuery(context.Background(), run); err == nil {
+		t.Fatal("expected an error")
+	}
+	if *calls != 1 {
+		t.Fatalf("expected no retries by default, got %d attempts", *calls)
+	}
+}
@@ -0,0 +1,102 @@
+package repl
+
+import (
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/metadata"
+)
+
+// resultRowsKey is the statistics metadata key under which
+// the row count of each result is recorded.
+const resultRowsKey = "flux/result-rows"
+
+// ResultRows is the number of rows that a result produced,
+// across its tables.
+type ResultRows struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+}
+
+// statsResultRows returns the row counts recorded in stats,
+// one for each result in the order they were produced.
+func statsResultRows(stats flux.Statistics) []ResultRows {
+	var results []ResultRows
+	for _, v := range stats.Metadata.GetAll(resultRowsKey) {
+		if rr, ok := v.(ResultRows); ok {
+			results = append(results, rr)
+		}
+	}
+	return results
+}
+
+// totalRows returns the rows produced by every result in results.
+func totalRows(results []ResultRows) int64 {
+	var n int64
+	for _, rr := range results {
+		n += rr.Rows
+	}
+	return n
+}
+
+// rowCounter counts the rows of the results of a query as their
+// tables are written out, so that a client can be told how many rows
+// it was returned without reading them a second time. Rows that are
+// not written, such as those past the result byte limit, are not counted.
+type rowCounter struct {
+	results []*ResultRows
+}
+
+// wrap returns a result with the tables of result
+// whose rows are counted as they are read.
+func (c *rowCounter) wrap(result flux.Result) flux.Result {
+	rr := &ResultRows{Name: result.Name()}
+	c.results = append(c.results, rr)
+	return &countedResult{Result: result, rows: rr}
+}
+
+// addTo records the row counts of the results in stats.
+func (c *rowCounter) addTo(stats flux.Statistics) flux.Statistics {
+	if len(c.results) == 0 {
+		return stats
+	}
+	if stats.Metadata == nil {
+		stats.Metadata = make(metadata.Metadata)
+	}
+	for _, rr := range c.results {
+		stats.Metadata.Add(resultRowsKey, *rr)
+	}
+	return stats
+}
+
+// countedResult is a result whose rows are counted as they are read.
+type countedResult struct {
+	flux.Result
+	rows *ResultRows
+}
+
+func (r *countedResult) Tables() flux.TableIterator {
+	return countedTables{TableIterator: r.Result.Tables(), rows: r.rows}
+}
+
+type countedTables struct {
+	flux.TableIterator
+	rows *ResultRows
+}
+
+func (t countedTables) Do(f func(flux.Table) error) error {
+	return t.TableIterator.Do(func(tbl flux.Table) error {
+		return f(&countedTable{Table: tbl, rows: t.rows})
+	})
+}
+
+// countedTable is a table whose rows are counted as they are read.
+type countedTable struct {
+	flux.Table
+	rows *ResultRows
+}
+
+func (t *countedTable) Do(f func(flux.ColReader) error) error {
+	return t.Table.Do(func(cr flux.ColReader) error {
+		t.rows.Rows += int64(cr.Len())
+		return f(cr)
+	})
+}
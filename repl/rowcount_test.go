@@ -0,0 +1,62 @@
+package repl
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/csv"
+	"github.com/influxdata/flux/execute/executetest"
+)
+
+func TestRowCounter(t *testing.T) {
+	cols := []flux.ColMeta{
+		{Label: "host", Type: flux.TString},
+		{Label: "_value", Type: flux.TInt},
+	}
+	results := []*executetest.Result{
+		{Nm: "a", Tbls: []*executetest.Table{
+			{KeyCols: []string{"host"}, ColMeta: cols, Data: [][]interface{}{{"x", int64(1)}, {"x", int64(2)}}},
+			{KeyCols: []string{"host"}, ColMeta: cols, Data: [][]interface{}{{"y", int64(3)}}},
+		}},
+		{Nm: "b", Tbls: []*executetest.Table{
+			{KeyCols: []string{"host"}, ColMeta: cols, Data: [][]interface{}{{"x", int64(4)}}},
+		}},
+	}
+
+	rows := &rowCounter{}
+	var a, b bytes.Buffer
+	states := newSinkStates([]ResultSink{
+		{Writer: &a, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())},
+		{Writer: &b, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())},
+	})
+	for _, result := range results {
+		if err := encodeResult(states, rows.wrap(result)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []ResultRows{{Name: "a", Rows: 3}, {Name: "b", Rows: 1}}
+	got := statsResultRows(rows.addTo(flux.Statistics{}))
+	if !cmp.Equal(want, got) {
+		t.Fatalf("unexpected row counts -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if n := totalRows(got); n != 4 {
+		t.Fatalf("expected 4 rows in total, got %d", n)
+	}
+}
+
+func TestLineResult_Response_ResultRows(t *testing.T) {
+	rows := []ResultRows{{Name: "a", Rows: 2}, {Name: "b", Rows: 5}}
+	var resp Response
+	if err := (lineResult{resultRows: rows}).response(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(rows, resp.ResultRows) {
+		t.Fatalf("unexpected row counts -want/+got:\n%s", cmp.Diff(rows, resp.ResultRows))
+	}
+	if resp.Rows != 7 {
+		t.Fatalf("expected 7 rows in total, got %d", resp.Rows)
+	}
+}
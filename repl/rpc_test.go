@@ -0,0 +1,269 @@
+package repl
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+type rpcResponse struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  interface{}     `json:"error"`
+}
+
+// serveTestService serves svc over an in-memory connection and returns a
+// function that sends a raw JSON-RPC request and decodes the response.
+func serveTestService(t *testing.T, svc *Service) func(req string) rpcResponse {
+	t.Helper()
+	server := rpc.NewServer()
+	if err := server.Register(svc); err != nil {
+		t.Fatal(err)
+	}
+	serverConn, clientConn := net.Pipe()
+	go server.ServeCodec(jsonrpc.NewServerCodec(serverConn))
+	t.Cleanup(func() { _ = clientConn.Close() })
+
+	dec := json.NewDecoder(clientConn)
+	return func(req string) rpcResponse {
+		t.Helper()
+		if _, err := clientConn.Write([]byte(req + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		var resp rpcResponse
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+}
+
+// newEchoService returns a Service whose DidOutput echoes its input.
+func newEchoService() *Service {
+	svc := &Service{c: make(chan InputRequest), res: make(chan lineResult)}
+	go func() {
+		for in := range svc.c {
+			svc.res <- lineResult{outputs: []string{in.Input}}
+		}
+	}()
+	return svc
+}
+
+func TestService_DidOutput_Params(t *testing.T) {
+	send := serveTestService(t, newEchoService())
+
+	resp := send(`{"method": "Service.DidOutput", "id": 1, "params": [{"input": "x = 1"}]}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	var result Response
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := result.Result, "x = 1"; got != want {
+		t.Fatalf("unexpected result -want/+got:\n\t- %s\n\t+ %s", want, got)
+	}
+	if want := []string{"x = 1"}; !cmp.Equal(want, result.Results) {
+		t.Fatalf("unexpected results -want/+got:\n%s", cmp.Diff(want, result.Results))
+	}
+
+	for _, req := range []string{
+		`{"method": "Service.DidOutput", "id": 2, "params": [{"text": "x = 1"}]}`,
+		`{"method": "Service.DidOutput", "id": 3, "params": [{}]}`,
+		`{"method": "Service.DidOutput", "id": 4, "params": [{"input": 1}]}`,
+		`{"method": "Service.DidOutput", "id": 5, "params": [{"input": "x", "title": "testing"}]}`,
+	} {
+		if resp := send(req); resp.Error == nil {
+			t.Errorf("expected error for malformed request %s", req)
+		}
+	}
+}
+
+func TestInputRequest_UnmarshalJSON(t *testing.T) {
+	var req InputRequest
+	if err := json.Unmarshal([]byte(`{"input": "1 + 1"}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.Input, "1 + 1"; got != want {
+		t.Fatalf("unexpected input -want/+got:\n\t- %s\n\t+ %s", want, got)
+	}
+
+	if err := json.Unmarshal([]byte(`{"input": "x", "labels": {"user": "a"}}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"user": "a"}; !cmp.Equal(want, req.Labels) {
+		t.Fatalf("unexpected labels -want/+got:\n%s", cmp.Diff(want, req.Labels))
+	}
+
+	if err := json.Unmarshal([]byte(`{"input": "x", "maxResultBytes": 1024}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.MaxResultBytes, int64(1024); got != want {
+		t.Fatalf("unexpected result byte limit: got %d want %d", got, want)
+	}
+
+	err := json.Unmarshal([]byte(`{"inptu": "1 + 1"}`), &req)
+	if got, want := errors.Code(err), codes.Invalid; got != want {
+		t.Fatalf("unexpected error code -want/+got:\n\t- %v\n\t+ %v", want, got)
+	}
+}
+
+func TestService_DidOutput_Error(t *testing.T) {
+	svc := &Service{c: make(chan InputRequest), res: make(chan lineResult)}
+	go func() {
+		for range svc.c {
+			svc.res <- lineResult{err: errors.New(codes.Invalid, "bad input")}
+		}
+	}()
+	send := serveTestService(t, svc)
+
+	resp := send(`{"method": "Service.DidOutput", "id": 1, "params": [{"input": "x +"}]}`)
+	if resp.Error == nil {
+		t.Fatal("expected an error response")
+	}
+}
+
+func TestService_DidOutput_LiveSources(t *testing.T) {
+	svc := &Service{c: make(chan InputRequest), res: make(chan lineResult)}
+	go func() {
+		for in := range svc.c {
+			var res lineResult
+			if strings.HasPrefix(in.Input, "from") {
+				res.liveSources = []string{"from", "sql.from"}
+			}
+			svc.res <- res
+		}
+	}()
+	send := serveTestService(t, svc)
+
+	resp := send(`{"method": "Service.DidOutput", "id": 1, "params": [{"input": "from(bucket: \"b\")"}]}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	var result Response
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"from", "sql.from"}; !cmp.Equal(want, result.LiveSources) {
+		t.Fatalf("unexpected live sources -want/+got:\n%s", cmp.Diff(want, result.LiveSources))
+	}
+
+	resp = send(`{"method": "Service.DidOutput", "id": 2, "params": [{"input": "1"}]}`)
+	if strings.Contains(string(resp.Result), "LiveSources") {
+		t.Fatalf("expected no live sources in %s", resp.Result)
+	}
+}
+
+func TestService_DidOutput_ErrorSpans(t *testing.T) {
+	spans := []ErrorSpan{{
+		Start:   Position{Line: 1, Column: 5},
+		End:     Position{Line: 1, Column: 8},
+		Message: "expected int but found string",
+	}}
+	svc := &Service{c: make(chan InputRequest), res: make(chan lineResult)}
+	go func() {
+		for range svc.c {
+			svc.res <- lineResult{
+				spans: spans,
+				err:   errors.New(codes.Invalid, "error @1:5-1:8: expected int but found string"),
+			}
+		}
+	}()
+	send := serveTestService(t, svc)
+
+	resp := send(`{"method": "Service.DidOutput", "id": 1, "params": [{"input": "1 + \"1\""}]}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	var result Response
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(spans, result.Errors) {
+		t.Fatalf("unexpected error spans -want/+got:\n%s", cmp.Diff(spans, result.Errors))
+	}
+}
+
+func TestService_DidOutput_FluxError(t *testing.T) {
+	detail := newFluxErrorDetail(errors.New(codes.Invalid, "error @1:6-1:7: expected RPAREN, got EOF"))
+	svc := &Service{c: make(chan InputRequest), res: make(chan lineResult)}
+	go func() {
+		for range svc.c {
+			svc.res <- lineResult{
+				spans:     detail.Errors,
+				fluxError: detail,
+				err:       errors.New(codes.Invalid, detail.Message),
+			}
+		}
+	}()
+	send := serveTestService(t, svc)
+
+	resp := send(`{"method": "Service.DidOutput", "id": 1, "params": [{"input": "f = ("}]}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	var result struct {
+		FluxError map[string]interface{}
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"code":    "invalid",
+		"message": "error @1:6-1:7: expected RPAREN, got EOF",
+		"errors": []interface{}{map[string]interface{}{
+			"start":   map[string]interface{}{"line": 1.0, "column": 6.0},
+			"end":     map[string]interface{}{"line": 1.0, "column": 7.0},
+			"message": "expected RPAREN, got EOF",
+		}},
+	}
+	if !cmp.Equal(want, result.FluxError) {
+		t.Fatalf("unexpected flux error -want/+got:\n%s", cmp.Diff(want, result.FluxError))
+	}
+}
+
+func TestLineResult_Response_FluxErrorWithoutLocation(t *testing.T) {
+	detail := newFluxErrorDetail(errors.New(codes.Invalid, "something went wrong"))
+	var resp Response
+	if err := (lineResult{fluxError: detail, err: errors.New(codes.Invalid, detail.Message)}).response(&resp); err != nil {
+		t.Fatalf("expected the error to be reported in the response, got %v", err)
+	}
+	if resp.FluxError != detail || len(resp.Errors) != 0 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestScopeHolder_Serve_EOF(t *testing.T) {
+	r := newTestHolder()
+	conn := rwCloser{
+		ReadCloser:  ioutil.NopCloser(strings.NewReader("")),
+		WriteCloser: nopWriteCloser{ioutil.Discard},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.serve(conn)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after EOF")
+	}
+}
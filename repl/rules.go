@@ -0,0 +1,125 @@
+package repl
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/plan"
+)
+
+// The kinds of planner rule reported by PlannerRules.
+const (
+	RuleLogical  = "logical"
+	RulePhysical = "physical"
+)
+
+// PlannerRule is a rule registered with the planner.
+type PlannerRule struct {
+	Name string `json:"name"`
+	// Kind is RuleLogical or RulePhysical.
+	Kind string `json:"kind"`
+	// Enabled reports whether the queries of the session are planned
+	// with the rule.
+	Enabled bool `json:"enabled"`
+}
+
+// PlannerRulesResponse is the response to Service.PlannerRules.
+type PlannerRulesResponse struct {
+	Rules []PlannerRule `json:"rules"`
+}
+
+// PlannerRuleRequest is the params object for Service.SetPlannerRule.
+type PlannerRuleRequest struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// PlannerRules reports the planner rules as described by
+// ScopeHolder.PlannerRules.
+func (s *Service) PlannerRules(req struct{}, resp *PlannerRulesResponse) error {
+	*resp = PlannerRulesResponse{Rules: s.r.PlannerRules()}
+	return nil
+}
+
+// SetPlannerRule enables or disables a planner rule for the session
+// as described by ScopeHolder.SetPlannerRule.
+func (s *Service) SetPlannerRule(req PlannerRuleRequest, resp *struct{}) error {
+	return s.r.SetPlannerRule(req.Name, req.Enabled)
+}
+
+// PlannerRules returns the logical and then the physical rules
+// registered with the planner, each sorted by name,
+// and whether the session plans its queries with them.
+func (r *ScopeHolder) PlannerRules() []PlannerRule {
+	var rules []PlannerRule
+	for _, name := range plan.LogicalRuleNames() {
+		rules = append(rules, PlannerRule{Name: name, Kind: RuleLogical, Enabled: !r.rules.isDisabled(name)})
+	}
+	for _, name := range plan.PhysicalRuleNames() {
+		rules = append(rules, PlannerRule{Name: name, Kind: RulePhysical, Enabled: !r.rules.isDisabled(name)})
+	}
+	return rules
+}
+
+// SetPlannerRule enables or disables the planner rule name for the
+// queries that the session plans from here on, including those of
+// Plan, so that a developer can see how a query is planned without
+// a rule they suspect. Every rule is enabled when the session starts.
+// A name that is not registered with the planner is not found.
+func (r *ScopeHolder) SetPlannerRule(name string, enabled bool) error {
+	if !isPlannerRule(name) {
+		return errors.Newf(codes.NotFound, "no planner rule named %q", name)
+	}
+	r.rules.set(name, enabled)
+	return nil
+}
+
+func isPlannerRule(name string) bool {
+	for _, names := range [][]string{plan.LogicalRuleNames(), plan.PhysicalRuleNames()} {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// plannerRules holds the planner rules that a session disabled.
+type plannerRules struct {
+	mu       sync.Mutex
+	disabled map[string]bool
+}
+
+func (p *plannerRules) set(name string, enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if enabled {
+		delete(p.disabled, name)
+		return
+	}
+	if p.disabled == nil {
+		p.disabled = make(map[string]bool)
+	}
+	p.disabled[name] = true
+}
+
+func (p *plannerRules) isDisabled(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.disabled[name]
+}
+
+// names returns the names of the disabled rules, sorted.
+func (p *plannerRules) names() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]string, 0, len(p.disabled))
+	for name := range p.disabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
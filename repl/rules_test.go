@@ -0,0 +1,118 @@
+package repl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/lang"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/stdlib/influxdata/influxdb"
+	"github.com/influxdata/flux/stdlib/universe"
+)
+
+// sortLimitSpec sorts and limits a range of a bucket,
+// which SortLimitRule plans as a single sortLimit.
+func sortLimitSpec() *flux.Spec {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	host := "http://localhost:8086"
+	return &flux.Spec{
+		Operations: []*flux.Operation{
+			{ID: "from0", Spec: &influxdb.FromOpSpec{
+				Org:    &influxdb.NameOrID{Name: "o"},
+				Bucket: influxdb.NameOrID{Name: "b"},
+				Host:   &host,
+			}},
+			{ID: "range1", Spec: &universe.RangeOpSpec{
+				Start:       flux.Time{IsRelative: true, Relative: -time.Hour},
+				Stop:        flux.Now,
+				TimeColumn:  "_time",
+				StartColumn: "_start",
+				StopColumn:  "_stop",
+			}},
+			{ID: "sort2", Spec: &universe.SortOpSpec{Columns: []string{"_value"}}},
+			{ID: "limit3", Spec: &universe.LimitOpSpec{N: 5}},
+		},
+		Edges: []flux.Edge{
+			{Parent: "from0", Child: "range1"},
+			{Parent: "range1", Child: "sort2"},
+			{Parent: "sort2", Child: "limit3"},
+		},
+		Now: now,
+	}
+}
+
+// planKinds returns the kinds of the nodes of the plan of spec.
+func planKinds(t *testing.T, r *ScopeHolder, spec *flux.Spec) map[plan.ProcedureKind]bool {
+	t.Helper()
+	program, err := r.compile(context.Background(), spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[plan.ProcedureKind]bool)
+	_ = program.(*lang.Program).PlanSpec.TopDownWalk(func(n plan.Node) error {
+		kinds[n.Kind()] = true
+		return nil
+	})
+	return kinds
+}
+
+func TestScopeHolder_SetPlannerRule(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithPlanCache(4)}} {
+		r := newTestHolder(opts...)
+		if kinds := planKinds(t, r, sortLimitSpec()); !kinds[universe.SortLimitKind] {
+			t.Fatalf("expected the sort and limit to be planned as a sortLimit, got %v", kinds)
+		}
+
+		if err := r.SetPlannerRule("SortLimitRule", false); err != nil {
+			t.Fatal(err)
+		}
+		kinds := planKinds(t, r, sortLimitSpec())
+		if kinds[universe.SortLimitKind] || !kinds[universe.SortKind] || !kinds[universe.LimitKind] {
+			t.Fatalf("expected the sort and limit to be planned apart without the rule, got %v", kinds)
+		}
+
+		if err := r.SetPlannerRule("SortLimitRule", true); err != nil {
+			t.Fatal(err)
+		}
+		if kinds := planKinds(t, r, sortLimitSpec()); !kinds[universe.SortLimitKind] {
+			t.Fatalf("expected the rule to apply once enabled again, got %v", kinds)
+		}
+	}
+}
+
+func TestScopeHolder_SetPlannerRule_NotFound(t *testing.T) {
+	err := newTestHolder().SetPlannerRule("NoSuchRule", false)
+	if got, want := errors.Code(err), codes.NotFound; got != want {
+		t.Fatalf("unexpected error code: got %v, want %v: %v", got, want, err)
+	}
+}
+
+func TestService_PlannerRules(t *testing.T) {
+	send := serveTestService(t, &Service{r: newTestHolder()})
+	if resp := send(`{"method": "Service.SetPlannerRule", "id": 1, "params": [{"name": "SortLimitRule", "enabled": false}]}`); resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	resp := send(`{"method": "Service.PlannerRules", "id": 2, "params": [{}]}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	var got PlannerRulesResponse
+	if err := json.Unmarshal(resp.Result, &got); err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]PlannerRule{}
+	for _, rule := range got.Rules {
+		found[rule.Name] = rule
+	}
+	if rule := found["SortLimitRule"]; rule.Kind != RulePhysical || rule.Enabled {
+		t.Errorf("expected SortLimitRule to be a disabled physical rule, got %+v", rule)
+	}
+	if rule := found["MergeGroupRule"]; rule.Kind != RuleLogical || !rule.Enabled {
+		t.Errorf("expected MergeGroupRule to be an enabled logical rule, got %+v", rule)
+	}
+}
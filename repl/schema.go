@@ -0,0 +1,823 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/parser"
+)
+
+// Schema describes the data in the buckets that queries read,
+// so that a query can be validated without a live source.
+type Schema struct {
+	Buckets []BucketSchema `json:"buckets"`
+}
+
+// BucketSchema describes the measurements in a bucket.
+type BucketSchema struct {
+	Name         string              `json:"name"`
+	Measurements []MeasurementSchema `json:"measurements"`
+}
+
+// MeasurementSchema describes the tags and fields of a measurement.
+type MeasurementSchema struct {
+	Name   string        `json:"name"`
+	Tags   []string      `json:"tags"`
+	Fields []FieldSchema `json:"fields"`
+}
+
+// FieldSchema describes a field and the type of its values,
+// which is one of "float", "int", "uint", "string" or "bool".
+type FieldSchema struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// fieldTypes are the types a field may have.
+var fieldTypes = map[string]bool{
+	"float":  true,
+	"int":    true,
+	"uint":   true,
+	"string": true,
+	"bool":   true,
+}
+
+// validate checks that every field of s has a supported type.
+func (s *Schema) validate() error {
+	for _, b := range s.Buckets {
+		for _, m := range b.Measurements {
+			for _, f := range m.Fields {
+				if !fieldTypes[f.Type] {
+					return errors.Newf(codes.Invalid, "field %q of measurement %q in bucket %q has unsupported type %q", f.Name, m.Name, b.Name, f.Type)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) bucket(name string) *BucketSchema {
+	for i := range s.Buckets {
+		if s.Buckets[i].Name == name {
+			return &s.Buckets[i]
+		}
+	}
+	return nil
+}
+
+// WithSchema sets the schema that Validate checks queries against
+// when a request does not supply its own.
+func WithSchema(schema Schema) Option {
+	return option(func(r *ScopeHolder) {
+		r.schema = &schema
+	})
+}
+
+// ValidateRequest is the request for Service.Validate.
+// The schema replaces that of the session when it is set.
+type ValidateRequest struct {
+	Input  string  `json:"input"`
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// ValidateResponse is the response to Service.Validate.
+type ValidateResponse struct {
+	Errors []ErrorSpan `json:"errors"`
+}
+
+// Validate checks the input against a schema without evaluating it.
+func (s *Service) Validate(req ValidateRequest, resp *ValidateResponse) error {
+	schema := req.Schema
+	if schema == nil {
+		schema = s.r.schema
+	}
+	spans, err := s.r.Validate(s.r.ctx, req.Input, schema)
+	if err != nil {
+		return err
+	}
+	*resp = ValidateResponse{Errors: spans}
+	return nil
+}
+
+// Validate type checks t and checks the columns that it references
+// in the data read by from() against schema, without evaluating t
+// or reading from a live source. It returns every problem that it
+// finds, or no spans if t is valid.
+//
+// The columns of a stream are followed through the transformations
+// whose output is known, such as filter(), map(), keep(), drop(),
+// rename() and pivot() on _field. Filtering on _measurement or _field
+// limits the columns to those of the measurements and fields that
+// are matched. Checking a stream stops at a function whose output
+// columns are not known.
+func (r *ScopeHolder) Validate(ctx context.Context, t string, schema *Schema) ([]ErrorSpan, error) {
+	if schema == nil {
+		return nil, errors.New(codes.Invalid, "no schema to validate against")
+	}
+	if err := schema.validate(); err != nil {
+		return nil, err
+	}
+
+	r.evalMu.Lock()
+	scope := r.nestScope()
+	analyzer, err := r.analyzerFor(scope, t)
+	if err != nil {
+		r.evalMu.Unlock()
+		return nil, err
+	}
+	_, fluxError, err := r.analyzeFB(ctx, analyzer, t)
+	r.discardAnalyzer(scope, analyzer, err)
+	r.evalMu.Unlock()
+	if fluxError != nil {
+		return fluxErrorSpans(fluxError), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := newSchemaChecker(schema, r.defaultBucket)
+	c.checkPackage(parser.ParseSource(t))
+	return c.spans, nil
+}
+
+// schemaChecker checks the column references of the streams in
+// a package against a schema.
+type schemaChecker struct {
+	schema        *Schema
+	defaultBucket string
+
+	// streams holds the columns of the streams that are bound
+	// to identifiers at the top level of the package.
+	streams map[string]*streamColumns
+	spans   []ErrorSpan
+}
+
+func newSchemaChecker(schema *Schema, defaultBucket string) *schemaChecker {
+	return &schemaChecker{
+		schema:        schema,
+		defaultBucket: defaultBucket,
+		streams:       make(map[string]*streamColumns),
+	}
+}
+
+func (c *schemaChecker) errorf(n ast.Node, format string, a ...interface{}) {
+	loc := n.Location()
+	c.spans = append(c.spans, ErrorSpan{
+		Start:   Position{Line: loc.Start.Line, Column: loc.Start.Column},
+		End:     Position{Line: loc.End.Line, Column: loc.End.Column},
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// checkPackage checks every stream in pkg that starts with from().
+func (c *schemaChecker) checkPackage(pkg *ast.Package) {
+	// The argument of a pipe is checked along with the pipe,
+	// so only the outermost pipe of each chain is a root.
+	piped := make(map[ast.Node]bool)
+	ast.Visit(pkg, func(n ast.Node) {
+		pipe, ok := n.(*ast.PipeExpression)
+		if !ok {
+			return
+		}
+		arg := pipe.Argument
+		piped[arg] = true
+		for paren, ok := arg.(*ast.ParenExpression); ok; paren, ok = arg.(*ast.ParenExpression) {
+			arg = paren.Expression
+			piped[arg] = true
+		}
+	})
+
+	// Streams bound at the top level are checked in order,
+	// so that the streams after them can refer to them.
+	for _, f := range pkg.Files {
+		for _, stmt := range f.Body {
+			if va, ok := stmt.(*ast.VariableAssignment); ok && isStream(va.Init) {
+				piped[va.Init] = true
+				if s := c.stream(va.Init); s != nil {
+					c.streams[va.ID.Name] = s
+				}
+			}
+		}
+	}
+
+	ast.Visit(pkg, func(n ast.Node) {
+		if e, ok := n.(ast.Expression); ok && isStream(e) && !piped[n] {
+			c.stream(e)
+		}
+	})
+	sort.SliceStable(c.spans, func(i, j int) bool {
+		a, b := c.spans[i].Start, c.spans[j].Start
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+}
+
+// isStream reports whether e is a pipe or a call to from().
+func isStream(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.PipeExpression:
+		return true
+	case *ast.CallExpression:
+		return isFrom(e)
+	}
+	return false
+}
+
+// isFrom reports whether call is a call to from() or influxdb.from().
+func isFrom(call *ast.CallExpression) bool {
+	switch callee := call.Callee.(type) {
+	case *ast.Identifier:
+		return callee.Name == "from"
+	case *ast.MemberExpression:
+		pkg, ok := callee.Object.(*ast.Identifier)
+		return ok && pkg.Name == "influxdb" && callee.Property.Key() == "from"
+	}
+	return false
+}
+
+// stream checks the stream e and returns its columns,
+// or nil if they are not known.
+func (c *schemaChecker) stream(e ast.Expression) *streamColumns {
+	switch e := e.(type) {
+	case *ast.ParenExpression:
+		return c.stream(e.Expression)
+	case *ast.Identifier:
+		return c.streams[e.Name]
+	case *ast.CallExpression:
+		if isFrom(e) {
+			return c.from(e)
+		}
+	case *ast.PipeExpression:
+		if in := c.stream(e.Argument); in != nil {
+			return c.call(in, e.Call)
+		}
+	}
+	return nil
+}
+
+// from returns the columns of the bucket read by a call to from().
+func (c *schemaChecker) from(call *ast.CallExpression) *streamColumns {
+	args := callArgs(call)
+	name := c.defaultBucket
+	if e, ok := args["bucket"]; ok {
+		lit, ok := e.(*ast.StringLiteral)
+		if !ok {
+			return nil
+		}
+		name = lit.Value
+	} else if _, ok := args["bucketID"]; ok {
+		return nil
+	}
+	if name == "" {
+		return nil
+	}
+	b := c.schema.bucket(name)
+	if b == nil {
+		c.errorf(call, "bucket %q does not exist in the schema", name)
+		return nil
+	}
+	return newStreamColumns(b)
+}
+
+// preservingFunctions are the functions whose output has the same
+// columns as their input, along with their parameters that name a
+// column or list columns.
+var preservingFunctions = map[string][]string{
+	"aggregateWindow": {"column"},
+	"bottom":          {"columns"},
+	"fill":            {"column"},
+	"first":           {"column"},
+	"group":           {"columns"},
+	"last":            {"column"},
+	"limit":           nil,
+	"max":             {"column"},
+	"min":             {"column"},
+	"range":           nil,
+	"sample":          {"column"},
+	"sort":            {"columns"},
+	"tail":            nil,
+	"timeShift":       {"columns"},
+	"top":             {"columns"},
+	"unique":          {"column"},
+	"window":          nil,
+	"yield":           nil,
+}
+
+// aggregateFunctions are the functions, along with their column
+// parameters, whose output columns depend on the group key,
+// which is not followed.
+var aggregateFunctions = map[string][]string{
+	"count":    {"column"},
+	"distinct": {"column"},
+	"integral": {"column"},
+	"mean":     {"column"},
+	"median":   {"column"},
+	"mode":     {"column"},
+	"spread":   {"column"},
+	"stddev":   {"column"},
+	"sum":      {"column"},
+}
+
+// call checks a function that is called on a stream with the columns in
+// and returns the columns of its output, or nil if they are not known.
+func (c *schemaChecker) call(in *streamColumns, call *ast.CallExpression) *streamColumns {
+	id, ok := call.Callee.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	args := callArgs(call)
+	if params, ok := preservingFunctions[id.Name]; ok {
+		c.checkColumnArgs(in, args, params)
+		return in
+	}
+	if params, ok := aggregateFunctions[id.Name]; ok {
+		c.checkColumnArgs(in, args, params)
+		return nil
+	}
+
+	switch id.Name {
+	case "filter":
+		return c.filter(in, args)
+	case "map":
+		return c.mapColumns(in, args)
+	case "keep":
+		cols, ok := c.columnList(in, args["columns"])
+		if !ok {
+			return nil
+		}
+		return in.keep(cols)
+	case "drop":
+		cols, ok := c.columnList(in, args["columns"])
+		if !ok {
+			return nil
+		}
+		return in.drop(cols)
+	case "rename":
+		return c.rename(in, args)
+	case "pivot":
+		return c.pivot(in, args)
+	}
+	return nil
+}
+
+// checkColumnArgs checks the columns named by the given parameters.
+func (c *schemaChecker) checkColumnArgs(in *streamColumns, args map[string]ast.Expression, params []string) {
+	for _, param := range params {
+		if e, ok := args[param]; ok {
+			c.columnList(in, e)
+		}
+	}
+}
+
+// columnList checks the column or list of columns e and returns
+// their names. It returns false if e is not made of literals.
+func (c *schemaChecker) columnList(in *streamColumns, e ast.Expression) ([]string, bool) {
+	var lits []*ast.StringLiteral
+	switch e := e.(type) {
+	case *ast.StringLiteral:
+		lits = append(lits, e)
+	case *ast.ArrayExpression:
+		for _, elem := range e.Elements {
+			lit, ok := elem.(*ast.StringLiteral)
+			if !ok {
+				return nil, false
+			}
+			lits = append(lits, lit)
+		}
+	default:
+		return nil, false
+	}
+
+	names := make([]string, 0, len(lits))
+	for _, lit := range lits {
+		c.checkColumn(in, lit.Value, lit)
+		names = append(names, lit.Value)
+	}
+	return names, true
+}
+
+func (c *schemaChecker) checkColumn(in *streamColumns, name string, n ast.Node) {
+	if _, ok := in.cols[name]; !ok {
+		c.errorf(n, "column %q does not exist", name)
+	}
+}
+
+// filter checks the predicate of filter() and returns the columns of
+// the measurements and fields the predicate matches.
+func (c *schemaChecker) filter(in *streamColumns, args map[string]ast.Expression) *streamColumns {
+	fn, param, ok := rowFunction(args["fn"])
+	if !ok {
+		return in
+	}
+
+	var measurements, fields []string
+	hasOr := false
+	ast.Visit(fn.Body, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.LogicalExpression:
+			if n.Operator == ast.OrOperator {
+				hasOr = true
+			}
+		case *ast.BinaryExpression:
+			if n.Operator != ast.EqualOperator {
+				return
+			}
+			col, e := columnComparison(n, param)
+			lit, ok := e.(*ast.StringLiteral)
+			if !ok {
+				return
+			}
+			switch col {
+			case "_measurement":
+				if !in.bucketHasMeasurement(lit.Value) {
+					c.errorf(lit, "measurement %q does not exist in bucket %q", lit.Value, in.bucket.Name)
+					return
+				}
+				measurements = append(measurements, lit.Value)
+			case "_field":
+				if !in.bucketHasField(lit.Value) {
+					c.errorf(lit, "field %q does not exist in bucket %q", lit.Value, in.bucket.Name)
+					return
+				}
+				fields = append(fields, lit.Value)
+			}
+		}
+	})
+
+	out := in.narrow(measurements, fields)
+	// A row may match another side of an or, so the references
+	// are checked against the columns of the whole input.
+	checked := out
+	if hasOr {
+		checked = in
+	}
+	c.checkReferences(checked, fn, param)
+	return out
+}
+
+// mapColumns checks the function of map() and returns
+// the columns of the records it returns.
+func (c *schemaChecker) mapColumns(in *streamColumns, args map[string]ast.Expression) *streamColumns {
+	fn, param, ok := rowFunction(args["fn"])
+	if !ok {
+		return nil
+	}
+	c.checkReferences(in, fn, param)
+
+	obj, ok := returnedObject(fn.Body)
+	if !ok {
+		return nil
+	}
+	cols := make(map[string]string)
+	if obj.With != nil {
+		if obj.With.Name != param {
+			return nil
+		}
+		for name, typ := range in.cols {
+			cols[name] = typ
+		}
+	}
+	for _, p := range obj.Properties {
+		cols[p.Key.Key()] = literalType(p.Value)
+	}
+	return in.withColumns(cols)
+}
+
+// rename checks the columns renamed by rename() and
+// returns the columns with their new names.
+func (c *schemaChecker) rename(in *streamColumns, args map[string]ast.Expression) *streamColumns {
+	obj, ok := args["columns"].(*ast.ObjectExpression)
+	if !ok {
+		return nil
+	}
+	cols := make(map[string]string, len(in.cols))
+	for name, typ := range in.cols {
+		cols[name] = typ
+	}
+	for _, p := range obj.Properties {
+		lit, ok := p.Value.(*ast.StringLiteral)
+		if !ok {
+			return nil
+		}
+		from := p.Key.Key()
+		c.checkColumn(in, from, p.Key)
+		typ := cols[from]
+		delete(cols, from)
+		cols[lit.Value] = typ
+	}
+	return in.withColumns(cols)
+}
+
+// pivot checks the columns used by pivot() and returns the columns
+// of its output when it turns each field into a column.
+func (c *schemaChecker) pivot(in *streamColumns, args map[string]ast.Expression) *streamColumns {
+	_, okRow := c.columnList(in, args["rowKey"])
+	columnKey, okCol := c.columnList(in, args["columnKey"])
+	valueColumn, okVal := c.columnList(in, args["valueColumn"])
+	if !okRow || !okCol || !okVal || !in.raw {
+		return nil
+	}
+	if len(columnKey) != 1 || columnKey[0] != "_field" || len(valueColumn) != 1 || valueColumn[0] != "_value" {
+		return nil
+	}
+	return in.pivot()
+}
+
+// checkReferences checks the columns of the records passed
+// to fn as param that fn refers to.
+func (c *schemaChecker) checkReferences(in *streamColumns, fn *ast.FunctionExpression, param string) {
+	// A column that is tested with exists may be missing.
+	tested := make(map[ast.Node]bool)
+	ast.Visit(fn.Body, func(n ast.Node) {
+		if u, ok := n.(*ast.UnaryExpression); ok && u.Operator == ast.ExistsOperator {
+			tested[u.Argument] = true
+		}
+	})
+	ast.Visit(fn.Body, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.MemberExpression:
+			if obj, ok := n.Object.(*ast.Identifier); ok && obj.Name == param && !tested[n] {
+				c.checkColumn(in, n.Property.Key(), n)
+			}
+		case *ast.BinaryExpression:
+			c.checkComparison(in, n, param)
+		}
+	})
+}
+
+// checkComparison checks that a column compared with a literal
+// has the same type as the literal.
+func (c *schemaChecker) checkComparison(in *streamColumns, n *ast.BinaryExpression, param string) {
+	switch n.Operator {
+	case ast.EqualOperator, ast.NotEqualOperator,
+		ast.LessThanOperator, ast.LessThanEqualOperator,
+		ast.GreaterThanOperator, ast.GreaterThanEqualOperator:
+	default:
+		return
+	}
+	col, lit := columnComparison(n, param)
+	if col == "" {
+		return
+	}
+	colType, litType := in.cols[col], literalType(lit)
+	if colType == "" || litType == "" || colType == litType || isNumeric(colType) && isNumeric(litType) {
+		return
+	}
+	c.errorf(n, "cannot compare column %q of type %s with a value of type %s", col, colType, litType)
+}
+
+func isNumeric(typ string) bool {
+	return typ == "float" || typ == "int" || typ == "uint"
+}
+
+// columnComparison returns the column of param and the literal that n
+// compares, in either order. It returns an empty column if n does not
+// compare a column of param with a literal.
+func columnComparison(n *ast.BinaryExpression, param string) (string, ast.Expression) {
+	col, other := memberOf(n.Left, param), n.Right
+	if col == "" {
+		col, other = memberOf(n.Right, param), n.Left
+	}
+	if col == "" || literalType(other) == "" {
+		return "", nil
+	}
+	return col, other
+}
+
+// memberOf returns the property of param that e refers to, if any.
+func memberOf(e ast.Expression, param string) string {
+	m, ok := e.(*ast.MemberExpression)
+	if !ok {
+		return ""
+	}
+	if obj, ok := m.Object.(*ast.Identifier); !ok || obj.Name != param {
+		return ""
+	}
+	return m.Property.Key()
+}
+
+// literalType returns the Flux type of the literal e,
+// or an empty string if e is not a literal.
+func literalType(e ast.Node) string {
+	switch e.(type) {
+	case *ast.StringLiteral:
+		return "string"
+	case *ast.IntegerLiteral:
+		return "int"
+	case *ast.UnsignedIntegerLiteral:
+		return "uint"
+	case *ast.FloatLiteral:
+		return "float"
+	case *ast.BooleanLiteral:
+		return "bool"
+	case *ast.DateTimeLiteral:
+		return "time"
+	}
+	return ""
+}
+
+// rowFunction returns the function e and the name of the
+// parameter that it receives each row as.
+func rowFunction(e ast.Expression) (*ast.FunctionExpression, string, bool) {
+	fn, ok := e.(*ast.FunctionExpression)
+	if !ok || len(fn.Params) == 0 {
+		return nil, "", false
+	}
+	return fn, fn.Params[0].Key.Key(), true
+}
+
+// returnedObject returns the object that the body of a function
+// returns, if it is an object expression.
+func returnedObject(body ast.Node) (*ast.ObjectExpression, bool) {
+	switch body := body.(type) {
+	case *ast.ObjectExpression:
+		return body, true
+	case *ast.ParenExpression:
+		return returnedObject(body.Expression)
+	case *ast.Block:
+		if n := len(body.Body); n > 0 {
+			if ret, ok := body.Body[n-1].(*ast.ReturnStatement); ok {
+				return returnedObject(ret.Argument)
+			}
+		}
+	}
+	return nil, false
+}
+
+// callArgs returns the arguments of call by name.
+func callArgs(call *ast.CallExpression) map[string]ast.Expression {
+	args := make(map[string]ast.Expression)
+	if len(call.Arguments) == 0 {
+		return args
+	}
+	obj, ok := call.Arguments[0].(*ast.ObjectExpression)
+	if !ok {
+		return args
+	}
+	for _, p := range obj.Properties {
+		if p.Value != nil {
+			args[p.Key.Key()] = p.Value
+		}
+	}
+	return args
+}
+
+// streamColumns is what is known about the columns of a stream.
+// It is not modified once it has been created, since a stream
+// bound to an identifier may be used more than once.
+type streamColumns struct {
+	bucket *BucketSchema
+	// measurements are those the rows of the stream may belong to.
+	measurements []MeasurementSchema
+	// fields limits the fields of the measurements when it is not nil.
+	fields map[string]bool
+	// pivoted reports whether each field is in a column of its own.
+	pivoted bool
+	// raw reports whether the columns are still those read
+	// from the bucket, so that they follow the measurements
+	// and fields of the stream.
+	raw bool
+	// cols maps each column to its type, or to an empty string
+	// if its type is not known.
+	cols map[string]string
+}
+
+func newStreamColumns(b *BucketSchema) *streamColumns {
+	s := &streamColumns{bucket: b, measurements: b.Measurements, raw: true}
+	s.cols = s.rawColumns()
+	return s
+}
+
+// rawColumns returns the columns read from the bucket for the
+// measurements and fields of s.
+func (s *streamColumns) rawColumns() map[string]string {
+	cols := map[string]string{
+		"_start":       "time",
+		"_stop":        "time",
+		"_time":        "time",
+		"_measurement": "string",
+	}
+	if !s.pivoted {
+		cols["_field"] = "string"
+	}
+	valueType, first := "", true
+	for _, m := range s.measurements {
+		for _, tag := range m.Tags {
+			cols[tag] = "string"
+		}
+		for _, f := range m.Fields {
+			if s.fields != nil && !s.fields[f.Name] {
+				continue
+			}
+			if s.pivoted {
+				if typ, ok := cols[f.Name]; ok && typ != f.Type {
+					cols[f.Name] = ""
+				} else {
+					cols[f.Name] = f.Type
+				}
+				continue
+			}
+			if first {
+				valueType, first = f.Type, false
+			} else if valueType != f.Type {
+				valueType = ""
+			}
+		}
+	}
+	if !s.pivoted {
+		cols["_value"] = valueType
+	}
+	return cols
+}
+
+func (s *streamColumns) bucketHasMeasurement(name string) bool {
+	for _, m := range s.bucket.Measurements {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *streamColumns) bucketHasField(name string) bool {
+	for _, m := range s.bucket.Measurements {
+		for _, f := range m.Fields {
+			if f.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// narrow returns the columns of the rows of s that belong to one of
+// the measurements and have one of the fields. An empty list of
+// measurements or fields does not narrow them.
+func (s *streamColumns) narrow(measurements, fields []string) *streamColumns {
+	if !s.raw || len(measurements) == 0 && len(fields) == 0 {
+		return s
+	}
+	out := *s
+	if len(measurements) > 0 {
+		out.measurements = nil
+		for _, m := range s.measurements {
+			for _, name := range measurements {
+				if m.Name == name {
+					out.measurements = append(out.measurements, m)
+					break
+				}
+			}
+		}
+	}
+	if len(fields) > 0 {
+		out.fields = make(map[string]bool)
+		for _, name := range fields {
+			if s.fields == nil || s.fields[name] {
+				out.fields[name] = true
+			}
+		}
+	}
+	out.cols = out.rawColumns()
+	return &out
+}
+
+// pivot returns the columns of s once each field is in a column of its own.
+func (s *streamColumns) pivot() *streamColumns {
+	out := *s
+	out.pivoted = true
+	out.cols = out.rawColumns()
+	return &out
+}
+
+// withColumns returns a stream with the columns cols, which no
+// longer follow the measurements and fields of s.
+func (s *streamColumns) withColumns(cols map[string]string) *streamColumns {
+	out := *s
+	out.raw = false
+	out.cols = cols
+	return &out
+}
+
+func (s *streamColumns) keep(names []string) *streamColumns {
+	cols := make(map[string]string, len(names))
+	for _, name := range names {
+		if typ, ok := s.cols[name]; ok {
+			cols[name] = typ
+		}
+	}
+	return s.withColumns(cols)
+}
+
+func (s *streamColumns) drop(names []string) *streamColumns {
+	cols := make(map[string]string, len(s.cols))
+	for name, typ := range s.cols {
+		cols[name] = typ
+	}
+	for _, name := range names {
+		delete(cols, name)
+	}
+	return s.withColumns(cols)
+}
@@ -0,0 +1,290 @@
+package repl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+var testSchema = Schema{
+	Buckets: []BucketSchema{{
+		Name: "telegraf",
+		Measurements: []MeasurementSchema{
+			{
+				Name: "cpu",
+				Tags: []string{"host", "cpu"},
+				Fields: []FieldSchema{
+					{Name: "usage_user", Type: "float"},
+					{Name: "usage_system", Type: "float"},
+				},
+			},
+			{
+				Name: "mem",
+				Tags: []string{"host", "region"},
+				Fields: []FieldSchema{
+					{Name: "used", Type: "int"},
+				},
+			},
+		},
+	}},
+}
+
+// The helpers below build the AST of a query, since the parser
+// is not needed to check one against a schema.
+
+func astString(v string) *ast.StringLiteral { return &ast.StringLiteral{Value: v} }
+
+func astProperty(key string, v ast.Expression) *ast.Property {
+	return &ast.Property{Key: &ast.Identifier{Name: key}, Value: v}
+}
+
+func astObject(props ...*ast.Property) *ast.ObjectExpression {
+	return &ast.ObjectExpression{Properties: props}
+}
+
+func astCall(name string, props ...*ast.Property) *ast.CallExpression {
+	call := &ast.CallExpression{Callee: &ast.Identifier{Name: name}}
+	if len(props) > 0 {
+		call.Arguments = []ast.Expression{astObject(props...)}
+	}
+	return call
+}
+
+func astPipe(arg ast.Expression, calls ...*ast.CallExpression) ast.Expression {
+	for _, call := range calls {
+		arg = &ast.PipeExpression{Argument: arg, Call: call}
+	}
+	return arg
+}
+
+func astColumns(names ...string) *ast.ArrayExpression {
+	arr := &ast.ArrayExpression{}
+	for _, name := range names {
+		arr.Elements = append(arr.Elements, astString(name))
+	}
+	return arr
+}
+
+// astRow refers to a column of the row passed to a function as r.
+func astRow(col string) *ast.MemberExpression {
+	return &ast.MemberExpression{Object: &ast.Identifier{Name: "r"}, Property: &ast.Identifier{Name: col}}
+}
+
+func astEqual(l, r ast.Expression) *ast.BinaryExpression {
+	return &ast.BinaryExpression{Operator: ast.EqualOperator, Left: l, Right: r}
+}
+
+func astLogical(op ast.LogicalOperatorKind, l, r ast.Expression) *ast.LogicalExpression {
+	return &ast.LogicalExpression{Operator: op, Left: l, Right: r}
+}
+
+// astFn is a function of r, as passed to filter() and map().
+func astFn(body ast.Node) *ast.FunctionExpression {
+	return &ast.FunctionExpression{
+		Params: []*ast.Property{{Key: &ast.Identifier{Name: "r"}}},
+		Body:   body,
+	}
+}
+
+func astFrom(bucket string) *ast.CallExpression {
+	return astCall("from", astProperty("bucket", astString(bucket)))
+}
+
+func astPackage(stmts ...ast.Statement) *ast.Package {
+	return &ast.Package{Files: []*ast.File{{Body: stmts}}}
+}
+
+func astExpr(e ast.Expression) ast.Statement {
+	return &ast.ExpressionStatement{Expression: e}
+}
+
+func TestSchemaChecker(t *testing.T) {
+	filter := func(pred ast.Expression) *ast.CallExpression {
+		return astCall("filter", astProperty("fn", astFn(pred)))
+	}
+	cpu := astEqual(astRow("_measurement"), astString("cpu"))
+
+	for _, tt := range []struct {
+		name string
+		pkg  *ast.Package
+		want []string
+	}{
+		{
+			name: "valid",
+			pkg: astPackage(astExpr(astPipe(astFrom("telegraf"),
+				astCall("range", astProperty("start", &ast.IntegerLiteral{Value: -1})),
+				filter(astLogical(ast.AndOperator, cpu, astEqual(astRow("host"), astString("a")))),
+				astCall("keep", astProperty("columns", astColumns("_time", "_value", "cpu"))),
+				astCall("group", astProperty("columns", astColumns("cpu"))),
+			))),
+		},
+		{
+			name: "missing bucket",
+			pkg:  astPackage(astExpr(astPipe(astFrom("nope"), filter(cpu)))),
+			want: []string{`bucket "nope" does not exist in the schema`},
+		},
+		{
+			name: "missing measurement",
+			pkg: astPackage(astExpr(astPipe(astFrom("telegraf"),
+				filter(astEqual(astRow("_measurement"), astString("disk"))),
+			))),
+			want: []string{`measurement "disk" does not exist in bucket "telegraf"`},
+		},
+		{
+			name: "missing field",
+			pkg: astPackage(astExpr(astPipe(astFrom("telegraf"),
+				filter(astEqual(astString("free"), astRow("_field"))),
+			))),
+			want: []string{`field "free" does not exist in bucket "telegraf"`},
+		},
+		{
+			name: "missing column",
+			pkg: astPackage(astExpr(astPipe(astFrom("telegraf"),
+				filter(astEqual(astRow("datacenter"), astString("a"))),
+			))),
+			want: []string{`column "datacenter" does not exist`},
+		},
+		{
+			name: "narrowed by measurement",
+			pkg: astPackage(astExpr(astPipe(astFrom("telegraf"),
+				filter(astLogical(ast.AndOperator, cpu, astEqual(astRow("region"), astString("eu")))),
+			))),
+			want: []string{`column "region" does not exist`},
+		},
+		{
+			name: "or is not narrowed",
+			pkg: astPackage(astExpr(astPipe(astFrom("telegraf"),
+				filter(astLogical(ast.OrOperator, cpu, astEqual(astRow("region"), astString("eu")))),
+			))),
+		},
+		{
+			name: "exists",
+			pkg: astPackage(astExpr(astPipe(astFrom("telegraf"),
+				filter(cpu),
+				filter(&ast.UnaryExpression{Operator: ast.ExistsOperator, Argument: astRow("region")}),
+			))),
+		},
+		{
+			name: "after keep",
+			pkg: astPackage(astExpr(astPipe(astFrom("telegraf"),
+				astCall("keep", astProperty("columns", astColumns("_time", "_value"))),
+				astCall("group", astProperty("columns", astColumns("host"))),
+			))),
+			want: []string{`column "host" does not exist`},
+		},
+		{
+			name: "drop and rename",
+			pkg: astPackage(astExpr(astPipe(astFrom("telegraf"),
+				astCall("drop", astProperty("columns", astColumns("cpu", "gpu"))),
+				astCall("rename", astProperty("columns", astObject(astProperty("host", astString("server"))))),
+				filter(astEqual(astRow("server"), astString("a"))),
+				filter(astEqual(astRow("host"), astString("a"))),
+			))),
+			want: []string{
+				`column "gpu" does not exist`,
+				`column "host" does not exist`,
+			},
+		},
+		{
+			name: "pivot and map",
+			pkg: astPackage(astExpr(astPipe(astFrom("telegraf"),
+				filter(astEqual(astRow("_field"), astString("usage_user"))),
+				astCall("pivot",
+					astProperty("rowKey", astColumns("_time")),
+					astProperty("columnKey", astColumns("_field")),
+					astProperty("valueColumn", astString("_value")),
+				),
+				astCall("map", astProperty("fn", astFn(&ast.ParenExpression{Expression: &ast.ObjectExpression{
+					With: &ast.Identifier{Name: "r"},
+					Properties: []*ast.Property{
+						astProperty("user", astRow("usage_user")),
+						astProperty("system", astRow("usage_system")),
+					},
+				}}))),
+				filter(astEqual(astRow("user"), &ast.FloatLiteral{Value: 1})),
+				filter(astEqual(astRow("_value"), &ast.FloatLiteral{Value: 1})),
+			))),
+			want: []string{
+				`column "usage_system" does not exist`,
+				`column "_value" does not exist`,
+			},
+		},
+		{
+			name: "types",
+			pkg: astPackage(astExpr(astPipe(astFrom("telegraf"),
+				filter(astEqual(astRow("host"), &ast.IntegerLiteral{Value: 1})),
+				filter(astEqual(astRow("_field"), astString("usage_user"))),
+				filter(astEqual(astRow("_value"), astString("high"))),
+				filter(astEqual(astRow("_value"), &ast.IntegerLiteral{Value: 1})),
+			))),
+			want: []string{
+				`cannot compare column "host" of type string with a value of type int`,
+				`cannot compare column "_value" of type float with a value of type string`,
+			},
+		},
+		{
+			name: "binding",
+			pkg: astPackage(
+				&ast.VariableAssignment{ID: &ast.Identifier{Name: "data"}, Init: astPipe(astFrom("telegraf"), filter(cpu))},
+				astExpr(astPipe(&ast.Identifier{Name: "data"}, filter(astEqual(astRow("region"), astString("eu"))))),
+				astExpr(astPipe(&ast.Identifier{Name: "data"}, filter(astEqual(astRow("host"), astString("a"))))),
+			),
+			want: []string{`column "region" does not exist`},
+		},
+		{
+			name: "unknown function",
+			pkg: astPackage(astExpr(astPipe(astFrom("telegraf"),
+				astCall("mean"),
+				filter(astEqual(astRow("anything"), astString("a"))),
+			))),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newSchemaChecker(&testSchema, "")
+			c.checkPackage(tt.pkg)
+			var got []string
+			for _, span := range c.spans {
+				got = append(got, span.Message)
+			}
+			if !cmp.Equal(tt.want, got) {
+				t.Fatalf("unexpected errors -want/+got:\n%s", cmp.Diff(tt.want, got))
+			}
+		})
+	}
+}
+
+func TestSchemaChecker_DefaultBucket(t *testing.T) {
+	pkg := astPackage(astExpr(astPipe(astCall("from"),
+		astCall("filter", astProperty("fn", astFn(astEqual(astRow("datacenter"), astString("a"))))),
+	)))
+	c := newSchemaChecker(&testSchema, "telegraf")
+	c.checkPackage(pkg)
+	if len(c.spans) != 1 || c.spans[0].Message != `column "datacenter" does not exist` {
+		t.Fatalf("unexpected errors: %v", c.spans)
+	}
+}
+
+func TestScopeHolder_Validate_Schema(t *testing.T) {
+	r := newTestHolder()
+	if _, err := r.Validate(r.ctx, `from(bucket: "telegraf")`, nil); err == nil {
+		t.Fatal("expected an error without a schema")
+	} else if got, want := errors.Code(err), codes.Invalid; got != want {
+		t.Fatalf("unexpected error code: got %v, want %v", got, want)
+	}
+
+	schema := Schema{Buckets: []BucketSchema{{
+		Name: "telegraf",
+		Measurements: []MeasurementSchema{{
+			Name:   "cpu",
+			Fields: []FieldSchema{{Name: "usage", Type: "decimal"}},
+		}},
+	}}}
+	if _, err := r.Validate(r.ctx, `from(bucket: "telegraf")`, &schema); err == nil {
+		t.Fatal("expected an error for an unsupported field type")
+	} else if got, want := errors.Code(err), codes.Invalid; got != want {
+		t.Fatalf("unexpected error code: got %v, want %v", got, want)
+	}
+}
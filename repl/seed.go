@@ -0,0 +1,46 @@
+package repl
+
+import (
+	"context"
+	"math"
+
+	"github.com/influxdata/flux/dependency"
+	"github.com/influxdata/flux/execute"
+)
+
+// WithRandomSeed makes the functions that choose at random, such as
+// sample with a negative pos, make the same choices every time a query
+// runs, so that the output of the session can be compared against a
+// golden file. The choices depend on the seed and on the operations of
+// the query, so a query only repeats its output under the same seed.
+//
+// By default, the choices differ from one run to the next.
+func WithRandomSeed(seed int64) Option {
+	return option(func(r *ScopeHolder) {
+		r.randomSeed = &seed
+	})
+}
+
+// executionDependencies returns the execution dependencies
+// for the evaluation of a line.
+func (r *ScopeHolder) executionDependencies() execute.ExecutionDependencies {
+	deps := execute.DefaultExecutionDependencies()
+	deps.Seed = r.randomSeed
+	return deps
+}
+
+// injectSeed injects the random seed of the session into the
+// execution dependencies of a query, if the session has one. The
+// returned function must be called once the query has finished.
+func (r *ScopeHolder) injectSeed(ctx context.Context) (context.Context, func()) {
+	if r.randomSeed == nil {
+		return ctx, func() {}
+	}
+	deps := r.executionDependencies()
+	// Queries are otherwise executed without execution dependencies,
+	// so keep the resource limits the executor chooses without them.
+	deps.ExecutionOptions.DefaultMemoryLimit = 0
+	deps.ExecutionOptions.ConcurrencyLimit = math.MaxInt
+	ctx, span := dependency.Inject(ctx, deps)
+	return ctx, span.Finish
+}
@@ -0,0 +1,143 @@
+package repl
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/semantic"
+)
+
+// SemanticResponse is the response to Service.Semantic.
+type SemanticResponse struct {
+	// FlatBuffer is the semantic graph as produced by the analyzer.
+	FlatBuffer []byte `json:"flatbuffer"`
+	// Package is the semantic graph as JSON. Each node has a type
+	// property naming its kind and each expression an inferred_type
+	// property holding its Flux type.
+	Package json.RawMessage `json:"package"`
+}
+
+// Semantic reports the semantic graph of the input.
+func (s *Service) Semantic(req InputRequest, resp *SemanticResponse) error {
+	fb, pkg, err := s.r.Semantic(s.r.ctx, req.Input)
+	if err != nil {
+		return err
+	}
+	data, err := SemanticJSON(pkg)
+	if err != nil {
+		return err
+	}
+	*resp = SemanticResponse{FlatBuffer: fb, Package: data}
+	return nil
+}
+
+// Semantic analyzes t and returns its semantic graph, both as the
+// FlatBuffer produced by the analyzer and deserialized.
+// The input is not evaluated, and names it defines are not
+// visible to later input.
+func (r *ScopeHolder) Semantic(ctx context.Context, t string) ([]byte, *semantic.Package, error) {
+	r.evalMu.Lock()
+	defer r.evalMu.Unlock()
+
+	scope := r.nestScope()
+	analyzer, err := r.analyzerFor(scope, t)
+	if err != nil {
+		return nil, nil, err
+	}
+	fb, _, err := r.analyzeFB(ctx, analyzer, t)
+	if err != nil {
+		r.discardAnalyzer(scope, analyzer, err)
+		return nil, nil, err
+	}
+	pkg, err := semantic.DeserializeFromFlatBuffer(fb)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fb, pkg, nil
+}
+
+// SemanticJSON encodes a semantic graph as JSON.
+func SemanticJSON(pkg *semantic.Package) ([]byte, error) {
+	return json.Marshal(semanticValue(reflect.ValueOf(pkg)))
+}
+
+var (
+	monoType   = reflect.TypeOf(semantic.MonoType{})
+	polyType   = reflect.TypeOf(semantic.PolyType{})
+	locType    = reflect.TypeOf(semantic.Loc{})
+	timeType   = reflect.TypeOf(time.Time{})
+	regexpType = reflect.TypeOf(&regexp.Regexp{})
+)
+
+// semanticValue converts part of a semantic graph into a value
+// that encoding/json marshals with the node kinds and types that
+// the graph does not export.
+func semanticValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return semanticValue(v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type() == regexpType {
+			return v.Interface().(*regexp.Regexp).String()
+		}
+		obj := semanticValue(v.Elem())
+		if m, ok := obj.(map[string]interface{}); ok {
+			if n, ok := v.Interface().(semantic.Node); ok {
+				m["type"] = n.NodeType()
+			}
+			if e, ok := v.Interface().(semantic.Expression); ok {
+				m["inferred_type"] = e.TypeOf().String()
+			}
+		}
+		return obj
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		elems := make([]interface{}, v.Len())
+		for i := range elems {
+			elems[i] = semanticValue(v.Index(i))
+		}
+		return elems
+	case reflect.Struct:
+		switch v.Type() {
+		case monoType:
+			return v.Interface().(semantic.MonoType).String()
+		case polyType:
+			return v.Interface().(semantic.PolyType).String()
+		case locType:
+			return ast.SourceLocation(v.Interface().(semantic.Loc))
+		case timeType:
+			return v.Interface()
+		}
+		obj := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			obj[lowerFirst(f.Name)] = semanticValue(v.Field(i))
+		}
+		return obj
+	default:
+		return v.Interface()
+	}
+}
+
+// lowerFirst returns s with its first letter in lower case.
+func lowerFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
@@ -0,0 +1,64 @@
+package repl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/semantic"
+)
+
+func TestSemanticJSON(t *testing.T) {
+	loc := semantic.Loc{
+		Start: ast.Position{Line: 1, Column: 1},
+		End:   ast.Position{Line: 1, Column: 6},
+	}
+	pkg := &semantic.Package{
+		Package: "main",
+		Files: []*semantic.File{{
+			Body: []semantic.Statement{
+				&semantic.NativeVariableAssignment{
+					Loc:        loc,
+					Identifier: &semantic.Identifier{Name: semantic.NewSymbol("x")},
+					Init:       &semantic.IntegerLiteral{Value: 1},
+				},
+			},
+		}},
+	}
+
+	data, err := SemanticJSON(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	stmt := got["files"].([]interface{})[0].(map[string]interface{})["body"].([]interface{})[0].(map[string]interface{})
+	if got, want := stmt["type"], "NativeVariableAssignment"; got != want {
+		t.Errorf("unexpected statement type: got %v, want %v", got, want)
+	}
+	wantLoc := map[string]interface{}{
+		"start": map[string]interface{}{"line": 1.0, "column": 1.0},
+		"end":   map[string]interface{}{"line": 1.0, "column": 6.0},
+	}
+	if !cmp.Equal(wantLoc, stmt["loc"]) {
+		t.Errorf("unexpected location -want/+got:\n%s", cmp.Diff(wantLoc, stmt["loc"]))
+	}
+	init := stmt["init"].(map[string]interface{})
+	wantInit := map[string]interface{}{
+		"type":          "IntegerLiteral",
+		"inferred_type": "int",
+		"loc":           init["loc"],
+		"value":         1.0,
+	}
+	if !cmp.Equal(wantInit, init) {
+		t.Errorf("unexpected init -want/+got:\n%s", cmp.Diff(wantInit, init))
+	}
+	ident := stmt["identifier"].(map[string]interface{})
+	if got, want := ident["name"], map[string]interface{}{"localName": "x", "package": ""}; !cmp.Equal(want, got) {
+		t.Errorf("unexpected identifier name -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,206 @@
+package repl
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// SessionResponse is the response to Service.Session.
+type SessionResponse struct {
+	// Token resumes the session on a later connection. It is empty
+	// when the session is not served from a SessionStore.
+	Token string `json:"token,omitempty"`
+}
+
+// Session reports the token that resumes the session
+// once the connection is lost.
+func (s *Service) Session(req struct{}, resp *SessionResponse) error {
+	*resp = SessionResponse{Token: s.token}
+	return nil
+}
+
+// SessionStore keeps the sessions of a server that accepts connections,
+// such as on a socket, so that a client whose connection drops can
+// resume its session with the token issued on its first connection.
+// A session is kept for the grace period after its connection closes
+// and every binding of its scope is there when it is resumed.
+type SessionStore struct {
+	grace      time.Duration
+	newSession func() (*ScopeHolder, error)
+	// now is replaced in tests.
+	now func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*storedSession
+}
+
+type storedSession struct {
+	r        *ScopeHolder
+	attached bool
+	created  time.Time
+	// expires is when a detached session is reaped.
+	expires time.Time
+}
+
+// NewSessionStore returns a store whose sessions are created by
+// newSession and kept for grace once their connection closes.
+func NewSessionStore(grace time.Duration, newSession func() (*ScopeHolder, error)) *SessionStore {
+	return &SessionStore{
+		grace:      grace,
+		newSession: newSession,
+		now:        time.Now,
+		sessions:   make(map[string]*storedSession),
+	}
+}
+
+// Serve serves the session of token on conn until the connection
+// closes. If token is empty, expired, unknown or in use by another
+// connection, a new session is served under a new token instead.
+// The client gets the token of the session it is served through
+// Service.Session.
+func (st *SessionStore) Serve(conn io.ReadWriteCloser, token string) error {
+	r, token, err := st.attach(token)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer st.detach(token)
+	r.serveSession(conn, st, token)
+	return nil
+}
+
+// attach returns the detached session of token, or a new session
+// with a new token, marked as attached to a connection.
+func (st *SessionStore) attach(token string) (*ScopeHolder, string, error) {
+	st.mu.Lock()
+	st.reapLocked()
+	if s, ok := st.sessions[token]; ok && !s.attached {
+		s.attached = true
+		st.mu.Unlock()
+		return s.r, token, nil
+	}
+	st.mu.Unlock()
+
+	r, err := st.newSession()
+	if err != nil {
+		return nil, "", err
+	}
+	token, err = newSessionToken()
+	if err != nil {
+		return nil, "", err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.sessions[token] = &storedSession{r: r, attached: true, created: st.now()}
+	return r, token, nil
+}
+
+// detach starts the grace period of the session of token.
+func (st *SessionStore) detach(token string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if s, ok := st.sessions[token]; ok {
+		s.attached = false
+		s.expires = st.now().Add(st.grace)
+	}
+}
+
+// Reap drops the detached sessions whose grace period is over
+// and returns how many were dropped. Sessions are also reaped
+// whenever a connection is served.
+func (st *SessionStore) Reap() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.reapLocked()
+}
+
+func (st *SessionStore) reapLocked() int {
+	now := st.now()
+	n := 0
+	for token, s := range st.sessions {
+		if s.attached || now.Before(s.expires) {
+			continue
+		}
+		delete(st.sessions, token)
+		// Nothing can read the results of a query the session still runs.
+		s.r.queries.cancelAll(cancelShutdown)
+		n++
+	}
+	return n
+}
+
+// Resources lists the sessions of the store. The ID of a session is
+// its token, which resumes the session, so the list is meant for the
+// operator of the server and is not served to its clients, which only
+// see their own token through Service.ListResources.
+func (st *SessionStore) Resources() []Resource {
+	return st.resources(func(string) bool { return true })
+}
+
+// resources lists the sessions of the store, leaving the ID
+// of a session empty unless reveal reports true for its token.
+func (st *SessionStore) resources(reveal func(token string) bool) []Resource {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	resources := make([]Resource, 0, len(st.sessions))
+	for token, s := range st.sessions {
+		res := Resource{Kind: ResourceSession, Created: s.created, Status: "attached"}
+		if reveal(token) {
+			res.ID = token
+		}
+		if !s.attached {
+			expires := s.expires
+			res.Status, res.Expires = "detached", &expires
+		}
+		resources = append(resources, res)
+	}
+	return resources
+}
+
+// Delete drops the detached session of token without waiting for its
+// grace period to end. A session that is attached to a connection
+// cannot be deleted.
+func (st *SessionStore) Delete(token string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s, ok := st.sessions[token]
+	if !ok {
+		return errors.New(codes.NotFound, "no such session")
+	}
+	if s.attached {
+		return errors.New(codes.FailedPrecondition, "the session is attached to a connection")
+	}
+	delete(st.sessions, token)
+	s.r.queries.cancelAll(cancelShutdown)
+	return nil
+}
+
+// ReapEvery reaps the store every d until ctx is done.
+func (st *SessionStore) ReapEvery(ctx context.Context, d time.Duration) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			st.Reap()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// newSessionToken returns a random token that cannot be guessed.
+func newSessionToken() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,157 @@
+package repl
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+// sessionConn is a connection served by a SessionStore.
+type sessionConn struct {
+	call  func(req string) rpcResponse
+	close func()
+}
+
+// connectSession serves a connection from st with token and returns the
+// client end, after waiting for the store to hand it to a session.
+func connectSession(t *testing.T, st *SessionStore, token string) sessionConn {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := st.Serve(serverConn, token); err != nil {
+			t.Error(err)
+		}
+	}()
+	closed := false
+	closeConn := func() {
+		if closed {
+			return
+		}
+		closed = true
+		_ = clientConn.Close()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("serve did not return after the connection closed")
+		}
+	}
+	t.Cleanup(closeConn)
+
+	dec := json.NewDecoder(clientConn)
+	call := func(req string) rpcResponse {
+		t.Helper()
+		if _, err := clientConn.Write([]byte(req + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		var resp rpcResponse
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	return sessionConn{call: call, close: closeConn}
+}
+
+// sessionToken asks the session served on c for its token.
+func sessionToken(t *testing.T, c sessionConn) string {
+	t.Helper()
+	resp := c.call(`{"method": "Service.Session", "id": 1, "params": [{}]}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	var session SessionResponse
+	if err := json.Unmarshal(resp.Result, &session); err != nil {
+		t.Fatal(err)
+	}
+	if session.Token == "" {
+		t.Fatal("expected a session token")
+	}
+	return session.Token
+}
+
+// newTestStore returns a store of test sessions whose clock is at *now.
+func newTestStore(grace time.Duration, now *time.Time) (*SessionStore, *[]*ScopeHolder) {
+	var created []*ScopeHolder
+	st := NewSessionStore(grace, func() (*ScopeHolder, error) {
+		r := newTestHolder()
+		created = append(created, r)
+		return r, nil
+	})
+	st.now = func() time.Time { return *now }
+	return st, &created
+}
+
+func TestSessionStore_Resume(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	st, created := newTestStore(time.Minute, &now)
+
+	first := connectSession(t, st, "")
+	token := sessionToken(t, first)
+	first.close()
+
+	now = now.Add(30 * time.Second)
+	second := connectSession(t, st, token)
+	if got := sessionToken(t, second); got != token {
+		t.Fatalf("expected the session of token %q to be resumed, got token %q", token, got)
+	}
+	if len(*created) != 1 {
+		t.Fatalf("expected one session to be created, got %d", len(*created))
+	}
+}
+
+func TestSessionStore_Expired(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	st, created := newTestStore(time.Minute, &now)
+
+	first := connectSession(t, st, "")
+	token := sessionToken(t, first)
+	first.close()
+
+	now = now.Add(2 * time.Minute)
+	second := connectSession(t, st, token)
+	if got := sessionToken(t, second); got == token {
+		t.Fatal("expected a new session once the grace period is over")
+	}
+	if len(*created) != 2 {
+		t.Fatalf("expected two sessions to be created, got %d", len(*created))
+	}
+}
+
+func TestSessionStore_Attached(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	st, _ := newTestStore(time.Minute, &now)
+
+	first := connectSession(t, st, "")
+	token := sessionToken(t, first)
+
+	// A session is served to one connection at a time.
+	second := connectSession(t, st, token)
+	if got := sessionToken(t, second); got == token {
+		t.Fatal("expected a new session while the session of the token is in use")
+	}
+}
+
+func TestSessionStore_Reap(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	st, _ := newTestStore(time.Minute, &now)
+
+	detached := connectSession(t, st, "")
+	sessionToken(t, detached)
+	detached.close()
+	attached := connectSession(t, st, "")
+	sessionToken(t, attached)
+
+	if n := st.Reap(); n != 0 {
+		t.Fatalf("expected no session to be reaped within the grace period, got %d", n)
+	}
+	now = now.Add(2 * time.Minute)
+	if n := st.Reap(); n != 1 {
+		t.Fatalf("expected the detached session to be reaped, got %d", n)
+	}
+	if len(st.sessions) != 1 {
+		t.Fatalf("expected the attached session to be kept, got %d sessions", len(st.sessions))
+	}
+}
@@ -0,0 +1,208 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/iocounter"
+)
+
+// ResultSink is a destination that the results of every query are
+// encoded to, such as annotated CSV written to a log file.
+//
+// The sinks of a query are encoded to concurrently, so sinks must not
+// share an encoder or a writer.
+type ResultSink struct {
+	Writer  io.Writer
+	Encoder flux.ResultEncoder
+}
+
+// WithResultSinks encodes the results of every query to each of the
+// sinks, in addition to writing them to the session output.
+// A query runs once however many sinks there are.
+//
+// A sink that fails is skipped for the rest of the query without
+// affecting the session output or the other sinks, and its error is
+// reported by SinkErrors.
+func WithResultSinks(sinks ...ResultSink) Option {
+	return option(func(r *ScopeHolder) {
+		r.resultSinks = append(r.resultSinks, sinks...)
+	})
+}
+
+// SinkErrors returns the errors of the sinks added WithResultSinks
+// since it was last called, in the order they occurred.
+func (r *ScopeHolder) SinkErrors() []error {
+	r.sinkMu.Lock()
+	defer r.sinkMu.Unlock()
+	errs := r.sinkErrors
+	r.sinkErrors = nil
+	return errs
+}
+
+func (r *ScopeHolder) addSinkError(err error) {
+	r.sinkMu.Lock()
+	defer r.sinkMu.Unlock()
+	r.sinkErrors = append(r.sinkErrors, err)
+}
+
+// sinks returns the sinks of a query whose formatted results are
+// written to w. The sink of w is always the first, unless the results
+// of ctx are sent in table frames, whose sink takes its place.
+func (r *ScopeHolder) sinks(ctx context.Context, w io.Writer) []ResultSink {
+	first := ResultSink{Writer: w, Encoder: resultFormatter{r: r}}
+	if sink, ok := ctx.Value(tableSinkKey{}).(ResultSink); ok {
+		first = sink
+	}
+	return append([]ResultSink{first}, r.resultSinks...)
+}
+
+// resultFormatter encodes a result as the session output.
+type resultFormatter struct {
+	r *ScopeHolder
+}
+
+func (f resultFormatter) Encode(w io.Writer, result flux.Result) (int64, error) {
+	cw := &iocounter.Writer{Writer: w}
+	err := f.r.writeResult(cw, result)
+	return cw.Count(), err
+}
+
+// sinkState is a sink along with the first error that it failed with
+// while encoding the results of a query.
+type sinkState struct {
+	ResultSink
+	err error
+}
+
+func newSinkStates(sinks []ResultSink) []*sinkState {
+	states := make([]*sinkState, len(sinks))
+	for i, s := range sinks {
+		states[i] = &sinkState{ResultSink: s}
+	}
+	return states
+}
+
+// bufferSinks returns sinks that write to a buffer of their own
+// in place of the writers of sinks, along with those buffers.
+func bufferSinks(sinks []ResultSink) ([]ResultSink, []*bytes.Buffer) {
+	buffered := make([]ResultSink, len(sinks))
+	bufs := make([]*bytes.Buffer, len(sinks))
+	for i, s := range sinks {
+		bufs[i] = new(bytes.Buffer)
+		buffered[i] = ResultSink{Writer: bufs[i], Encoder: s.Encoder}
+	}
+	return buffered, bufs
+}
+
+// finishSinks reports the outcome of encoding a query to its sinks.
+// The error of the first sink is returned, and those of the other
+// sinks are reported by SinkErrors.
+func (r *ScopeHolder) finishSinks(states []*sinkState) error {
+	if len(states) == 0 {
+		return nil
+	}
+	for i, s := range states[1:] {
+		if s.err != nil {
+			r.addSinkError(errors.Wrapf(s.err, codes.Inherit, "result sink %d", i))
+		}
+	}
+	return states[0].err
+}
+
+// encodeResult encodes result to every sink that has not failed.
+// The tables of result are only read once: each table is copied
+// and handed to the encoder of every sink in turn, with the encoders
+// running concurrently so that only one table is held at a time.
+//
+// An error is returned if the tables of result cannot be read,
+// or once every sink has failed.
+func encodeResult(states []*sinkState, result flux.Result) error {
+	var live []*sinkState
+	for _, s := range states {
+		if s.err == nil {
+			live = append(live, s)
+		}
+	}
+	switch len(live) {
+	case 0:
+		return states[0].err
+	case 1:
+		s := live[0]
+		if _, s.err = s.Encoder.Encode(s.Writer, result); s.err != nil {
+			return states[0].err
+		}
+		return nil
+	}
+
+	feeds := make([]chan flux.Table, len(live))
+	done := make(chan struct{})
+	for i, s := range live {
+		feeds[i] = make(chan flux.Table)
+		go func(s *sinkState, feed chan flux.Table) {
+			defer func() { done <- struct{}{} }()
+			_, s.err = s.Encoder.Encode(s.Writer, &teeResult{name: result.Name(), feed: feed})
+			// Release any tables that the encoder did not read.
+			for tbl := range feed {
+				tbl.Done()
+			}
+		}(s, feeds[i])
+	}
+
+	err := result.Tables().Do(func(tbl flux.Table) error {
+		buf, err := execute.CopyTable(tbl)
+		if err != nil {
+			return err
+		}
+		defer buf.Done()
+		for _, feed := range feeds {
+			feed <- buf.Copy()
+		}
+		return nil
+	})
+	for _, feed := range feeds {
+		close(feed)
+	}
+	for range live {
+		<-done
+	}
+	if err != nil {
+		return err
+	}
+	for _, s := range live {
+		if s.err == nil {
+			return nil
+		}
+	}
+	return states[0].err
+}
+
+// teeResult is the result given to the encoder of one sink,
+// whose tables are copies of those of the result of the query.
+type teeResult struct {
+	name string
+	feed <-chan flux.Table
+}
+
+func (r *teeResult) Name() string {
+	return r.name
+}
+
+func (r *teeResult) Tables() flux.TableIterator {
+	return r
+}
+
+func (r *teeResult) Do(f func(flux.Table) error) error {
+	for tbl := range r.feed {
+		if err := f(tbl); err != nil {
+			tbl.Done()
+			return err
+		}
+	}
+	return nil
+}
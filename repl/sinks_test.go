@@ -0,0 +1,118 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/csv"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func sinkTestResult() flux.Result {
+	cols := []flux.ColMeta{
+		{Label: "host", Type: flux.TString},
+		{Label: "_value", Type: flux.TInt},
+	}
+	return &executetest.Result{
+		Nm: "_result",
+		Tbls: []*executetest.Table{
+			{KeyCols: []string{"host"}, ColMeta: cols, Data: [][]interface{}{{"a", int64(1)}, {"a", int64(2)}}},
+			{KeyCols: []string{"host"}, ColMeta: cols, Data: [][]interface{}{{"b", int64(3)}}},
+		},
+	}
+}
+
+// failingWriter fails every write and counts the attempts.
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New(codes.Unavailable, "disk full")
+}
+
+func TestEncodeResult(t *testing.T) {
+	var want bytes.Buffer
+	enc := csv.NewResultEncoder(csv.DefaultEncoderConfig())
+	if _, err := enc.Encode(&want, sinkTestResult()); err != nil {
+		t.Fatal(err)
+	}
+
+	var a, b bytes.Buffer
+	states := newSinkStates([]ResultSink{
+		{Writer: &a, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())},
+		{Writer: &b, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())},
+	})
+	if err := encodeResult(states, sinkTestResult()); err != nil {
+		t.Fatal(err)
+	}
+	for i, got := range []string{a.String(), b.String()} {
+		if want := want.String(); got != want {
+			t.Fatalf("unexpected output of sink %d -want/+got:\n%s", i, cmp.Diff(want, got))
+		}
+	}
+}
+
+func TestEncodeResult_SinkError(t *testing.T) {
+	var primary, audit bytes.Buffer
+	failing := &failingWriter{}
+	states := newSinkStates([]ResultSink{
+		{Writer: &primary, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())},
+		{Writer: failing, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())},
+		{Writer: &audit, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())},
+	})
+	for i := 0; i < 2; i++ {
+		if err := encodeResult(states, sinkTestResult()); err != nil {
+			t.Fatalf("unexpected error for result %d: %v", i, err)
+		}
+	}
+	if states[1].err == nil {
+		t.Fatal("expected the failing sink to record its error")
+	}
+	if failing.writes != 1 {
+		t.Fatalf("expected the failing sink to be skipped after its error, got %d writes", failing.writes)
+	}
+	if primary.Len() == 0 || primary.String() != audit.String() {
+		t.Fatalf("expected the other sinks to receive every result, got:\n%s\nand:\n%s", primary.String(), audit.String())
+	}
+	if got := strings.Count(primary.String(), "#datatype"); got != 2 {
+		t.Fatalf("expected both results to be encoded, got %d", got)
+	}
+}
+
+func TestEncodeResult_AllSinksFail(t *testing.T) {
+	states := newSinkStates([]ResultSink{
+		{Writer: &failingWriter{}, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())},
+		{Writer: &failingWriter{}, Encoder: csv.NewResultEncoder(csv.DefaultEncoderConfig())},
+	})
+	err := encodeResult(states, sinkTestResult())
+	if err == nil || err != states[0].err {
+		t.Fatalf("expected the error of the first sink, got %v", err)
+	}
+}
+
+func TestScopeHolder_FinishSinks(t *testing.T) {
+	r := newTestHolder()
+	primaryErr := errors.New(codes.Invalid, "bad output")
+	states := newSinkStates(make([]ResultSink, 3))
+	states[0].err = primaryErr
+	states[2].err = io.ErrShortWrite
+
+	if err := r.finishSinks(states); err != primaryErr {
+		t.Fatalf("expected the error of the first sink, got %v", err)
+	}
+	errs := r.SinkErrors()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "result sink 1") {
+		t.Fatalf("unexpected sink errors: %v", errs)
+	}
+	if errs := r.SinkErrors(); len(errs) != 0 {
+		t.Fatalf("expected the sink errors to be cleared, got %v", errs)
+	}
+}
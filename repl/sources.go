@@ -0,0 +1,89 @@
+package repl
+
+import (
+	"sort"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/metadata"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/stdlib/influxdata/influxdb"
+	"github.com/influxdata/flux/stdlib/sql"
+)
+
+// liveSourcesKey is the statistics metadata key under which the names
+// of the external data sources read by a query are recorded.
+const liveSourcesKey = "flux/live-sources"
+
+// liveSourceNames maps the kind of each source procedure that reads from
+// an external data store to the name of the Flux function that creates it.
+var liveSourceNames = map[plan.ProcedureKind]string{
+	influxdb.FromKind:          "from",
+	influxdb.FromRemoteKind:    "from",
+	influxdb.BucketsKind:       "buckets",
+	influxdb.BucketsRemoteKind: "buckets",
+	sql.FromSQLKind:            "sql.from",
+}
+
+// liveSources returns the sorted names of the external data sources
+// read by the plan. Sources that produce in-memory data,
+// such as array.from(), are not included.
+func liveSources(ps *plan.Spec) []string {
+	seen := make(map[string]bool)
+	_ = ps.BottomUpWalk(func(pn plan.Node) error {
+		if len(pn.Predecessors()) > 0 {
+			return nil
+		}
+		if name, ok := liveSourceNames[pn.Kind()]; ok {
+			seen[name] = true
+		}
+		return nil
+	})
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addLiveSources records the live sources in the statistics metadata.
+func addLiveSources(stats flux.Statistics, sources []string) flux.Statistics {
+	if len(sources) == 0 {
+		return stats
+	}
+	if stats.Metadata == nil {
+		stats.Metadata = make(metadata.Metadata)
+	}
+	for _, name := range sources {
+		stats.Metadata.Add(liveSourcesKey, name)
+	}
+	return stats
+}
+
+// statsLiveSources returns the sorted, distinct live sources recorded in stats.
+func statsLiveSources(stats flux.Statistics) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, v := range stats.Metadata.GetAll(liveSourcesKey) {
+		if name, ok := v.(string); ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mergeLiveSources returns the sorted, distinct live sources of a and b.
+func mergeLiveSources(a, b []string) []string {
+	names := a
+	for _, name := range b {
+		if i := sort.SearchStrings(names, name); i == len(names) || names[i] != name {
+			names = append(names, "")
+			copy(names[i+1:], names[i:])
+			names[i] = name
+		}
+	}
+	return names
+}
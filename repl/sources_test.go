@@ -0,0 +1,83 @@
+package repl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/plan/plantest"
+	"github.com/influxdata/flux/stdlib/array"
+	"github.com/influxdata/flux/stdlib/influxdata/influxdb"
+	"github.com/influxdata/flux/stdlib/sql"
+	"github.com/influxdata/flux/stdlib/universe"
+)
+
+func TestLiveSources(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		plan *plantest.PlanSpec
+		want []string
+	}{
+		{
+			name: "from",
+			plan: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreateLogicalNode("from", &influxdb.FromProcedureSpec{}),
+					plan.CreateLogicalNode("range", &universe.RangeProcedureSpec{}),
+				},
+				Edges: [][2]int{{0, 1}},
+			},
+			want: []string{"from"},
+		},
+		{
+			name: "array.from",
+			plan: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreateLogicalNode("array", &array.FromProcedureSpec{}),
+					plan.CreateLogicalNode("range", &universe.RangeProcedureSpec{}),
+				},
+				Edges: [][2]int{{0, 1}},
+			},
+			want: []string{},
+		},
+		{
+			name: "union",
+			plan: &plantest.PlanSpec{
+				Nodes: []plan.Node{
+					plan.CreateLogicalNode("sql", &sql.FromSQLProcedureSpec{}),
+					plan.CreateLogicalNode("array", &array.FromProcedureSpec{}),
+					plan.CreateLogicalNode("from", &influxdb.FromProcedureSpec{}),
+					plan.CreateLogicalNode("union", &universe.UnionProcedureSpec{}),
+				},
+				Edges: [][2]int{{0, 3}, {1, 3}, {2, 3}},
+			},
+			want: []string{"from", "sql.from"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := liveSources(plantest.CreatePlanSpec(tt.plan))
+			if !cmp.Equal(tt.want, got) {
+				t.Fatalf("unexpected sources -want/+got:\n%s", cmp.Diff(tt.want, got))
+			}
+		})
+	}
+}
+
+func TestStatsLiveSources(t *testing.T) {
+	stats := addLiveSources(flux.Statistics{}, []string{"from", "sql.from"})
+	stats = stats.Add(addLiveSources(flux.Statistics{}, []string{"from"}))
+	if got, want := statsLiveSources(stats), []string{"from", "sql.from"}; !cmp.Equal(want, got) {
+		t.Fatalf("unexpected sources -want/+got:\n%s", cmp.Diff(want, got))
+	}
+	if got := statsLiveSources(flux.Statistics{}); len(got) != 0 {
+		t.Fatalf("expected no sources, got %v", got)
+	}
+}
+
+func TestMergeLiveSources(t *testing.T) {
+	got := mergeLiveSources([]string{"from"}, []string{"sql.from", "buckets", "from"})
+	if want := []string{"buckets", "from", "sql.from"}; !cmp.Equal(want, got) {
+		t.Fatalf("unexpected sources -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
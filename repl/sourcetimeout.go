@@ -0,0 +1,47 @@
+package repl
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/influxdata/flux/dependency"
+	"github.com/influxdata/flux/execute"
+)
+
+// WithSourceTimeout limits how long each source of a query, such as
+// a from, may run to d. A source that runs past it fails the results
+// that read from it with an error naming the source, while the other
+// results of the query go on. A zero d, the default, does not limit
+// the sources.
+func WithSourceTimeout(d time.Duration) Option {
+	return option(func(r *ScopeHolder) {
+		r.sourceTimeout = d
+	})
+}
+
+// injectSourceTimeout sets the source timeout of the session on the
+// execution dependencies of ctx, keeping the rest of them, such as
+// the random seed. If ctx has none, it injects the defaults along
+// with the timeout. The returned function must be called once the
+// query has finished.
+func (r *ScopeHolder) injectSourceTimeout(ctx context.Context) (context.Context, func()) {
+	if r.sourceTimeout == 0 {
+		return ctx, func() {}
+	}
+	var deps execute.ExecutionDependencies
+	if execute.HaveExecutionDependencies(ctx) {
+		deps = execute.GetExecutionDependencies(ctx)
+	} else {
+		deps = execute.DefaultExecutionDependencies()
+		// Keep the resource limits the executor chooses without
+		// execution dependencies, as injectSeed does.
+		deps.ExecutionOptions.DefaultMemoryLimit = 0
+		deps.ExecutionOptions.ConcurrencyLimit = math.MaxInt
+	}
+	opts := *deps.ExecutionOptions
+	opts.SourceTimeout = r.sourceTimeout
+	deps.ExecutionOptions = &opts
+	ctx, span := dependency.Inject(ctx, deps)
+	return ctx, span.Finish
+}
@@ -0,0 +1,55 @@
+package repl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influxdata/flux/execute"
+)
+
+func TestWithSourceTimeout_Inject(t *testing.T) {
+	r := newTestHolder(WithSourceTimeout(time.Second))
+
+	ctx, finish := r.injectSourceTimeout(context.Background())
+	defer finish()
+	if !execute.HaveExecutionDependencies(ctx) {
+		t.Fatal("expected execution dependencies with a source timeout")
+	}
+	deps := execute.GetExecutionDependencies(ctx)
+	if got := deps.ExecutionOptions.SourceTimeout; got != time.Second {
+		t.Fatalf("expected a source timeout of %v, got %v", time.Second, got)
+	}
+	if deps.Seed != nil {
+		t.Fatalf("expected no random seed, got %d", *deps.Seed)
+	}
+}
+
+func TestWithSourceTimeout_KeepsSeed(t *testing.T) {
+	r := newTestHolder(WithRandomSeed(7), WithSourceTimeout(time.Second))
+
+	ctx, finishSeed := r.injectSeed(context.Background())
+	defer finishSeed()
+	seeded := execute.GetExecutionDependencies(ctx).ExecutionOptions
+	ctx, finish := r.injectSourceTimeout(ctx)
+	defer finish()
+
+	deps := execute.GetExecutionDependencies(ctx)
+	if deps.Seed == nil || *deps.Seed != 7 {
+		t.Fatalf("expected the random seed to be kept, got %v", deps.Seed)
+	}
+	if got := deps.ExecutionOptions.SourceTimeout; got != time.Second {
+		t.Fatalf("expected a source timeout of %v, got %v", time.Second, got)
+	}
+	if seeded.SourceTimeout != 0 {
+		t.Fatal("expected the execution options of the outer context to be left alone")
+	}
+}
+
+func TestWithSourceTimeout_None(t *testing.T) {
+	r := newTestHolder()
+	ctx := context.Background()
+	if got, _ := r.injectSourceTimeout(ctx); got != ctx {
+		t.Fatal("expected the context to be kept without a source timeout")
+	}
+}
@@ -0,0 +1,106 @@
+package repl
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/libflux/go/libflux"
+)
+
+// Position is a line and column in the source of an input.
+// Both are 1-based.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// ErrorSpan is a single compile error along with the region
+// of the input that it refers to.
+type ErrorSpan struct {
+	Start   Position `json:"start"`
+	End     Position `json:"end"`
+	Message string   `json:"message"`
+}
+
+// FluxErrorDetail is the error reported by the analyzer for an input,
+// as returned over RPC by a session created WithFluxErrors.
+//
+// libflux exposes its errors only as a message, so Code is the code
+// the message is reported with and Errors holds every location that
+// the message contains. Message is kept as it was reported, including
+// any text that does not belong to a location.
+type FluxErrorDetail struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Errors  []ErrorSpan `json:"errors"`
+}
+
+// WithFluxErrors reports the full analyzer error of an input in the
+// FluxError field of an RPC response, in addition to its locations in
+// Errors. Errors without a location are then also reported in the
+// response rather than as an RPC error.
+func WithFluxErrors() Option {
+	return option(func(r *ScopeHolder) {
+		r.fluxErrors = true
+	})
+}
+
+// newFluxErrorDetail returns the detail of err, the Go form
+// of an analyzer error.
+func newFluxErrorDetail(err error) *FluxErrorDetail {
+	msg := err.Error()
+	return &FluxErrorDetail{
+		Code:    errors.Code(err).String(),
+		Message: msg,
+		Errors:  errorSpans(msg),
+	}
+}
+
+// setLineError records the outcome of evaluating a line in res,
+// where fluxError is set if the line failed to compile.
+func (r *ScopeHolder) setLineError(res *lineResult, fluxError *libflux.FluxError, err error) {
+	res.spans, res.err = fluxErrorSpans(fluxError), err
+	if r.fluxErrors && fluxError != nil {
+		res.fluxError = newFluxErrorDetail(fluxError.GoError())
+	}
+}
+
+// errorLocation matches the location prefix libflux
+// writes before each error, e.g. "error @1:5-1:8: ".
+var errorLocation = regexp.MustCompile(`^\s*(?:\w+ )?error @(\d+):(\d+)-(\d+):(\d+): `)
+
+// fluxErrorSpans returns the location of each error described by fluxError.
+func fluxErrorSpans(fluxError *libflux.FluxError) []ErrorSpan {
+	if fluxError == nil {
+		return nil
+	}
+	return errorSpans(fluxError.GoError().Error())
+}
+
+// errorSpans parses the errors in a libflux error message.
+// A message may contain more than one error, each starting on its own line.
+// Lines that do not start a new error are treated as part of the previous one.
+func errorSpans(msg string) []ErrorSpan {
+	var spans []ErrorSpan
+	for _, line := range strings.Split(msg, "\n") {
+		m := errorLocation.FindStringSubmatchIndex(line)
+		if m == nil {
+			if n := len(spans); n > 0 && strings.TrimSpace(line) != "" {
+				spans[n-1].Message += "\n" + line
+			}
+			continue
+		}
+		atoi := func(i int) int {
+			n, _ := strconv.Atoi(line[m[2*i]:m[2*i+1]])
+			return n
+		}
+		spans = append(spans, ErrorSpan{
+			Start:   Position{Line: atoi(1), Column: atoi(2)},
+			End:     Position{Line: atoi(3), Column: atoi(4)},
+			Message: line[m[1]:],
+		})
+	}
+	return spans
+}
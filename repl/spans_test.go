@@ -0,0 +1,96 @@
+package repl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func TestErrorSpans(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		msg  string
+		want []ErrorSpan
+	}{
+		{
+			name: "single",
+			msg:  "error @1:5-1:8: expected int but found string",
+			want: []ErrorSpan{{
+				Start:   Position{Line: 1, Column: 5},
+				End:     Position{Line: 1, Column: 8},
+				Message: "expected int but found string",
+			}},
+		},
+		{
+			name: "multiple",
+			msg:  "error @2:17-2:18: undefined identifier y\n\nerror @3:17-3:27: expected int but found string",
+			want: []ErrorSpan{
+				{
+					Start:   Position{Line: 2, Column: 17},
+					End:     Position{Line: 2, Column: 18},
+					Message: "undefined identifier y",
+				},
+				{
+					Start:   Position{Line: 3, Column: 17},
+					End:     Position{Line: 3, Column: 27},
+					Message: "expected int but found string",
+				},
+			},
+		},
+		{
+			name: "prefixed",
+			msg:  "type error @2:34-2:59: expected ELSE, got RBRACE (}) at 2:58",
+			want: []ErrorSpan{{
+				Start:   Position{Line: 2, Column: 34},
+				End:     Position{Line: 2, Column: 59},
+				Message: "expected ELSE, got RBRACE (}) at 2:58",
+			}},
+		},
+		{
+			name: "continuation",
+			msg:  "error @1:1-1:4: missing required argument\n  note: see f",
+			want: []ErrorSpan{{
+				Start:   Position{Line: 1, Column: 1},
+				End:     Position{Line: 1, Column: 4},
+				Message: "missing required argument\n  note: see f",
+			}},
+		},
+		{
+			name: "no location",
+			msg:  "something went wrong",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := errorSpans(tt.msg)
+			if !cmp.Equal(tt.want, got) {
+				t.Fatalf("unexpected spans -want/+got:\n%s", cmp.Diff(tt.want, got))
+			}
+		})
+	}
+}
+
+func TestNewFluxErrorDetail(t *testing.T) {
+	msg := "error @1:6-1:7: expected RPAREN, got EOF\n\nerror @2:1-2:4: undefined identifier foo"
+	got := newFluxErrorDetail(errors.New(codes.Invalid, msg))
+	want := &FluxErrorDetail{
+		Code:    "invalid",
+		Message: msg,
+		Errors: []ErrorSpan{
+			{
+				Start:   Position{Line: 1, Column: 6},
+				End:     Position{Line: 1, Column: 7},
+				Message: "expected RPAREN, got EOF",
+			},
+			{
+				Start:   Position{Line: 2, Column: 1},
+				End:     Position{Line: 2, Column: 4},
+				Message: "undefined identifier foo",
+			},
+		},
+	}
+	if !cmp.Equal(want, got) {
+		t.Fatalf("unexpected detail -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,45 @@
+package repl
+
+import (
+	"context"
+
+	"github.com/influxdata/flux"
+)
+
+// SpecsResponse is the response to Service.Specs.
+// Each operation is serialized along with the kind of its spec.
+type SpecsResponse struct {
+	Specs []*flux.Spec `json:"specs"`
+}
+
+// Specs reports the query spec of each query in the input.
+func (s *Service) Specs(req InputRequest, resp *SpecsResponse) error {
+	specs, err := s.r.Specs(s.r.ctx, req.Input)
+	if err != nil {
+		return err
+	}
+	*resp = SpecsResponse{Specs: specs}
+	return nil
+}
+
+// Specs returns the query spec of each query that evaluating t would run,
+// in order, without planning or executing them. A spec is the graph of
+// operations and edges built from the table object of an expression
+// statement, before it is compiled into a plan.
+// Yields are renamed as they would be when the queries are run.
+// Bindings made by t are not kept in scope.
+func (r *ScopeHolder) Specs(ctx context.Context, t string) ([]*flux.Spec, error) {
+	ses, err := r.evalNested(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	specs, err := r.tableSpecs(ctx, ses)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkYields(specs, r.disambiguateYields); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
@@ -0,0 +1,50 @@
+package repl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/stdlib/universe"
+)
+
+func TestSpecsResponse_JSON(t *testing.T) {
+	resp := SpecsResponse{Specs: []*flux.Spec{{
+		Operations: []*flux.Operation{
+			{ID: "range0", Spec: &universe.RangeOpSpec{}},
+			{ID: "yield1", Spec: &universe.YieldOpSpec{Name: "a"}},
+		},
+		Edges: []flux.Edge{{Parent: "range0", Child: "yield1"}},
+	}}}
+	bs, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Specs []struct {
+			Operations []struct {
+				Kind string `json:"kind"`
+				ID   string `json:"id"`
+			} `json:"operations"`
+			Edges []flux.Edge `json:"edges"`
+		} `json:"specs"`
+	}
+	if err := json.Unmarshal(bs, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Specs) != 1 {
+		t.Fatalf("expected one spec, got %d", len(got.Specs))
+	}
+	var ops []string
+	for _, op := range got.Specs[0].Operations {
+		ops = append(ops, op.Kind+":"+op.ID)
+	}
+	if want := []string{"range:range0", "yield:yield1"}; !cmp.Equal(want, ops) {
+		t.Fatalf("unexpected operations -want/+got:\n%s", cmp.Diff(want, ops))
+	}
+	if want := resp.Specs[0].Edges; !cmp.Equal(want, got.Specs[0].Edges) {
+		t.Fatalf("unexpected edges -want/+got:\n%s", cmp.Diff(want, got.Specs[0].Edges))
+	}
+}
@@ -0,0 +1,222 @@
+package repl
+
+import (
+	"context"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/semantic"
+	"github.com/influxdata/flux/values"
+)
+
+// TableResult is a result of a query with its tables read into memory.
+type TableResult struct {
+	Name   string
+	Tables []Table
+}
+
+// Table is a table read into memory.
+// Each row maps the label of a column to its value in that row.
+type Table struct {
+	Key     flux.GroupKey
+	Columns []flux.ColMeta
+	Rows    []map[string]values.Value
+}
+
+// WithRowLimit limits the number of rows that EvalTables reads into
+// memory for a single evaluation, that EvalPiped holds for its
+// producer and that FetchPage buffers for a result. Evaluations that would read more rows fail. There is no
+// limit by default.
+func WithRowLimit(n int) Option {
+	return option(func(r *ScopeHolder) {
+		r.rowLimit = n
+	})
+}
+
+type rowLimitKey struct{}
+
+// withRowLimit returns a context in which the queries whose rows are
+// read into memory are limited to n rows, or to the row limit of the
+// session if it is lower.
+func withRowLimit(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, rowLimitKey{}, n)
+}
+
+// tableRowLimit returns the row limit of the queries run in ctx
+// whose rows are read into memory.
+func (r *ScopeHolder) tableRowLimit(ctx context.Context) int {
+	n := r.rowLimit
+	if m, ok := ctx.Value(rowLimitKey{}).(int); ok && m > 0 && (n <= 0 || m < n) {
+		n = m
+	}
+	return n
+}
+
+// EvalTables evaluates the Flux source t in the session scope and
+// returns the results of every query it runs, in order, with their
+// rows read into memory. Expression statements that do not produce
+// tables are evaluated but not returned.
+//
+// A session created WithDiagnostics returns the error of a failing
+// evaluation as a result instead.
+func (r *ScopeHolder) EvalTables(ctx context.Context, t string) ([]TableResult, error) {
+	results, err := r.evalTables(ctx, t)
+	if err != nil && r.diagnostics {
+		return append(results, diagnosticsResult(err)), nil
+	}
+	return results, err
+}
+
+// evalTables evaluates t like EvalTables. The results of the
+// queries that completed are returned along with any error.
+func (r *ScopeHolder) evalTables(ctx context.Context, t string) ([]TableResult, error) {
+	ctx, end := r.beginLine(ctx)
+	defer end()
+	ctx, stop := r.watch(ctx)
+	defer stop()
+
+	ses, _, err := r.evalWithFluxError(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	specs, err := r.tableSpecs(ctx, ses)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkYields(specs, r.disambiguateYields); err != nil {
+		return nil, err
+	}
+
+	var (
+		results []TableResult
+		rows    int
+	)
+	for _, se := range ses {
+		if _, ok := se.Node.(*semantic.ExpressionStatement); !ok {
+			continue
+		}
+		if _, ok := se.Value.(*flux.TableObject); !ok {
+			continue
+		}
+		s := specs[0]
+		specs = specs[1:]
+		queryResults, queryRows, err := r.runTables(ctx, s, rows)
+		if err != nil {
+			return results, err
+		}
+		results, rows = append(results, queryResults...), queryRows
+	}
+	return results, nil
+}
+
+// runTables runs the query spec, retrying it as configured, and reads
+// its results into memory. The row limit applies to rows along with the
+// rows of the query, and the total is returned along with the results.
+func (r *ScopeHolder) runTables(ctx context.Context, s *flux.Spec, rows int) ([]TableResult, int, error) {
+	var (
+		results   []TableResult
+		queryRows int
+	)
+	limit := r.tableRowLimit(ctx)
+	_, err := r.retryQuery(ctx, func() (flux.Statistics, error) {
+		results, queryRows = nil, rows
+		return r.runQuery(ctx, s, func(result flux.Result) error {
+			res := TableResult{Name: result.Name()}
+			if err := result.Tables().Do(func(tbl flux.Table) error {
+				table, err := readRows(tbl, &queryRows, limit)
+				if err != nil {
+					return err
+				}
+				res.Tables = append(res.Tables, table)
+				return nil
+			}); err != nil {
+				return err
+			}
+			results = append(results, res)
+			return nil
+		})
+	})
+	return results, queryRows, err
+}
+
+// readTable reads the rows of tbl into memory.
+// It adds the rows it reads to *rows and fails if that
+// exceeds the row limit.
+func (r *ScopeHolder) readTable(tbl flux.Table, rows *int) (Table, error) {
+	return readRows(tbl, rows, r.rowLimit)
+}
+
+// readRows reads the rows of tbl into memory like readTable,
+// with a limit of limit rows, or none if it is zero or less.
+func readRows(tbl flux.Table, rows *int, limit int) (Table, error) {
+	table := Table{Key: tbl.Key(), Columns: tbl.Cols()}
+	err := tbl.Do(func(cr flux.ColReader) error {
+		*rows += cr.Len()
+		if limit > 0 && *rows > limit {
+			return errors.Newf(codes.ResourceExhausted, "query results exceed the limit of %d rows", limit)
+		}
+		for i := 0; i < cr.Len(); i++ {
+			row := make(map[string]values.Value, len(table.Columns))
+			for j, c := range table.Columns {
+				row[c.Label] = execute.ValueForRow(cr, i, j)
+			}
+			table.Rows = append(table.Rows, row)
+		}
+		return nil
+	})
+	return table, err
+}
+
+// Summary is the result of EvalSummary.
+type Summary struct {
+	// Value holds the only value produced by the queries, if they
+	// produced exactly one table with one row and one column outside
+	// its group key. Type is the Flux type of Value.
+	Value values.Value
+	Type  string
+	// Results holds the results of the queries otherwise.
+	Results []TableResult
+}
+
+// EvalSummary evaluates t like EvalTables, but returns the single
+// value of a query such as an aggregate that produces one row in place
+// of the table that holds it. Any other results are returned in full.
+func (r *ScopeHolder) EvalSummary(ctx context.Context, t string) (Summary, error) {
+	results, err := r.EvalTables(ctx, t)
+	if err != nil {
+		return Summary{}, err
+	}
+	if v, ok := singleValue(results); ok {
+		return Summary{Value: v, Type: v.Type().String()}, nil
+	}
+	return Summary{Results: results}, nil
+}
+
+// singleValue returns the value of results if they consist of one
+// table with one row and one column that is not in its group key.
+func singleValue(results []TableResult) (values.Value, bool) {
+	if len(results) != 1 || len(results[0].Tables) != 1 {
+		return nil, false
+	}
+	tbl := results[0].Tables[0]
+	if len(tbl.Rows) != 1 {
+		return nil, false
+	}
+	var label string
+	for _, c := range tbl.Columns {
+		if tbl.Key.HasCol(c.Label) {
+			continue
+		}
+		if label != "" {
+			return nil, false
+		}
+		label = c.Label
+	}
+	if label == "" {
+		return nil, false
+	}
+	return tbl.Rows[0][label], true
+}
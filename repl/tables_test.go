@@ -0,0 +1,98 @@
+package repl
+
+import (
+	"testing"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+func testTable(values ...int64) *executetest.Table {
+	tbl := &executetest.Table{
+		KeyCols: []string{"t0"},
+		ColMeta: []flux.ColMeta{
+			{Label: "t0", Type: flux.TString},
+			{Label: "_value", Type: flux.TInt},
+		},
+	}
+	for _, v := range values {
+		tbl.Data = append(tbl.Data, []interface{}{"a", v})
+	}
+	return tbl
+}
+
+func TestReadTable(t *testing.T) {
+	var rows int
+	table, err := newTestHolder().readTable(testTable(1, 2), &rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 2 || len(table.Rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d read and %d kept", rows, len(table.Rows))
+	}
+	if got := table.Key.ValueString(0); got != "a" {
+		t.Fatalf("unexpected group key value: %s", got)
+	}
+	for i, want := range []int64{1, 2} {
+		row := table.Rows[i]
+		if got := row["_value"].Int(); got != want {
+			t.Errorf("unexpected _value in row %d: got %d, want %d", i, got, want)
+		}
+		if got := row["t0"].Str(); got != "a" {
+			t.Errorf("unexpected t0 in row %d: got %s, want a", i, got)
+		}
+	}
+}
+
+func TestReadTable_RowLimit(t *testing.T) {
+	r := newTestHolder(WithRowLimit(3))
+	var rows int
+	if _, err := r.readTable(testTable(1, 2), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.readTable(testTable(3, 4), &rows); errors.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected the row limit to be exceeded across tables, got %v", err)
+	}
+}
+
+func TestSingleValue(t *testing.T) {
+	read := func(tbls ...*executetest.Table) []TableResult {
+		res := TableResult{Name: "_result"}
+		var rows int
+		for _, tbl := range tbls {
+			table, err := newTestHolder().readTable(tbl, &rows)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Tables = append(res.Tables, table)
+		}
+		return []TableResult{res}
+	}
+
+	v, ok := singleValue(read(testTable(6)))
+	if !ok {
+		t.Fatal("expected a single value")
+	}
+	if v.Int() != 6 {
+		t.Fatalf("unexpected value: %v", v)
+	}
+
+	for name, results := range map[string][]TableResult{
+		"multiple rows":   read(testTable(1, 2)),
+		"multiple tables": read(testTable(1), testTable(2)),
+		"no results":      nil,
+	} {
+		if _, ok := singleValue(results); ok {
+			t.Errorf("expected %s not to be a single value", name)
+		}
+	}
+
+	wide := testTable()
+	wide.ColMeta = append(wide.ColMeta, flux.ColMeta{Label: "_other", Type: flux.TInt})
+	wide.Data = [][]interface{}{{"a", int64(1), int64(2)}}
+	if _, ok := singleValue(read(wide)); ok {
+		t.Error("expected a row with several values not to be a single value")
+	}
+}
@@ -0,0 +1,147 @@
+package repl
+
+import (
+	"context"
+	"sync"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+)
+
+// TailedTable is a table of a tailed query, read into memory.
+type TailedTable struct {
+	// Result is the name of the result the table belongs to.
+	Result string
+	Table  Table
+}
+
+// Subscription is a query that is kept running by Tail
+// until it is unsubscribed.
+type Subscription struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	// err is set before done is closed.
+	err error
+	// unsubscribed is closed by Unsubscribe.
+	unsubscribed chan struct{}
+	once         sync.Once
+}
+
+// Tail evaluates the Flux source t in the session scope and keeps the
+// query it produces running, calling fn with each table as soon as
+// the source produces it, until the subscription is unsubscribed or
+// the query ends. A query over a finite source ends once its tables
+// have been read, whereas one over a streaming source does not.
+//
+// The source must produce exactly one query. Rows are read into memory
+// a table at a time, so the row limit applies to each table. The query
+// is not watched by the watchdog and is not interrupted by Cancel, but
+// is listed by ActiveQueries, holds a query slot while it runs and is
+// canceled by Reset.
+// An error returned by fn ends the query with that error.
+func (r *ScopeHolder) Tail(ctx context.Context, t string, fn func(TailedTable) error) (*Subscription, error) {
+	specs, err := r.tailSpecs(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	if len(specs) != 1 {
+		return nil, errors.Newf(codes.Invalid, "tail requires exactly one query, got %d", len(specs))
+	}
+	if err := checkYields(specs, r.disambiguateYields); err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, detachedKey{}, true)
+	return subscribe(ctx, func(ctx context.Context) error {
+		_, err := r.runQuery(ctx, specs[0], func(result flux.Result) error {
+			return r.tailResult(result, fn)
+		})
+		return err
+	}), nil
+}
+
+// tailSpecs evaluates t for Tail and returns the specs of its queries.
+// The query is not started here, since it outlives the line.
+func (r *ScopeHolder) tailSpecs(ctx context.Context, t string) ([]*flux.Spec, error) {
+	ctx, end := r.beginLine(ctx)
+	defer end()
+	ses, _, err := r.evalWithFluxError(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	return r.tableSpecs(ctx, ses)
+}
+
+// detachedKey marks the context of a query that runs in
+// the background, which Cancel does not interrupt.
+type detachedKey struct{}
+
+func isDetached(ctx context.Context) bool {
+	detached, _ := ctx.Value(detachedKey{}).(bool)
+	return detached
+}
+
+// subscribe calls run in the background with a context that is
+// canceled when the subscription is unsubscribed.
+func subscribe(ctx context.Context, run func(ctx context.Context) error) *Subscription {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Subscription{
+		cancel:       cancel,
+		done:         make(chan struct{}),
+		unsubscribed: make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		defer cancel()
+		err := run(ctx)
+		select {
+		case <-s.unsubscribed:
+			// The query was interrupted on purpose.
+			err = nil
+		default:
+		}
+		s.err = err
+	}()
+	return s
+}
+
+// Done returns a channel that is closed once the query has ended.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error that the query ended with once Done is closed.
+// It is nil if the query ended because it was unsubscribed.
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Unsubscribe interrupts the query and waits for it to end,
+// returning the error it ended with before it was unsubscribed, if any.
+func (s *Subscription) Unsubscribe() error {
+	s.once.Do(func() {
+		close(s.unsubscribed)
+		s.cancel()
+	})
+	<-s.done
+	return s.err
+}
+
+// tailResult reads each table of result into memory as it arrives
+// and hands it to fn.
+func (r *ScopeHolder) tailResult(result flux.Result, fn func(TailedTable) error) error {
+	return result.Tables().Do(func(tbl flux.Table) error {
+		var rows int
+		table, err := r.readTable(tbl, &rows)
+		if err != nil {
+			return err
+		}
+		return fn(TailedTable{Result: result.Name(), Table: table})
+	})
+}
@@ -0,0 +1,105 @@
+package repl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/mock"
+)
+
+// streamingResult is a result whose tables are sent
+// on a channel as the source produces them.
+type streamingResult struct {
+	tables <-chan flux.Table
+}
+
+func (r *streamingResult) Name() string               { return "_result" }
+func (r *streamingResult) Tables() flux.TableIterator { return r }
+
+func (r *streamingResult) Do(f func(flux.Table) error) error {
+	for tbl := range r.tables {
+		if err := f(tbl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestTailResult(t *testing.T) {
+	tables := make(chan flux.Table)
+	q := &mock.Query{}
+	q.ProduceResults(func(results chan<- flux.Result, canceled <-chan struct{}) {
+		results <- &streamingResult{tables: tables}
+	})
+
+	got := make(chan TailedTable)
+	done := make(chan error, 1)
+	go func() {
+		_, err := drainQuery(q, func(result flux.Result) error {
+			return newTestHolder().tailResult(result, func(tbl TailedTable) error {
+				got <- tbl
+				return nil
+			})
+		})
+		done <- err
+	}()
+
+	// Each table is handed over before the next one is produced.
+	for i := int64(0); i < 3; i++ {
+		tables <- &executetest.Table{
+			ColMeta: []flux.ColMeta{{Label: "_value", Type: flux.TInt}},
+			Data:    [][]interface{}{{i}},
+		}
+		select {
+		case tbl := <-got:
+			if tbl.Result != "_result" || len(tbl.Table.Rows) != 1 || tbl.Table.Rows[0]["_value"].Int() != i {
+				t.Fatalf("unexpected table %d: %+v", i, tbl)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for table %d", i)
+		}
+	}
+	close(tables)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSubscription_Unsubscribe(t *testing.T) {
+	s := subscribe(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	select {
+	case <-s.Done():
+		t.Fatal("expected the query to keep running until it is unsubscribed")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if err := s.Unsubscribe(); err != nil {
+		t.Fatalf("expected no error once unsubscribed, got %v", err)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("expected no error once unsubscribed, got %v", err)
+	}
+	if err := s.Unsubscribe(); err != nil {
+		t.Fatalf("expected unsubscribing again to do nothing, got %v", err)
+	}
+}
+
+func TestSubscription_Err(t *testing.T) {
+	s := subscribe(context.Background(), func(ctx context.Context) error {
+		return errors.New(codes.Internal, "source failed")
+	})
+	<-s.Done()
+	if got, want := errors.Code(s.Err()), codes.Internal; got != want {
+		t.Fatalf("unexpected error code: got %v, want %v", got, want)
+	}
+	if err := s.Unsubscribe(); err == nil {
+		t.Fatal("expected the error of the query once it has ended")
+	}
+}
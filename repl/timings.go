@@ -0,0 +1,69 @@
+package repl
+
+import (
+	"context"
+	"time"
+)
+
+// PhaseTimings reports how long each phase of evaluating a line took,
+// in nanoseconds. Plan and Execute are summed over every query run.
+type PhaseTimings struct {
+	Analyze     time.Duration `json:"analyze"`
+	Deserialize time.Duration `json:"deserialize"`
+	Eval        time.Duration `json:"eval"`
+	Plan        time.Duration `json:"plan"`
+	Execute     time.Duration `json:"execute"`
+}
+
+// WithPhaseTimings sets whether each response reports how long
+// each phase of evaluating its input took.
+// Timings are not collected by default.
+func WithPhaseTimings(enabled bool) Option {
+	return option(func(r *ScopeHolder) {
+		r.phaseTimings = enabled
+	})
+}
+
+type phase int
+
+const (
+	phaseAnalyze phase = iota
+	phaseDeserialize
+	phaseEval
+	phasePlan
+	phaseExecute
+)
+
+type phaseTimingsKey struct{}
+
+// withPhaseTimings returns a context that collects the timings of
+// the phases run with it, or ctx and nil if timings are disabled.
+func (r *ScopeHolder) withPhaseTimings(ctx context.Context) (context.Context, *PhaseTimings) {
+	if !r.phaseTimings {
+		return ctx, nil
+	}
+	timings := &PhaseTimings{}
+	return context.WithValue(ctx, phaseTimingsKey{}, timings), timings
+}
+
+// recordPhase adds the time since start to the timing of p,
+// if ctx is collecting timings.
+func recordPhase(ctx context.Context, p phase, start time.Time) {
+	timings, ok := ctx.Value(phaseTimingsKey{}).(*PhaseTimings)
+	if !ok {
+		return
+	}
+	d := time.Since(start)
+	switch p {
+	case phaseAnalyze:
+		timings.Analyze += d
+	case phaseDeserialize:
+		timings.Deserialize += d
+	case phaseEval:
+		timings.Eval += d
+	case phasePlan:
+		timings.Plan += d
+	case phaseExecute:
+		timings.Execute += d
+	}
+}
@@ -0,0 +1,39 @@
+package repl
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRecordPhase(t *testing.T) {
+	r := newTestHolder(WithPhaseTimings(true))
+	ctx, timings := r.withPhaseTimings(context.Background())
+	if timings == nil {
+		t.Fatal("expected timings to be collected")
+	}
+
+	start := time.Now().Add(-time.Second)
+	recordPhase(ctx, phasePlan, start)
+	recordPhase(ctx, phasePlan, start)
+	recordPhase(ctx, phaseEval, start)
+	if timings.Plan < 2*time.Second {
+		t.Fatalf("expected plan timings to be summed, got %v", timings.Plan)
+	}
+	if timings.Eval < time.Second || timings.Eval >= timings.Plan {
+		t.Fatalf("unexpected eval timing: %v", timings.Eval)
+	}
+	if timings.Analyze != 0 || timings.Deserialize != 0 || timings.Execute != 0 {
+		t.Fatalf("expected other phases to be unset, got %+v", timings)
+	}
+}
+
+func TestRecordPhase_Disabled(t *testing.T) {
+	r := newTestHolder()
+	ctx := context.Background()
+	timingsCtx, timings := r.withPhaseTimings(ctx)
+	if timings != nil || timingsCtx != ctx {
+		t.Fatal("expected timings not to be collected by default")
+	}
+	recordPhase(ctx, phaseEval, time.Now())
+}
@@ -0,0 +1,46 @@
+package repl
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// WithTracer sets the tracer used to record a span for each phase of
+// evaluating a line: analyze, eval, plan and execute, all within a
+// span for the line itself. Nothing is traced by default.
+func WithTracer(tracer opentracing.Tracer) Option {
+	return option(func(r *ScopeHolder) {
+		r.tracer = tracer
+	})
+}
+
+// startSpan starts a span named after a phase of evaluation as a child
+// of any span in ctx, and returns a context that carries it.
+// The span is a no-op when no tracer has been set.
+func (r *ScopeHolder) startSpan(ctx context.Context, phase string) (opentracing.Span, context.Context) {
+	if r.tracer == nil {
+		return opentracing.NoopTracer{}.StartSpan(phase), ctx
+	}
+	var opts []opentracing.StartSpanOption
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+	labels := queryLabels(ctx)
+	for _, k := range sortedLabelKeys(labels) {
+		opts = append(opts, opentracing.Tag{Key: "label." + k, Value: labels[k]})
+	}
+	span := r.tracer.StartSpan(phase, opts...)
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+// finishSpan records the outcome of a phase on span and finishes it.
+func finishSpan(span opentracing.Span, err error) {
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(log.Error(err))
+	}
+	span.Finish()
+}
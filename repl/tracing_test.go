@@ -0,0 +1,51 @@
+package repl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestScopeHolder_StartSpan(t *testing.T) {
+	tracer := mocktracer.New()
+	r := newTestHolder(WithTracer(tracer))
+
+	parent, ctx := r.startSpan(context.Background(), "repl.line")
+	child, childCtx := r.startSpan(ctx, "repl.eval")
+	if got := opentracing.SpanFromContext(childCtx); got != child {
+		t.Fatal("expected the context to carry the child span")
+	}
+	finishSpan(child, errors.New("expected error"))
+	finishSpan(parent, nil)
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 finished spans, got %d", len(spans))
+	}
+	eval, line := spans[0], spans[1]
+	if eval.OperationName != "repl.eval" || line.OperationName != "repl.line" {
+		t.Fatalf("unexpected spans: %s, %s", eval.OperationName, line.OperationName)
+	}
+	if eval.ParentID != line.SpanContext.SpanID {
+		t.Fatal("expected the eval span to be a child of the line span")
+	}
+	if got := eval.Tag("error"); got != true {
+		t.Fatalf("expected the eval span to be marked as an error, got %v", got)
+	}
+	if got := line.Tag("error"); got != nil {
+		t.Fatalf("expected the line span not to be marked as an error, got %v", got)
+	}
+}
+
+func TestScopeHolder_StartSpan_NoTracer(t *testing.T) {
+	r := newTestHolder()
+	ctx := context.Background()
+	span, spanCtx := r.startSpan(ctx, "repl.line")
+	if spanCtx != ctx {
+		t.Fatal("expected the context to be unchanged without a tracer")
+	}
+	finishSpan(span, errors.New("expected error"))
+}
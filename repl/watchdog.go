@@ -0,0 +1,73 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+)
+
+// Watchdog configures how evaluations that appear to be stuck are reported.
+type Watchdog struct {
+	// Threshold is how long an evaluation may run before it is reported.
+	Threshold time.Duration
+	// Stacks includes the stack of every goroutine in the report.
+	Stacks bool
+	// Cancel cancels the evaluation once it has been reported.
+	Cancel bool
+	// Output is where reports are written. It defaults to os.Stderr.
+	Output io.Writer
+}
+
+// WithWatchdog reports each evaluation that runs for longer than the
+// threshold of w, which can help to diagnose a deadlock in a deployed
+// session. Evaluations are not watched by default.
+func WithWatchdog(w Watchdog) Option {
+	return option(func(r *ScopeHolder) {
+		if w.Output == nil {
+			w.Output = os.Stderr
+		}
+		r.watchdog = &w
+	})
+}
+
+// watch returns a context for an evaluation that the watchdog reports
+// if it is still running after the threshold, and a function to call
+// once the evaluation has finished.
+func (r *ScopeHolder) watch(ctx context.Context) (context.Context, func()) {
+	w := r.watchdog
+	if w == nil || w.Threshold <= 0 {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	timedOut := new(int32)
+	ctx = context.WithValue(ctx, watchdogKey{}, timedOut)
+	timer := time.AfterFunc(w.Threshold, func() {
+		fmt.Fprintf(w.Output, "Warning: evaluation has been running for more than %s\n", w.Threshold)
+		if w.Stacks {
+			_ = pprof.Lookup("goroutine").WriteTo(w.Output, 2)
+		}
+		if w.Cancel {
+			atomic.StoreInt32(timedOut, 1)
+			cancel()
+		}
+	})
+	return ctx, func() {
+		timer.Stop()
+		cancel()
+	}
+}
+
+// watchdogKey is the context key under which the watchdog
+// records that it canceled an evaluation.
+type watchdogKey struct{}
+
+// watchdogCanceled reports whether ctx was canceled by the watchdog
+// because the evaluation ran for too long.
+func watchdogCanceled(ctx context.Context) bool {
+	timedOut, ok := ctx.Value(watchdogKey{}).(*int32)
+	return ok && atomic.LoadInt32(timedOut) == 1
+}
@@ -0,0 +1,82 @@
+package repl
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuilder is a strings.Builder that is safe to write to
+// from the watchdog while the test reads it.
+type syncBuilder struct {
+	mu sync.Mutex
+	sb strings.Builder
+}
+
+func (b *syncBuilder) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sb.Write(p)
+}
+
+func (b *syncBuilder) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sb.String()
+}
+
+func TestWatchdog(t *testing.T) {
+	var out syncBuilder
+	r := newTestHolder(WithWatchdog(Watchdog{
+		Threshold: 10 * time.Millisecond,
+		Stacks:    true,
+		Cancel:    true,
+		Output:    &out,
+	}))
+
+	// Simulate an evaluation that never finishes on its own.
+	ctx, stop := r.watch(context.Background())
+	defer stop()
+	select {
+	case <-ctx.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the watchdog to cancel the evaluation")
+	}
+
+	got := out.String()
+	if !strings.HasPrefix(got, "Warning: evaluation has been running for more than 10ms\n") {
+		t.Fatalf("unexpected report:\n%s", got)
+	}
+	if !strings.Contains(got, "TestWatchdog") {
+		t.Fatalf("expected the report to include goroutine stacks, got:\n%s", got)
+	}
+}
+
+func TestWatchdog_Finished(t *testing.T) {
+	var out syncBuilder
+	r := newTestHolder(WithWatchdog(Watchdog{
+		Threshold: 10 * time.Millisecond,
+		Output:    &out,
+	}))
+
+	ctx, stop := r.watch(context.Background())
+	stop()
+	time.Sleep(50 * time.Millisecond)
+	if got := out.String(); got != "" {
+		t.Fatalf("expected a finished evaluation not to be reported, got:\n%s", got)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("expected the evaluation context to be released")
+	}
+}
+
+func TestWatchdog_Disabled(t *testing.T) {
+	ctx := context.Background()
+	watched, stop := newTestHolder().watch(ctx)
+	defer stop()
+	if watched != ctx {
+		t.Fatal("expected the context to be unchanged without a watchdog")
+	}
+}
@@ -0,0 +1,136 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/plan"
+	"github.com/influxdata/flux/stdlib/universe"
+)
+
+// WithDisambiguateYields controls how a query that calls yield() more than
+// once with the same name is handled. By default the query is rejected
+// before it runs. When enabled, the second and later yields with a name
+// are renamed by appending a numeric suffix, e.g. "x_1", "x_2".
+// The implicit "_result" of a stream that is not yielded is never renamed.
+func WithDisambiguateYields(enabled bool) Option {
+	return option(func(r *ScopeHolder) {
+		r.disambiguateYields = enabled
+	})
+}
+
+// YieldsResponse is the response to Service.Yields.
+type YieldsResponse struct {
+	Names []string `json:"names"`
+}
+
+// Yields reports the names of the results the input will produce.
+func (s *Service) Yields(req InputRequest, resp *YieldsResponse) error {
+	names, err := s.r.Yields(s.r.ctx, req.Input)
+	if err != nil {
+		return err
+	}
+	*resp = YieldsResponse{Names: names}
+	return nil
+}
+
+// Yields returns the names of the results that evaluating t would produce,
+// in the order they would be produced, without executing any queries.
+// Streams that are not explicitly yielded are reported with the
+// implicit name "_result". Bindings made by t are not kept in scope.
+//
+// An error is returned if a yield repeats a name, as running the
+// queries would: each statement that is not yielded produces its own
+// "_result", which may be reported more than once.
+func (r *ScopeHolder) Yields(ctx context.Context, t string) ([]string, error) {
+	ses, err := r.evalNested(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	specs, err := r.tableSpecs(ctx, ses)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkYields(specs, r.disambiguateYields); err != nil {
+		return nil, err
+	}
+
+	return specsYieldNames(specs)
+}
+
+// specsYieldNames returns the names of the results produced by the
+// queries of specs, in order. The names are not checked for
+// duplicates; that is left to checkYields.
+func specsYieldNames(specs []*flux.Spec) ([]string, error) {
+	var names []string
+	for _, s := range specs {
+		specNames, err := yieldNames(s)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, specNames...)
+	}
+	return names, nil
+}
+
+// yieldNames returns the names of the results produced by a query spec.
+func yieldNames(s *flux.Spec) ([]string, error) {
+	var names []string
+	err := s.Walk(func(o *flux.Operation) error {
+		if spec, ok := o.Spec.(*universe.YieldOpSpec); ok {
+			names = append(names, spec.Name)
+		} else if len(s.Children(o.ID)) == 0 {
+			names = append(names, plan.DefaultYieldName)
+		}
+		return nil
+	})
+	return names, err
+}
+
+// checkYields verifies that the yields in specs have distinct names.
+// The specs are the queries run for a single input. Every explicit
+// yield name must be unique across them, and must also differ from the
+// implicit "_result" of any stream in its own spec that is not yielded.
+// When disambiguate is set, duplicate yields are renamed instead.
+func checkYields(specs []*flux.Spec, disambiguate bool) error {
+	used := make(map[string]bool)
+	for _, s := range specs {
+		implicit := false
+		var yields []*universe.YieldOpSpec
+		if err := s.Walk(func(o *flux.Operation) error {
+			if spec, ok := o.Spec.(*universe.YieldOpSpec); ok {
+				yields = append(yields, spec)
+			} else if len(s.Children(o.ID)) == 0 {
+				implicit = true
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		taken := func(name string) bool {
+			return used[name] || implicit && name == plan.DefaultYieldName
+		}
+		for _, spec := range yields {
+			if !taken(spec.Name) {
+				used[spec.Name] = true
+				continue
+			}
+			if !disambiguate {
+				return errors.Newf(codes.Invalid, "found more than one call to yield() with the name %q", spec.Name)
+			}
+			name := spec.Name
+			for i := 1; taken(name); i++ {
+				name = fmt.Sprintf("%s_%d", spec.Name, i)
+			}
+			spec.Name = name
+			used[name] = true
+		}
+	}
+	return nil
+}
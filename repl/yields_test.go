@@ -0,0 +1,128 @@
+package repl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/stdlib/universe"
+)
+
+func TestYieldNames(t *testing.T) {
+	s := &flux.Spec{
+		Operations: []*flux.Operation{
+			{ID: "test0", Spec: testOpSpec{}},
+			{ID: "yield1", Spec: &universe.YieldOpSpec{Name: "a"}},
+			{ID: "test2", Spec: testOpSpec{}},
+		},
+		Edges: []flux.Edge{
+			{Parent: "test0", Child: "yield1"},
+			{Parent: "yield1", Child: "test2"},
+		},
+	}
+
+	got, err := yieldNames(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "_result"}; !cmp.Equal(want, got) {
+		t.Fatalf("unexpected yield names -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func duplicateYieldSpec() *flux.Spec {
+	return &flux.Spec{
+		Operations: []*flux.Operation{
+			{ID: "test0", Spec: testOpSpec{}},
+			{ID: "yield1", Spec: &universe.YieldOpSpec{Name: "x"}},
+			{ID: "yield2", Spec: &universe.YieldOpSpec{Name: "x"}},
+			{ID: "yield3", Spec: &universe.YieldOpSpec{Name: "_result"}},
+			{ID: "test4", Spec: testOpSpec{}},
+		},
+		Edges: []flux.Edge{
+			{Parent: "test0", Child: "yield1"},
+			{Parent: "test0", Child: "yield2"},
+			{Parent: "test0", Child: "yield3"},
+			{Parent: "yield3", Child: "test4"},
+		},
+	}
+}
+
+func TestCheckYields(t *testing.T) {
+	err := checkYields([]*flux.Spec{duplicateYieldSpec()}, false)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if got, want := errors.Code(err), codes.Invalid; got != want {
+		t.Fatalf("unexpected error code: got %v, want %v", got, want)
+	}
+}
+
+func TestCheckYields_Disambiguate(t *testing.T) {
+	s := duplicateYieldSpec()
+	if err := checkYields([]*flux.Spec{s}, true); err != nil {
+		t.Fatal(err)
+	}
+	got, err := yieldNames(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"_result_1", "_result", "x", "x_1"}; !cmp.Equal(want, got) {
+		t.Fatalf("unexpected yield names -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestCheckYields_AcrossSpecs(t *testing.T) {
+	yieldSpec := func(name string) *flux.Spec {
+		return &flux.Spec{
+			Operations: []*flux.Operation{
+				{ID: "test0", Spec: testOpSpec{}},
+				{ID: "yield1", Spec: &universe.YieldOpSpec{Name: name}},
+			},
+			Edges: []flux.Edge{
+				{Parent: "test0", Child: "yield1"},
+			},
+		}
+	}
+	implicitSpec := func() *flux.Spec {
+		return &flux.Spec{
+			Operations: []*flux.Operation{
+				{ID: "test0", Spec: testOpSpec{}},
+			},
+		}
+	}
+
+	// Each statement that is not yielded produces its own "_result".
+	implicit := []*flux.Spec{implicitSpec(), implicitSpec(), yieldSpec("_result")}
+	if err := checkYields(implicit, false); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	names, err := specsYieldNames(implicit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"_result", "_result", "_result"}; !cmp.Equal(want, names) {
+		t.Fatalf("unexpected yield names -want/+got:\n%s", cmp.Diff(want, names))
+	}
+
+	specs := []*flux.Spec{yieldSpec("x"), yieldSpec("x")}
+	if err := checkYields(specs, false); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := checkYields(specs, true); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range specs {
+		names, err := yieldNames(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, names...)
+	}
+	if want := []string{"x", "x_1"}; !cmp.Equal(want, got) {
+		t.Fatalf("unexpected yield names -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
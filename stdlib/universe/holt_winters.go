@@ -2,6 +2,7 @@ package universe
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/influxdata/flux"
 	"github.com/influxdata/flux/array"
@@ -9,6 +10,7 @@ import (
 	"github.com/influxdata/flux/codes"
 	"github.com/influxdata/flux/execute"
 	"github.com/influxdata/flux/internal/errors"
+	"github.com/influxdata/flux/internal/feature"
 	"github.com/influxdata/flux/memory"
 	"github.com/influxdata/flux/plan"
 	"github.com/influxdata/flux/runtime"
@@ -95,6 +97,10 @@ type HoltWintersProcedureSpec struct {
 	N          int64
 	S          int64
 	Interval   flux.Duration
+	// Concurrency is the number of groups that are forecast at once.
+	// If it is zero, the holtWintersConcurrency feature flag is used.
+	// Each group is forecast as it is processed when it is one or less.
+	Concurrency int
 }
 
 func newHoltWintersProcedure(qs flux.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
@@ -131,6 +137,11 @@ func createHoltWintersTransformation(id execute.DatasetID, mode execute.Accumula
 	if !ok {
 		return nil, nil, errors.Newf(codes.Internal, "invalid spec type %T", spec)
 	}
+	if s.Concurrency == 0 {
+		ns := *s
+		ns.Concurrency = feature.HoltWintersConcurrency().Int(a.Context())
+		s = &ns
+	}
 	cache := execute.NewTableBuilderCache(a.Allocator())
 	d := execute.NewDataset(id, mode, cache)
 	t := NewHoltWintersTransformation(d, cache, a.Allocator(), s)
@@ -149,10 +160,18 @@ type holtWintersTransformation struct {
 	n          int64
 	s          int64
 	interval   values.Duration
+
+	// sem bounds the number of groups that are forecast at once.
+	// It is nil when each group is forecast as it is processed.
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
 }
 
 func NewHoltWintersTransformation(d execute.Dataset, cache execute.TableBuilderCache, alloc memory.Allocator, spec *HoltWintersProcedureSpec) *holtWintersTransformation {
-	return &holtWintersTransformation{
+	hwt := &holtWintersTransformation{
 		d:          d,
 		cache:      cache,
 		alloc:      alloc,
@@ -163,6 +182,10 @@ func NewHoltWintersTransformation(d execute.Dataset, cache execute.TableBuilderC
 		s:          spec.S,
 		interval:   values.Duration(spec.Interval),
 	}
+	if spec.Concurrency > 1 {
+		hwt.sem = make(chan struct{}, spec.Concurrency)
+	}
+	return hwt
 }
 
 func (hwt *holtWintersTransformation) Process(id execute.DatasetID, tbl flux.Table) error {
@@ -212,15 +235,55 @@ func (hwt *holtWintersTransformation) Process(id execute.DatasetID, tbl flux.Tab
 		return err
 	}
 
+	if hwt.sem == nil {
+		return hwt.forecast(builder, tbl.Key(), vs, start, stop, newTimeIdx, newValueIdx, hwt.alloc)
+	}
+
+	// The fit of a group does not depend on any other group, so it is
+	// done in the background once a slot is free. The table builder is
+	// only written by the goroutine of its own group and is not read
+	// before wait returns.
+	hwt.sem <- struct{}{}
+	if err := hwt.firstErr(); err != nil {
+		<-hwt.sem
+		vs.Release()
+		return err
+	}
+	hwt.wg.Add(1)
+	go func(key flux.GroupKey) {
+		defer hwt.wg.Done()
+		defer func() { <-hwt.sem }()
+		defer func() {
+			if e := recover(); e != nil {
+				vs.Release()
+				hwt.setErr(panicError(e))
+			}
+		}()
+		// Each group has an allocator of its own that accounts
+		// its memory to the allocator of the transformation.
+		alloc := memory.NewResourceAllocator(hwt.alloc)
+		if err := hwt.forecast(builder, key, vs, start, stop, newTimeIdx, newValueIdx, alloc); err != nil {
+			hwt.setErr(err)
+		}
+	}(tbl.Key())
+	return nil
+}
+
+// forecast runs Holt-Winters on the cleaned values vs of the group
+// with key and appends the result to builder. It releases vs.
+func (hwt *holtWintersTransformation) forecast(builder execute.TableBuilder, key flux.GroupKey, vs *array.Float, start, stop values.Time, timeIdx, valueIdx int, alloc memory.Allocator) error {
 	// Holt Winters.
-	hw := holt_winters.New(int(hwt.n), int(hwt.s), hwt.withFit, fluxarrow.NewAllocator(hwt.alloc))
-	newVs := hw.Do(vs)
+	hw := holt_winters.New(int(hwt.n), int(hwt.s), hwt.withFit, fluxarrow.NewAllocator(alloc))
+	newVs, err := hw.Do(vs)
 	// don't need vs anymore
 	vs.Release()
+	if err != nil {
+		return err
+	}
 
 	// Crafting timestamps.
 	// Timestamps are deduced by summing the interval to the first/last valid timestamp.
-	tsb := array.NewIntBuilder(fluxarrow.NewAllocator(hwt.alloc))
+	tsb := array.NewIntBuilder(fluxarrow.NewAllocator(alloc))
 	s := stop.Add(hwt.interval)
 	if hwt.withFit {
 		s = start
@@ -236,18 +299,52 @@ func (hwt *holtWintersTransformation) Process(id execute.DatasetID, tbl flux.Tab
 	}()
 
 	// Appending columns.
-	if err := builder.AppendTimes(newTimeIdx, newTs); err != nil {
+	if err := builder.AppendTimes(timeIdx, newTs); err != nil {
 		return err
 	}
-	if err := builder.AppendFloats(newValueIdx, newVs); err != nil {
+	if err := builder.AppendFloats(valueIdx, newVs); err != nil {
 		return err
 	}
-	if err := execute.AppendKeyValuesN(tbl.Key(), builder, newVs.Len()); err != nil {
+	if err := execute.AppendKeyValuesN(key, builder, newVs.Len()); err != nil {
 		return err
 	}
 	return nil
 }
 
+// panicError returns the error for a panic recovered while forecasting
+// a group in the background, keeping the code of an exceeded memory limit.
+func panicError(e interface{}) error {
+	err, ok := e.(error)
+	if !ok {
+		err = fmt.Errorf("%v", e)
+	}
+	if errors.Code(err) == codes.ResourceExhausted {
+		return err
+	}
+	return errors.Wrap(err, codes.Internal, "holtWinters panic")
+}
+
+func (hwt *holtWintersTransformation) setErr(err error) {
+	hwt.mu.Lock()
+	defer hwt.mu.Unlock()
+	if hwt.err == nil {
+		hwt.err = err
+	}
+}
+
+func (hwt *holtWintersTransformation) firstErr() error {
+	hwt.mu.Lock()
+	defer hwt.mu.Unlock()
+	return hwt.err
+}
+
+// wait waits for the groups that are being forecast in the background
+// and returns the first error that any of them failed with.
+func (hwt *holtWintersTransformation) wait() error {
+	hwt.wg.Wait()
+	return hwt.firstErr()
+}
+
 // getCleanData returns cleaned data (using the value and time column), and the first and last valid timestamps.
 // Below are the cleaning criteria.
 // Rows that have a null timestamp get discarded.
@@ -354,16 +451,31 @@ func (hwt *holtWintersTransformation) getCleanData(tbl flux.Table, colIdx, timeI
 	return vs.NewFloatArray(), values.Time(start), values.Time(stop), nil
 }
 
+// The methods below may emit or discard the tables that are being
+// built, so they first wait for the groups forecast in the background.
+
 func (hwt *holtWintersTransformation) RetractTable(id execute.DatasetID, key flux.GroupKey) error {
+	if err := hwt.wait(); err != nil {
+		return err
+	}
 	return hwt.d.RetractTable(key)
 }
 
 func (hwt *holtWintersTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	if err := hwt.wait(); err != nil {
+		return err
+	}
 	return hwt.d.UpdateWatermark(mark)
 }
 func (hwt *holtWintersTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	if err := hwt.wait(); err != nil {
+		return err
+	}
 	return hwt.d.UpdateProcessingTime(pt)
 }
 func (hwt *holtWintersTransformation) Finish(id execute.DatasetID, err error) {
+	if werr := hwt.wait(); err == nil {
+		err = werr
+	}
 	hwt.d.Finish(err)
 }
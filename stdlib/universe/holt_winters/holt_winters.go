@@ -6,6 +6,8 @@ import (
 	"github.com/apache/arrow/go/v7/arrow/memory"
 	"github.com/influxdata/flux/array"
 	"github.com/influxdata/flux/arrow"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
 	"github.com/influxdata/flux/internal/mutable"
 )
 
@@ -22,6 +24,14 @@ type HoltWinters struct {
 	optim *Optimizer
 	// Small difference bound for the optimizer
 	epsilon float64
+	// Caller supplied starting parameters used in place of the grid search
+	initial []float64
+	// Parameters found by the last call to Do
+	fitted []float64
+	// How the SSE accounts for forecast values that are NaN
+	nanPenalty NaNPenalty
+	// The largest number of points that may be forecast, if positive
+	maxHorizon int
 
 	vs    *array.Float
 	alloc memory.Allocator
@@ -41,6 +51,37 @@ const (
 	hwGuessUpper = 1.0
 	// The step between guesses
 	hwGuessStep = 0.4
+	// The weight of a NaN forecast value under NaNPenaltyScaled
+	hwNaNPenaltyWeight = 1.0e6
+)
+
+// DefaultMaxHorizon is the largest number of points that HoltWinters
+// forecasts unless WithMaxHorizon sets another limit.
+const DefaultMaxHorizon = 1 << 24
+
+// NaNPenalty chooses how the sum of squared errors minimized by the fit
+// accounts for the points where a candidate set of parameters forecasts NaN,
+// such as when a seasonal factor reaches zero.
+type NaNPenalty int
+
+const (
+	// NaNPenaltyInfinite gives parameters that forecast any NaN an infinite
+	// error, so that they are never chosen. The optimizer cannot tell such
+	// parameters apart however many points are NaN, so a simplex that falls
+	// in a region of NaNs can stop there instead of finding its way out.
+	// This is the default.
+	NaNPenaltyInfinite NaNPenalty = iota
+	// NaNPenaltyScaled adds a large but finite error for each NaN point,
+	// proportional to the square of the value that was expected there.
+	// Parameters with fewer NaN points have a lower error, which lets the
+	// optimizer move towards the parameters that forecast none.
+	NaNPenaltyScaled
+	// NaNPenaltySkip leaves the NaN points out of the error like missing
+	// values. The error is smooth, but parameters that forecast NaN for
+	// most points may fit the few that remain best and be chosen, and the
+	// chosen parameters may then forecast NaN beyond the data.
+	// Parameters that forecast NaN for every point are never chosen.
+	NaNPenaltySkip
 )
 
 // New creates a new HoltWinters.
@@ -55,13 +96,139 @@ func New(n, s int, withFit bool, alloc memory.Allocator) *HoltWinters {
 		includeFitData: withFit,
 		optim:          NewOptimizer(alloc),
 		epsilon:        hwDefaultEpsilon,
+		maxHorizon:     DefaultMaxHorizon,
 		alloc:          alloc,
 	}
 }
 
+// WithInitialParams seeds the optimizer with params instead of searching
+// the grid of initial guesses. This is useful to warm-start a refit from
+// the parameters of a previous fit, as returned by Params.
+//
+// The parameters are, in order: alpha, beta, gamma, phi, the initial level,
+// the initial trend and, for a seasonal model, one initial value per season.
+// If params does not match this layout, or holds a non-finite value,
+// it is ignored and the grid search is used.
+func (r *HoltWinters) WithInitialParams(params []float64) *HoltWinters {
+	r.initial = append([]float64(nil), params...)
+	return r
+}
+
+// WithNaNPenalty sets how the fit accounts for forecast values that are
+// NaN. The default is NaNPenaltyInfinite.
+func (r *HoltWinters) WithNaNPenalty(p NaNPenalty) *HoltWinters {
+	r.nanPenalty = p
+	return r
+}
+
+// WithMaxHorizon sets the largest number of points that may be forecast.
+// Do fails instead of reserving room for a larger forecast.
+// A limit of zero or less removes it. The default is DefaultMaxHorizon.
+func (r *HoltWinters) WithMaxHorizon(n int) *HoltWinters {
+	r.maxHorizon = n
+	return r
+}
+
+// Params returns the parameters found by the last call to Do
+// in the layout accepted by WithInitialParams.
+// It returns nil if Do has not fitted the data.
+func (r *HoltWinters) Params() []float64 {
+	return append([]float64(nil), r.fitted...)
+}
+
+// validInitialParams reports whether the caller supplied
+// parameters can be used for a model with the given number of parameters.
+func (r *HoltWinters) validInitialParams(size int) bool {
+	if len(r.initial) != size {
+		return false
+	}
+	for _, v := range r.initial {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
 // Do returns the points generated by the HoltWinters algorithm given a dataset.
-func (r *HoltWinters) Do(vs *array.Float) *array.Float {
+//
+// An invalid error is returned if the forecast would exceed the maximum
+// horizon, and a resource exhausted error if the allocator runs out of
+// memory for the forecast, rather than panicking.
+func (r *HoltWinters) Do(vs *array.Float) (fcast *array.Float, err error) {
+	if r.maxHorizon > 0 && r.n > r.maxHorizon {
+		return nil, errors.Newf(codes.Invalid, "cannot forecast %d points: the maximum is %d", r.n, r.maxHorizon)
+	}
+	defer recoverLimit(&err)
+	return r.do(vs), nil
+}
+
+// DoHorizons is like Do, but returns one forecast for each of the
+// horizons, in the same order, from a single fit of the data. The
+// series is forecast once to the largest of the horizons, and the
+// forecast of each horizon is the first of its points, so that each
+// forecast is a prefix of the longest one. When the fit data is
+// included, every forecast starts with all of it.
+//
+// The horizons must be positive, and the largest of them is limited
+// as the number of points of Do is. The horizon given to New is not
+// used. It fails like Do otherwise.
+func (r *HoltWinters) DoHorizons(vs *array.Float, horizons []int) ([]*array.Float, error) {
+	longest := 0
+	for _, h := range horizons {
+		if h <= 0 {
+			return nil, errors.Newf(codes.Invalid, "cannot forecast a horizon of %d points", h)
+		}
+		if h > longest {
+			longest = h
+		}
+	}
+	if longest == 0 {
+		return nil, nil
+	}
+
+	n := r.n
+	r.n = longest
+	defer func() { r.n = n }()
+	fcast, err := r.Do(vs)
+	if err != nil {
+		return nil, err
+	}
+	defer fcast.Release()
+
+	fit := 0
+	if r.includeFitData {
+		fit = vs.Len()
+	}
+	fcasts := make([]*array.Float, len(horizons))
+	for i, h := range horizons {
+		// The forecast is empty when the data cannot be fitted.
+		end := fit + h
+		if end > fcast.Len() {
+			end = fcast.Len()
+		}
+		fcasts[i] = array.Slice(fcast, 0, end).(*array.Float)
+	}
+	return fcasts, nil
+}
+
+// recoverLimit recovers from a panic of the allocator because its
+// limit was reached and sets *err to its error. Other panics carry on.
+func recoverLimit(err *error) {
+	e := recover()
+	if e == nil {
+		return
+	}
+	if perr, ok := e.(error); ok && errors.Code(perr) == codes.ResourceExhausted {
+		*err = perr
+		return
+	}
+	panic(e)
+}
+
+func (r *HoltWinters) do(vs *array.Float) *array.Float {
 	r.vs = vs
+	r.fitted = nil
 	l := vs.Len() // l is the length of both times and values
 	if l < 2 || r.seasonal && l < r.s || r.n <= 0 {
 		return arrow.NewFloat(nil, nil)
@@ -120,6 +287,115 @@ func (r *HoltWinters) Do(vs *array.Float) *array.Float {
 	}
 
 	// Determine best fit for the various parameters
+	var bestParams *mutable.Float64Array
+	if r.validInitialParams(size) {
+		for i, v := range r.initial {
+			initParams.Set(i, v)
+		}
+		_, bestParams = r.optim.Optimize(r.sse, initParams, r.epsilon, 1)
+	} else {
+		bestParams = r.gridSearch(initParams)
+	}
+	r.constrain(bestParams)
+	r.fitted = make([]float64, bestParams.Len())
+	for i := range r.fitted {
+		r.fitted[i] = bestParams.Value(i)
+	}
+
+	// Final forecast
+	fcast := func() *mutable.Float64Array {
+		fcast := r.forecast(bestParams, false)
+		// Now that bestParams have been used to generate the final forecast, they can be released.
+		defer bestParams.Release()
+		return fcast
+	}()
+	return fcast.NewFloat64Array()
+}
+
+// DoWithIntervals is like Do, but also returns the lower and upper bounds
+// of a prediction interval around each point of the forecast.
+// The bounds are z standard errors away from the forecast, so a z of 1.96
+// gives an approximate 95% interval.
+//
+// The standard error is estimated from the residuals of the fit and grows
+// with the horizon, since the errors of every step accumulate into the next.
+// The variance at step h is that of the damped trend method,
+// σ²(1 + Σ c_j²) for j in [1, h), with c_j = α(1 + β(φ + ... + φ^j)).
+// Fit data points, when included, use the one step standard error.
+//
+// It fails like Do.
+func (r *HoltWinters) DoWithIntervals(vs *array.Float, z float64) (fcast, lower, upper *array.Float, err error) {
+	if fcast, err = r.Do(vs); err != nil {
+		return nil, nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			fcast.Release()
+			fcast = nil
+		}
+	}()
+	defer recoverLimit(&err)
+	widths := r.intervalWidths(fcast.Len(), z)
+	lvs := mutable.NewFloat64Array(r.alloc)
+	defer lvs.Release()
+	lvs.Reserve(len(widths))
+	uvs := mutable.NewFloat64Array(r.alloc)
+	defer uvs.Release()
+	uvs.Reserve(len(widths))
+	for i, w := range widths {
+		lvs.Append(fcast.Value(i) - w)
+		uvs.Append(fcast.Value(i) + w)
+	}
+	return fcast, lvs.NewFloat64Array(), uvs.NewFloat64Array(), nil
+}
+
+// intervalWidths returns the half width of the prediction interval
+// for each of the size points returned by the last call to Do.
+func (r *HoltWinters) intervalWidths(size int, z float64) []float64 {
+	if r.fitted == nil || size == 0 {
+		return nil
+	}
+	sigma := math.Sqrt(r.residualVariance())
+
+	widths := make([]float64, size)
+	fit := size - r.n
+	for i := 0; i < fit; i++ {
+		widths[i] = z * sigma
+	}
+
+	alpha, beta, phi := r.fitted[0], r.fitted[1], r.fitted[3]
+	variance, phiJ, phiSum := 1.0, 1.0, 0.0
+	for h := 0; h < r.n; h++ {
+		widths[fit+h] = z * sigma * math.Sqrt(variance)
+		phiJ *= phi
+		phiSum += phiJ
+		c := alpha * (1 + beta*phiSum)
+		variance += c * c
+	}
+	return widths
+}
+
+// residualVariance returns the mean squared error of the fitted
+// parameters over the valid values of the dataset.
+func (r *HoltWinters) residualVariance() float64 {
+	// The forecast updates the seasonal values of the parameters,
+	// so work on a copy of the fitted ones.
+	params := mutable.NewFloat64Array(r.alloc)
+	defer params.Release()
+	params.AppendValues(r.fitted)
+
+	valid := r.vs.Len() - r.vs.NullN()
+	if valid == 0 {
+		return 0
+	}
+	return r.sse(params) / float64(valid)
+}
+
+// gridSearch optimizes the parameters starting from each guess
+// in the grid of initial values for alpha, beta, gamma, and phi,
+// and returns the best parameters found.
+// The caller is responsible for releasing the returned parameters.
+func (r *HoltWinters) gridSearch(initParams *mutable.Float64Array) *mutable.Float64Array {
 	minSSE := math.Inf(1)
 	var bestParams *mutable.Float64Array
 	for alpha := hwGuessLower; alpha < hwGuessUpper; alpha += hwGuessStep {
@@ -148,15 +424,7 @@ func (r *HoltWinters) Do(vs *array.Float) *array.Float {
 			}
 		}
 	}
-
-	// Final forecast
-	fcast := func() *mutable.Float64Array {
-		fcast := r.forecast(bestParams, false)
-		// Now that bestParams have been used to generate the final forecast, they can be released.
-		defer bestParams.Release()
-		return fcast
-	}()
-	return fcast.NewFloat64Array()
+	return bestParams
 }
 
 // Using the recursive relations compute the next values
@@ -252,6 +520,7 @@ func (r *HoltWinters) forecast(params *mutable.Float64Array, onlyFit bool) *muta
 // Compute sum squared error for the given parameters.
 func (r *HoltWinters) sse(params *mutable.Float64Array) float64 {
 	sse := 0.0
+	compared, skipped := 0, 0
 	fcast := r.forecast(params, true)
 	// These forecast values are used only to compute the sum of squares.
 	// They can be released at the end of this function.
@@ -262,12 +531,26 @@ func (r *HoltWinters) sse(params *mutable.Float64Array) float64 {
 			// Compute error
 			if math.IsNaN(fcast.Value(i)) {
 				// Penalize fcast NaNs
-				return math.Inf(1)
+				switch r.nanPenalty {
+				case NaNPenaltyScaled:
+					v := r.vs.Value(i)
+					sse += hwNaNPenaltyWeight * (1 + v*v)
+					compared++
+				case NaNPenaltySkip:
+					skipped++
+				default:
+					return math.Inf(1)
+				}
+				continue
 			}
 			diff := fcast.Value(i) - r.vs.Value(i)
 			sse += diff * diff
+			compared++
 		}
 	}
+	if compared == 0 && skipped > 0 {
+		return math.Inf(1)
+	}
 	return sse
 }
 
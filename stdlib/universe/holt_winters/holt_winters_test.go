@@ -0,0 +1,312 @@
+package holt_winters_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/influxdata/flux/array"
+	"github.com/influxdata/flux/arrow"
+	"github.com/influxdata/flux/codes"
+	"github.com/influxdata/flux/internal/errors"
+	fluxmemory "github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/stdlib/universe/holt_winters"
+)
+
+var seasonalData = []float64{
+	10, 12, 14, 11,
+	11, 13, 15, 12,
+	12, 14, 16, 13,
+	13, 15, 17, 14,
+}
+
+func values(a *array.Float) []float64 {
+	vs := make([]float64, a.Len())
+	for i := range vs {
+		vs[i] = a.Value(i)
+	}
+	return vs
+}
+
+func forecast(t *testing.T, hw *holt_winters.HoltWinters) []float64 {
+	t.Helper()
+	vs := arrow.NewFloat(seasonalData, fluxmemory.DefaultAllocator)
+	defer vs.Release()
+	fcast, err := hw.Do(vs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fcast.Release()
+	return values(fcast)
+}
+
+func TestHoltWinters_WithInitialParams(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer mem.AssertSize(t, 0)
+
+	hw := holt_winters.New(4, 4, false, mem)
+	if params := hw.Params(); params != nil {
+		t.Fatalf("expected no parameters before fitting, got %v", params)
+	}
+	want := forecast(t, hw)
+	params := hw.Params()
+	if got, want := len(params), 6+4; got != want {
+		t.Fatalf("unexpected number of parameters: got %d, want %d", got, want)
+	}
+
+	warm := holt_winters.New(4, 4, false, mem).WithInitialParams(params)
+	got := forecast(t, warm)
+	if len(got) != len(want) {
+		t.Fatalf("unexpected forecast length: got %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-2*math.Abs(want[i]) {
+			t.Errorf("unexpected warm-started forecast at %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestHoltWinters_WithInitialParams_Invalid(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer mem.AssertSize(t, 0)
+
+	want := forecast(t, holt_winters.New(4, 4, false, mem))
+	for name, params := range map[string][]float64{
+		"too short":  {0.5, 0.5, 0.5, 0.5, 10, 1},
+		"too long":   {0.5, 0.5, 0.5, 0.5, 10, 1, 1, 1, 1, 1, 1},
+		"not finite": {0.5, 0.5, 0.5, math.NaN(), 10, 1, 1, 1, 1, 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			hw := holt_winters.New(4, 4, false, mem).WithInitialParams(params)
+			got := forecast(t, hw)
+			if len(got) != len(want) {
+				t.Fatalf("unexpected forecast length: got %d, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("expected grid search forecast at %d: got %v, want %v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHoltWinters_DoWithIntervals(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer mem.AssertSize(t, 0)
+
+	// Add some deterministic noise to the seasonal data
+	// so the fit leaves residuals to estimate the error from.
+	noise := []float64{0.8, -0.5, 0.3, -0.9, 0.6, -0.2, 0.7, -0.4}
+	data := make([]float64, 0, 2*len(seasonalData))
+	for i := 0; i < 2; i++ {
+		for j, v := range seasonalData {
+			data = append(data, v+float64(4*i)+noise[(i+j)%len(noise)])
+		}
+	}
+	vs := arrow.NewFloat(data, fluxmemory.DefaultAllocator)
+	defer vs.Release()
+
+	const n = 8
+	hw := holt_winters.New(n, 4, false, mem)
+	fcast, lower, upper, err := hw.DoWithIntervals(vs, 1.96)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fcast.Release()
+	defer lower.Release()
+	defer upper.Release()
+
+	if fcast.Len() != n || lower.Len() != n || upper.Len() != n {
+		t.Fatalf("unexpected lengths: forecast %d, lower %d, upper %d", fcast.Len(), lower.Len(), upper.Len())
+	}
+	for i := 0; i < n; i++ {
+		if !(lower.Value(i) < fcast.Value(i) && fcast.Value(i) < upper.Value(i)) {
+			t.Errorf("forecast %v at %d is not within its interval [%v, %v]", fcast.Value(i), i, lower.Value(i), upper.Value(i))
+		}
+	}
+	first := upper.Value(0) - lower.Value(0)
+	last := upper.Value(n-1) - lower.Value(n-1)
+	if !(last > first) {
+		t.Errorf("expected the interval to widen with the horizon: width at step 1 %v, at step %d %v", first, n, last)
+	}
+}
+
+func TestHoltWinters_DoWithIntervals_WithFit(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer mem.AssertSize(t, 0)
+
+	vs := arrow.NewFloat(seasonalData, fluxmemory.DefaultAllocator)
+	defer vs.Release()
+
+	hw := holt_winters.New(4, 4, true, mem)
+	fcast, lower, upper, err := hw.DoWithIntervals(vs, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fcast.Release()
+	defer lower.Release()
+	defer upper.Release()
+
+	if got, want := fcast.Len(), len(seasonalData)+4; got != want {
+		t.Fatalf("unexpected forecast length: got %d, want %d", got, want)
+	}
+	if lower.Len() != fcast.Len() || upper.Len() != fcast.Len() {
+		t.Fatalf("interval lengths %d and %d do not match the forecast length %d", lower.Len(), upper.Len(), fcast.Len())
+	}
+	// Fit data points all use the one step error.
+	width := upper.Value(0) - lower.Value(0)
+	for i := 1; i < len(seasonalData); i++ {
+		if got := upper.Value(i) - lower.Value(i); math.Abs(got-width) > 1e-9 {
+			t.Errorf("unexpected interval width for fit point %d: got %v, want %v", i, got, width)
+		}
+	}
+}
+
+func TestHoltWinters_WithNaNPenalty(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer mem.AssertSize(t, 0)
+
+	// The zeros that start each season make the seasonal factors zero,
+	// so that many candidate parameters forecast 0/0 along the way.
+	data := []float64{
+		0, 5, 10, 5,
+		0, 6, 11, 6,
+		0, 7, 12, 7,
+		0, 8, 13, 8,
+	}
+	fit := func(hw *holt_winters.HoltWinters) []float64 {
+		vs := arrow.NewFloat(data, fluxmemory.DefaultAllocator)
+		defer vs.Release()
+		fcast, err := hw.Do(vs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fcast.Release()
+		return values(fcast)
+	}
+
+	want := fit(holt_winters.New(4, 4, false, mem))
+	infinite := fit(holt_winters.New(4, 4, false, mem).WithNaNPenalty(holt_winters.NaNPenaltyInfinite))
+	for i := range want {
+		if infinite[i] != want[i] {
+			t.Fatalf("expected the infinite penalty to be the default at %d: got %v, want %v", i, infinite[i], want[i])
+		}
+	}
+
+	scaled := fit(holt_winters.New(4, 4, false, mem).WithNaNPenalty(holt_winters.NaNPenaltyScaled))
+	if len(scaled) != len(want) {
+		t.Fatalf("unexpected forecast length: got %d, want %d", len(scaled), len(want))
+	}
+	changed := false
+	for i := range scaled {
+		if math.IsNaN(scaled[i]) || math.IsInf(scaled[i], 0) {
+			t.Fatalf("expected a finite forecast at %d, got %v", i, scaled[i])
+		}
+		if scaled[i] != want[i] {
+			changed = true
+		}
+	}
+	if !changed {
+		t.Fatalf("expected the scaled penalty to change the fit, got %v for both", want)
+	}
+}
+
+func TestHoltWinters_MaxHorizon(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer mem.AssertSize(t, 0)
+
+	vs := arrow.NewFloat(seasonalData, fluxmemory.DefaultAllocator)
+	defer vs.Release()
+
+	_, err := holt_winters.New(1e9, 4, false, mem).Do(vs)
+	if err == nil {
+		t.Fatal("expected an error for a horizon beyond the default maximum")
+	}
+	if got, want := errors.Code(err), codes.Invalid; got != want {
+		t.Fatalf("unexpected error code: got %v, want %v", got, want)
+	}
+	if _, _, _, err := holt_winters.New(8, 4, false, mem).WithMaxHorizon(4).DoWithIntervals(vs, 1); err == nil {
+		t.Fatal("expected an error for a horizon beyond the maximum")
+	}
+
+	fcast, err := holt_winters.New(8, 4, false, mem).WithMaxHorizon(8).Do(vs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fcast.Release()
+	if fcast.Len() != 8 {
+		t.Fatalf("unexpected forecast length: got %d, want 8", fcast.Len())
+	}
+}
+
+func TestHoltWinters_MemoryLimit(t *testing.T) {
+	vs := arrow.NewFloat(seasonalData, fluxmemory.DefaultAllocator)
+	defer vs.Release()
+
+	// The fit itself stays well within the limit,
+	// whereas the forecast needs 8 bytes for each point.
+	limit := int64(1 << 20)
+	mem := &fluxmemory.ResourceAllocator{Limit: &limit}
+	_, err := holt_winters.New(1<<20, 4, false, mem).WithMaxHorizon(0).Do(vs)
+	if err == nil {
+		t.Fatal("expected an error once the allocator reaches its limit")
+	}
+	if got, want := errors.Code(err), codes.ResourceExhausted; got != want {
+		t.Fatalf("unexpected error code: got %v, want %v", got, want)
+	}
+}
+
+func TestHoltWinters_DoHorizons(t *testing.T) {
+	for _, withFit := range []bool{false, true} {
+		mem := memory.NewCheckedAllocator(memory.DefaultAllocator)
+		vs := arrow.NewFloat(seasonalData, fluxmemory.DefaultAllocator)
+
+		horizons := []int{4, 12, 1}
+		fcasts, err := holt_winters.New(0, 4, withFit, mem).DoHorizons(vs, horizons)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fit := 0
+		if withFit {
+			fit = len(seasonalData)
+		}
+		longest := values(fcasts[1])
+		if want := forecast(t, holt_winters.New(12, 4, withFit, mem)); !equalValues(longest, want) {
+			t.Errorf("withFit %v: expected the longest forecast to be that of Do, got %v, want %v", withFit, longest, want)
+		}
+		for i, h := range horizons {
+			got := values(fcasts[i])
+			if len(got) != fit+h {
+				t.Errorf("withFit %v: unexpected length of horizon %d: got %d, want %d", withFit, h, len(got), fit+h)
+				continue
+			}
+			if !equalValues(got, longest[:len(got)]) {
+				t.Errorf("withFit %v: forecast of horizon %d is not a prefix of the longest: %v, %v", withFit, h, got, longest)
+			}
+		}
+		for _, fcast := range fcasts {
+			fcast.Release()
+		}
+
+		if _, err := holt_winters.New(0, 4, withFit, mem).DoHorizons(vs, []int{4, 0}); err == nil {
+			t.Error("expected an error for a horizon of zero points")
+		} else if got, want := errors.Code(err), codes.Invalid; got != want {
+			t.Errorf("unexpected error code: got %v, want %v", got, want)
+		}
+		vs.Release()
+		mem.AssertSize(t, 0)
+	}
+}
+
+func equalValues(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package holt_winters_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/influxdata/flux/internal/mutable"
+	"github.com/influxdata/flux/stdlib/universe/holt_winters"
+)
+
+func TestOptimizer_Optimize(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer mem.AssertSize(t, 0)
+
+	// A paraboloid with its minimum of 1 at (3, -2).
+	objective := func(p *mutable.Float64Array) float64 {
+		x, y := p.Value(0)-3, p.Value(1)+2
+		return x*x + y*y + 1
+	}
+	start := mutable.NewFloat64Array(mem)
+	start.AppendValues([]float64{0, 0})
+	defer start.Release()
+
+	o := holt_winters.NewOptimizer(mem)
+	min, params := o.Optimize(objective, start, 1e-10, 1)
+	defer params.Release()
+
+	if math.Abs(min-1) > 1e-6 {
+		t.Errorf("unexpected minimum: got %v, want 1", min)
+	}
+	for i, want := range []float64{3, -2} {
+		if got := params.Value(i); math.Abs(got-want) > 1e-3 {
+			t.Errorf("unexpected parameter %d: got %v, want %v", i, got, want)
+		}
+	}
+	if start.Value(0) != 0 || start.Value(1) != 0 {
+		t.Error("expected the starting point to be left unchanged")
+	}
+}
+
+func TestOptimizer_MaxIterations(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer mem.AssertSize(t, 0)
+
+	var calls int
+	objective := func(p *mutable.Float64Array) float64 {
+		calls++
+		x := p.Value(0) - 100
+		return x * x
+	}
+	start := mutable.NewFloat64Array(mem)
+	start.AppendValues([]float64{0})
+	defer start.Release()
+
+	o := holt_winters.NewOptimizer(mem)
+	o.MaxIterations = 1
+	_, params := o.Optimize(objective, start, 1e-10, 1)
+	defer params.Release()
+
+	if got := params.Value(0); math.Abs(got-100) < 1 {
+		t.Errorf("expected a single iteration not to reach the minimum, got %v", got)
+	}
+	if calls > 10 {
+		t.Errorf("expected the iteration limit to bound the evaluations, got %d", calls)
+	}
+}
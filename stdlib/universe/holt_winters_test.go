@@ -1,14 +1,19 @@
 package universe_test
 
 import (
+	"context"
+	"fmt"
 	"runtime"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/influxdata/flux"
 	"github.com/influxdata/flux/execute"
 	"github.com/influxdata/flux/execute/executetest"
+	"github.com/influxdata/flux/internal/gen"
 	"github.com/influxdata/flux/memory"
+	"github.com/influxdata/flux/plan"
 	"github.com/influxdata/flux/querytest"
 	"github.com/influxdata/flux/stdlib/influxdata/influxdb"
 	"github.com/influxdata/flux/stdlib/universe"
@@ -898,3 +903,124 @@ func TestHoltWinters_Process(t *testing.T) {
 		})
 	}
 }
+
+func TestHoltWinters_Process_Concurrent(t *testing.T) {
+	// Each group holds the NOAA water data scaled by a different factor,
+	// so that the forecast of every group is different.
+	water := []float64{
+		4.948, 2.192, 3.035, 2.93, 5.121, 1.722, 3.209, 2.877, 5.449, 0.896,
+		3.655, 2.71, 5.961, 0.404, 4.357, 2.618, 6.102, 0.072, 4.816, 2.612,
+	}
+	data := func() []flux.Table {
+		var tables []flux.Table
+		for g := 0; g < 16; g++ {
+			tbl := &executetest.Table{
+				KeyCols: []string{"t0"},
+				ColMeta: []flux.ColMeta{
+					{Label: "t0", Type: flux.TString},
+					{Label: "_value", Type: flux.TFloat},
+					{Label: "_stop", Type: flux.TTime},
+				},
+			}
+			for i, v := range water {
+				ts := execute.Time(1440281520000000000 + int64(i)*int64(379*time.Minute))
+				tbl.Data = append(tbl.Data, []interface{}{fmt.Sprintf("g%02d", g), v * float64(g+1), ts})
+			}
+			tables = append(tables, tbl)
+		}
+		return tables
+	}
+	run := func(concurrency int) []*executetest.Table {
+		t.Helper()
+		alloc := &memory.ResourceAllocator{}
+		d := executetest.NewDataset(executetest.RandomDatasetID())
+		c := execute.NewTableBuilderCache(executetest.UnlimitedAllocator)
+		c.SetTriggerSpec(plan.DefaultTriggerSpec)
+		tx := universe.NewHoltWintersTransformation(d, c, alloc, &universe.HoltWintersProcedureSpec{
+			Column:      "_value",
+			TimeColumn:  "_stop",
+			WithFit:     true,
+			N:           10,
+			S:           4,
+			Interval:    flux.ConvertDuration(379 * time.Minute),
+			Concurrency: concurrency,
+		})
+
+		parentID := executetest.RandomDatasetID()
+		for _, tbl := range data() {
+			if err := tx.Process(parentID, tbl); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tx.Finish(parentID, nil)
+		if d.FinishedErr != nil {
+			t.Fatal(d.FinishedErr)
+		}
+		got, err := executetest.TablesFromCache(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 30; i++ {
+			runtime.GC()
+			if alloc.Allocated() <= 0 {
+				break
+			}
+		}
+		if m := alloc.Allocated(); m != 0 {
+			t.Errorf("HoltWinters is using memory after finishing: %d", m)
+		}
+		return got
+	}
+
+	want := run(1)
+	if len(want) != 16 {
+		t.Fatalf("expected a table for each group, got %d", len(want))
+	}
+	// The tables are compared in the order they are produced.
+	if got := run(4); !cmp.Equal(want, got) {
+		t.Fatalf("unexpected tables -want/+got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func BenchmarkHoltWinters_Groups(b *testing.B) {
+	for _, concurrency := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			benchmarkHoltWinters(b, concurrency)
+		})
+	}
+}
+
+func benchmarkHoltWinters(b *testing.B, concurrency int) {
+	spec := &universe.HoltWintersProcedureSpec{
+		Column:      "_value",
+		TimeColumn:  "_time",
+		WithFit:     true,
+		N:           10,
+		S:           4,
+		Interval:    flux.ConvertDuration(10 * time.Second),
+		Concurrency: concurrency,
+	}
+	executetest.ProcessBenchmarkHelper(b,
+		func(alloc memory.Allocator) (flux.TableIterator, error) {
+			seed := int64(1)
+			schema := gen.Schema{
+				NumPoints: 40,
+				Alloc:     alloc,
+				Tags: []gen.Tag{
+					{Name: "_measurement", Cardinality: 1},
+					{Name: "_field", Cardinality: 1},
+					{Name: "t0", Cardinality: 32},
+				},
+				Seed: &seed,
+			}
+			return gen.Input(context.Background(), schema)
+		},
+		func(id execute.DatasetID, alloc memory.Allocator) (execute.Transformation, execute.Dataset) {
+			cache := execute.NewTableBuilderCache(alloc)
+			d := execute.NewDataset(id, execute.DiscardingMode, cache)
+			t := universe.NewHoltWintersTransformation(d, cache, alloc, spec)
+			return t, d
+		},
+	)
+}
@@ -107,6 +107,9 @@ func (s *SampleProcedureSpec) TriggerSpec() plan.TriggerSpec {
 type SampleSelector struct {
 	N   int
 	Pos int
+	// Rand picks the random positions.
+	// The global source is used when it is nil.
+	Rand *rand.Rand
 
 	offset   int
 	selected []int
@@ -122,6 +125,9 @@ func createSampleTransformation(id execute.DatasetID, mode execute.AccumulationM
 		N:   int(ps.N),
 		Pos: int(ps.Pos),
 	}
+	if execute.HaveExecutionDependencies(a.Context()) {
+		ss.Rand = execute.GetExecutionDependencies(a.Context()).NewRand(id)
+	}
 	t, d := execute.NewIndexSelectorTransformationAndDataset(id, mode, ss, ps.SelectorConfig, a.Allocator())
 	return t, d, nil
 }
@@ -129,7 +135,11 @@ func createSampleTransformation(id execute.DatasetID, mode execute.AccumulationM
 func (s *SampleSelector) reset() {
 	pos := s.Pos
 	if pos < 0 {
-		pos = rand.Intn(s.N)
+		if s.Rand != nil {
+			pos = s.Rand.Intn(s.N)
+		} else {
+			pos = rand.Intn(s.N)
+		}
 	}
 	s.offset = pos
 }